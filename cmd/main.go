@@ -1,13 +1,36 @@
 package main
 
 import (
+	"context"
+	"os"
+
+	"github.com/lucastomic/dmsStorageService/internal/archive"
+	"github.com/lucastomic/dmsStorageService/internal/audit"
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
 	"github.com/lucastomic/dmsStorageService/internal/controller"
+	"github.com/lucastomic/dmsStorageService/internal/dav"
+	"github.com/lucastomic/dmsStorageService/internal/doctypes"
+	"github.com/lucastomic/dmsStorageService/internal/encryption"
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/folders"
 	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/maintenance"
+	"github.com/lucastomic/dmsStorageService/internal/metadata"
 	"github.com/lucastomic/dmsStorageService/internal/middleware"
+	"github.com/lucastomic/dmsStorageService/internal/outbox"
 	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
 	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/pipeline"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/search"
 	"github.com/lucastomic/dmsStorageService/internal/server"
 	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/thumbnail"
+	"github.com/lucastomic/dmsStorageService/internal/webhook"
 )
 
 func main() {
@@ -16,13 +39,209 @@ func main() {
 	dataLogger := logging.NewLogrusLogger()
 	pathRepo := pathrepository.MemoryRepository(dataLogger)
 	pathservice := pathservice.New(logicLogger, pathRepo)
-	storageservice := storageservice.New(logicLogger, pathservice)
-	controller := controller.New(logicLogger, storageservice)
+	quotaTracker := quota.NewMemoryTracker(quota.LimitsFromEnvironment())
+	blobStore := blobstore.NewFileSystem(loadKeyring(logicLogger))
+	blobGuard := blobstore.NewGuard()
+	auditLog, err := audit.OpenFileLog(audit.PathFromEnvironment())
+	if err != nil {
+		logicLogger.Error(context.Background(), "Failed to open audit log: %v", err)
+		os.Exit(1)
+	}
+	dispatcher, err := webhook.NewDispatcher(logicLogger, webhook.ConfigFromEnvironment())
+	if err != nil {
+		logicLogger.Error(context.Background(), "Failed to load webhooks: %v", err)
+		os.Exit(1)
+	}
+	go dispatcher.Run(context.Background())
+	pipeline, err := pipeline.New(logicLogger, pathservice, pipeline.ConfigFromEnvironment())
+	if err != nil {
+		logicLogger.Error(context.Background(), "Failed to load pipeline jobs: %v", err)
+		os.Exit(1)
+	}
+	retentionService := retention.NewAudited(retention.New(logicLogger, pathservice), auditLog, logicLogger)
+	coreStorage := storageservice.New(
+		logicLogger,
+		pathservice,
+		quotaTracker,
+		blobStore,
+		blobGuard,
+		retentionService,
+		loadCompression(logicLogger),
+		loadScanPolicy(logicLogger),
+	)
+	retentionService.UseRecords(coreStorage)
+	docTypes := doctypes.NewAudited(doctypes.New(logicLogger, pathservice), auditLog, logicLogger)
+	storageservice := storageservice.NewAudited(
+		storageservice.NewValidated(coreStorage, docTypes),
+		auditLog,
+		logicLogger,
+	)
+	// The search engine and the thumbnail generator read the documents from the core storage, so their reads
+	// aren't audited.
+	searchEngine := search.NewEngine(logicLogger, pathservice, coreStorage, search.ConfigFromEnvironment())
+	pipeline.Register(searchEngine.Stage())
+	go searchEngine.Reindex(context.Background())
+	thumbnails := thumbnail.NewGenerator(
+		logicLogger,
+		pathservice,
+		coreStorage,
+		blobStore,
+		loadThumbnailConfig(logicLogger),
+	)
+	pipeline.Register(thumbnails.Stage())
+	// The metadata service audits and validates the label changes itself, so it changes them through the core
+	// storage.
+	metadataService := metadata.NewAudited(
+		metadata.New(logicLogger, pathservice, coreStorage, docTypes),
+		auditLog,
+		logicLogger,
+	)
+	folderService := folders.NewAudited(
+		folders.New(logicLogger, pathservice, storageservice),
+		auditLog,
+		logicLogger,
+	)
+	relay := outbox.NewRelay(
+		logicLogger,
+		pathservice,
+		loadEventPublishers(logicLogger, dispatcher, pipeline, searchEngine, thumbnails, folderService),
+		outbox.ConfigFromEnvironment(),
+	)
+	go relay.Run(context.Background())
+	scrubber := maintenance.NewScrubber(
+		logicLogger,
+		pathservice,
+		blobStore,
+		maintenance.ScrubConfigFromEnvironment(),
+	)
+	go scrubber.Run(context.Background())
+	collector := maintenance.NewCollector(
+		logicLogger,
+		pathservice,
+		blobStore,
+		blobGuard,
+		maintenance.GCConfigFromEnvironment(),
+	)
+	go collector.Run(context.Background())
+	trashPurger := maintenance.NewTrashPurger(
+		logicLogger,
+		pathservice,
+		storageservice,
+		maintenance.TrashConfigFromEnvironment(),
+	)
+	go trashPurger.Run(context.Background())
+	go pipeline.Run(context.Background())
+	expiryReaper := maintenance.NewExpiryReaper(
+		logicLogger,
+		pathservice,
+		storageservice,
+		maintenance.ExpiryConfigFromEnvironment(),
+	)
+	go expiryReaper.Run(context.Background())
+	controllers := []controller.Controller{
+		controller.New(logicLogger, storageservice, metadataService),
+		controller.NewBatchController(logicLogger, storageservice),
+		controller.NewTrashController(logicLogger, storageservice),
+		controller.NewLockController(logicLogger, storageservice, environment.GetAdminUsers()),
+		controller.NewCopyController(logicLogger, storageservice),
+		controller.NewVersionController(logicLogger, storageservice),
+		controller.NewRetentionController(logicLogger, retentionService),
+		controller.NewMetadataController(logicLogger, metadataService),
+		controller.NewDocTypeController(logicLogger, docTypes),
+		controller.NewQuotaController(logicLogger, quotaTracker),
+		controller.NewAuditController(logicLogger, auditLog),
+		controller.NewMaintenanceController(logicLogger, scrubber, collector),
+		controller.NewWebhookController(logicLogger, dispatcher),
+		controller.NewProcessingController(logicLogger, pipeline),
+		controller.NewSearchController(logicLogger, searchEngine),
+		controller.NewThumbnailController(logicLogger, thumbnails),
+		controller.NewArchiveController(logicLogger, archive.New(logicLogger, storageservice)),
+		controller.NewFolderController(logicLogger, folderService, storageservice),
+		controller.NewDAVController(
+			logicLogger,
+			dav.NewServer(logicLogger, controller.DAVPrefix, folderService, pathservice, storageservice),
+		),
+	}
 	middlewares := []middleware.Middleware{
 		middleware.NewLoggingMiddleware(apilogger),
 		middleware.NewRequestIDMiddleware(),
+		middleware.NewTenantMiddleware(),
+		middleware.NewUserMiddleware(),
 		middleware.NewPathVarsMiddleware(),
 	}
-	server := server.New(":3003", controller, apilogger, logicLogger, middlewares)
+	server := server.New(":3003", controllers, apilogger, logicLogger, middlewares)
 	server.Run()
 }
+
+// loadKeyring loads the master keys for encryption at rest from the configured keyfile.
+// It returns nil, disabling encryption, if no keyfile is configured, and exits if it can't be loaded.
+func loadKeyring(logger logging.Logger) *encryption.Keyring {
+	keyfile := environment.GetMasterKeyFile()
+	if keyfile == "" {
+		logger.Info(context.Background(), "No master key file configured, files will be stored unencrypted")
+		return nil
+	}
+	keyring, err := encryption.LoadKeyring(keyfile)
+	if err != nil {
+		logger.Error(context.Background(), "Failed to load master keys: %v", err)
+		os.Exit(1)
+	}
+	return keyring
+}
+
+// loadCompression parses the configured compression codec, exiting if it's not a valid one.
+func loadCompression(logger logging.Logger) compression.Codec {
+	codec, err := compression.ParseCodec(environment.GetCompression())
+	if err != nil {
+		logger.Error(context.Background(), "Invalid compression configuration: %v", err)
+		os.Exit(1)
+	}
+	return codec
+}
+
+// loadScanPolicy reads the policy uploads are scanned for malware with, exiting if it's not a valid one.
+func loadScanPolicy(logger logging.Logger) scanning.Policy {
+	policy, err := scanning.PolicyFromEnvironment()
+	if err != nil {
+		logger.Error(context.Background(), "Invalid scanner configuration: %v", err)
+		os.Exit(1)
+	}
+	if !policy.Enabled() {
+		logger.Info(context.Background(), "No scanner configured, uploads won't be scanned for malware")
+	}
+	return policy
+}
+
+// loadThumbnailConfig reads the configuration of the thumbnails, exiting if it's not a valid one.
+func loadThumbnailConfig(logger logging.Logger) thumbnail.Config {
+	config, err := thumbnail.ConfigFromEnvironment()
+	if err != nil {
+		logger.Error(context.Background(), "Invalid thumbnail configuration: %v", err)
+		os.Exit(1)
+	}
+	return config
+}
+
+// loadEventPublishers builds the publishers the outbox is relayed through: the webhook dispatcher, the
+// post-processing pipeline, the search engine, the thumbnail generator, the folder tree and, if an event log
+// file is configured, the file too. It exits if the event log file can't be opened.
+func loadEventPublishers(
+	logger logging.Logger,
+	dispatcher *webhook.Dispatcher,
+	pipeline *pipeline.Pipeline,
+	searchEngine *search.Engine,
+	thumbnails *thumbnail.Generator,
+	folders folders.Service,
+) []events.EventPublisher {
+	publishers := []events.EventPublisher{dispatcher, pipeline, searchEngine, thumbnails, folders}
+	path := environment.GetEventLogFile()
+	if path == "" {
+		return publishers
+	}
+	file, err := events.OpenFilePublisher(path)
+	if err != nil {
+		logger.Error(context.Background(), "Failed to open event log: %v", err)
+		os.Exit(1)
+	}
+	return append(publishers, file)
+}
@@ -0,0 +1,75 @@
+// Command rotatekeys rewraps the data keys of every encrypted file with the active master key.
+//
+// To rotate the master key, append a new key to the keyfile, restart the service so new files use it,
+// and run this command. Once it finishes without errors, the old keys can be removed from the keyfile.
+// The content of the files isn't re-encrypted, only their headers are rewritten.
+package main
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/lucastomic/dmsStorageService/internal/encryption"
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+func main() {
+	ctx := context.Background()
+	logger := logging.NewLogrusLogger()
+	keyring, err := encryption.LoadKeyring(environment.GetMasterKeyFile())
+	if err != nil {
+		logger.Error(ctx, "Failed to load master keys: %v", err)
+		os.Exit(1)
+	}
+	roots, err := tenancy.StorageRoots()
+	if err != nil {
+		logger.Error(ctx, "Failed to list storage roots: %v", err)
+		os.Exit(1)
+	}
+
+	var rewrapped, failed int
+	for tenant, root := range roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			changed, err := rewrap(path, keyring)
+			if err != nil {
+				failed++
+				logger.Error(ctx, "Failed to rewrap %s of tenant %s: %v", path, tenant, err)
+			} else if changed {
+				rewrapped++
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error(ctx, "Failed to walk storage root of tenant %s: %v", tenant, err)
+			failed++
+		}
+	}
+	logger.Info(ctx, "Rewrapped %d files, %d failures", rewrapped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// rewrap rewraps the data key of the file at path, if it's encrypted.
+func rewrap(path string, keyring *encryption.Keyring) (bool, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	if !encryption.IsEncrypted(file) {
+		return false, nil
+	}
+	changed, err := encryption.Rewrap(file, keyring)
+	if err != nil {
+		return false, err
+	}
+	return changed, file.Close()
+}
@@ -0,0 +1,37 @@
+// Command verifyaudit verifies the hash chain of the audit log.
+//
+// It reads the audit log configured for the service, or the one given as its only argument, and checks that
+// no entry has been modified, reordered or removed. It prints the number of entries and the hash of the last
+// one, which can be compared with a copy kept elsewhere to detect entries removed from the end of the log.
+// It exits with a non-zero status if the log has been tampered with.
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/lucastomic/dmsStorageService/internal/audit"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+func main() {
+	ctx := context.Background()
+	logger := logging.NewLogrusLogger()
+	path := audit.PathFromEnvironment()
+	if len(os.Args) > 1 {
+		path = os.Args[1]
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Error(ctx, "Failed to open audit log: %v", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	head, err := audit.Verify(file)
+	if err != nil {
+		logger.Error(ctx, "Audit log %s failed verification: %v", path, err)
+		os.Exit(1)
+	}
+	logger.Info(ctx, "Audit log %s verified: %d entries, last hash %s", path, head.Entries, head.Hash)
+}
@@ -0,0 +1,206 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+)
+
+// ManifestName is the name of the manifest entry, written at the end of every archive.
+const ManifestName = "manifest.json"
+
+// Manifest describes the content of an archive.
+type Manifest struct {
+	Files   []Entry   `json:"files"`   // Files are the files in the archive, in order.
+	Missing []Missing `json:"missing"` // Missing are the files requested that couldn't be archived.
+}
+
+// Entry is a file in an archive.
+type Entry struct {
+	ID   int64  `json:"id"`   // ID is the identifier of the file.
+	Name string `json:"name"` // Name is the name of the file in the archive, de-duplicated if needed.
+	Size int64  `json:"size"` // Size is the size of the file's content in bytes.
+}
+
+// Missing is a file requested that couldn't be archived.
+type Missing struct {
+	ID    int64  `json:"id"`    // ID is the identifier of the file.
+	Error string `json:"error"` // Error describes why the file couldn't be archived.
+}
+
+// Archiver builds ZIP archives of stored files.
+type Archiver struct {
+	logger  logging.Logger
+	storage storageservice.StorageService
+}
+
+// New creates a new Archiver of the files of the given storage service.
+func New(logger logging.Logger, storage storageservice.StorageService) *Archiver {
+	return &Archiver{logger, storage}
+}
+
+// Select returns the IDs of the files of the tenant found in the context matching the filter, sorted by ID.
+func (a *Archiver) Select(ctx context.Context, filter storageservice.Filter) ([]int64, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	files, err := a.storage.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, file := range filter.Apply(files) {
+		ids = append(ids, file.ID)
+	}
+	return ids, nil
+}
+
+// Write streams a ZIP archive of the files of the given IDs, of the tenant found in the context, to w.
+// Files are read one at a time and copied as they are read, so memory use doesn't depend on their size.
+// They are named after their original names, made safe to extract as described in entryName, and de-duplicated
+// by adding a number to the names already taken.
+// Files that can't be found or read are left out and reported as missing in the manifest entry, written last.
+// Repeated IDs are archived once. It returns the manifest, or an error if the archive can't be written to w,
+// in which case it's left incomplete.
+func (a *Archiver) Write(ctx context.Context, w io.Writer, ids []int64) (Manifest, error) {
+	archive := zip.NewWriter(w)
+	now := time.Now()
+	manifest := Manifest{Files: []Entry{}, Missing: []Missing{}}
+	names := map[string]bool{strings.ToLower(ManifestName): true}
+	archived := make(map[int64]bool)
+	for _, id := range ids {
+		if archived[id] {
+			continue
+		}
+		archived[id] = true
+		if err := ctx.Err(); err != nil {
+			return manifest, err
+		}
+		entry, err := a.writeFile(ctx, archive, id, names, now)
+		var sourceErr *sourceError
+		switch {
+		case errors.As(err, &sourceErr):
+			manifest.Missing = append(manifest.Missing, Missing{id, sourceErr.Error()})
+		case err != nil:
+			return manifest, err
+		default:
+			manifest.Files = append(manifest.Files, entry)
+		}
+	}
+
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: ManifestName, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return manifest, err
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return manifest, err
+	}
+	return manifest, archive.Close()
+}
+
+// sourceError is a failure to read a file, which is then reported as missing, rather than to write the archive.
+type sourceError struct {
+	err error
+}
+
+// Error returns the reason the file couldn't be read.
+func (e *sourceError) Error() string {
+	return e.err.Error()
+}
+
+// writeFile adds the file of the given ID to the archive, under a name not taken yet, which it then takes,
+// modified at the given time. It returns a sourceError if the file can't be found or read.
+func (a *Archiver) writeFile(
+	ctx context.Context,
+	archive *zip.Writer,
+	id int64,
+	names map[string]bool,
+	modified time.Time,
+) (Entry, error) {
+	file, err := a.storage.Get(ctx, id)
+	if errors.Is(err, errs.ErrNotFound) {
+		return Entry{}, &sourceError{fmt.Errorf("file with ID %d not found", id)}
+	}
+	if err != nil {
+		a.logger.Error(ctx, "Failed to open file %d for archiving: %s", id, err.Error())
+		return Entry{}, &sourceError{fmt.Errorf("file with ID %d can't be read", id)}
+	}
+	defer file.Close()
+
+	name := uniqueName(entryName(file.Name, id), names)
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified}
+	if compression.Choose(file.ContentType, compression.Gzip) == compression.None {
+		// Already compressed content is stored as is, as deflating it again would only waste time.
+		header.Method = zip.Store
+	}
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return Entry{}, err
+	}
+	destination := &trackingWriter{w: writer}
+	size, err := io.Copy(destination, file)
+	if err != nil && destination.err == nil {
+		// The entry is already in the archive, so it's kept, but reported as missing as it's incomplete.
+		a.logger.Error(ctx, "Failed to read file %d for archiving: %s", id, err.Error())
+		return Entry{}, &sourceError{fmt.Errorf("file with ID %d can't be read completely, %s is truncated", id, name)}
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{ID: id, Name: name, Size: size}, nil
+}
+
+// entryName returns the name of the file of the given ID as an entry that extracts inside the destination
+// directory: path separators are replaced by underscores and leading dots are trimmed, so it's neither a path,
+// ".", ".." nor a hidden file. If nothing is left, the file is named after its ID.
+func entryName(name string, id int64) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return strconv.FormatInt(id, 10)
+	}
+	return name
+}
+
+// uniqueName returns the name, or, if it's taken, the first name not taken adding a number before its
+// extension, e.g. "report (1).pdf", and takes it. Names are compared case-insensitively, as not every file
+// system tells them apart.
+func uniqueName(name string, taken map[string]bool) string {
+	extension := filepath.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+	unique := name
+	for i := 1; taken[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s (%d)%s", base, i, extension)
+	}
+	taken[strings.ToLower(unique)] = true
+	return unique
+}
+
+// trackingWriter records the error of the writer it wraps, to tell write errors from read errors.
+type trackingWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write writes p to the wrapped writer, recording any error.
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if err != nil {
+		t.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,182 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// upload uploads a file with the given ID, name and content.
+func upload(
+	t *testing.T,
+	ctx context.Context,
+	service storageservice.StorageService,
+	id int64,
+	name string,
+	content string,
+) {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "content")
+	if err := os.WriteFile(src, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	data := storageservice.UploadData{File: file, Filename: name, Id: id, Size: int64(len(content))}
+	if err := service.Upload(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestArchiver returns an archiver of a temporary storage with a few files, and a context of the tenant "acme".
+func newTestArchiver(t *testing.T) (*Archiver, context.Context) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	service := storageservice.New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, paths),
+		compression.None,
+		scanning.Policy{},
+	)
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	upload(t, ctx, service, 1, "report.txt", "first report")
+	upload(t, ctx, service, 2, "Report.txt", "second report")
+	upload(t, ctx, service, 3, "manifest.json", "{}")
+	upload(t, ctx, service, 4, "deleted.txt", "deleted")
+	service.Delete(ctx, 4)
+	return New(logger, service), ctx
+}
+
+// readArchive returns the content of the entries of the archive, by name, in order.
+func readArchive(t *testing.T, content []byte) ([]string, map[string]string) {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	entries := make(map[string]string)
+	for _, file := range reader.File {
+		entry, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(entry)
+		entry.Close()
+		names = append(names, file.Name)
+		entries[file.Name] = string(data)
+	}
+	return names, entries
+}
+
+func TestWrite(t *testing.T) {
+	archiver, ctx := newTestArchiver(t)
+	var buffer bytes.Buffer
+	manifest, err := archiver.Write(ctx, &buffer, []int64{1, 2, 3, 4, 1, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, entries := readArchive(t, buffer.Bytes())
+	expected := []string{"report.txt", "Report (1).txt", "manifest (1).json", ManifestName}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected entries %v, got %v", expected, names)
+	}
+	for i, name := range expected {
+		if names[i] != name {
+			t.Errorf("Expected entry %d to be %s, got %s", i, name, names[i])
+		}
+	}
+	if entries["report.txt"] != "first report" || entries["Report (1).txt"] != "second report" {
+		t.Errorf("Unexpected content of the entries: %v", entries)
+	}
+
+	var written Manifest
+	if err := json.Unmarshal([]byte(entries[ManifestName]), &written); err != nil {
+		t.Fatal(err)
+	}
+	if len(written.Files) != 3 || written.Files[1].Name != "Report (1).txt" || written.Files[1].Size != 13 {
+		t.Errorf("Unexpected files in the manifest: %+v", written.Files)
+	}
+	if len(written.Missing) != 2 || written.Missing[0].ID != 4 || written.Missing[1].ID != 5 {
+		t.Errorf("Expected the deleted and unknown files to be missing, got %+v", written.Missing)
+	}
+	if len(manifest.Files) != len(written.Files) {
+		t.Errorf("Expected the manifest returned to be the one written, got %+v", manifest)
+	}
+}
+
+func TestUnsafeNames(t *testing.T) {
+	archiver, ctx := newTestArchiver(t)
+	upload(t, ctx, archiver.storage, 5, "..", "parent")
+	upload(t, ctx, archiver.storage, 6, ".env", "hidden")
+	upload(t, ctx, archiver.storage, 7, `..\evil.txt`, "escaped")
+	var buffer bytes.Buffer
+	if _, err := archiver.Write(ctx, &buffer, []int64{5, 6, 7}); err != nil {
+		t.Fatal(err)
+	}
+	names, entries := readArchive(t, buffer.Bytes())
+	expected := []string{"5", "env", "_evil.txt", ManifestName}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected entries %v, got %v", expected, names)
+	}
+	for i, name := range expected {
+		if names[i] != name {
+			t.Errorf("Expected entry %d to be %s, got %s", i, name, names[i])
+		}
+	}
+	if entries["5"] != "parent" || entries["_evil.txt"] != "escaped" {
+		t.Errorf("Unexpected content of the entries: %v", entries)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	archiver, ctx := newTestArchiver(t)
+	ids, err := archiver.Select(ctx, storageservice.Filter{Name: "*.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Expected the text files that aren't in the trash, got %v", ids)
+	}
+	if _, err := archiver.Select(ctx, storageservice.Filter{Name: "[txt"}); err == nil {
+		t.Errorf("Expected an error for a malformed pattern")
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestWriteToClosedStream(t *testing.T) {
+	archiver, ctx := newTestArchiver(t)
+	if _, err := archiver.Write(ctx, failingWriter{}, []int64{1, 2}); err == nil {
+		t.Errorf("Expected an error writing to a closed stream")
+	}
+}
@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionUpload         = "upload"
+	ActionDownload       = "download"
+	ActionReplace        = "replace"
+	ActionDelete         = "delete"
+	ActionRestore        = "restore"
+	ActionPurge          = "purge"
+	ActionExpire         = "expire"
+	ActionSetRetention   = "retention.set"
+	ActionAddRule        = "retention.rule.add"
+	ActionRemoveRule     = "retention.rule.remove"
+	ActionSetLegalHold   = "hold.set"
+	ActionClearLegalHold = "hold.clear"
+	ActionCheckOut       = "lock.checkout"
+	ActionCheckIn        = "lock.checkin"
+	ActionReleaseLock    = "lock.release"
+	ActionBreakLock      = "lock.break"
+	ActionChangeTags     = "tags.change"
+	ActionChangeMetadata = "metadata.change"
+	ActionChangeType     = "type.change"
+	ActionCopy           = "copy"
+	ActionMove           = "move"
+	ActionCreateDocType  = "doctype.create"
+	ActionUpdateDocType  = "doctype.update"
+	ActionDeleteDocType  = "doctype.delete"
+	ActionCreateFolder   = "folder.create"
+	ActionMoveFolder     = "folder.move"
+	ActionDeleteFolder   = "folder.delete"
+	ActionPlace          = "folder.place"
+	ActionUnplace        = "folder.unplace"
+)
+
+// Outcomes of the recorded operations.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// ErrTampered is returned when the audit log doesn't match its hash chain.
+var ErrTampered = errors.New("audit log has been tampered with")
+
+// Log is an append-only log of the operations performed on documents.
+// Every entry is chained to the previous one through its hash, so modifying, reordering or removing
+// entries breaks the chain and can be detected with Verify.
+type Log interface {
+	// Record appends an entry for the given action on the given document, attributed to the tenant, user and
+	// request found in the context. The outcome is a failure, detailed by err, if err is not nil.
+	Record(ctx context.Context, action string, documentID int64, err error) error
+
+	// Query returns the entries of the tenant found in the context that match the filter, oldest first.
+	Query(ctx context.Context, filter Filter) ([]Entry, error)
+}
+
+// Entry is a record of the audit log.
+type Entry struct {
+	Seq        int64     `json:"seq"`                 // Seq is the position of the entry in the log, starting at 1.
+	Time       time.Time `json:"time"`                // Time is when the operation was performed, in UTC.
+	Tenant     string    `json:"tenant"`              // Tenant is the tenant the operation was performed on.
+	Actor      string    `json:"actor,omitempty"`     // Actor is the user who performed the operation, if known.
+	RequestID  string    `json:"requestId,omitempty"` // RequestID is the ID of the request that performed it.
+	Action     string    `json:"action"`              // Action is the operation performed.
+	DocumentID int64     `json:"documentId"`          // DocumentID is the ID of the document operated on, 0 if none.
+	Outcome    string    `json:"outcome"`             // Outcome is either OutcomeSuccess or OutcomeFailure.
+	Detail     string    `json:"detail,omitempty"`    // Detail describes why the operation failed.
+	PrevHash   string    `json:"prevHash"`            // PrevHash is the hash of the previous entry, empty for the first one.
+	Hash       string    `json:"hash"`                // Hash is the hex-encoded SHA-256 of the entry and PrevHash.
+}
+
+// computeHash returns the hash of the entry, which covers every field but the hash itself.
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	encoded, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(e.PrevHash+"\n"), encoded...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Filter selects entries of the audit log. Zero fields match every entry.
+type Filter struct {
+	DocumentID int64     // DocumentID matches the entries of a document.
+	Actor      string    // Actor matches the entries of a user.
+	Action     string    // Action matches the entries of an action.
+	From       time.Time // From matches the entries recorded at or after it.
+	To         time.Time // To matches the entries recorded before it.
+	Limit      int       // Limit caps the number of entries, keeping the most recent ones.
+}
+
+// Matches reports whether the entry is selected by the filter.
+func (f Filter) Matches(e Entry) bool {
+	return (f.DocumentID == 0 || e.DocumentID == f.DocumentID) &&
+		(f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.From.IsZero() || !e.Time.Before(f.From)) &&
+		(f.To.IsZero() || e.Time.Before(f.To))
+}
+
+// Head is the last entry of a verified audit log.
+type Head struct {
+	Entries int64  // Entries is the number of entries in the log.
+	Hash    string // Hash is the hash of the last entry. Comparing it with a copy kept elsewhere detects truncation.
+}
+
+// Verify reads an audit log, one JSON entry per line, checking that the entries are consecutive and that
+// their hash chain is intact. It returns the head of the log, or an ErrTampered error naming the first
+// entry that doesn't match the chain.
+func Verify(r io.Reader) (Head, error) {
+	var head Head
+	err := scan(r, func(entry Entry, line int64) error {
+		if entry.Seq != head.Entries+1 {
+			return fmt.Errorf(
+				"%w: line %d has sequence number %d, expected %d",
+				ErrTampered,
+				line,
+				entry.Seq,
+				head.Entries+1,
+			)
+		}
+		if entry.PrevHash != head.Hash {
+			return fmt.Errorf("%w: entry %d isn't chained to the previous one", ErrTampered, entry.Seq)
+		}
+		hash, err := entry.computeHash()
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("%w: entry %d doesn't match its hash", ErrTampered, entry.Seq)
+		}
+		head = Head{entry.Seq, entry.Hash}
+		return nil
+	})
+	return head, err
+}
+
+// scan calls fn with every entry of an audit log, along with its line number.
+// It returns an ErrTampered error if a line isn't a valid entry.
+func scan(r io.Reader, fn func(Entry, int64) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	var line int64
+	for scanner.Scan() {
+		line++
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("%w: line %d is not a valid entry", ErrTampered, line)
+		}
+		if err := fn(entry, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/contextypes"
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// PathFromEnvironment returns the path of the audit log file, as specified by the "AUDIT_LOG_FILE" environment
+// variable. It defaults to logs/audit.log inside the project root.
+func PathFromEnvironment() string {
+	if path := environment.GetAuditLogFile(); path != "" {
+		return path
+	}
+	return filepath.Join(environment.GetProjectRoot(), "logs", "audit.log")
+}
+
+// fileLog implements the Log interface over a file, appending one JSON entry per line.
+type fileLog struct {
+	mu   sync.Mutex // mu serializes the appends, so entries are chained in order.
+	file *os.File
+	head Head  // head is the last entry appended.
+	size int64 // size is the length of the file up to the end of the last entry appended.
+}
+
+// OpenFileLog opens the audit log at the given path, creating it if it doesn't exist, and resumes its hash
+// chain from its last entry. A last line left incomplete by a crash is discarded. The chain itself isn't
+// verified, that's what Verify is for.
+func OpenFileLog(path string) (Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	complete := int64(bytes.LastIndexByte(content, '\n') + 1)
+	if complete < int64(len(content)) {
+		if err := file.Truncate(complete); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(complete, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	log := &fileLog{file: file, size: complete}
+	err = scan(bytes.NewReader(content[:complete]), func(entry Entry, _ int64) error {
+		log.head = Head{entry.Seq, entry.Hash}
+		return nil
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return log, nil
+}
+
+// Record appends an entry to the file, chained to the last one, and syncs it to disk.
+func (l *fileLog) Record(ctx context.Context, action string, documentID int64, err error) error {
+	tenant, tenantErr := tenancy.FromContext(ctx)
+	if tenantErr != nil {
+		return tenantErr
+	}
+	entry := Entry{
+		Time:       time.Now().UTC(),
+		Tenant:     tenant,
+		Actor:      tenancy.UserFromContext(ctx),
+		Action:     action,
+		DocumentID: documentID,
+		Outcome:    OutcomeSuccess,
+	}
+	if requestID, ok := ctx.Value(contextypes.CTXRequestIDKey{}).(string); ok {
+		entry.RequestID = requestID
+	}
+	if err != nil {
+		entry.Outcome = OutcomeFailure
+		entry.Detail = err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.Seq = l.head.Entries + 1
+	entry.PrevHash = l.head.Hash
+	hash, hashErr := entry.computeHash()
+	if hashErr != nil {
+		return hashErr
+	}
+	entry.Hash = hash
+	line, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append audit entry: %w", err)
+	}
+	l.size += int64(len(line)) + 1
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	l.head = Head{entry.Seq, entry.Hash}
+	return nil
+}
+
+// Query reads the file from its start up to the last entry appended when it's called, returning the entries of
+// the tenant that match the filter. The file is read without holding the lock, so entries are recorded
+// meanwhile. If the filter has a limit, only the most recent matching entries are kept.
+func (l *fileLog) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	size := l.size
+	l.mu.Unlock()
+	reader := io.NewSectionReader(l.file, 0, size)
+	entries := []Entry{}
+	err = scan(reader, func(entry Entry, _ int64) error {
+		if entry.Tenant != tenant || !filter.Matches(entry) {
+			return nil
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) > filter.Limit {
+			entries = entries[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
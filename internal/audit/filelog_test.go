@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/contextypes"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// requestContext returns the context of a request of the given tenant and user.
+func requestContext(tenant string, user string) context.Context {
+	ctx := tenancy.WithUser(tenancy.WithTenant(context.Background(), tenant), user)
+	return context.WithValue(ctx, contextypes.CTXRequestIDKey{}, "req-1")
+}
+
+// openTestLog opens an audit log in a temporary directory, returning it along with its path.
+func openTestLog(t *testing.T) (Log, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := OpenFileLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log, path
+}
+
+// verifyFile verifies the audit log at path.
+func verifyFile(t *testing.T, path string) (Head, error) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Verify(bytes.NewReader(content))
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	log, _ := openTestLog(t)
+	alice := requestContext("acme", "alice")
+	log.Record(alice, ActionUpload, 1, nil)
+	log.Record(requestContext("acme", "bob"), ActionDownload, 1, nil)
+	log.Record(alice, ActionDelete, 2, errors.New("not found"))
+	log.Record(requestContext("other", "alice"), ActionUpload, 1, nil)
+
+	entries, err := log.Query(alice, Filter{DocumentID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != ActionUpload || entries[1].Action != ActionDownload {
+		t.Errorf("Expected the upload and download of document 1 of the tenant, got: %+v", entries)
+	}
+	if entries[0].Actor != "alice" || entries[0].RequestID != "req-1" || entries[0].Outcome != OutcomeSuccess {
+		t.Errorf("Unexpected entry: %+v", entries[0])
+	}
+
+	entries, _ = log.Query(alice, Filter{Actor: "alice"})
+	if len(entries) != 2 || entries[1].Outcome != OutcomeFailure || entries[1].Detail != "not found" {
+		t.Errorf("Expected the entries of alice in the tenant, got: %+v", entries)
+	}
+	entries, _ = log.Query(alice, Filter{From: time.Now().Add(time.Hour)})
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries in the future, got: %+v", entries)
+	}
+	entries, _ = log.Query(alice, Filter{Limit: 1})
+	if len(entries) != 1 || entries[0].Action != ActionDelete {
+		t.Errorf("Expected only the most recent entry, got: %+v", entries)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	log, path := openTestLog(t)
+	ctx := requestContext("acme", "alice")
+	for id := int64(1); id <= 3; id++ {
+		log.Record(ctx, ActionUpload, id, nil)
+	}
+	head, err := verifyFile(t, path)
+	if err != nil || head.Entries != 3 || head.Hash == "" {
+		t.Fatalf("Expected an intact log of 3 entries, got: %+v, err=%v", head, err)
+	}
+
+	content, _ := os.ReadFile(path)
+	tampered := strings.Replace(string(content), `"actor":"alice"`, `"actor":"mallory"`, 1)
+	os.WriteFile(path, []byte(tampered), 0644)
+	if _, err := verifyFile(t, path); !errors.Is(err, ErrTampered) {
+		t.Errorf("Expected ErrTampered for a modified entry, got: %v", err)
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	os.WriteFile(path, []byte(lines[0]+lines[2]), 0644)
+	if _, err := verifyFile(t, path); !errors.Is(err, ErrTampered) {
+		t.Errorf("Expected ErrTampered for a removed entry, got: %v", err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	log, path := openTestLog(t)
+	ctx := requestContext("acme", "alice")
+	log.Record(ctx, ActionUpload, 1, nil)
+	log.(*fileLog).file.Close()
+
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"seq":2,"time":`) // A write interrupted by a crash.
+	file.Close()
+
+	log, err := OpenFileLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Record(ctx, ActionDownload, 1, nil)
+	head, err := verifyFile(t, path)
+	if err != nil || head.Entries != 2 {
+		t.Errorf("Expected the chain to resume after the last complete entry, got: %+v, err=%v", head, err)
+	}
+}
+
+func TestQueryWhileRecording(t *testing.T) {
+	log, _ := openTestLog(t)
+	ctx := requestContext("acme", "alice")
+	log.Record(ctx, ActionUpload, 1, nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			log.Record(ctx, ActionDownload, 1, nil)
+		}
+	}()
+
+	previous := 0
+	for recording := true; recording; {
+		select {
+		case <-done:
+			recording = false
+		default:
+		}
+		entries, err := log.Query(ctx, Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) < previous || entries[len(entries)-1].Seq != int64(len(entries)) {
+			t.Fatalf("Expected a consistent snapshot of the log, got %d entries after %d", len(entries), previous)
+		}
+		previous = len(entries)
+	}
+	if previous != 101 {
+		t.Errorf("Expected every entry once recording finished, got %d", previous)
+	}
+}
@@ -0,0 +1,47 @@
+package blobstore
+
+import (
+	"io"
+	"time"
+)
+
+// BlobStore defines the interface for storing the content of the files, the blobs.
+// It abstracts how the content is laid out in the storage, e.g. whether it's encrypted,
+// so the rest of the application always deals with the content as it was uploaded.
+type BlobStore interface {
+	// Create creates the blob at the given path, overwriting any existing one, and returns a writer
+	// for its content. The blob is only complete once the writer is closed.
+	Create(path string) (io.WriteCloser, error)
+
+	// Open opens the blob at the given path for reading.
+	// It returns an error wrapping fs.ErrNotExist if there's no blob at the path.
+	Open(path string) (Blob, error)
+
+	// Remove deletes the blob at the given path.
+	// It returns an error wrapping fs.ErrNotExist if there's no blob at the path.
+	Remove(path string) error
+
+	// Move moves the blob at the given path to a new one, overwriting any existing blob there.
+	// It returns an error wrapping fs.ErrNotExist if there's no blob at the path.
+	Move(from, to string) error
+
+	// List returns every blob under the given root directory.
+	// Entries whose name starts with a dot are skipped, as they are reserved for internal bookkeeping.
+	// It returns an empty list if the root doesn't exist.
+	List(root string) ([]BlobInfo, error)
+}
+
+// BlobInfo describes a blob found in the store.
+type BlobInfo struct {
+	Path       string    // Path is the location of the blob.
+	StoredSize int64     // StoredSize is the number of bytes the blob takes in the storage.
+	ModTime    time.Time // ModTime is the last time the blob was modified.
+}
+
+// Blob is a blob opened for reading. Reads return the content as it was written to the store.
+type Blob interface {
+	io.ReadSeekCloser
+
+	// Size returns the size of the content, which may differ from the size the blob takes in the storage.
+	Size() int64
+}
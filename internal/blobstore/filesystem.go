@@ -0,0 +1,155 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucastomic/dmsStorageService/internal/encryption"
+)
+
+// NewFileSystem returns a BlobStore that keeps every blob in a file at its path.
+// If a keyring is given, new blobs are encrypted with envelope encryption, using a random data key per blob
+// wrapped by the keyring's active master key. Otherwise they are written in plain.
+// Both encrypted and plain blobs can be read, as long as the keyring holds the key of the encrypted ones.
+func NewFileSystem(keyring *encryption.Keyring) BlobStore {
+	return fileSystem{keyring}
+}
+
+// fileSystem implements the BlobStore interface over the local filesystem.
+type fileSystem struct {
+	keyring *encryption.Keyring // keyring encrypts new blobs. It's nil if encryption is disabled.
+}
+
+// Create creates the file at the given path, along with its parent directories.
+// The returned writer encrypts the content if encryption is enabled.
+func (f fileSystem) Create(path string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	if f.keyring == nil {
+		return file, nil
+	}
+	writer, err := encryption.NewWriter(file, f.keyring)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+	return &encryptedWriter{writer, file}, nil
+}
+
+// Open opens the file at the given path. Encrypted files are decrypted transparently while they are read.
+func (f fileSystem) Open(path string) (Blob, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !encryption.IsEncrypted(file) {
+		return plainBlob{file, info.Size()}, nil
+	}
+	if f.keyring == nil {
+		file.Close()
+		return nil, errors.New("blob is encrypted but no master key is configured")
+	}
+	reader, err := encryption.NewReader(file, info.Size(), f.keyring)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return encryptedBlob{reader, file}, nil
+}
+
+// Remove deletes the file at the given path.
+func (f fileSystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
+// Move renames the file at the given path, creating the parent directories of the new one.
+func (f fileSystem) Move(from, to string) error {
+	if _, err := os.Stat(from); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return os.Rename(from, to)
+}
+
+// List walks the given root directory, returning every file under it.
+func (f fileSystem) List(root string) ([]BlobInfo, error) {
+	var blobs []BlobInfo
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == root {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, BlobInfo{Path: path, StoredSize: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return blobs, err
+}
+
+// encryptedWriter closes both the encryption writer and the underlying file.
+type encryptedWriter struct {
+	io.WriteCloser
+	file *os.File
+}
+
+// Close seals the encrypted content and closes the file.
+func (w *encryptedWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// plainBlob is a blob stored without encryption.
+type plainBlob struct {
+	*os.File
+	size int64
+}
+
+// Size returns the size of the file.
+func (b plainBlob) Size() int64 {
+	return b.size
+}
+
+// encryptedBlob is a blob decrypted while it's read.
+type encryptedBlob struct {
+	*encryption.Reader
+	file *os.File
+}
+
+// Close closes the underlying file.
+func (b encryptedBlob) Close() error {
+	return b.file.Close()
+}
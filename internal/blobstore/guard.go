@@ -0,0 +1,31 @@
+package blobstore
+
+import "sync"
+
+// Guard coordinates the writers of new blobs with the jobs that remove the blobs no path references.
+// A blob is written before the path referencing it is saved, so without coordination a job could take
+// the blob of an in-flight upload for an unreferenced one.
+// Any number of writes can be in progress at once, while a sweep waits for them to finish and holds
+// new ones back until it's done.
+type Guard struct {
+	mu sync.RWMutex
+}
+
+// NewGuard returns a new Guard.
+func NewGuard() *Guard {
+	return &Guard{}
+}
+
+// BeginWrite marks the start of a write, which lasts until the path referencing the new blob is saved
+// or the write fails. The returned function marks its end.
+func (g *Guard) BeginWrite() func() {
+	g.mu.RLock()
+	return g.mu.RUnlock
+}
+
+// BeginSweep waits for the writes in progress to finish and holds new ones back until the returned function
+// is called. Every blob found during the sweep is either referenced by a path or unreferenced for good.
+func (g *Guard) BeginSweep() func() {
+	g.mu.Lock()
+	return g.mu.Unlock
+}
@@ -0,0 +1,159 @@
+package checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+// ErrMismatch is returned when content doesn't match its checksum.
+var ErrMismatch = errors.New("checksum mismatch")
+
+// castagnoli is the CRC32C table.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Algorithms supported for client-supplied digests, named as in the HTTP Digest Algorithm registry.
+const (
+	SHA256 = "sha-256"
+	SHA512 = "sha-512"
+	MD5    = "md5"
+)
+
+// Sum holds the checksums of a content.
+type Sum struct {
+	SHA256 string // SHA256 is the hex-encoded SHA-256 hash of the content.
+	CRC32C uint32 // CRC32C is the CRC-32 checksum of the content, using the Castagnoli polynomial.
+}
+
+// Digests are digests of a content supplied by a client, mapped by algorithm.
+type Digests map[string][]byte
+
+// ParseContentDigest parses a Content-Digest header, as defined by RFC 9530, e.g. "sha-256=:base64:".
+// Digests of algorithms that aren't supported are ignored.
+func ParseContentDigest(header string) (Digests, error) {
+	digests := make(Digests)
+	for _, member := range strings.Split(header, ",") {
+		algorithm, value, found := strings.Cut(strings.TrimSpace(member), "=")
+		if !found || len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return nil, fmt.Errorf("%w: malformed Content-Digest", errs.ErrInvalidInput)
+		}
+		algorithm = strings.ToLower(algorithm)
+		if algorithm != SHA256 && algorithm != SHA512 {
+			continue
+		}
+		digest, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed %s Content-Digest", errs.ErrInvalidInput, algorithm)
+		}
+		digests[algorithm] = digest
+	}
+	return digests, nil
+}
+
+// ParseContentMD5 parses a Content-MD5 header, the base64-encoded MD5 hash of the content.
+func ParseContentMD5(header string) ([]byte, error) {
+	digest, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header))
+	if err != nil || len(digest) != md5.Size {
+		return nil, fmt.Errorf("%w: malformed Content-MD5", errs.ErrInvalidInput)
+	}
+	return digest, nil
+}
+
+// ReprDigest formats the SHA-256 checksum of sum as a Repr-Digest header value, as defined by RFC 9530.
+func ReprDigest(sum Sum) string {
+	return fmt.Sprintf("%s=:%s:", SHA256, base64.StdEncoding.EncodeToString(decodeHex(sum.SHA256)))
+}
+
+// Digest formats the SHA-256 checksum of sum as a Digest header value, as defined by RFC 3230.
+func Digest(sum Sum) string {
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(decodeHex(sum.SHA256))
+}
+
+// Hasher computes the checksums of everything written to it, along with the digests
+// that have to be verified against the ones supplied by a client.
+type Hasher struct {
+	sha256   hash.Hash
+	crc32c   hash.Hash32
+	expected Digests
+	others   map[string]hash.Hash
+	writer   io.Writer
+}
+
+// NewHasher returns a Hasher that, besides the checksums, computes the digests needed to verify
+// the expected ones.
+func NewHasher(expected Digests) *Hasher {
+	h := &Hasher{
+		sha256:   sha256.New(),
+		crc32c:   crc32.New(castagnoli),
+		expected: expected,
+		others:   make(map[string]hash.Hash),
+	}
+	writers := []io.Writer{h.sha256, h.crc32c}
+	for algorithm := range expected {
+		switch algorithm {
+		case SHA512:
+			h.others[algorithm] = sha512.New()
+		case MD5:
+			h.others[algorithm] = md5.New()
+		default:
+			continue
+		}
+		writers = append(writers, h.others[algorithm])
+	}
+	h.writer = io.MultiWriter(writers...)
+	return h
+}
+
+// Write adds p to the hashed content.
+func (h *Hasher) Write(p []byte) (int, error) {
+	return h.writer.Write(p)
+}
+
+// Sum returns the checksums of the content written so far.
+func (h *Hasher) Sum() Sum {
+	return Sum{SHA256: hex.EncodeToString(h.sha256.Sum(nil)), CRC32C: h.crc32c.Sum32()}
+}
+
+// Verify checks the content written so far against the expected digests.
+// It returns an ErrMismatch error naming the first digest that doesn't match.
+func (h *Hasher) Verify() error {
+	for algorithm, digest := range h.expected {
+		computed, ok := h.others[algorithm]
+		if algorithm == SHA256 {
+			computed, ok = h.sha256, true
+		}
+		if ok && !bytes.Equal(computed.Sum(nil), digest) {
+			return fmt.Errorf("%w: %s digest doesn't match the content", ErrMismatch, algorithm)
+		}
+	}
+	return nil
+}
+
+// Compute reads r until EOF and returns the checksums of its content.
+func Compute(r io.Reader) (Sum, error) {
+	h := NewHasher(nil)
+	if _, err := io.Copy(h, r); err != nil {
+		return Sum{}, err
+	}
+	return h.Sum(), nil
+}
+
+// decodeHex decodes a hex-encoded checksum, returning nil if it's malformed.
+func decodeHex(s string) []byte {
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
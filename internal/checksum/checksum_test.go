@@ -0,0 +1,84 @@
+package checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+var content = []byte("Lorem ipsum dolor sit amet")
+
+func TestCompute(t *testing.T) {
+	sum, err := Compute(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.SHA256 != "16aba5393ad72c0041f5600ad3c2c52ec437a2f0c7fc08fadfc3c0fe9641d7a3" {
+		t.Errorf("Unexpected SHA-256 %s", sum.SHA256)
+	}
+	if sum.CRC32C == 0 {
+		t.Errorf("Expected CRC32C to be computed")
+	}
+}
+
+func TestVerifyDigests(t *testing.T) {
+	sha := sha256.Sum256(content)
+	md := md5.Sum(content)
+	header := "sha-256=:" + base64.StdEncoding.EncodeToString(sha[:]) + ":, unixsum=:MTIz:"
+	digests, err := ParseContentDigest(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digests[MD5], _ = ParseContentMD5(base64.StdEncoding.EncodeToString(md[:]))
+
+	hasher := NewHasher(digests)
+	hasher.Write(content)
+	if err := hasher.Verify(); err != nil {
+		t.Errorf("Expected digests to match, got %v", err)
+	}
+
+	hasher = NewHasher(digests)
+	hasher.Write([]byte("tampered"))
+	if err := hasher.Verify(); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected ErrMismatch, got %v", err)
+	}
+}
+
+func TestParseMalformedDigests(t *testing.T) {
+	if _, err := ParseContentDigest("sha-256=abc"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for a malformed Content-Digest, got %v", err)
+	}
+	if _, err := ParseContentMD5("bm90IGFuIG1kNQ=="); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for a malformed Content-MD5, got %v", err)
+	}
+}
+
+func TestVerifyingReader(t *testing.T) {
+	sum, _ := Compute(bytes.NewReader(content))
+
+	reader := NewVerifyingReader(bytes.NewReader(content), sum.SHA256, int64(len(content)))
+	reader.Seek(0, io.SeekEnd)
+	reader.Seek(0, io.SeekStart)
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Errorf("Expected intact content to be verified, got %v", err)
+	}
+
+	corrupted := []byte(strings.ToUpper(string(content)))
+	reader = NewVerifyingReader(bytes.NewReader(corrupted), sum.SHA256, int64(len(content)))
+	if _, err := io.ReadAll(reader); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected ErrMismatch reading corrupted content, got %v", err)
+	}
+
+	reader = NewVerifyingReader(bytes.NewReader(corrupted), sum.SHA256, int64(len(content)))
+	reader.Seek(6, io.SeekStart)
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Errorf("Expected verification to be skipped for partial reads, got %v", err)
+	}
+}
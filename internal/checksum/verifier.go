@@ -0,0 +1,69 @@
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// VerifyingReader verifies the SHA-256 checksum of a content while it's read.
+// Verification only happens if the content is read sequentially from its beginning until its end:
+// once a read doesn't continue where the previous one finished, e.g. to serve a range, it's disabled.
+// Seeking around without reading, like http.ServeContent does to find out the size, doesn't disable it.
+type VerifyingReader struct {
+	src      io.ReadSeeker
+	expected string
+	size     int64
+	hash     hash.Hash
+	offset   int64
+	hashed   int64
+	disabled bool
+	verified bool
+}
+
+// NewVerifyingReader returns a VerifyingReader over src, a content of the given size whose
+// hex-encoded SHA-256 checksum is expected.
+func NewVerifyingReader(src io.ReadSeeker, expected string, size int64) *VerifyingReader {
+	return &VerifyingReader{src: src, expected: expected, size: size, hash: sha256.New()}
+}
+
+// Read reads from the content, hashing it. As soon as the whole content has been read, the checksum is
+// verified. If it doesn't match, the last bytes read are withheld and an ErrMismatch error is returned,
+// so the reader of the content can't mistake it for a complete and intact one.
+func (v *VerifyingReader) Read(p []byte) (int, error) {
+	if v.offset != v.hashed {
+		v.disabled = true
+	}
+	n, err := v.src.Read(p)
+	v.offset += int64(n)
+	if v.disabled || v.verified {
+		return n, err
+	}
+	v.hash.Write(p[:n])
+	v.hashed += int64(n)
+	if v.hashed == v.size && (n > 0 || errors.Is(err, io.EOF)) {
+		v.verified = true
+		if computed := hex.EncodeToString(v.hash.Sum(nil)); computed != v.expected {
+			return 0, fmt.Errorf("%w: expected sha-256 %s, got %s", ErrMismatch, v.expected, computed)
+		}
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read, as described by io.Seeker.
+func (v *VerifyingReader) Seek(offset int64, whence int) (int64, error) {
+	offset, err := v.src.Seek(offset, whence)
+	if err != nil {
+		return offset, err
+	}
+	v.offset = offset
+	return offset, nil
+}
+
+// Size returns the size of the content.
+func (v *VerifyingReader) Size() int64 {
+	return v.size
+}
@@ -0,0 +1,201 @@
+package compression
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec identifies a compression format. Its values match the HTTP content-coding names,
+// so they can be used directly in the Content-Encoding header.
+type Codec string
+
+const (
+	None Codec = ""     // None stores content uncompressed.
+	Gzip Codec = "gzip" // Gzip compresses content with gzip.
+	Zstd Codec = "zstd" // Zstd compresses content with Zstandard.
+)
+
+// ParseCodec parses the name of a codec. An empty name or "none" disable compression.
+func ParseCodec(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return None, nil
+	case "gzip":
+		return Gzip, nil
+	case "zstd":
+		return Zstd, nil
+	default:
+		return None, fmt.Errorf("unknown compression codec %q", name)
+	}
+}
+
+// alreadyCompressed lists the content types whose formats are already compressed,
+// so compressing them again would only waste CPU.
+var alreadyCompressed = map[string]bool{
+	"application/zip":              true,
+	"application/x-gzip":           true,
+	"application/gzip":             true,
+	"application/x-rar-compressed": true,
+	"application/x-7z-compressed":  true,
+	"application/zstd":             true,
+	"application/pdf":              true,
+	"application/wasm":             true,
+	"font/woff":                    true,
+	"font/woff2":                   true,
+	"image/jpeg":                   true,
+	"image/png":                    true,
+	"image/gif":                    true,
+	"image/webp":                   true,
+}
+
+// Choose returns the codec a file of the given content type should be stored with, given the preferred codec.
+// It returns None for already compressed formats, including audio and video.
+func Choose(contentType string, preferred Codec) Codec {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if alreadyCompressed[mediaType] ||
+		strings.HasPrefix(mediaType, "audio/") ||
+		strings.HasPrefix(mediaType, "video/") {
+		return None
+	}
+	return preferred
+}
+
+// NewWriter returns a writer that compresses everything written to it into dst with the given codec.
+// Close must be called to flush the compressed content. It doesn't close dst.
+func NewWriter(dst io.Writer, codec Codec) (io.WriteCloser, error) {
+	switch codec {
+	case Gzip:
+		return gzip.NewWriter(dst), nil
+	case Zstd:
+		return zstd.NewWriter(dst)
+	default:
+		return nil, fmt.Errorf("unknown compression codec %q", codec)
+	}
+}
+
+// newDecoder returns a reader that decompresses src with the given codec.
+func newDecoder(src io.Reader, codec Codec) (io.ReadCloser, error) {
+	switch codec {
+	case Gzip:
+		return gzip.NewReader(src)
+	case Zstd:
+		decoder, err := zstd.NewReader(src, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression codec %q", codec)
+	}
+}
+
+// Reader decompresses content while it's read. As compressed streams can't be accessed randomly,
+// seeking is emulated: seeking forward discards decompressed content, and seeking backwards restarts
+// the decompression from the start of the content. Sequential reads, the common case, are not affected.
+type Reader struct {
+	src      io.ReadSeeker
+	codec    Codec
+	size     int64
+	offset   int64
+	decoder  io.ReadCloser
+	position int64
+}
+
+// NewReader returns a Reader that decompresses src, compressed with the given codec.
+// The size of the decompressed content must be known beforehand, so the reader can be seeked from its end.
+func NewReader(src io.ReadSeeker, codec Codec, size int64) *Reader {
+	return &Reader{src: src, codec: codec, size: size}
+}
+
+// Size returns the size of the decompressed content.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Read reads decompressed content from the current offset.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.decoder == nil || r.position > r.offset {
+		if err := r.restart(); err != nil {
+			return 0, err
+		}
+	}
+	if r.position < r.offset {
+		skipped, err := io.CopyN(io.Discard, r.decoder, r.offset-r.position)
+		r.position += skipped
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+	}
+	if remaining := r.size - r.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.decoder.Read(p)
+	r.position += int64(n)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && r.offset < r.size {
+		return n, io.ErrUnexpectedEOF
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read, as described by io.Seeker.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close releases the decoder. It doesn't close the source.
+func (r *Reader) Close() error {
+	if r.decoder == nil {
+		return nil
+	}
+	return r.decoder.Close()
+}
+
+// restart starts decompressing the source from its beginning.
+func (r *Reader) restart() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	if _, err := r.src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	decoder, err := newDecoder(r.src, r.codec)
+	if err != nil {
+		return err
+	}
+	r.decoder, r.position = decoder, 0
+	return nil
+}
+
+// unexpectedEOF turns an io.EOF, found before the expected end of the content, into an io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
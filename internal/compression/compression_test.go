@@ -0,0 +1,81 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// compress compresses content with the given codec.
+func compress(t *testing.T, content []byte, codec Codec) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	content := []byte(strings.Repeat("id;name;amount\n1;acme;100\n", 1000))
+	for _, codec := range []Codec{Gzip, Zstd} {
+		compressed := compress(t, content, codec)
+		if len(compressed) >= len(content) {
+			t.Errorf("Expected %s to compress the content, got %d bytes", codec, len(compressed))
+		}
+		reader := NewReader(bytes.NewReader(compressed), codec, int64(len(content)))
+		got, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("Round trip with %s failed, got %d bytes, err=%v", codec, len(got), err)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10000))
+	for _, codec := range []Codec{Gzip, Zstd} {
+		reader := NewReader(bytes.NewReader(compress(t, content, codec)), codec, int64(len(content)))
+		for _, offset := range []int64{5000, 42, 99990} {
+			reader.Seek(offset, io.SeekStart)
+			got := make([]byte, 10)
+			if _, err := io.ReadFull(reader, got); err != nil || !bytes.Equal(got, content[offset:offset+10]) {
+				t.Errorf("Expected to read at offset %d with %s, got %q, err=%v", offset, codec, got, err)
+			}
+		}
+		if end, _ := reader.Seek(0, io.SeekEnd); end != int64(len(content)) {
+			t.Errorf("Expected seeking to the end to return the size, got %d", end)
+		}
+	}
+}
+
+func TestTruncatedContent(t *testing.T) {
+	content := []byte(strings.Repeat("truncated ", 100))
+	compressed := compress(t, content, Gzip)
+	reader := NewReader(bytes.NewReader(compressed), Gzip, int64(len(content))+10)
+	if _, err := io.ReadAll(reader); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF when content is shorter than its size, got %v", err)
+	}
+}
+
+func TestChoose(t *testing.T) {
+	tests := map[string]Codec{
+		"text/plain; charset=utf-8": Zstd,
+		"application/octet-stream":  Zstd,
+		"application/zip":           None,
+		"image/png":                 None,
+		"video/mp4":                 None,
+	}
+	for contentType, expected := range tests {
+		if got := Choose(contentType, Zstd); got != expected {
+			t.Errorf("Expected %q to be stored with %q, got %q", contentType, expected, got)
+		}
+	}
+	if got := Choose("text/plain", None); got != None {
+		t.Errorf("Expected no compression when it's disabled, got %q", got)
+	}
+}
@@ -5,5 +5,13 @@ package contextypes
 // the likelihood of key collisions when using context values.
 type CTXRequestIDKey struct{}
 
+// CTXTenantIDKey is a type used exclusively as a key in context.Context for storing and retrieving
+// the tenant the HTTP request acts on behalf of. Every repository key and storage path is scoped by it.
+type CTXTenantIDKey struct{}
+
+// CTXUserIDKey is a type used exclusively as a key in context.Context for storing and retrieving
+// the user, inside the tenant, that performs the HTTP request.
+type CTXUserIDKey struct{}
+
 // ContextPathVarKey is a type used as a context key for storing and retrieving path variables.
 type ContextPathVarKey string
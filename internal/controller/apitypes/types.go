@@ -19,6 +19,8 @@ type Response struct {
 	Status  int               // HTTP status code to be returned with the response.
 	Content any               // The payload of the response, allowing for flexible data types.
 	Headers map[string]string // HTTP headers to be returned witht he response.
+	// Written tells the handler already wrote the whole response by itself, so nothing else must be written.
+	Written bool
 }
 
 // Route is a struct with the necessary information for defaining and endpoint. Its path,
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/archive"
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+)
+
+// ArchiveController exports sets of files as ZIP archives.
+type ArchiveController struct {
+	logger   logging.Logger
+	archiver *archive.Archiver
+	common   CommonController
+}
+
+// NewArchiveController creates a new instance of ArchiveController with the provided logger and archiver.
+func NewArchiveController(logger logging.Logger, archiver *archive.Archiver) Controller {
+	return &ArchiveController{logger, archiver, CommonController{}}
+}
+
+// Router defines the routes that the ArchiveController handles.
+// It sets up a single route for downloading files as an archive.
+func (c *ArchiveController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/files/archive",
+			Method:  "POST",
+			Handler: c.Archive,
+		},
+	}
+}
+
+// archiveRequest is the body of an archive request.
+type archiveRequest struct {
+	IDs    []int64                `json:"ids"`    // IDs are the IDs of the files to archive.
+	Filter *storageservice.Filter `json:"filter"` // Filter selects the files to archive instead of the IDs.
+}
+
+// Archive handles the download of a ZIP archive of the files whose IDs are in the ids field of the body, or,
+// without IDs, of the files selected by the filter field, every file if there's none. The archive is built
+// while it's streamed, ending with a manifest entry listing the files archived and those missing.
+func (c *ArchiveController) Archive(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	var request archiveRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	ids := request.IDs
+	if len(ids) > 0 && request.Filter != nil {
+		return c.common.ParseError(
+			req.Context(),
+			req,
+			w,
+			fmt.Errorf("%w: either ids or filter must be set, not both", errs.ErrInvalidInput),
+		)
+	}
+	if len(ids) == 0 {
+		filter := storageservice.Filter{}
+		if request.Filter != nil {
+			filter = *request.Filter
+		}
+		var err error
+		if ids, err = c.archiver.Select(req.Context(), filter); err != nil {
+			return c.common.ParseError(req.Context(), req, w, err)
+		}
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := c.archiver.Write(req.Context(), writer, ids)
+		if err != nil {
+			c.logger.Error(req.Context(), "Failed to stream archive: %s", err.Error())
+		}
+		writer.CloseWithError(err)
+	}()
+	return apitypes.Response{
+		Status: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Disposition": "attachment; filename=archive.zip",
+			"Content-Type":        "application/zip",
+		},
+		Content: reader,
+	}
+}
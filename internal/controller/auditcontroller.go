@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/audit"
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+// defaultAuditLimit is the number of entries returned by an audit query that doesn't set a limit.
+const defaultAuditLimit = 1000
+
+// AuditController exposes the audit log of the operations performed on documents.
+type AuditController struct {
+	logger logging.Logger
+	log    audit.Log
+	common CommonController
+}
+
+// NewAuditController creates a new instance of AuditController with the provided logger and audit log.
+func NewAuditController(logger logging.Logger, log audit.Log) Controller {
+	return &AuditController{logger, log, CommonController{}}
+}
+
+// Router defines the routes that the AuditController handles.
+// It sets up a single route for querying the audit log.
+func (c *AuditController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/audit",
+			Method:  "GET",
+			Handler: c.Query,
+		},
+	}
+}
+
+// Query handles the request of the audit entries of the request's tenant, oldest first.
+// Entries can be filtered with the query parameters document, actor, action, and from and to, RFC 3339 times
+// bounding when they were recorded. Only the most recent entries are returned, as many as the limit parameter.
+func (c *AuditController) Query(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	filter, err := parseAuditFilter(req)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	entries, err := c.log.Query(req.Context(), filter)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, entries)
+}
+
+// parseAuditFilter parses the audit filter from the query parameters of the request.
+// It returns an ErrInvalidInput error if any of them is malformed.
+func parseAuditFilter(req *http.Request) (audit.Filter, error) {
+	query := req.URL.Query()
+	filter := audit.Filter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Limit:  defaultAuditLimit,
+	}
+	var err error
+	if document := query.Get("document"); document != "" {
+		if filter.DocumentID, err = strconv.ParseInt(document, 10, 64); err != nil {
+			return audit.Filter{}, fmt.Errorf("%w: document must be an integer", errs.ErrInvalidInput)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			return audit.Filter{}, fmt.Errorf("%w: limit must be a positive integer", errs.ErrInvalidInput)
+		}
+	}
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return audit.Filter{}, fmt.Errorf("%w: from must be an RFC 3339 time", errs.ErrInvalidInput)
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return audit.Filter{}, fmt.Errorf("%w: to must be an RFC 3339 time", errs.ErrInvalidInput)
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+)
+
+const (
+	maxBatchSize  = 100 << 20 // maxBatchSize is the maximum size of a batch, and of the content of its archive.
+	maxBatchFiles = 1000      // maxBatchFiles is the maximum number of files in a batch.
+)
+
+// BatchController uploads several files in a single request.
+type BatchController struct {
+	logger         logging.Logger
+	storageservice storageservice.StorageService
+	common         CommonController
+}
+
+// NewBatchController creates a new instance of BatchController with the provided logger and storage service.
+func NewBatchController(logger logging.Logger, storageservice storageservice.StorageService) Controller {
+	return &BatchController{logger, storageservice, CommonController{}}
+}
+
+// Router defines the routes that the BatchController handles.
+// It sets up a single route for uploading a batch of files.
+func (c *BatchController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/files/batch",
+			Method:  "POST",
+			Handler: c.Upload,
+		},
+	}
+}
+
+// batchResult is the outcome of the upload of a file of a batch.
+type batchResult struct {
+	ID     int64  `json:"id"`              // ID is the identifier of the file.
+	Name   string `json:"name"`            // Name is the name of the file.
+	Status int    `json:"status"`          // Status is the HTTP status the upload of the file alone would have.
+	Error  string `json:"error,omitempty"` // Error describes why the file wasn't uploaded, if it wasn't.
+}
+
+// Upload handles the upload of a batch of files, given either as several uploadFile form files, each one with
+// the Id form value in the same position, or as a ZIP archive in the archive form file, whose files are
+// uploaded with consecutive IDs starting at the FirstId form value. Folders of the archive are ignored. Every
+// file is limited to the maximum size of a single upload.
+// The ExpiresAt and TTL form values apply to every file, like in StorageController.Upload, and the batch is
+// made all-or-nothing with the atomic form value set to true.
+// It returns the result of every file, in order, with a 201 status if all of them were uploaded and a 207 one
+// otherwise. The files that weren't uploaded only because another file of an atomic batch failed have a 424
+// status.
+func (c *BatchController) Upload(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	req.Body = http.MaxBytesReader(w, req.Body, maxBatchSize)
+	if err := req.ParseMultipartForm(10 << 20); err != nil {
+		return c.common.ParseError(
+			req.Context(),
+			req,
+			w,
+			fmt.Errorf("%w: the batch is too big, the maximum size is 100MB", errs.ErrInvalidInput),
+		)
+	}
+	defer req.MultipartForm.RemoveAll()
+
+	atomic, err := parseBool(req.FormValue("atomic"))
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	expiresAt, err := parseExpiry(req.FormValue("ExpiresAt"), req.FormValue("TTL"), time.Now())
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	files, err := c.parseBatch(req.MultipartForm)
+	defer closeBatch(files)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	for i := range files {
+		files[i].ExpiresAt = expiresAt
+	}
+
+	status := http.StatusCreated
+	results := make([]batchResult, len(files))
+	for i, err := range c.storageservice.UploadBatch(req.Context(), files, atomic) {
+		results[i] = batchResult{ID: files[i].Id, Name: path.Base(files[i].Filename), Status: http.StatusCreated}
+		if err == nil {
+			continue
+		}
+		status = http.StatusMultiStatus
+		if errors.Is(err, storageservice.ErrBatchAborted) {
+			results[i].Status, results[i].Error = http.StatusFailedDependency, err.Error()
+			continue
+		}
+		httpErr := mapDomainErrorToHTTP(err)
+		results[i].Status, results[i].Error = httpErr.Code, httpErr.Error()
+	}
+	return jsonResponse(status, map[string]any{"results": results})
+}
+
+// parseBatch extracts the files of a batch from the multipart form, either from the uploadFile form files or
+// from the archive form file. It returns an ErrInvalidInput error if the batch is malformed, empty or over the
+// maximum number of files. The files returned must be closed even if there's an error.
+func (c *BatchController) parseBatch(form *multipart.Form) ([]storageservice.UploadData, error) {
+	uploaded, archives := form.File["uploadFile"], form.File["archive"]
+	var files []storageservice.UploadData
+	var err error
+	switch {
+	case len(uploaded) > 0 && len(archives) > 0:
+		return nil, fmt.Errorf("%w: either uploadFile or archive must be given, not both", errs.ErrInvalidInput)
+	case len(archives) > 1:
+		return nil, fmt.Errorf("%w: only one archive can be given", errs.ErrInvalidInput)
+	case len(uploaded) == 0 && len(archives) == 0:
+		return nil, fmt.Errorf("%w: the batch has no files", errs.ErrInvalidInput)
+	case len(archives) == 1:
+		files, err = expandArchive(archives[0], form.Value["FirstId"])
+	default:
+		files, err = openFiles(uploaded, form.Value["Id"])
+	}
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("%w: the batch has no files", errs.ErrInvalidInput)
+	}
+	if err == nil && len(files) > maxBatchFiles {
+		err = fmt.Errorf("%w: a batch can have up to %d files", errs.ErrInvalidInput, maxBatchFiles)
+	}
+	return files, err
+}
+
+// openFiles opens the uploaded files, along with their digests, identifying them by the IDs in the same
+// position.
+func openFiles(headers []*multipart.FileHeader, ids []string) ([]storageservice.UploadData, error) {
+	if len(ids) != len(headers) {
+		return nil, fmt.Errorf("%w: every uploadFile must have an Id", errs.ErrInvalidInput)
+	}
+	files := make([]storageservice.UploadData, 0, len(headers))
+	for i, header := range headers {
+		id, err := strconv.ParseInt(ids[i], 10, 64)
+		if err != nil {
+			return files, fmt.Errorf("%w: Id must be an integer, got %q", errs.ErrInvalidInput, ids[i])
+		}
+		digests, err := parseDigests(header.Header)
+		if err != nil {
+			return files, err
+		}
+		if header.Size > maxUploadSize {
+			return files, tooBig(header.Filename)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return files, fmt.Errorf("%w: could not read uploaded file %s", errs.ErrInvalidInput, header.Filename)
+		}
+		files = append(files, storageservice.UploadData{
+			File:     file,
+			Filename: header.Filename,
+			Id:       id,
+			Size:     header.Size,
+			Digests:  digests,
+		})
+	}
+	return files, nil
+}
+
+// expandArchive reads the files of the uploaded ZIP archive, skipping folders and the metadata macOS adds to
+// archives, identifying them by consecutive IDs starting at the only value of firstID. The files are decompressed
+// one at a time into temporary files, rather than into memory, up to the maximum size of a single upload each
+// and of a batch overall.
+func expandArchive(header *multipart.FileHeader, firstID []string) ([]storageservice.UploadData, error) {
+	if len(firstID) != 1 {
+		return nil, fmt.Errorf("%w: an archive must have exactly one FirstId", errs.ErrInvalidInput)
+	}
+	id, err := strconv.ParseInt(firstID[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: FirstId must be an integer", errs.ErrInvalidInput)
+	}
+	src, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read uploaded archive", errs.ErrInvalidInput)
+	}
+	defer src.Close()
+	archive, err := zip.NewReader(src, header.Size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the archive is not a valid ZIP file", errs.ErrInvalidInput)
+	}
+
+	var files []storageservice.UploadData
+	remaining := int64(maxBatchSize)
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || strings.HasPrefix(entry.Name, "__MACOSX/") {
+			continue
+		}
+		if len(files) == maxBatchFiles {
+			return files, fmt.Errorf("%w: a batch can have up to %d files", errs.ErrInvalidInput, maxBatchFiles)
+		}
+		file, size, err := spoolEntry(entry, remaining)
+		if err != nil {
+			return files, err
+		}
+		remaining -= size
+		files = append(files, storageservice.UploadData{
+			File:     file,
+			Filename: path.Base(entry.Name),
+			Id:       id,
+			Size:     size,
+		})
+		id++
+	}
+	return files, nil
+}
+
+// spoolEntry decompresses a file of an archive into a temporary file, removed once it's closed, returning it
+// along with its size. It returns an ErrInvalidInput error if the file is over the maximum size of an upload,
+// takes the content of the archive over remaining bytes or can't be decompressed.
+func spoolEntry(entry *zip.File, remaining int64) (multipart.File, int64, error) {
+	if entry.UncompressedSize64 > maxUploadSize {
+		return nil, 0, tooBig(entry.Name)
+	}
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: could not read %s from the archive", errs.ErrInvalidInput, entry.Name)
+	}
+	defer reader.Close()
+	file, err := os.CreateTemp("", "batch-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	spooled := tempFile{file}
+	limit := min(int64(maxUploadSize), remaining)
+	size, err := io.Copy(spooled, io.LimitReader(reader, limit+1))
+	switch {
+	case err != nil:
+		err = fmt.Errorf("%w: could not read %s from the archive", errs.ErrInvalidInput, entry.Name)
+	case size > remaining:
+		err = fmt.Errorf("%w: the content of the archive is over 100MB", errs.ErrInvalidInput)
+	case size > maxUploadSize:
+		err = tooBig(entry.Name)
+	}
+	if err == nil {
+		_, err = spooled.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
+		return nil, 0, err
+	}
+	return spooled, size, nil
+}
+
+// tooBig returns the error of a file of a batch over the maximum size of an upload.
+func tooBig(name string) error {
+	return fmt.Errorf("%w: %s is too big, the maximum file size is 10MB", errs.ErrInvalidInput, name)
+}
+
+// closeBatch closes the files of a batch.
+func closeBatch(files []storageservice.UploadData) {
+	for _, file := range files {
+		file.File.Close()
+	}
+}
+
+// parseBool parses an optional boolean form value, returning false if it's empty and an ErrInvalidInput error if
+// it's malformed.
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %q is not a boolean", errs.ErrInvalidInput, value)
+	}
+	return parsed, nil
+}
+
+// tempFile is a temporary file, removed once it's closed.
+type tempFile struct {
+	*os.File
+}
+
+// Close closes the file and removes it.
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
@@ -24,7 +24,7 @@ type CommonController struct{}
 // It uses the mapDomainErrorToHTTP function to convert domain-specific errors to HTTP errors,
 // encapsulating the process of translating backend errors into user-friendly HTTP responses.
 // It's important to take into account that if an error is not already defined into the domain-specific errors,
-// it will map the error as a internalServerError
+// it will map the error as a internalServerError. Validation errors also list their invalid fields.
 func (c *CommonController) ParseError(
 	ctx context.Context,
 	r *http.Request,
@@ -32,9 +32,14 @@ func (c *CommonController) ParseError(
 	err error,
 ) apitypes.Response {
 	httpErr := mapDomainErrorToHTTP(err)
+	content := map[string]any{"error": httpErr.Error()}
+	var validationErr *errs.ValidationError
+	if errors.As(err, &validationErr) {
+		content["fields"] = validationErr.Fields
+	}
 	return apitypes.Response{
 		Status:  httpErr.Code,
-		Content: map[string]any{"error": httpErr.Error()},
+		Content: content,
 		Headers: map[string]string{"Content-Type": "application/json"},
 	}
 }
@@ -50,6 +55,20 @@ func mapDomainErrorToHTTP(err error) errs.HTTPError {
 		return *errs.NewHTTPError(http.StatusInternalServerError, err.Error())
 	case errors.Is(err, errs.ErrNotFound):
 		return *errs.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, errs.ErrQuotaExceeded):
+		return *errs.NewHTTPError(http.StatusInsufficientStorage, err.Error())
+	case errors.Is(err, errs.ErrRetained):
+		return *errs.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, errs.ErrInfected):
+		return *errs.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, errs.ErrConflict):
+		return *errs.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, errs.ErrLocked):
+		return *errs.NewHTTPError(http.StatusLocked, err.Error())
+	case errors.Is(err, errs.ErrForbidden):
+		return *errs.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, errs.ErrUnavailable):
+		return *errs.NewHTTPError(http.StatusServiceUnavailable, err.Error())
 	default:
 		return *errs.NewHTTPError(http.StatusInternalServerError, "internal error")
 	}
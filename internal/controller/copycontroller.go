@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+)
+
+// CopyController manages the server-side copy of files to new IDs, and the move of files to other IDs or names.
+type CopyController struct {
+	logger         logging.Logger
+	storageservice storageservice.StorageService
+	common         CommonController
+}
+
+// NewCopyController creates a new instance of CopyController with the provided logger and storage service.
+func NewCopyController(logger logging.Logger, storageservice storageservice.StorageService) Controller {
+	return &CopyController{logger, storageservice, CommonController{}}
+}
+
+// Router defines the routes that the CopyController handles.
+// It sets up the routes for copying and moving single files.
+func (c *CopyController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/file/{id}/copy",
+			Method:  "POST",
+			Handler: c.Copy,
+		},
+		{
+			Path:    "/file/{id}/move",
+			Method:  "POST",
+			Handler: c.Move,
+		},
+	}
+}
+
+// copyRequest is the body of a request copying or moving a file.
+type copyRequest struct {
+	ID   *int64 `json:"id"`   // ID is the new ID of the file.
+	Name string `json:"name"` // Name is the new name of the file, if it changes.
+}
+
+// Copy handles the copy of a file based on its ID from the request's path variable to a new file of the ID in
+// the id field of the body, named after the name field, if set. The copy is returned.
+func (c *CopyController) Copy(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var request copyRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	if request.ID == nil {
+		err := fmt.Errorf("%w: the id of the copy is required", errs.ErrInvalidInput)
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	file, err := c.storageservice.Copy(req.Context(), id, *request.ID, request.Name)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusCreated, file)
+}
+
+// Move handles moving a file based on its ID from the request's path variable to the ID in the id field of the
+// body and renaming it to the name field. Either can be left unset to keep the current one. The moved file is
+// returned.
+func (c *CopyController) Move(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var request copyRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	newID := id
+	if request.ID != nil {
+		newID = *request.ID
+	}
+	file, err := c.storageservice.Move(req.Context(), id, newID, request.Name)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, file)
+}
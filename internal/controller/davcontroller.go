@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/dav"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+// DAVPrefix is the URL path prefix the WebDAV endpoint is served under.
+const DAVPrefix = "/dav"
+
+// davMethods are the HTTP methods the WebDAV endpoint handles.
+var davMethods = []string{
+	"OPTIONS", "GET", "HEAD", "PUT", "DELETE",
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// DAVController serves the folder tree over WebDAV, so it can be mounted as a network drive.
+// Its requests go through the same middlewares as the rest of the API, so they're authenticated the same way,
+// with the headers set by the gateway in front of the service.
+type DAVController struct {
+	logger logging.Logger
+	server *dav.Server
+}
+
+// NewDAVController creates a new instance of DAVController with the provided logger and WebDAV server, which
+// must be served under DAVPrefix.
+func NewDAVController(logger logging.Logger, server *dav.Server) Controller {
+	return &DAVController{logger, server}
+}
+
+// Router defines the routes that the DAVController handles.
+// It sets up a route for every WebDAV method, matching any path under DAVPrefix.
+func (c *DAVController) Router() apitypes.Router {
+	var router apitypes.Router
+	for _, method := range davMethods {
+		router = append(router, apitypes.Route{
+			Path:    DAVPrefix + "{path:(?:/.*)?}",
+			Method:  method,
+			Handler: c.Serve,
+		})
+	}
+	return router
+}
+
+// Serve handles a WebDAV request, which is written by the WebDAV server itself.
+func (c *DAVController) Serve(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	c.server.ServeHTTP(w, req)
+	return apitypes.Response{Written: true}
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/contextypes"
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/doctypes"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+// DocTypeController manages the document types, whose schemas the metadata of the documents of each type must
+// satisfy.
+type DocTypeController struct {
+	logger   logging.Logger
+	doctypes doctypes.Service
+	common   CommonController
+}
+
+// NewDocTypeController creates a new instance of DocTypeController with the provided logger and document type
+// service.
+func NewDocTypeController(logger logging.Logger, doctypes doctypes.Service) Controller {
+	return &DocTypeController{logger, doctypes, CommonController{}}
+}
+
+// Router defines the routes that the DocTypeController handles.
+// It sets up the routes for creating, listing, reading, replacing and deleting document types.
+func (c *DocTypeController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/types",
+			Method:  "POST",
+			Handler: c.Create,
+		},
+		{
+			Path:    "/types",
+			Method:  "GET",
+			Handler: c.List,
+		},
+		{
+			Path:    "/types/{name}",
+			Method:  "GET",
+			Handler: c.Get,
+		},
+		{
+			Path:    "/types/{name}",
+			Method:  "PUT",
+			Handler: c.Update,
+		},
+		{
+			Path:    "/types/{name}",
+			Method:  "DELETE",
+			Handler: c.Delete,
+		},
+	}
+}
+
+// Create handles the creation of the document type described by the body.
+func (c *DocTypeController) Create(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	var docType doctypes.DocumentType
+	if err := decodeJSON(req, &docType); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	docType, err := c.doctypes.CreateType(req.Context(), docType)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusCreated, docType)
+}
+
+// List handles the request of the document types.
+func (c *DocTypeController) List(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	types, err := c.doctypes.Types(req.Context())
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, types)
+}
+
+// Get handles the request of a document type based on its name from the request's path variable.
+func (c *DocTypeController) Get(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	docType, err := c.doctypes.Type(req.Context(), typeName(req))
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, docType)
+}
+
+// Update handles the replacement of the document type named in the request's path variable with the one
+// described by the body, whose name is ignored.
+func (c *DocTypeController) Update(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	var docType doctypes.DocumentType
+	if err := decodeJSON(req, &docType); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	docType.Name = typeName(req)
+	docType, err := c.doctypes.UpdateType(req.Context(), docType)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, docType)
+}
+
+// Delete handles the deletion of a document type based on its name from the request's path variable.
+func (c *DocTypeController) Delete(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	if err := c.doctypes.DeleteType(req.Context(), typeName(req)); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "Document type deleted successfully")
+}
+
+// typeName returns the name of the document type from the request's path variable.
+func typeName(req *http.Request) string {
+	name, _ := req.Context().Value(contextypes.ContextPathVarKey("name")).(string)
+	return name
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/contextypes"
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/folders"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+)
+
+// FolderController manages the folder tree and serves documents by their logical path.
+type FolderController struct {
+	logger         logging.Logger
+	folders        folders.Service
+	storageservice storageservice.StorageService
+	common         CommonController
+}
+
+// NewFolderController creates a new instance of FolderController with the provided logger, folder service and
+// storage service.
+func NewFolderController(
+	logger logging.Logger,
+	folders folders.Service,
+	storageservice storageservice.StorageService,
+) Controller {
+	return &FolderController{logger, folders, storageservice, CommonController{}}
+}
+
+// Router defines the routes that the FolderController handles.
+// It sets up the routes for managing folders, placing documents in them and resolving logical paths.
+func (c *FolderController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/folders",
+			Method:  "POST",
+			Handler: c.Create,
+		},
+		{
+			Path:    "/folders/{id}",
+			Method:  "GET",
+			Handler: c.List,
+		},
+		{
+			Path:    "/folders/{id}",
+			Method:  "PATCH",
+			Handler: c.Move,
+		},
+		{
+			Path:    "/folders/{id}",
+			Method:  "DELETE",
+			Handler: c.Delete,
+		},
+		{
+			Path:    "/file/{id}/location",
+			Method:  "PUT",
+			Handler: c.Place,
+		},
+		{
+			Path:    "/file/{id}/location",
+			Method:  "DELETE",
+			Handler: c.Unplace,
+		},
+		{
+			Path:    "/tree",
+			Method:  "GET",
+			Handler: c.Resolve,
+		},
+		{
+			Path:    "/tree/{path:.*}",
+			Method:  "GET",
+			Handler: c.Resolve,
+		},
+	}
+}
+
+// folderRequest is the body of a request creating or moving a folder.
+type folderRequest struct {
+	ParentID *int64  `json:"parentId"` // ParentID is the ID of the folder to create or move the folder in.
+	Name     *string `json:"name"`     // Name is the name of the folder.
+}
+
+// placeRequest is the body of a request placing a document in a folder.
+type placeRequest struct {
+	FolderID int64  `json:"folderId"` // FolderID is the ID of the folder, the root if it's not set.
+	Name     string `json:"name"`     // Name is the name of the document, the name it was uploaded with if empty.
+}
+
+// Create handles the creation of a folder, with the name field of the body, in the folder of the parentId
+// field, the root folder if it's not set.
+func (c *FolderController) Create(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	var request folderRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	parentID, name := folders.Root, ""
+	if request.ParentID != nil {
+		parentID = *request.ParentID
+	}
+	if request.Name != nil {
+		name = *request.Name
+	}
+	folder, err := c.folders.CreateFolder(req.Context(), parentID, name)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusCreated, folder)
+}
+
+// List handles the request of the content of a folder based on its ID from the request's path variable.
+// The root folder has ID 0.
+func (c *FolderController) List(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	listing, err := c.folders.List(req.Context(), id)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, listing)
+}
+
+// Move handles the renaming and moving of a folder based on its ID from the request's path variable.
+// The folder is moved to the folder of the parentId field of the body and renamed to its name field, keeping
+// its current parent or name for the fields that aren't set.
+func (c *FolderController) Move(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var request folderRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	listing, err := c.folders.List(req.Context(), id)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	parentID, name := listing.Folder.ParentID, listing.Folder.Name
+	if request.ParentID != nil {
+		parentID = *request.ParentID
+	}
+	if request.Name != nil {
+		name = *request.Name
+	}
+	folder, err := c.folders.MoveFolder(req.Context(), id, parentID, name)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, folder)
+}
+
+// Delete handles the deletion of a folder based on its ID from the request's path variable.
+// Only empty folders are deleted, unless the recursive query parameter is true, in which case the documents in
+// the folder are moved to the trash.
+func (c *FolderController) Delete(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	recursive, err := parseBool(req.URL.Query().Get("recursive"))
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	if err := c.folders.DeleteFolder(req.Context(), id, recursive); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "Folder deleted")
+}
+
+// Place handles the placement of a document, based on its ID from the request's path variable, in the folder
+// of the folderId field of the body, under its name field.
+func (c *FolderController) Place(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var request placeRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	document, err := c.folders.Place(req.Context(), id, request.FolderID, request.Name)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, document)
+}
+
+// Unplace handles taking a document, based on its ID from the request's path variable, out of its folder.
+// The document itself is kept.
+func (c *FolderController) Unplace(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	if err := c.folders.Unplace(req.Context(), id); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "Document taken out of its folder")
+}
+
+// Resolve handles the request of a logical path, such as /tree/contracts/2024/acme.pdf. If there's a document
+// at the path its content is returned, like in StorageController.Get, named after its name in the tree, and if
+// there's a folder, its content is listed.
+func (c *FolderController) Resolve(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	path, _ := req.Context().Value(contextypes.ContextPathVarKey("path")).(string)
+	node, err := c.folders.Resolve(req.Context(), path)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	if node.Folder != nil {
+		listing, err := c.folders.List(req.Context(), node.Folder.ID)
+		if err != nil {
+			return c.common.ParseError(req.Context(), req, w, err)
+		}
+		return jsonResponse(http.StatusOK, listing)
+	}
+	file, err := c.storageservice.Get(req.Context(), node.Document.ID)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	file.Name = node.Document.Name
+	return fileResponse(req, file)
+}
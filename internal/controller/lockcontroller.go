@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// defaultLockTTL is how long a file is checked out for when the request doesn't say.
+const defaultLockTTL = 8 * time.Hour
+
+// LockController manages the check-out and check-in of files, which lock them for a single user.
+type LockController struct {
+	logger         logging.Logger
+	storageservice storageservice.StorageService
+	admins         map[string]bool // admins are the users allowed to break the locks of others.
+	common         CommonController
+}
+
+// NewLockController creates a new instance of LockController with the provided logger, storage service and
+// IDs of the users allowed to break the locks of others.
+func NewLockController(
+	logger logging.Logger,
+	storageservice storageservice.StorageService,
+	admins []string,
+) Controller {
+	adminSet := make(map[string]bool, len(admins))
+	for _, admin := range admins {
+		adminSet[admin] = true
+	}
+	return &LockController{logger, storageservice, adminSet, CommonController{}}
+}
+
+// Router defines the routes that the LockController handles.
+// It sets up the routes for checking files out and in, releasing their locks and breaking them.
+func (c *LockController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/file/{id}/checkout",
+			Method:  "POST",
+			Handler: c.CheckOut,
+		},
+		{
+			Path:    "/file/{id}/checkout",
+			Method:  "DELETE",
+			Handler: c.Release,
+		},
+		{
+			Path:    "/file/{id}/checkin",
+			Method:  "POST",
+			Handler: c.CheckIn,
+		},
+		{
+			Path:    "/file/{id}/checkout/break",
+			Method:  "POST",
+			Handler: c.Break,
+		},
+	}
+}
+
+// checkOutRequest is the body of a request checking a file out.
+type checkOutRequest struct {
+	// TTL is how long the file is checked out for, a duration such as "90m" or a number of seconds.
+	TTL string `json:"ttl"`
+}
+
+// CheckOut handles the check-out of a file based on its ID from the request's path variable, locking it for
+// the request's user for the ttl field of the body, 8 hours if it's not set. While it's checked out, no one
+// else can replace nor delete the file. The file is returned along with its lock.
+func (c *LockController) CheckOut(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var request checkOutRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	ttl := defaultLockTTL
+	if request.TTL != "" {
+		now := time.Now()
+		until, err := parseExpiry("", request.TTL, now)
+		if err != nil {
+			return c.common.ParseError(req.Context(), req, w, err)
+		}
+		ttl = until.Sub(now)
+	}
+	file, err := c.storageservice.CheckOut(req.Context(), id, ttl)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, file)
+}
+
+// CheckIn handles the check-in of a file based on its ID from the request's path variable, which must be checked
+// out by the request's user. The new content is uploaded as the uploadFile form file, like in
+// StorageController.Replace, becoming a new version of the file, and its lock is released.
+func (c *LockController) CheckIn(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	uploadData, err := parseUploadedFile(req, w)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	uploadData.Id = id
+	if err := c.storageservice.CheckIn(req.Context(), uploadData); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "File checked in successfully")
+}
+
+// Release handles the release of the lock of a file based on its ID from the request's path variable, which
+// must be checked out by the request's user, leaving the file unchanged.
+func (c *LockController) Release(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	if err := c.storageservice.ReleaseLock(req.Context(), id, false); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "Lock released successfully")
+}
+
+// Break handles breaking the lock of a file based on its ID from the request's path variable, whoever checked
+// it out. Only admins can break locks.
+func (c *LockController) Break(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	if user := tenancy.UserFromContext(req.Context()); !c.admins[user] {
+		err := fmt.Errorf("%w: only admins can break the locks of files", errs.ErrForbidden)
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	if err := c.storageservice.ReleaseLock(req.Context(), id, true); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "Lock broken successfully")
+}
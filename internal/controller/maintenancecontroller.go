@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/maintenance"
+)
+
+// MaintenanceController exposes the results of the storage maintenance jobs.
+type MaintenanceController struct {
+	logger    logging.Logger
+	scrubber  *maintenance.Scrubber
+	collector *maintenance.Collector
+	common    CommonController
+}
+
+// NewMaintenanceController creates a new instance of MaintenanceController with the provided logger,
+// scrubber and garbage collector.
+func NewMaintenanceController(
+	logger logging.Logger,
+	scrubber *maintenance.Scrubber,
+	collector *maintenance.Collector,
+) Controller {
+	return &MaintenanceController{logger, scrubber, collector, CommonController{}}
+}
+
+// Router defines the routes that the MaintenanceController handles.
+// It sets up routes for reporting the findings of the latest scrubbing pass and garbage collection.
+func (c *MaintenanceController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/scrub/report",
+			Method:  "GET",
+			Handler: c.ScrubReport,
+		},
+		{
+			Path:    "/gc/report",
+			Method:  "GET",
+			Handler: c.GCReport,
+		},
+	}
+}
+
+// ScrubReport handles the request of the latest scrubbing report.
+// Only the findings that concern the request's tenant are returned.
+func (c *MaintenanceController) ScrubReport(
+	w http.ResponseWriter,
+	req *http.Request,
+) apitypes.Response {
+	report, err := c.scrubber.Report(req.Context())
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Content: report,
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
+
+// GCReport handles the request of the latest garbage collection report, including the bytes it reclaimed.
+// Only the blobs of the request's tenant are returned.
+func (c *MaintenanceController) GCReport(
+	w http.ResponseWriter,
+	req *http.Request,
+) apitypes.Response {
+	report, err := c.collector.Report(req.Context())
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Content: report,
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
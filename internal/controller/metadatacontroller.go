@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/contextypes"
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/metadata"
+)
+
+// MetadataController manages the tags and the key/value metadata of files.
+type MetadataController struct {
+	logger   logging.Logger
+	metadata metadata.Service
+	common   CommonController
+}
+
+// NewMetadataController creates a new instance of MetadataController with the provided logger and metadata
+// service.
+func NewMetadataController(logger logging.Logger, metadata metadata.Service) Controller {
+	return &MetadataController{logger, metadata, CommonController{}}
+}
+
+// Router defines the routes that the MetadataController handles.
+// It sets up the routes for reading, replacing, adding and removing the tags and the metadata of single files,
+// and for setting their document type.
+func (c *MetadataController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/file/{id}/labels",
+			Method:  "GET",
+			Handler: c.Get,
+		},
+		{
+			Path:    "/file/{id}/tags",
+			Method:  "PUT",
+			Handler: c.SetTags,
+		},
+		{
+			Path:    "/file/{id}/tags",
+			Method:  "POST",
+			Handler: c.AddTags,
+		},
+		{
+			Path:    "/file/{id}/tags/{tag}",
+			Method:  "DELETE",
+			Handler: c.RemoveTag,
+		},
+		{
+			Path:    "/file/{id}/metadata",
+			Method:  "PUT",
+			Handler: c.SetMetadata,
+		},
+		{
+			Path:    "/file/{id}/metadata",
+			Method:  "PATCH",
+			Handler: c.UpdateMetadata,
+		},
+		{
+			Path:    "/file/{id}/metadata/{key}",
+			Method:  "DELETE",
+			Handler: c.DeleteMetadata,
+		},
+		{
+			Path:    "/file/{id}/type",
+			Method:  "PUT",
+			Handler: c.SetType,
+		},
+	}
+}
+
+// tagsRequest is the body of a request setting or adding tags.
+type tagsRequest struct {
+	Tags []string `json:"tags"` // Tags are the tags to set or add.
+}
+
+// typeRequest is the body of a request setting the document type of a file.
+type typeRequest struct {
+	Type string `json:"type"` // Type is the name of the document type, empty for none.
+}
+
+// Get handles the request of the tags and the metadata of a file based on its ID from the request's path
+// variable.
+func (c *MetadataController) Get(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	labels, err := c.metadata.Get(req.Context(), id)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, labels)
+}
+
+// SetTags handles the replacement of the tags of a file with the tags field of the body.
+func (c *MetadataController) SetTags(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	return c.changeTags(w, req, c.metadata.SetTags)
+}
+
+// AddTags handles the addition of the tags field of the body to the tags of a file.
+func (c *MetadataController) AddTags(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	return c.changeTags(w, req, c.metadata.AddTags)
+}
+
+// RemoveTag handles the removal of the tag from the request's path variable from the tags of a file.
+func (c *MetadataController) RemoveTag(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	tag, _ := req.Context().Value(contextypes.ContextPathVarKey("tag")).(string)
+	labels, err := c.metadata.RemoveTag(req.Context(), id, tag)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, labels)
+}
+
+// SetMetadata handles the replacement of the metadata of a file with the body, a JSON object of string values.
+func (c *MetadataController) SetMetadata(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	return c.changeMetadata(w, req, c.metadata.SetMetadata)
+}
+
+// UpdateMetadata handles setting the keys of the body, a JSON object of string values, in the metadata of a
+// file, keeping its other keys.
+func (c *MetadataController) UpdateMetadata(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	return c.changeMetadata(w, req, c.metadata.UpdateMetadata)
+}
+
+// DeleteMetadata handles the removal of the key from the request's path variable from the metadata of a file.
+func (c *MetadataController) DeleteMetadata(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	key, _ := req.Context().Value(contextypes.ContextPathVarKey("key")).(string)
+	labels, err := c.metadata.DeleteMetadata(req.Context(), id, key)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, labels)
+}
+
+// SetType handles setting the document type of a file to the type field of the body. The metadata of the file
+// must satisfy the schema of the type.
+func (c *MetadataController) SetType(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var request typeRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	labels, err := c.metadata.SetType(req.Context(), id, request.Type)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, labels)
+}
+
+// changeTags changes the tags of the file identified by the request's path variable with the tags field of
+// the body, returning its labels.
+func (c *MetadataController) changeTags(
+	w http.ResponseWriter,
+	req *http.Request,
+	change func(ctx context.Context, id int64, tags []string) (metadata.Labels, error),
+) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var request tagsRequest
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	labels, err := change(req.Context(), id, request.Tags)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, labels)
+}
+
+// changeMetadata changes the metadata of the file identified by the request's path variable with the body,
+// returning its labels.
+func (c *MetadataController) changeMetadata(
+	w http.ResponseWriter,
+	req *http.Request,
+	change func(ctx context.Context, id int64, metadata map[string]string) (metadata.Labels, error),
+) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var request map[string]string
+	if err := decodeJSON(req, &request); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	labels, err := change(req.Context(), id, request)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, labels)
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pipeline"
+)
+
+// ProcessingController exposes the status of the post-processing of documents.
+type ProcessingController struct {
+	logger   logging.Logger
+	pipeline *pipeline.Pipeline
+	common   CommonController
+}
+
+// NewProcessingController creates a new instance of ProcessingController with the provided logger and pipeline.
+func NewProcessingController(logger logging.Logger, pipeline *pipeline.Pipeline) Controller {
+	return &ProcessingController{logger, pipeline, CommonController{}}
+}
+
+// Router defines the routes that the ProcessingController handles.
+// It sets up a single route for the processing status of a document.
+func (c *ProcessingController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/file/{id}/processing",
+			Method:  "GET",
+			Handler: c.Status,
+		},
+	}
+}
+
+// Status handles the request of the processing status of a document, stage by stage.
+func (c *ProcessingController) Status(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	status, err := c.pipeline.Status(req.Context(), id)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, status)
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// QuotaController exposes the storage usage of the tenants and users against their quotas.
+type QuotaController struct {
+	logger  logging.Logger
+	tracker quota.Tracker
+	common  CommonController
+}
+
+// NewQuotaController creates a new instance of QuotaController with the provided logger and quota tracker.
+func NewQuotaController(logger logging.Logger, tracker quota.Tracker) Controller {
+	return &QuotaController{logger, tracker, CommonController{}}
+}
+
+// Router defines the routes that the QuotaController handles.
+// It sets up a single route for reporting the current usage, using the HTTP GET method.
+func (c *QuotaController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/quota",
+			Method:  "GET",
+			Handler: c.Get,
+		},
+	}
+}
+
+// Get handles the request of the current quota usage.
+// It reports the usage of the request's tenant and, if the request identifies a user, of that user.
+func (c *QuotaController) Get(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	summary, err := c.tracker.Report(req.Context(), tenancy.UserFromContext(req.Context()))
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Content: summary,
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+)
+
+// RetentionController manages the retention of files: the retention set on single files, the retention rules
+// and the legal holds.
+type RetentionController struct {
+	logger    logging.Logger
+	retention retention.Service
+	common    CommonController
+}
+
+// NewRetentionController creates a new instance of RetentionController with the provided logger and
+// retention service.
+func NewRetentionController(logger logging.Logger, retention retention.Service) Controller {
+	return &RetentionController{logger, retention, CommonController{}}
+}
+
+// Router defines the routes that the RetentionController handles.
+// It sets up the routes for the retention and legal hold of single files, and for managing retention rules.
+func (c *RetentionController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/file/{id}/retention",
+			Method:  "GET",
+			Handler: c.Status,
+		},
+		{
+			Path:    "/file/{id}/retention",
+			Method:  "PUT",
+			Handler: c.SetRetention,
+		},
+		{
+			Path:    "/file/{id}/hold",
+			Method:  "PUT",
+			Handler: c.SetHold,
+		},
+		{
+			Path:    "/file/{id}/hold",
+			Method:  "DELETE",
+			Handler: c.ClearHold,
+		},
+		{
+			Path:    "/retention/rules",
+			Method:  "GET",
+			Handler: c.Rules,
+		},
+		{
+			Path:    "/retention/rules",
+			Method:  "POST",
+			Handler: c.AddRule,
+		},
+		{
+			Path:    "/retention/rules/{id}",
+			Method:  "DELETE",
+			Handler: c.RemoveRule,
+		},
+	}
+}
+
+// retentionRequest is the body of a request setting the retention of a file.
+type retentionRequest struct {
+	RetainUntil time.Time `json:"retainUntil"` // RetainUntil is an RFC 3339 time.
+}
+
+// holdRequest is the body of a request setting or clearing a legal hold.
+type holdRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Status handles the request of the retention status of a file, including the history of its legal hold.
+func (c *RetentionController) Status(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	status, err := c.retention.Status(req.Context(), id)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, status)
+}
+
+// SetRetention handles the request of retaining a file until a given time.
+func (c *RetentionController) SetRetention(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var body retentionRequest
+	if err := decodeJSON(req, &body); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	if err := c.retention.SetRetention(req.Context(), id, body.RetainUntil); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "Retention set successfully")
+}
+
+// SetHold handles the request of placing a file under a legal hold.
+func (c *RetentionController) SetHold(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	return c.changeHold(w, req, true)
+}
+
+// ClearHold handles the request of releasing a file from its legal hold.
+func (c *RetentionController) ClearHold(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	return c.changeHold(w, req, false)
+}
+
+// Rules handles the request of the retention rules.
+func (c *RetentionController) Rules(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	rules, err := c.retention.Rules(req.Context())
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, rules)
+}
+
+// AddRule handles the creation of a retention rule, returning it along with its ID.
+func (c *RetentionController) AddRule(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	var rule retention.Rule
+	if err := decodeJSON(req, &rule); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	rule, err := c.retention.AddRule(req.Context(), rule)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusCreated, rule)
+}
+
+// RemoveRule handles the removal of a retention rule based on its ID from the request's path variable.
+func (c *RetentionController) RemoveRule(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	if err := c.retention.RemoveRule(req.Context(), id); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "Retention rule removed successfully")
+}
+
+// changeHold sets or clears the legal hold of the file identified by the request's path variable.
+// The reason of the change can be given in the request's body.
+func (c *RetentionController) changeHold(
+	w http.ResponseWriter,
+	req *http.Request,
+	hold bool,
+) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	var body holdRequest
+	if err := decodeJSON(req, &body); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	if err := c.retention.SetLegalHold(req.Context(), id, hold, body.Reason); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	if hold {
+		return jsonResponse(http.StatusOK, "Legal hold set successfully")
+	}
+	return jsonResponse(http.StatusOK, "Legal hold cleared successfully")
+}
+
+// decodeJSON decodes the JSON body of the request into v. An empty body leaves v untouched.
+// It returns an ErrInvalidInput error if the body is malformed.
+func decodeJSON(req *http.Request, v any) error {
+	const maxBodySize = 1 << 20 // 1MB
+	err := json.NewDecoder(io.LimitReader(req.Body, maxBodySize)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: malformed request body: %s", errs.ErrInvalidInput, err.Error())
+	}
+	return nil
+}
+
+// badRequest builds the response to a request whose path variables are invalid.
+func badRequest(err error) apitypes.Response {
+	return apitypes.Response{
+		Status:  http.StatusBadRequest,
+		Content: map[string]string{"error": err.Error()},
+	}
+}
+
+// jsonResponse builds a response with the given status whose content is encoded as JSON.
+func jsonResponse(status int, content any) apitypes.Response {
+	return apitypes.Response{
+		Status:  status,
+		Content: content,
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/search"
+)
+
+// defaultSearchLimit is the number of hits returned by a search that doesn't set a limit.
+const defaultSearchLimit = 20
+
+// SearchController exposes the full-text search over the content of documents.
+type SearchController struct {
+	logger logging.Logger
+	engine *search.Engine
+	common CommonController
+}
+
+// NewSearchController creates a new instance of SearchController with the provided logger and search engine.
+func NewSearchController(logger logging.Logger, engine *search.Engine) Controller {
+	return &SearchController{logger, engine, CommonController{}}
+}
+
+// Router defines the routes that the SearchController handles.
+// It sets up a single route for searching documents.
+func (c *SearchController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/search",
+			Method:  "GET",
+			Handler: c.Search,
+		},
+	}
+}
+
+// Search handles the search of the documents of the request's tenant containing the words of the q query
+// parameter, returning their IDs and snippets of their content, the most relevant first. Only as many
+// documents as the limit parameter are returned.
+func (c *SearchController) Search(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	query := req.URL.Query()
+	limit := defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			return c.common.ParseError(
+				req.Context(),
+				req,
+				w,
+				fmt.Errorf("%w: limit must be a positive integer", errs.ErrInvalidInput),
+			)
+		}
+	}
+	hits, err := c.engine.Search(req.Context(), query.Get("q"), limit)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, hits)
+}
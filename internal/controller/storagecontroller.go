@@ -1,39 +1,50 @@
 package controller
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/textproto"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/lucastomic/dmsStorageService/internal/checksum"
 	"github.com/lucastomic/dmsStorageService/internal/contextypes"
 	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
 	"github.com/lucastomic/dmsStorageService/internal/errs"
-	"github.com/lucastomic/dmsStorageService/internal/fileutils"
 	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/metadata"
 	"github.com/lucastomic/dmsStorageService/internal/storageservice"
 )
 
+// maxUploadSize is the maximum size of an uploaded file, whether it's uploaded alone or in a batch.
+const maxUploadSize = 10 << 20 // 10MB
+
 // StorageController manages file upload and retrieve operations from the local storage.
-// It leverages a storage service for handling file storage and a common controller
-// for shared HTTP handling logic.
+// It leverages a storage service for handling file storage, a metadata service for querying files by their
+// tags and metadata, and a common controller for shared HTTP handling logic.
 type StorageController struct {
 	logger         logging.Logger
 	storageservice storageservice.StorageService
+	metadata       metadata.Service
 	common         CommonController
 }
 
-// New creates a new instance of StorageController with the provided logger and storage service.
-// It initializes a StorageController that is responsible for handling file upload requests.
+// New creates a new instance of StorageController with the provided logger, storage service and metadata
+// service. It initializes a StorageController that is responsible for handling file upload requests.
 func New(
 	logger logging.Logger,
 	storageservice storageservice.StorageService,
+	metadata metadata.Service,
 ) Controller {
-	return &StorageController{logger, storageservice, CommonController{}}
+	return &StorageController{logger, storageservice, metadata, CommonController{}}
 }
 
 // Router defines the routes that the StorageController handles.
-// Currently, it sets up a single route for file upload requests, using the HTTP POST method.
+// It sets up the routes for uploading, retrieving, replacing, listing and deleting files.
 func (c *StorageController) Router() apitypes.Router {
 	return []apitypes.Route{
 		{
@@ -46,6 +57,21 @@ func (c *StorageController) Router() apitypes.Router {
 			Method:  "GET",
 			Handler: c.Get,
 		},
+		{
+			Path:    "/file/{id}",
+			Method:  "PUT",
+			Handler: c.Replace,
+		},
+		{
+			Path:    "/files",
+			Method:  "GET",
+			Handler: c.List,
+		},
+		{
+			Path:    "/file/{id}",
+			Method:  "DELETE",
+			Handler: c.Delete,
+		},
 	}
 }
 
@@ -71,8 +97,33 @@ func (c *StorageController) Upload(
 	}
 }
 
+// Replace handles the replacement of the content of a file based on its ID from the request's path variable.
+// The new content is uploaded as the uploadFile form file, like in Upload.
+func (c *StorageController) Replace(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	uploadData, err := parseUploadedFile(req, w)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	uploadData.Id = id
+	if err := c.storageservice.Replace(req.Context(), uploadData); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Content: "File replaced successfully",
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
+
 // Get handles the retrieval of a file based on its ID from the request's path variable.
 // It validates the presence and type of the ID, retrieves the file, and returns it in the HTTP response.
+// If the file is stored compressed and the client accepts its encoding, the compressed content is returned
+// as is, with the corresponding Content-Encoding, unless a range of the content was requested.
+// Otherwise, the SHA-256 checksum of the content is returned in the Digest and Repr-Digest headers.
 // On failure, it constructs an appropriate error response.
 func (c *StorageController) Get(w http.ResponseWriter, req *http.Request) apitypes.Response {
 	id, err := extractIDFromRequest(req)
@@ -86,17 +137,93 @@ func (c *StorageController) Get(w http.ResponseWriter, req *http.Request) apityp
 	if err != nil {
 		return c.common.ParseError(req.Context(), req, w, err)
 	}
-	contentType, err := fileutils.DetermineMIME(file)
+	return fileResponse(req, file)
+}
+
+// fileResponse builds the response serving the content of the file, compressed if it's stored compressed and
+// the request accepts its encoding and doesn't ask for a range, as described in StorageController.Get.
+func fileResponse(req *http.Request, file storageservice.File) apitypes.Response {
+	headers := map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%s", file.Name),
+		"Content-Type":        file.ContentType,
+		"Vary":                "Accept-Encoding",
+	}
+	var content any = file
+	if file.Encoding != "" && req.Header.Get("Range") == "" && acceptsEncoding(req, file.Encoding) {
+		headers["Content-Encoding"] = file.Encoding
+		content = file.Encoded()
+	} else if file.Checksum.SHA256 != "" {
+		headers["Digest"] = checksum.Digest(file.Checksum)
+		headers["Repr-Digest"] = checksum.ReprDigest(file.Checksum)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Headers: headers,
+		Content: content,
+	}
+}
+
+// List handles the request of the files that aren't in the trash.
+// Files can be filtered with the query parameters contentType, owner and name, as described by
+// storageservice.Filter, by their tags with the tags query parameter, a boolean expression such as
+// "invoice AND NOT draft", and by their metadata with meta query parameters, conditions such as
+// "amount>=1000", as described by metadata.ParseQuery.
+func (c *StorageController) List(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	query := req.URL.Query()
+	filter := storageservice.Filter{
+		ContentType: query.Get("contentType"),
+		Owner:       query.Get("owner"),
+		Name:        query.Get("name"),
+	}
+	if err := filter.Validate(); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	labelQuery, err := metadata.ParseQuery(query.Get("tags"), query["meta"])
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	files, err := c.storageservice.List(req.Context())
 	if err != nil {
 		return c.common.ParseError(req.Context(), req, w, err)
 	}
+	files = filter.Apply(files)
+	if !labelQuery.Empty() {
+		matching, err := c.metadata.Find(req.Context(), labelQuery)
+		if err != nil {
+			return c.common.ParseError(req.Context(), req, w, err)
+		}
+		labeled := []storageservice.FileInfo{}
+		for _, file := range files {
+			if matching[file.ID] {
+				labeled = append(labeled, file)
+			}
+		}
+		files = labeled
+	}
 	return apitypes.Response{
-		Status: http.StatusOK,
-		Headers: map[string]string{
-			"Content-Disposition": fmt.Sprintf("attachment; filename=%s", file.Name()),
-			"Content-Type":        contentType,
-		},
-		Content: file,
+		Status:  http.StatusOK,
+		Content: files,
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
+
+// Delete handles the deletion of a file based on its ID from the request's path variable.
+// It validates the presence and type of the ID, moves the file to the trash, and returns a confirmation message.
+func (c *StorageController) Delete(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return apitypes.Response{
+			Status:  http.StatusBadRequest,
+			Content: map[string]string{"error": err.Error()},
+		}
+	}
+	if err := c.storageservice.Delete(req.Context(), id); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Content: "File moved to the trash",
+		Headers: map[string]string{"Content-Type": "application/json"},
 	}
 }
 
@@ -104,27 +231,102 @@ func (c *StorageController) Get(w http.ResponseWriter, req *http.Request) apityp
 // for the file upload, such as ensuring the file size is within limits and extracting file metadata.
 // It returns structured upload data or an error if validation fails.
 // It checks the request is not over the maximum size and the form values Id and uploadfile exist.
+// Digests of the file can be supplied in the Content-Digest and Content-MD5 headers of the uploadFile part.
+// The file can be set to expire with either the ExpiresAt form value, an RFC 3339 time, or the TTL form value,
+// a duration such as "90m" or a number of seconds.
+// The document type of the file can be given in the Type form value, and its metadata, as a JSON object of
+// strings, in the Metadata form value.
 func (c *StorageController) parseAndValidateUploadReq(
 	req *http.Request,
 	w http.ResponseWriter,
 ) (storageservice.UploadData, error) {
-	const maxUploadSize = 10 << 20 // 10MB
-	req.Body = http.MaxBytesReader(w, req.Body, maxUploadSize)
+	uploadData, err := parseUploadedFile(req, w)
+	if err != nil {
+		return storageservice.UploadData{}, err
+	}
 
-	if err := req.ParseMultipartForm(maxUploadSize); err != nil {
+	id, err := strconv.ParseInt(req.FormValue("Id"), 10, 64)
+	if err != nil {
 		return storageservice.UploadData{}, fmt.Errorf(
 			"%w:%s",
 			errs.ErrInvalidInput,
-			"The uploaded file is too big. Maximum file size is 10MB.",
+			"Id must be an integer.",
 		)
 	}
+	uploadData.Id = id
 
-	id, err := strconv.ParseInt(req.FormValue("Id"), 10, 64)
+	uploadData.ExpiresAt, err = parseExpiry(req.FormValue("ExpiresAt"), req.FormValue("TTL"), time.Now())
+	if err != nil {
+		return storageservice.UploadData{}, err
+	}
+	uploadData.Type = req.FormValue("Type")
+	uploadData.Metadata, err = parseMetadata(req.FormValue("Metadata"))
 	if err != nil {
+		return storageservice.UploadData{}, err
+	}
+	return uploadData, nil
+}
+
+// parseMetadata parses the metadata of an upload, a JSON object of strings, returning nil if it's empty, and
+// an ErrInvalidInput error if it's malformed or invalid.
+func parseMetadata(value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, fmt.Errorf("%w: Metadata must be a JSON object of strings", errs.ErrInvalidInput)
+	}
+	if err := metadata.ValidateMetadata(parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// parseExpiry parses the expiry of an upload, given either as an RFC 3339 time or as a time-to-live from now.
+// It returns a zero time if neither is given, and an ErrInvalidInput error if both are given, either is
+// malformed, the expiry isn't in the future or the TTL is too long to be represented.
+func parseExpiry(expiresAt string, ttl string, now time.Time) (time.Time, error) {
+	switch {
+	case expiresAt != "" && ttl != "":
+		return time.Time{}, fmt.Errorf("%w: only one of ExpiresAt and TTL can be given", errs.ErrInvalidInput)
+	case expiresAt != "":
+		expiry, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: ExpiresAt must be an RFC 3339 time", errs.ErrInvalidInput)
+		}
+		if !expiry.After(now) {
+			return time.Time{}, fmt.Errorf("%w: ExpiresAt must be in the future", errs.ErrInvalidInput)
+		}
+		return expiry, nil
+	case ttl != "":
+		duration, err := time.ParseDuration(ttl)
+		if seconds, intErr := strconv.ParseInt(ttl, 10, 64); intErr == nil {
+			// Longer TTLs would overflow the duration.
+			if maxSeconds := int64(math.MaxInt64 / time.Second); seconds > maxSeconds {
+				return time.Time{}, fmt.Errorf("%w: TTL can be up to %d seconds", errs.ErrInvalidInput, maxSeconds)
+			}
+			duration, err = time.Duration(seconds)*time.Second, nil
+		}
+		if err != nil || duration <= 0 {
+			return time.Time{}, fmt.Errorf("%w: TTL must be a positive duration or number of seconds", errs.ErrInvalidInput)
+		}
+		return now.Add(duration), nil
+	default:
+		return time.Time{}, nil
+	}
+}
+
+// parseUploadedFile parses the multipart form of the request, checking it's not over the maximum size,
+// and extracts the uploadFile form file along with its digests. The ID of the returned data is left unset.
+func parseUploadedFile(req *http.Request, w http.ResponseWriter) (storageservice.UploadData, error) {
+	req.Body = http.MaxBytesReader(w, req.Body, maxUploadSize)
+
+	if err := req.ParseMultipartForm(maxUploadSize); err != nil {
 		return storageservice.UploadData{}, fmt.Errorf(
 			"%w:%s",
 			errs.ErrInvalidInput,
-			"Id must be an integer.",
+			"The uploaded file is too big. Maximum file size is 10MB.",
 		)
 	}
 
@@ -138,13 +340,51 @@ func (c *StorageController) parseAndValidateUploadReq(
 	}
 	defer file.Close()
 
+	digests, err := parseDigests(fileHeader.Header)
+	if err != nil {
+		return storageservice.UploadData{}, err
+	}
+
 	return storageservice.UploadData{
 		File:     file,
 		Filename: fileHeader.Filename,
-		Id:       id,
+		Size:     fileHeader.Size,
+		Digests:  digests,
 	}, nil
 }
 
+// parseDigests parses the Content-Digest and Content-MD5 headers of an uploaded file, if present.
+func parseDigests(header textproto.MIMEHeader) (checksum.Digests, error) {
+	digests := make(checksum.Digests)
+	if contentDigest := header.Get("Content-Digest"); contentDigest != "" {
+		parsed, err := checksum.ParseContentDigest(contentDigest)
+		if err != nil {
+			return nil, err
+		}
+		digests = parsed
+	}
+	if contentMD5 := header.Get("Content-MD5"); contentMD5 != "" {
+		digest, err := checksum.ParseContentMD5(contentMD5)
+		if err != nil {
+			return nil, err
+		}
+		digests[checksum.MD5] = digest
+	}
+	return digests, nil
+}
+
+// acceptsEncoding reports whether the request's Accept-Encoding header accepts the given content coding.
+func acceptsEncoding(req *http.Request, encoding string) bool {
+	for _, accepted := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
 // extractIDFromRequest extracts and parses the ID path variable from the request context.
 // Returns the parsed ID as int64 or an error if the ID is missing or not an integer.
 func extractIDFromRequest(req *http.Request) (int64, error) {
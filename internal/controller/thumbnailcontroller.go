@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/thumbnail"
+)
+
+// ThumbnailController serves the thumbnails generated for images.
+type ThumbnailController struct {
+	logger    logging.Logger
+	generator *thumbnail.Generator
+	common    CommonController
+}
+
+// NewThumbnailController creates a new instance of ThumbnailController with the provided logger and thumbnail
+// generator.
+func NewThumbnailController(logger logging.Logger, generator *thumbnail.Generator) Controller {
+	return &ThumbnailController{logger, generator, CommonController{}}
+}
+
+// Router defines the routes that the ThumbnailController handles.
+// It sets up a single route for the thumbnails of a file.
+func (c *ThumbnailController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/file/{id}/thumbnail",
+			Method:  "GET",
+			Handler: c.Get,
+		},
+	}
+}
+
+// Get handles the retrieval of the thumbnail of an image based on its ID from the request's path variable.
+// The size of the thumbnail is set with the size query parameter, and defaults to the smallest one.
+func (c *ThumbnailController) Get(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	size := c.generator.Sizes()[0]
+	if value := req.URL.Query().Get("size"); value != "" {
+		if size, err = strconv.Atoi(value); err != nil {
+			return c.common.ParseError(
+				req.Context(),
+				req,
+				w,
+				fmt.Errorf("%w: size must be an integer", errs.ErrInvalidInput),
+			)
+		}
+	}
+	thumbnail, err := c.generator.Open(req.Context(), id, size)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Headers: map[string]string{"Content-Type": thumbnail.ContentType},
+		Content: thumbnail,
+	}
+}
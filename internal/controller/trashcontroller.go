@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+)
+
+// TrashController manages the files that have been deleted, which stay in the trash until they are
+// restored or purged.
+type TrashController struct {
+	logger         logging.Logger
+	storageservice storageservice.StorageService
+	common         CommonController
+}
+
+// NewTrashController creates a new instance of TrashController with the provided logger and storage service.
+func NewTrashController(
+	logger logging.Logger,
+	storageservice storageservice.StorageService,
+) Controller {
+	return &TrashController{logger, storageservice, CommonController{}}
+}
+
+// Router defines the routes that the TrashController handles.
+// It sets up the routes for listing the trash, restoring files from it and purging them.
+func (c *TrashController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/trash",
+			Method:  "GET",
+			Handler: c.List,
+		},
+		{
+			Path:    "/trash/{id}/restore",
+			Method:  "POST",
+			Handler: c.Restore,
+		},
+		{
+			Path:    "/trash/{id}",
+			Method:  "DELETE",
+			Handler: c.Purge,
+		},
+	}
+}
+
+// List handles the request of the files in the trash.
+func (c *TrashController) List(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	files, err := c.storageservice.Trash(req.Context())
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Content: files,
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
+
+// Restore handles the restoration of a file from the trash based on its ID from the request's path variable.
+func (c *TrashController) Restore(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return apitypes.Response{
+			Status:  http.StatusBadRequest,
+			Content: map[string]string{"error": err.Error()},
+		}
+	}
+	if err := c.storageservice.Restore(req.Context(), id); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Content: "File restored successfully",
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
+
+// Purge handles the permanent removal of a file in the trash based on its ID from the request's path variable.
+func (c *TrashController) Purge(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return apitypes.Response{
+			Status:  http.StatusBadRequest,
+			Content: map[string]string{"error": err.Error()},
+		}
+	}
+	if err := c.storageservice.Purge(req.Context(), id); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return apitypes.Response{
+		Status:  http.StatusOK,
+		Content: "File purged successfully",
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/lucastomic/dmsStorageService/internal/contextypes"
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+)
+
+// VersionController manages the versions of the content of files, kept every time a file is replaced or
+// checked in.
+type VersionController struct {
+	logger         logging.Logger
+	storageservice storageservice.StorageService
+	common         CommonController
+}
+
+// NewVersionController creates a new instance of VersionController with the provided logger and storage service.
+func NewVersionController(logger logging.Logger, storageservice storageservice.StorageService) Controller {
+	return &VersionController{logger, storageservice, CommonController{}}
+}
+
+// Router defines the routes that the VersionController handles.
+// It sets up the routes for listing the versions of a file and retrieving one of them.
+func (c *VersionController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/file/{id}/versions",
+			Method:  "GET",
+			Handler: c.List,
+		},
+		{
+			Path:    "/file/{id}/versions/{version}",
+			Method:  "GET",
+			Handler: c.Get,
+		},
+	}
+}
+
+// List handles the request of the versions of a file based on its ID from the request's path variable, oldest
+// first, the current one being the last.
+func (c *VersionController) List(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	versions, err := c.storageservice.Versions(req.Context(), id)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, versions)
+}
+
+// Get handles the retrieval of a version of a file based on its ID and the number of the version from the
+// request's path variables. The version is served like StorageController.Get serves the current one.
+func (c *VersionController) Get(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	param, _ := req.Context().Value(contextypes.ContextPathVarKey("version")).(string)
+	version, err := strconv.Atoi(param)
+	if err != nil || version < 1 {
+		return badRequest(errors.New("version param type is invalid"))
+	}
+	file, err := c.storageservice.GetVersion(req.Context(), id, version)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return fileResponse(req, file)
+}
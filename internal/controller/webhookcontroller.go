@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/controller/apitypes"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/webhook"
+)
+
+// WebhookController manages the webhook subscriptions notified of document events, and their dead letters.
+type WebhookController struct {
+	logger     logging.Logger
+	dispatcher *webhook.Dispatcher
+	common     CommonController
+}
+
+// NewWebhookController creates a new instance of WebhookController with the provided logger and dispatcher.
+func NewWebhookController(logger logging.Logger, dispatcher *webhook.Dispatcher) Controller {
+	return &WebhookController{logger, dispatcher, CommonController{}}
+}
+
+// Router defines the routes that the WebhookController handles.
+// It sets up routes for registering, listing and removing subscriptions, and for listing and redelivering
+// dead letters.
+func (c *WebhookController) Router() apitypes.Router {
+	return []apitypes.Route{
+		{
+			Path:    "/webhooks",
+			Method:  "GET",
+			Handler: c.Subscriptions,
+		},
+		{
+			Path:    "/webhooks",
+			Method:  "POST",
+			Handler: c.Register,
+		},
+		{
+			Path:    "/webhooks/deadletters",
+			Method:  "GET",
+			Handler: c.DeadLetters,
+		},
+		{
+			Path:    "/webhooks/deadletters/{id}/redeliver",
+			Method:  "POST",
+			Handler: c.Redeliver,
+		},
+		{
+			Path:    "/webhooks/{id}",
+			Method:  "DELETE",
+			Handler: c.Unregister,
+		},
+	}
+}
+
+// Subscriptions handles the request of the webhook subscriptions, whose secrets are withheld.
+func (c *WebhookController) Subscriptions(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	subscriptions, err := c.dispatcher.Subscriptions(req.Context())
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, subscriptions)
+}
+
+// Register handles the creation of a webhook subscription, returning it along with its ID and its secret,
+// which isn't disclosed again.
+func (c *WebhookController) Register(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	var subscription webhook.Subscription
+	if err := decodeJSON(req, &subscription); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	subscription, err := c.dispatcher.Register(req.Context(), subscription)
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusCreated, subscription)
+}
+
+// Unregister handles the removal of a webhook subscription based on its ID from the request's path variable.
+func (c *WebhookController) Unregister(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	if err := c.dispatcher.Unregister(req.Context(), id); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, "Webhook removed successfully")
+}
+
+// DeadLetters handles the request of the deliveries that ran out of attempts.
+func (c *WebhookController) DeadLetters(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	deliveries, err := c.dispatcher.DeadLetters(req.Context())
+	if err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusOK, deliveries)
+}
+
+// Redeliver handles the request of attempting a dead-lettered delivery again.
+func (c *WebhookController) Redeliver(w http.ResponseWriter, req *http.Request) apitypes.Response {
+	id, err := extractIDFromRequest(req)
+	if err != nil {
+		return badRequest(err)
+	}
+	if err := c.dispatcher.Redeliver(req.Context(), id); err != nil {
+		return c.common.ParseError(req.Context(), req, w, err)
+	}
+	return jsonResponse(http.StatusAccepted, "Delivery scheduled")
+}
@@ -0,0 +1,108 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/lucastomic/dmsStorageService/internal/folders"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"golang.org/x/net/webdav"
+)
+
+// maxBodySize is the maximum size of the body of a request, and so of a file written through WebDAV.
+const maxBodySize = 100 << 20 // 100MB
+
+// Server serves the folder tree of every tenant over WebDAV, so it can be mounted as a network drive.
+// Folders are collections and the documents placed in them are files, read, written and deleted through the
+// storage service. Locks are kept in memory, apart for every tenant, and only bind WebDAV clients.
+type Server struct {
+	logger logging.Logger
+	prefix string
+	fs     *fileSystem
+	mu     sync.Mutex                   // mu guards locks.
+	locks  map[string]webdav.LockSystem // locks are the lock systems of every tenant.
+}
+
+// NewServer creates a new Server, served under the given URL path prefix, of the given folder tree, whose
+// documents are described by the path service and read and written through the storage service.
+func NewServer(
+	logger logging.Logger,
+	prefix string,
+	folders folders.Service,
+	pathsrv pathservice.PathService,
+	storage storageservice.StorageService,
+) *Server {
+	return &Server{
+		logger: logger,
+		prefix: prefix,
+		fs:     &fileSystem{logger: logger, folders: folders, pathsrv: pathsrv, storage: storage},
+		locks:  make(map[string]webdav.LockSystem),
+	}
+}
+
+// ServeHTTP serves a WebDAV request on the tree of the tenant found in the context of the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, err := tenancy.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body := &trackingBody{ReadCloser: http.MaxBytesReader(w, r.Body, maxBodySize)}
+	r.Body = body
+	r = r.WithContext(context.WithValue(r.Context(), bodyKey{}, body))
+	handler := &webdav.Handler{
+		Prefix:     s.prefix,
+		FileSystem: s.fs,
+		LockSystem: s.lockSystem(tenant),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !os.IsNotExist(err) {
+				s.logger.Error(r.Context(), "Failed to serve WebDAV %s of %s: %s", r.Method, r.URL.Path, err.Error())
+			}
+		},
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// lockSystem returns the lock system of the tenant, creating it if needed.
+func (s *Server) lockSystem(tenant string) webdav.LockSystem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	locks, found := s.locks[tenant]
+	if !found {
+		locks = webdav.NewMemLS()
+		s.locks[tenant] = locks
+	}
+	return locks
+}
+
+// bodyKey is the context key of the body of a WebDAV request.
+type bodyKey struct{}
+
+// trackingBody records whether reading the body of a request failed, so a file written from a body that was
+// cut short isn't stored, as the WebDAV handler closes the files it writes regardless.
+type trackingBody struct {
+	io.ReadCloser
+	failed bool
+}
+
+// Read reads from the body, recording any failure.
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		b.failed = true
+	}
+	return n, err
+}
+
+// bodyFailed reports whether reading the body of the request of the context failed.
+func bodyFailed(ctx context.Context) bool {
+	body, ok := ctx.Value(bodyKey{}).(*trackingBody)
+	return ok && body.failed
+}
@@ -0,0 +1,114 @@
+package dav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/folders"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// newTestServer returns a WebDAV server over a temporary storage, along with a function sending it requests
+// on behalf of the tenant "acme", which return the status and the body of the response.
+func newTestServer(t *testing.T) (*Server, func(method, path, body string, headers ...string) (int, string)) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	storage := storageservice.New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, paths),
+		compression.None,
+		scanning.Policy{},
+	)
+	server := NewServer(logger, "/dav", folders.New(logger, paths, storage), paths, storage)
+	send := func(method, path, body string, headers ...string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		req = req.WithContext(tenancy.WithTenant(context.Background(), "acme"))
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		content, _ := io.ReadAll(recorder.Body)
+		return recorder.Code, string(content)
+	}
+	return server, send
+}
+
+func TestServer(t *testing.T) {
+	_, send := newTestServer(t)
+	if status, _ := send("MKCOL", "/dav/contracts", ""); status != http.StatusCreated {
+		t.Fatalf("Expected the folder to be created, got %d", status)
+	}
+	if status, _ := send("MKCOL", "/dav/missing/2024", ""); status != http.StatusConflict {
+		t.Errorf("Expected a conflict creating a folder in a missing one, got %d", status)
+	}
+	if status, _ := send("PUT", "/dav/contracts/acme.txt", "first draft"); status != http.StatusCreated {
+		t.Fatalf("Expected the document to be created, got %d", status)
+	}
+	if status, _ := send("PUT", "/dav/contracts/acme.txt", "final version"); status != http.StatusCreated {
+		t.Fatalf("Expected the document to be replaced, got %d", status)
+	}
+	if status, body := send("GET", "/dav/contracts/acme.txt", ""); status != http.StatusOK || body != "final version" {
+		t.Errorf("Expected the content of the document, got %d %q", status, body)
+	}
+
+	status, body := send("PROPFIND", "/dav/contracts", "", "Depth", "1")
+	if status != http.StatusMultiStatus ||
+		!strings.Contains(body, "/dav/contracts/acme.txt") ||
+		!strings.Contains(body, "<D:getcontentlength>13</D:getcontentlength>") {
+		t.Errorf("Expected the document to be listed, got %d %s", status, body)
+	}
+
+	status, _ = send("COPY", "/dav/contracts/acme.txt", "", "Destination", "http://example.com/dav/copy.txt")
+	if status != http.StatusCreated {
+		t.Fatalf("Expected the document to be copied, got %d", status)
+	}
+	status, _ = send("MOVE", "/dav/contracts", "", "Destination", "http://example.com/dav/deals")
+	if status != http.StatusCreated {
+		t.Fatalf("Expected the folder to be moved, got %d", status)
+	}
+	if status, body := send("GET", "/dav/deals/acme.txt", ""); status != http.StatusOK || body != "final version" {
+		t.Errorf("Expected the document to move along with its folder, got %d %q", status, body)
+	}
+	if status, body := send("GET", "/dav/copy.txt", ""); status != http.StatusOK || body != "final version" {
+		t.Errorf("Expected the copy to have the content of the document, got %d %q", status, body)
+	}
+
+	if status, _ := send("DELETE", "/dav/deals", ""); status != http.StatusNoContent {
+		t.Fatalf("Expected the folder to be deleted, got %d", status)
+	}
+	if status, _ := send("GET", "/dav/deals/acme.txt", ""); status != http.StatusNotFound {
+		t.Errorf("Expected the documents of the deleted folder to be gone, got %d", status)
+	}
+}
+
+func TestLocks(t *testing.T) {
+	_, send := newTestServer(t)
+	send("PUT", "/dav/acme.txt", "draft")
+	lock := `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+	if status, _ := send("LOCK", "/dav/acme.txt", lock); status != http.StatusOK {
+		t.Fatalf("Expected the document to be locked, got %d", status)
+	}
+	if status, _ := send("PUT", "/dav/acme.txt", "overwritten"); status != http.StatusLocked {
+		t.Errorf("Expected writing a locked document without its token to fail, got %d", status)
+	}
+}
@@ -0,0 +1,261 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/folders"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"golang.org/x/net/webdav"
+)
+
+// fileInfo describes a folder or a document. Documents carry their content type and checksum, so the WebDAV
+// handler doesn't read their content to find them out.
+type fileInfo struct {
+	name        string
+	size        int64
+	modTime     time.Time
+	dir         bool
+	contentType string
+	etag        string
+}
+
+// folderInfo describes a folder.
+func folderInfo(folder folders.Folder) fileInfo {
+	info := fileInfo{name: folder.Name, dir: true}
+	if folder.CreatedAt != nil {
+		info.modTime = *folder.CreatedAt
+	}
+	return info
+}
+
+// Name returns the name of the folder or the document.
+func (i fileInfo) Name() string {
+	return i.name
+}
+
+// Size returns the size of the document's content in bytes.
+func (i fileInfo) Size() int64 {
+	return i.size
+}
+
+// Mode returns the permissions of the folder or the document, read-write for everyone.
+func (i fileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o777
+	}
+	return 0o666
+}
+
+// ModTime returns the time the folder was created or the document uploaded.
+func (i fileInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+// IsDir reports whether it describes a folder.
+func (i fileInfo) IsDir() bool {
+	return i.dir
+}
+
+// Sys returns nil, as there's no underlying data source.
+func (i fileInfo) Sys() any {
+	return nil
+}
+
+// ContentType returns the MIME type of the document.
+func (i fileInfo) ContentType(ctx context.Context) (string, error) {
+	if i.contentType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return i.contentType, nil
+}
+
+// ETag returns the SHA-256 checksum of the document's content as its ETag.
+func (i fileInfo) ETag(ctx context.Context) (string, error) {
+	if i.etag == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + i.etag + `"`, nil
+}
+
+// directory is a folder opened for listing its content.
+type directory struct {
+	ctx     context.Context
+	fs      *fileSystem
+	folder  folders.Folder
+	entries []fs.FileInfo // entries are the folders and documents in the folder, once listed.
+	read    int           // read is the number of entries already returned by Readdir.
+}
+
+// Readdir returns the next count entries of the folder, or all the remaining ones if count isn't positive.
+func (d *directory) Readdir(count int) ([]fs.FileInfo, error) {
+	if d.entries == nil {
+		listing, err := d.fs.folders.List(d.ctx, d.folder.ID)
+		if err != nil {
+			return nil, pathError("readdir", d.folder.Path, err)
+		}
+		d.entries = []fs.FileInfo{}
+		for _, folder := range listing.Folders {
+			d.entries = append(d.entries, folderInfo(folder))
+		}
+		for _, document := range listing.Documents {
+			info, err := d.fs.documentInfo(d.ctx, document)
+			if errors.Is(err, errs.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, pathError("readdir", document.Path, err)
+			}
+			d.entries = append(d.entries, info)
+		}
+	}
+	remaining := d.entries[d.read:]
+	if count > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(remaining) {
+		remaining = remaining[:count]
+	}
+	d.read += len(remaining)
+	return remaining, nil
+}
+
+// Stat describes the folder.
+func (d *directory) Stat() (fs.FileInfo, error) {
+	return folderInfo(d.folder), nil
+}
+
+// Read fails, as folders have no content.
+func (d *directory) Read([]byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.folder.Path, Err: fs.ErrInvalid}
+}
+
+// Seek fails, as folders have no content.
+func (d *directory) Seek(int64, int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: d.folder.Path, Err: fs.ErrInvalid}
+}
+
+// Write fails, as folders have no content.
+func (d *directory) Write([]byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.folder.Path, Err: fs.ErrInvalid}
+}
+
+// Close does nothing, as there's nothing to release.
+func (d *directory) Close() error {
+	return nil
+}
+
+// reader is a document opened for reading.
+type reader struct {
+	storageservice.File
+	info fileInfo
+}
+
+// Readdir fails, as documents aren't folders.
+func (r *reader) Readdir(int) ([]fs.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: r.info.name, Err: fs.ErrInvalid}
+}
+
+// Stat describes the document.
+func (r *reader) Stat() (fs.FileInfo, error) {
+	return r.info, nil
+}
+
+// Write fails, as the document was opened for reading.
+func (r *reader) Write([]byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: r.info.name, Err: fs.ErrPermission}
+}
+
+// writer is a document opened for writing. What's written is kept in a temporary file until the writer is
+// closed, when it's uploaded as a new document or replaces the content of the existing one.
+type writer struct {
+	*os.File
+	ctx      context.Context
+	fs       *fileSystem
+	folderID int64             // folderID is the ID of the folder the document is in.
+	name     string            // name is the name of the document in the folder.
+	existing *folders.Document // existing is the document written, nil if it's a new one.
+}
+
+// newWriter opens a document for writing, the existing one or, if it's nil, a new one with the given name
+// in the folder.
+func newWriter(
+	ctx context.Context,
+	fsys *fileSystem,
+	folderID int64,
+	name string,
+	existing *folders.Document,
+) (*writer, error) {
+	temp, err := os.CreateTemp("", "dav-*")
+	if err != nil {
+		return nil, err
+	}
+	return &writer{File: temp, ctx: ctx, fs: fsys, folderID: folderID, name: name, existing: existing}, nil
+}
+
+// Readdir fails, as documents aren't folders.
+func (w *writer) Readdir(int) ([]fs.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: w.name, Err: fs.ErrInvalid}
+}
+
+// Stat describes the document as written so far.
+func (w *writer) Stat() (fs.FileInfo, error) {
+	stat, err := w.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{name: w.name, size: stat.Size(), modTime: time.Now()}, nil
+}
+
+// Close stores what was written, unless the body of the request it was written from was cut short, and
+// removes the temporary file.
+func (w *writer) Close() error {
+	defer os.Remove(w.File.Name())
+	defer w.File.Close()
+	if bodyFailed(w.ctx) {
+		return fmt.Errorf("%w: the content of %s was cut short", errs.ErrInvalidInput, w.name)
+	}
+	stat, err := w.File.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := w.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data := storageservice.UploadData{File: w.File, Size: stat.Size()}
+	if w.existing != nil {
+		record, err := w.fs.pathsrv.GetPath(w.ctx, w.existing.ID)
+		if err != nil {
+			return pathError("write", w.name, err)
+		}
+		data.Id, data.Filename = w.existing.ID, record.Name
+		return pathError("write", w.name, w.fs.storage.Replace(w.ctx, data))
+	}
+	return pathError("write", w.name, w.create(data))
+}
+
+// create uploads the data as a new document, with an ID allocated by the repository, so concurrent creations
+// never take the same one, and places it in the folder. If it can't be placed, it's moved to the trash.
+func (w *writer) create(data storageservice.UploadData) error {
+	id, err := w.fs.pathsrv.NextID(w.ctx)
+	if err != nil {
+		return err
+	}
+	data.Id, data.Filename = id, w.name
+	if err := w.fs.storage.Upload(w.ctx, data); err != nil {
+		return err
+	}
+	if _, err := w.fs.folders.Place(w.ctx, id, w.folderID, w.name); err != nil {
+		if deleteErr := w.fs.storage.Delete(w.ctx, id); deleteErr != nil {
+			w.fs.logger.Error(w.ctx, "Failed to trash document %d that couldn't be placed: %s", id, deleteErr.Error())
+		}
+		return err
+	}
+	return nil
+}
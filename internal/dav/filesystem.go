@@ -0,0 +1,178 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/folders"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"golang.org/x/net/webdav"
+)
+
+// fileSystem implements webdav.FileSystem over the folder tree of the tenant found in the context.
+// Documents that aren't placed in the tree aren't exposed.
+type fileSystem struct {
+	logger  logging.Logger
+	folders folders.Service
+	pathsrv pathservice.PathService
+	storage storageservice.StorageService
+}
+
+// Mkdir creates a folder.
+func (f *fileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	parentID, base, err := f.parent(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = f.folders.CreateFolder(ctx, parentID, base)
+	return pathError("mkdir", name, err)
+}
+
+// OpenFile opens a folder or a document. Documents opened for writing, which are created if they don't exist
+// and the flags allow it, are stored when they're closed, their whole content replaced by what was written.
+func (f *fileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	writing := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+	node, err := f.folders.Resolve(ctx, name)
+	switch {
+	case errors.Is(err, errs.ErrNotFound) && flag&os.O_CREATE != 0:
+		parentID, base, err := f.parent(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return newWriter(ctx, f, parentID, base, nil)
+	case err != nil:
+		return nil, pathError("open", name, err)
+	case node.Folder != nil && writing:
+		return nil, pathError("open", name, fmt.Errorf("%w: %s is a folder", errs.ErrInvalidInput, name))
+	case node.Folder != nil:
+		return &directory{ctx: ctx, fs: f, folder: *node.Folder}, nil
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, pathError("open", name, fmt.Errorf("%w: %s already exists", errs.ErrConflict, name))
+	case writing:
+		return newWriter(ctx, f, node.Document.FolderID, node.Document.Name, node.Document)
+	}
+	info, err := f.documentInfo(ctx, *node.Document)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	file, err := f.storage.Get(ctx, node.Document.ID)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	return &reader{File: file, info: info}, nil
+}
+
+// RemoveAll moves the document to the trash, or deletes the folder, moving the documents in it to the trash.
+// Either way, the documents are taken out of the tree, so their names can be used again right away.
+// Like os.RemoveAll, it returns nil if there's nothing to remove.
+func (f *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	node, err := f.folders.Resolve(ctx, name)
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		return nil
+	case err != nil:
+		return pathError("remove", name, err)
+	case node.Folder != nil && node.Folder.ID == folders.Root:
+		return &os.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	case node.Folder != nil:
+		return pathError("remove", name, f.folders.DeleteFolder(ctx, node.Folder.ID, true))
+	}
+	if err := f.storage.Delete(ctx, node.Document.ID); err != nil {
+		return pathError("remove", name, err)
+	}
+	if err := f.folders.Unplace(ctx, node.Document.ID); err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return pathError("remove", name, err)
+	}
+	return nil
+}
+
+// Rename moves a folder, along with everything in it, or a document.
+func (f *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	node, err := f.folders.Resolve(ctx, oldName)
+	if err != nil {
+		return pathError("rename", oldName, err)
+	}
+	parentID, base, err := f.parent(ctx, newName)
+	if err != nil {
+		return err
+	}
+	switch {
+	case node.Folder != nil && node.Folder.ID == folders.Root:
+		return &os.PathError{Op: "rename", Path: oldName, Err: fs.ErrPermission}
+	case node.Folder != nil:
+		_, err = f.folders.MoveFolder(ctx, node.Folder.ID, parentID, base)
+	default:
+		_, err = f.folders.Place(ctx, node.Document.ID, parentID, base)
+	}
+	return pathError("rename", oldName, err)
+}
+
+// Stat describes a folder or a document.
+func (f *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	node, err := f.folders.Resolve(ctx, name)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	if node.Folder != nil {
+		return folderInfo(*node.Folder), nil
+	}
+	info, err := f.documentInfo(ctx, *node.Document)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	return info, nil
+}
+
+// parent returns the ID of the folder the file of the given name is in, along with the name of the file in it.
+// It returns an error satisfying os.IsNotExist if the folder doesn't exist.
+func (f *fileSystem) parent(ctx context.Context, name string) (int64, string, error) {
+	dir, base := path.Split(path.Clean("/" + name))
+	node, err := f.folders.Resolve(ctx, dir)
+	if err == nil && node.Folder == nil {
+		err = fmt.Errorf("%w: %s is not a folder", errs.ErrNotFound, dir)
+	}
+	if err != nil {
+		return 0, "", pathError("open", dir, err)
+	}
+	return node.Folder.ID, base, nil
+}
+
+// documentInfo describes the document placed in the tree.
+func (f *fileSystem) documentInfo(ctx context.Context, document folders.Document) (fileInfo, error) {
+	record, err := f.pathsrv.GetPath(ctx, document.ID)
+	if err != nil {
+		return fileInfo{}, err
+	}
+	return fileInfo{
+		name:        document.Name,
+		size:        record.Size,
+		modTime:     record.CreatedAt,
+		contentType: record.ContentType,
+		etag:        record.SHA256,
+	}, nil
+}
+
+// pathError translates the errors of the services into those of the os package the WebDAV handler understands,
+// so missing files are reported as such, or returns nil if err is nil.
+func pathError(op string, name string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errs.ErrNotFound):
+		return &os.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case errors.Is(err, errs.ErrConflict):
+		return &os.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	case errors.Is(err, errs.ErrRetained), errors.Is(err, errs.ErrLocked):
+		return &os.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	case errors.Is(err, errs.ErrInvalidInput):
+		return &os.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return err
+}
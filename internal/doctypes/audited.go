@@ -0,0 +1,50 @@
+package doctypes
+
+import (
+	"context"
+
+	"github.com/lucastomic/dmsStorageService/internal/audit"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+// NewAudited wraps a Service so every change of the document types, successful or not, is recorded in the
+// audit log. Failing to record a change doesn't fail it, but it's logged.
+func NewAudited(service Service, log audit.Log, logger logging.Logger) Service {
+	return &auditedService{service, log, logger}
+}
+
+// auditedService implements the Service interface, recording the changes made through the wrapped one.
+type auditedService struct {
+	Service
+	log    audit.Log
+	logger logging.Logger
+}
+
+// CreateType creates the document type and records the change. Types aren't tied to a document, so it's
+// recorded with ID 0.
+func (s *auditedService) CreateType(ctx context.Context, docType DocumentType) (DocumentType, error) {
+	docType, err := s.Service.CreateType(ctx, docType)
+	s.record(ctx, audit.ActionCreateDocType, err)
+	return docType, err
+}
+
+// UpdateType updates the document type and records the change.
+func (s *auditedService) UpdateType(ctx context.Context, docType DocumentType) (DocumentType, error) {
+	docType, err := s.Service.UpdateType(ctx, docType)
+	s.record(ctx, audit.ActionUpdateDocType, err)
+	return docType, err
+}
+
+// DeleteType deletes the document type and records the change.
+func (s *auditedService) DeleteType(ctx context.Context, name string) error {
+	err := s.Service.DeleteType(ctx, name)
+	s.record(ctx, audit.ActionDeleteDocType, err)
+	return err
+}
+
+// record records a change in the audit log, logging any failure.
+func (s *auditedService) record(ctx context.Context, action string, err error) {
+	if auditErr := s.log.Record(ctx, action, 0, err); auditErr != nil {
+		s.logger.Error(ctx, "Failed to audit %s: %s", action, auditErr.Error())
+	}
+}
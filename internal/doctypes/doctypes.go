@@ -0,0 +1,76 @@
+package doctypes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+// namePattern restricts the names of document types to lowercase letters, digits, "_" and "-".
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Validator checks the metadata of documents against the schema of their document type.
+type Validator interface {
+	// Validate returns an errs.ValidationError listing the fields of the metadata not satisfying the schema of
+	// the given document type, or nil if it does. Documents without a type have free-form metadata, so it
+	// returns nil for an empty type. It returns an ErrInvalidInput error if there's no such type.
+	Validate(ctx context.Context, docType string, metadata map[string]string) error
+}
+
+// Service manages the document types of every tenant, such as "invoice" or "contract", and validates the
+// metadata of documents against their schemas. The tenant is always taken from the context.
+type Service interface {
+	Validator
+
+	// CreateType creates a document type.
+	// It returns an ErrConflict error if there's already a type with the same name.
+	CreateType(ctx context.Context, docType DocumentType) (DocumentType, error)
+
+	// Types returns the document types, sorted by name.
+	Types(ctx context.Context) ([]DocumentType, error)
+
+	// Type returns the document type of the given name.
+	// It returns an ErrNotFound error if there's no such type.
+	Type(ctx context.Context, name string) (DocumentType, error)
+
+	// UpdateType replaces the description and the schema of a document type. The documents of the type aren't
+	// validated again, only their next uploads and metadata changes are.
+	// It returns an ErrNotFound error if there's no such type.
+	UpdateType(ctx context.Context, docType DocumentType) (DocumentType, error)
+
+	// DeleteType deletes the document type of the given name.
+	// It returns an ErrConflict error if any document is of that type, or an ErrNotFound error if there's no
+	// such type.
+	DeleteType(ctx context.Context, name string) error
+}
+
+// DocumentType is a class of documents whose metadata satisfies a schema.
+type DocumentType struct {
+	Name        string          `json:"name"`                  // Name identifies the type, e.g. "invoice".
+	Description string          `json:"description,omitempty"` // Description describes the type.
+	Schema      json.RawMessage `json:"schema"`                // Schema is the JSON Schema of the metadata.
+}
+
+// Parse checks the name and the schema of the document type, returning the schema parsed, or an
+// ErrInvalidInput error if either is invalid.
+func (t DocumentType) Parse() (*Schema, error) {
+	if err := ValidateName(t.Name); err != nil {
+		return nil, err
+	}
+	if len(t.Schema) == 0 {
+		return nil, fmt.Errorf("%w: document type %q has no schema", errs.ErrInvalidInput, t.Name)
+	}
+	return ParseSchema(t.Schema)
+}
+
+// ValidateName checks the name of a document type is 1-64 lowercase letters, digits, "_" or "-", starting with
+// a letter or a digit, returning an ErrInvalidInput error otherwise.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q is not a valid document type name", errs.ErrInvalidInput, name)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package doctypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+// Types of the values of metadata fields. Metadata values are always strings, so the type says what they must
+// parse as.
+const (
+	typeString  = "string"
+	typeNumber  = "number"
+	typeInteger = "integer"
+	typeBoolean = "boolean"
+)
+
+// Schema is the JSON Schema the metadata of the documents of a type must satisfy. As metadata is a flat object
+// of string values, only a subset of JSON Schema is supported: an object schema whose properties have a type,
+// string by default, along with "enum", "pattern", "minLength", "maxLength", "format" ("date" or "date-time"),
+// "minimum", "maximum", "exclusiveMinimum" and "exclusiveMaximum". Any other keyword is rejected, rather than
+// silently ignored.
+type Schema struct {
+	SchemaURI            string              `json:"$schema,omitempty"`
+	ID                   string              `json:"$id,omitempty"`
+	Title                string              `json:"title,omitempty"`
+	Description          string              `json:"description,omitempty"`
+	Type                 string              `json:"type,omitempty"` // Type must be "object" if it's set.
+	Properties           map[string]Property `json:"properties,omitempty"`
+	Required             []string            `json:"required,omitempty"`
+	AdditionalProperties *bool               `json:"additionalProperties,omitempty"` // They're allowed if unset.
+}
+
+// Property is the schema of a single metadata field.
+type Property struct {
+	Title            string   `json:"title,omitempty"`
+	Description      string   `json:"description,omitempty"`
+	Type             string   `json:"type,omitempty"`
+	Enum             []any    `json:"enum,omitempty"`
+	Pattern          string   `json:"pattern,omitempty"`
+	MinLength        *int     `json:"minLength,omitempty"`
+	MaxLength        *int     `json:"maxLength,omitempty"`
+	Format           string   `json:"format,omitempty"`
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+
+	pattern *regexp.Regexp // pattern is Pattern compiled.
+}
+
+// ParseSchema parses and checks a schema, returning an ErrInvalidInput error if it's malformed or uses
+// keywords that aren't supported.
+func ParseSchema(raw json.RawMessage) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	var schema Schema
+	if err := decoder.Decode(&schema); err != nil {
+		return nil, fmt.Errorf("%w: malformed or unsupported schema: %s", errs.ErrInvalidInput, err.Error())
+	}
+	if schema.Type != "" && schema.Type != "object" {
+		return nil, fmt.Errorf("%w: the schema must be of type object", errs.ErrInvalidInput)
+	}
+	for _, field := range schema.Required {
+		if _, found := schema.Properties[field]; !found && schema.AdditionalProperties != nil &&
+			!*schema.AdditionalProperties {
+			return nil, fmt.Errorf("%w: required field %q isn't allowed by the schema", errs.ErrInvalidInput, field)
+		}
+	}
+	for name, property := range schema.Properties {
+		if err := property.compile(); err != nil {
+			return nil, fmt.Errorf("%w: property %q: %s", errs.ErrInvalidInput, name, err.Error())
+		}
+		schema.Properties[name] = property
+	}
+	return &schema, nil
+}
+
+// compile checks the property and compiles its pattern.
+func (p *Property) compile() error {
+	switch p.Type {
+	case "":
+		p.Type = typeString
+	case typeString, typeNumber, typeInteger, typeBoolean:
+	default:
+		return fmt.Errorf("type %q isn't supported", p.Type)
+	}
+	switch p.Format {
+	case "", "date", "date-time":
+	default:
+		return fmt.Errorf("format %q isn't supported", p.Format)
+	}
+	if p.Pattern != "" {
+		pattern, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return fmt.Errorf("malformed pattern: %s", err.Error())
+		}
+		p.pattern = pattern
+	}
+	for _, value := range p.Enum {
+		if _, err := p.parse(enumValue(value)); err != nil {
+			return fmt.Errorf("enum value %v isn't a %s", value, p.Type)
+		}
+	}
+	return nil
+}
+
+// Validate returns an errs.ValidationError listing every field of the metadata not satisfying the schema,
+// sorted by name, or nil if the metadata satisfies it.
+func (s *Schema) Validate(metadata map[string]string) error {
+	var problems []errs.FieldError
+	for _, field := range s.Required {
+		if _, found := metadata[field]; !found {
+			problems = append(problems, errs.FieldError{Field: field, Message: "is required"})
+		}
+	}
+	for field, value := range metadata {
+		property, found := s.Properties[field]
+		switch {
+		case found:
+			if err := property.check(value); err != nil {
+				problems = append(problems, errs.FieldError{Field: field, Message: err.Error()})
+			}
+		case s.AdditionalProperties != nil && !*s.AdditionalProperties:
+			problems = append(problems, errs.FieldError{Field: field, Message: "is not allowed"})
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Field < problems[j].Field })
+	return &errs.ValidationError{Fields: problems}
+}
+
+// check returns an error describing why the value doesn't satisfy the property, or nil if it does.
+func (p *Property) check(value string) error {
+	parsed, err := p.parse(value)
+	if err != nil {
+		return err
+	}
+	if len(p.Enum) > 0 && !p.inEnum(parsed) {
+		return fmt.Errorf("must be one of %s", p.enumList())
+	}
+	length := utf8.RuneCountInString(value)
+	switch {
+	case p.MinLength != nil && length < *p.MinLength:
+		return fmt.Errorf("must be at least %d characters long", *p.MinLength)
+	case p.MaxLength != nil && length > *p.MaxLength:
+		return fmt.Errorf("must be at most %d characters long", *p.MaxLength)
+	case p.pattern != nil && !p.pattern.MatchString(value):
+		return fmt.Errorf("must match %s", p.Pattern)
+	}
+	if number, ok := parsed.(float64); ok {
+		switch {
+		case p.Minimum != nil && number < *p.Minimum:
+			return fmt.Errorf("must be at least %v", *p.Minimum)
+		case p.Maximum != nil && number > *p.Maximum:
+			return fmt.Errorf("must be at most %v", *p.Maximum)
+		case p.ExclusiveMinimum != nil && number <= *p.ExclusiveMinimum:
+			return fmt.Errorf("must be greater than %v", *p.ExclusiveMinimum)
+		case p.ExclusiveMaximum != nil && number >= *p.ExclusiveMaximum:
+			return fmt.Errorf("must be less than %v", *p.ExclusiveMaximum)
+		}
+	}
+	return nil
+}
+
+// parse parses the value as the type and format of the property, returning a float64 for numbers and
+// integers, a bool for booleans and the value itself for strings.
+func (p *Property) parse(value string) (any, error) {
+	switch p.Type {
+	case typeNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return number, nil
+	case typeInteger:
+		integer, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return float64(integer), nil
+	case typeBoolean:
+		if value != "true" && value != "false" {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return value == "true", nil
+	}
+	switch p.Format {
+	case "date":
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			return nil, fmt.Errorf("must be a date such as 2024-03-01")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return nil, fmt.Errorf("must be an RFC 3339 time such as 2024-03-01T09:00:00Z")
+		}
+	}
+	return value, nil
+}
+
+// inEnum reports whether the parsed value is one of the values of the enum.
+func (p *Property) inEnum(parsed any) bool {
+	for _, value := range p.Enum {
+		if allowed, err := p.parse(enumValue(value)); err == nil && allowed == parsed {
+			return true
+		}
+	}
+	return false
+}
+
+// enumList lists the values of the enum.
+func (p *Property) enumList() string {
+	values := make([]string, len(p.Enum))
+	for i, value := range p.Enum {
+		values[i] = enumValue(value)
+	}
+	return strings.Join(values, ", ")
+}
+
+// enumValue formats a value of an enum as a metadata value. Numbers are decoded from JSON as float64, so they're
+// formatted without an exponent, as 1000000 rather than 1e+06.
+func enumValue(value any) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
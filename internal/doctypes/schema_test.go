@@ -0,0 +1,80 @@
+package doctypes
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+const invoiceSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"properties": {
+		"amount": {"type": "number", "exclusiveMinimum": 0},
+		"vendor": {"type": "string", "minLength": 2},
+		"currency": {"enum": ["EUR", "USD"]},
+		"due": {"format": "date"},
+		"paid": {"type": "boolean"}
+	},
+	"required": ["amount", "vendor"],
+	"additionalProperties": false
+}`
+
+func TestSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(invoiceSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := map[string]string{"amount": "120.50", "vendor": "Acme", "currency": "EUR", "due": "2024-03-01"}
+	if err := schema.Validate(valid); err != nil {
+		t.Errorf("Expected valid metadata to pass, got: %v", err)
+	}
+
+	err = schema.Validate(map[string]string{"amount": "0", "currency": "GBP", "due": "01/03/2024", "po": "7"})
+	var validationErr *errs.ValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("Expected a ValidationError wrapping ErrInvalidInput, got: %v", err)
+	}
+	fields := []string{}
+	for _, field := range validationErr.Fields {
+		fields = append(fields, field.Field)
+	}
+	if want := []string{"amount", "currency", "due", "po", "vendor"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Expected every invalid field, sorted, to be listed, got: %v", validationErr.Fields)
+	}
+}
+
+func TestMalformedSchema(t *testing.T) {
+	for _, schema := range []string{
+		`{"type": "array"}`,
+		`{"properties": {"amount": {"type": "object"}}}`,
+		`{"properties": {"amount": {"format": "email"}}}`,
+		`{"properties": {"code": {"pattern": "("}}}`,
+		`{"properties": {"amount": {"type": "integer", "enum": ["one"]}}}`,
+		`{"properties": {"amount": {"multipleOf": 2}}}`,
+		`{"oneOf": []}`,
+		`{"required": ["vendor"], "additionalProperties": false}`,
+		`not json`,
+	} {
+		if _, err := ParseSchema([]byte(schema)); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput parsing %s, got: %v", schema, err)
+		}
+	}
+}
+
+func TestLargeNumberEnum(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"properties": {"limit": {"type": "integer", "enum": [1000000, 5000000]}}}`))
+	if err != nil {
+		t.Fatalf("Expected an enum of large integers to be accepted, got: %v", err)
+	}
+	if err := schema.Validate(map[string]string{"limit": "1000000"}); err != nil {
+		t.Errorf("Expected a value of the enum to pass, got: %v", err)
+	}
+	err = schema.Validate(map[string]string{"limit": "2000000"})
+	var validationErr *errs.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Fields[0].Message != "must be one of 1000000, 5000000" {
+		t.Errorf("Expected the enum to be listed as written, got: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package doctypes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// New returns a Service that keeps the document types in memory, and looks up the documents of a type in the
+// path records.
+func New(logger logging.Logger, pathsrv pathservice.PathService) Service {
+	return &service{
+		logger:  logger,
+		pathsrv: pathsrv,
+		types:   make(map[string]map[string]entry),
+	}
+}
+
+// entry is a document type along with its schema, parsed.
+type entry struct {
+	docType DocumentType
+	schema  *Schema
+}
+
+// service implements the Service interface.
+type service struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	mu      sync.Mutex                  // mu guards the types.
+	types   map[string]map[string]entry // types are the document types of every tenant, by name.
+}
+
+// Validate validates the metadata against the schema of the document type.
+func (s *service) Validate(ctx context.Context, docType string, metadata map[string]string) error {
+	if docType == "" {
+		return nil
+	}
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	entry, found := s.types[tenant][docType]
+	s.mu.Unlock()
+	if !found {
+		return fmt.Errorf("%w: unknown document type %q", errs.ErrInvalidInput, docType)
+	}
+	return entry.schema.Validate(metadata)
+}
+
+// CreateType checks the document type and adds it to the types of the tenant.
+func (s *service) CreateType(ctx context.Context, docType DocumentType) (DocumentType, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return DocumentType{}, err
+	}
+	schema, err := docType.Parse()
+	if err != nil {
+		return DocumentType{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.types[tenant][docType.Name]; found {
+		return DocumentType{}, fmt.Errorf("%w: document type %q already exists", errs.ErrConflict, docType.Name)
+	}
+	if s.types[tenant] == nil {
+		s.types[tenant] = make(map[string]entry)
+	}
+	s.types[tenant][docType.Name] = entry{docType, schema}
+	return docType, nil
+}
+
+// Types returns the document types of the tenant, sorted by name.
+func (s *service) Types(ctx context.Context) ([]DocumentType, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	types := make([]DocumentType, 0, len(s.types[tenant]))
+	for _, entry := range s.types[tenant] {
+		types = append(types, entry.docType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types, nil
+}
+
+// Type returns the document type of the tenant with the given name.
+func (s *service) Type(ctx context.Context, name string) (DocumentType, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return DocumentType{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, found := s.types[tenant][name]
+	if !found {
+		return DocumentType{}, notFound(name)
+	}
+	return entry.docType, nil
+}
+
+// UpdateType checks the document type and replaces the one of the tenant with the same name.
+func (s *service) UpdateType(ctx context.Context, docType DocumentType) (DocumentType, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return DocumentType{}, err
+	}
+	schema, err := docType.Parse()
+	if err != nil {
+		return DocumentType{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.types[tenant][docType.Name]; !found {
+		return DocumentType{}, notFound(docType.Name)
+	}
+	s.types[tenant][docType.Name] = entry{docType, schema}
+	return docType, nil
+}
+
+// DeleteType removes the document type from the types of the tenant, unless a document of the tenant, in the
+// trash or not, is of that type.
+func (s *service) DeleteType(ctx context.Context, name string) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.types[tenant][name]; !found {
+		return notFound(name)
+	}
+	entries, err := s.pathsrv.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Record.Type == name {
+			return fmt.Errorf(
+				"%w: document type %q is used by the file with ID %d",
+				errs.ErrConflict,
+				name,
+				entry.ID,
+			)
+		}
+	}
+	delete(s.types[tenant], name)
+	return nil
+}
+
+// notFound returns the error for a document type that doesn't exist.
+func notFound(name string) error {
+	return fmt.Errorf("%w: document type %q not found", errs.ErrNotFound, name)
+}
@@ -0,0 +1,59 @@
+package doctypes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+func TestService(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	other := tenancy.WithTenant(context.Background(), "globex")
+	service := New(logger, paths)
+
+	invoice := DocumentType{Name: "invoice", Schema: []byte(invoiceSchema)}
+	if _, err := service.CreateType(ctx, invoice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateType(ctx, invoice); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Expected ErrConflict creating a type twice, got: %v", err)
+	}
+	_, err := service.CreateType(ctx, DocumentType{Name: "Invoice!", Schema: []byte(`{}`)})
+	if !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput creating a type with an invalid name, got: %v", err)
+	}
+
+	if err := service.Validate(ctx, "invoice", map[string]string{"amount": "10", "vendor": "Acme"}); err != nil {
+		t.Errorf("Expected valid metadata to pass, got: %v", err)
+	}
+	var validationErr *errs.ValidationError
+	if err := service.Validate(ctx, "invoice", nil); !errors.As(err, &validationErr) {
+		t.Errorf("Expected a ValidationError for missing required fields, got: %v", err)
+	}
+	if err := service.Validate(ctx, "", map[string]string{"anything": "goes"}); err != nil {
+		t.Errorf("Expected untyped metadata to be free-form, got: %v", err)
+	}
+	if err := service.Validate(other, "invoice", nil); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected the types of a tenant to be unknown to another one, got: %v", err)
+	}
+
+	paths.SavePath(ctx, 1, pathrepository.PathRecord{Path: "invoice.pdf", Type: "invoice"})
+	if err := service.DeleteType(ctx, "invoice"); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Expected ErrConflict deleting a type in use, got: %v", err)
+	}
+	paths.DeletePath(ctx, 1)
+	if err := service.DeleteType(ctx, "invoice"); err != nil {
+		t.Errorf("Expected a type no longer in use to be deleted, got: %v", err)
+	}
+	if _, err := service.Type(ctx, "invoice"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting a deleted type, got: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted blobs start with a fixed-size header followed by the content, split in chunks
+// that are sealed independently with AES-GCM under a random data key:
+//
+//	magic | version | chunk size | master key ID | wrapping nonce | wrapped data key | chunk 0 | chunk 1 | ...
+//
+// The nonce of every chunk is its index, and the last chunk is flagged in its nonce, so chunks can't be
+// reordered nor the content truncated without it being detected. As the header has a fixed size, the data
+// key can be rewrapped with a new master key by overwriting the header, without re-encrypting the content.
+const (
+	magic            = "DMSENC"
+	version          = 1
+	keyIDSize        = 8
+	nonceSize        = 12
+	tagSize          = 16
+	dataKeySize      = 32
+	DefaultChunkSize = 64 << 10
+
+	// prefixSize is the size of the part of the header the wrapped data key is bound to.
+	prefixSize = len(magic) + 1 + 4 + keyIDSize
+	headerSize = prefixSize + nonceSize + dataKeySize + tagSize
+)
+
+// ErrMalformed is returned when a blob isn't a valid encrypted blob or its content has been tampered with.
+var ErrMalformed = errors.New("malformed encrypted blob")
+
+// header is the decoded header of an encrypted blob.
+type header struct {
+	chunkSize uint32
+	keyID     keyID
+	nonce     []byte
+	wrapped   []byte
+}
+
+// prefix encodes the part of the header the wrapped data key is bound to.
+func (h header) prefix() []byte {
+	buf := make([]byte, 0, prefixSize)
+	buf = append(buf, magic...)
+	buf = append(buf, version)
+	buf = binary.BigEndian.AppendUint32(buf, h.chunkSize)
+	return append(buf, h.keyID[:]...)
+}
+
+// marshal encodes the header.
+func (h header) marshal() []byte {
+	buf := h.prefix()
+	buf = append(buf, h.nonce...)
+	return append(buf, h.wrapped...)
+}
+
+// readHeader reads and decodes the header at the start of src.
+func readHeader(src io.ReaderAt) (header, error) {
+	buf := make([]byte, headerSize)
+	if _, err := src.ReadAt(buf, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return header{}, ErrMalformed
+		}
+		return header{}, err
+	}
+	if !bytes.HasPrefix(buf, []byte(magic)) || buf[len(magic)] != version {
+		return header{}, ErrMalformed
+	}
+	h := header{chunkSize: binary.BigEndian.Uint32(buf[len(magic)+1:])}
+	if h.chunkSize == 0 {
+		return header{}, ErrMalformed
+	}
+	copy(h.keyID[:], buf[prefixSize-keyIDSize:prefixSize])
+	h.nonce = buf[prefixSize : prefixSize+nonceSize]
+	h.wrapped = buf[prefixSize+nonceSize:]
+	return h, nil
+}
+
+// IsEncrypted reports whether src holds an encrypted blob, as opposed to plain content.
+func IsEncrypted(src io.ReaderAt) bool {
+	buf := make([]byte, len(magic))
+	if _, err := src.ReadAt(buf, 0); err != nil {
+		return false
+	}
+	return string(buf) == magic
+}
+
+// chunkNonce returns the nonce of the chunk with the given index.
+func chunkNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// writer encrypts everything written to it into dst.
+type writer struct {
+	dst       io.Writer
+	aead      cipher.AEAD
+	chunkSize int
+	buf       []byte
+	index     uint64
+	closed    bool
+}
+
+// NewWriter returns a writer that encrypts everything written to it into dst, using a new random data key
+// wrapped by the active master key of the keyring. Content is buffered one chunk at a time, so memory
+// usage doesn't depend on the size of the content.
+// Close must be called to seal the last chunk. It doesn't close dst.
+func NewWriter(dst io.Writer, keyring *Keyring) (io.WriteCloser, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	h := header{chunkSize: DefaultChunkSize}
+	h.keyID = keyring.active
+	id, nonce, wrapped, err := keyring.wrap(dataKey, h.prefix())
+	if err != nil {
+		return nil, err
+	}
+	h.keyID, h.nonce, h.wrapped = id, nonce, wrapped
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(h.marshal()); err != nil {
+		return nil, err
+	}
+	return &writer{dst: dst, aead: aead, chunkSize: DefaultChunkSize}, nil
+}
+
+// Write buffers p, sealing and writing every chunk that is known not to be the last one.
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encryption writer")
+	}
+	w.buf = append(w.buf, p...)
+	for len(w.buf) > w.chunkSize {
+		if err := w.seal(w.buf[:w.chunkSize], false); err != nil {
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], w.buf[w.chunkSize:]...)
+	}
+	return len(p), nil
+}
+
+// Close seals and writes the last chunk.
+func (w *writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(w.buf, true)
+}
+
+// seal encrypts a chunk and writes it to dst.
+func (w *writer) seal(chunk []byte, last bool) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.index, last), chunk, nil)
+	w.index++
+	_, err := w.dst.Write(sealed)
+	return err
+}
+
+// Reader decrypts an encrypted blob. It supports seeking, decrypting only the chunks that are read.
+type Reader struct {
+	src        io.ReaderAt
+	aead       cipher.AEAD
+	chunkSize  int64
+	chunks     int64
+	size       int64
+	offset     int64
+	chunk      []byte
+	chunkIndex int64
+}
+
+// NewReader returns a Reader that decrypts the encrypted blob of the given size held by src.
+// The data key is unwrapped with the keyring, so the blob must have been wrapped by one of its keys.
+func NewReader(src io.ReaderAt, size int64, keyring *Keyring) (*Reader, error) {
+	h, err := readHeader(src)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := keyring.unwrap(h.keyID, h.nonce, h.wrapped, h.prefix())
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	sealedChunkSize := int64(h.chunkSize) + tagSize
+	body := size - int64(headerSize)
+	chunks := (body + sealedChunkSize - 1) / sealedChunkSize
+	if chunks == 0 || body-(chunks-1)*sealedChunkSize < tagSize {
+		return nil, fmt.Errorf("%w: unexpected blob size", ErrMalformed)
+	}
+	return &Reader{
+		src:        src,
+		aead:       aead,
+		chunkSize:  int64(h.chunkSize),
+		chunks:     chunks,
+		size:       body - chunks*tagSize,
+		chunkIndex: -1,
+	}, nil
+}
+
+// Size returns the size of the decrypted content.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Read reads decrypted content, decrypting the chunks as they are reached.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		if r.size == 0 && r.chunkIndex < 0 {
+			// Authenticate the empty last chunk, so an empty blob can't be forged either.
+			if err := r.load(0); err != nil {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+	index := r.offset / r.chunkSize
+	if index != r.chunkIndex {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk[r.offset-index*r.chunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset for the next Read, as described by io.Seeker.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// load reads and decrypts the chunk with the given index.
+func (r *Reader) load(index int64) error {
+	sealedChunkSize := r.chunkSize + tagSize
+	start := int64(headerSize) + index*sealedChunkSize
+	length := sealedChunkSize
+	last := index == r.chunks-1
+	if last {
+		length = r.size + r.chunks*tagSize - index*sealedChunkSize
+	}
+	sealed := make([]byte, length)
+	if _, err := r.src.ReadAt(sealed, start); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	chunk, err := r.aead.Open(sealed[:0], chunkNonce(uint64(index), last), sealed, nil)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d failed authentication", ErrMalformed, index)
+	}
+	r.chunk, r.chunkIndex = chunk, index
+	return nil
+}
+
+// readerWriterAt is implemented by files opened for reading and writing.
+type readerWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// Rewrap rewraps the data key of the encrypted blob held by blob with the active master key of the keyring,
+// overwriting the blob's header in place. The content isn't re-encrypted.
+// It returns false, without modifying the blob, if the data key is already wrapped by the active key.
+func Rewrap(blob readerWriterAt, keyring *Keyring) (bool, error) {
+	h, err := readHeader(blob)
+	if err != nil {
+		return false, err
+	}
+	if h.keyID == keyring.active {
+		return false, nil
+	}
+	dataKey, err := keyring.unwrap(h.keyID, h.nonce, h.wrapped, h.prefix())
+	if err != nil {
+		return false, err
+	}
+	h.keyID = keyring.active
+	id, nonce, wrapped, err := keyring.wrap(dataKey, h.prefix())
+	if err != nil {
+		return false, err
+	}
+	h.keyID, h.nonce, h.wrapped = id, nonce, wrapped
+	if _, err := blob.WriteAt(h.marshal(), 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,135 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, masterKeySize)
+	newKey = bytes.Repeat([]byte{2}, masterKeySize)
+)
+
+// encrypt encrypts content with the given keyring into a new file in a temporary directory.
+func encrypt(t *testing.T, keyring *Keyring, content []byte) *os.File {
+	file, err := os.Create(filepath.Join(t.TempDir(), "blob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	w, err := NewWriter(file, keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// decrypt opens the encrypted file with the given keyring.
+func decrypt(t *testing.T, keyring *Keyring, file *os.File) (*Reader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewReader(file, info.Size(), keyring)
+}
+
+func TestRoundTrip(t *testing.T) {
+	keyring, _ := NewKeyring(oldKey)
+	for _, size := range []int{0, 1, DefaultChunkSize, DefaultChunkSize + 1, 3*DefaultChunkSize - 7} {
+		content := bytes.Repeat([]byte("0123456789"), size/10+1)[:size]
+		file := encrypt(t, keyring, content)
+		if !IsEncrypted(file) {
+			t.Errorf("Expected blob of size %d to be detected as encrypted", size)
+		}
+		reader, err := decrypt(t, keyring, file)
+		if err != nil {
+			t.Fatalf("Failed to open blob of size %d: %v", size, err)
+		}
+		got, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(got, content) || reader.Size() != int64(size) {
+			t.Errorf("Round trip of %d bytes failed, got %d bytes, err=%v", size, len(got), err)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	keyring, _ := NewKeyring(oldKey)
+	content := make([]byte, 2*DefaultChunkSize+100)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	reader, err := decrypt(t, keyring, encrypt(t, keyring, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := int64(DefaultChunkSize - 10)
+	reader.Seek(offset, io.SeekStart)
+	got := make([]byte, 50)
+	if _, err := io.ReadFull(reader, got); err != nil || !bytes.Equal(got, content[offset:offset+50]) {
+		t.Errorf("Expected to read across chunk boundary after seeking, err=%v", err)
+	}
+}
+
+func TestTamperedBlob(t *testing.T) {
+	keyring, _ := NewKeyring(oldKey)
+	file := encrypt(t, keyring, []byte("confidential contract"))
+	file.WriteAt([]byte{0xff}, int64(headerSize)+3)
+	reader, err := decrypt(t, keyring, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(reader); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected ErrMalformed reading a tampered blob, got %v", err)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	oldKeyring, _ := NewKeyring(oldKey)
+	content := []byte("rotate me")
+	file := encrypt(t, oldKeyring, content)
+
+	rotated, _ := NewKeyring(oldKey, newKey)
+	changed, err := Rewrap(file, rotated)
+	if err != nil || !changed {
+		t.Fatalf("Expected blob to be rewrapped, got changed=%v, err=%v", changed, err)
+	}
+	if changed, _ := Rewrap(file, rotated); changed {
+		t.Errorf("Expected rewrapping with the same active key to be a no-op")
+	}
+
+	newKeyring, _ := NewKeyring(newKey)
+	reader, err := decrypt(t, newKeyring, file)
+	if err != nil {
+		t.Fatalf("Expected blob to be readable with the new key only, got %v", err)
+	}
+	if got, _ := io.ReadAll(reader); !bytes.Equal(got, content) {
+		t.Errorf("Expected content to be preserved, got %q", got)
+	}
+	if _, err := decrypt(t, oldKeyring, file); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey with the old keyring, got %v", err)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyfile")
+	os.WriteFile(path, []byte("# master keys\n"+
+		"0101010101010101010101010101010101010101010101010101010101010101\n\n"+
+		"0202020202020202020202020202020202020202020202020202020202020202\n"), 0600)
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyring.active != idOf(newKey) || len(keyring.keys) != 2 {
+		t.Errorf("Expected last key of the file to be the active one")
+	}
+}
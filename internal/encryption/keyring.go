@@ -0,0 +1,124 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// masterKeySize is the size of the master keys. They are AES-256 keys.
+const masterKeySize = 32
+
+// ErrUnknownKey is returned when a blob's data key was wrapped by a master key the keyring doesn't hold.
+var ErrUnknownKey = errors.New("data key wrapped by an unknown master key")
+
+// keyID identifies a master key. It's derived from the key itself, so it doesn't need to be configured.
+type keyID [keyIDSize]byte
+
+// Keyring holds the master keys used to wrap the data keys of the encrypted blobs.
+// The active key wraps every new data key, while the rest of the keys are only kept for unwrapping
+// data keys of blobs that haven't been rewrapped yet.
+type Keyring struct {
+	keys   map[keyID]cipher.AEAD // keys maps every master key ID to an AEAD built from that key.
+	active keyID                 // active is the ID of the key used for wrapping.
+}
+
+// NewKeyring builds a Keyring from the given master keys. The last key becomes the active one.
+// Every key must be 32 bytes long.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keyring needs at least one master key")
+	}
+	keyring := &Keyring{keys: make(map[keyID]cipher.AEAD)}
+	for i, key := range keys {
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("master key %d must be %d bytes long", i, masterKeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		id := idOf(key)
+		keyring.keys[id] = aead
+		keyring.active = id
+	}
+	return keyring, nil
+}
+
+// LoadKeyring reads the master keys from a local keyfile.
+// The keyfile holds one hex-encoded 32 bytes key per line. Empty lines and lines starting with '#'
+// are ignored. The last key is the active one, so rotating the master key consists of appending a
+// new key to the file and rewrapping the stored blobs.
+func LoadKeyring(path string) (*Keyring, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyfile: %w", err)
+	}
+	defer file.Close()
+
+	var keys [][]byte
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, err := hex.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("keyfile line %d is not a hex-encoded key", line)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read keyfile: %w", err)
+	}
+	return NewKeyring(keys...)
+}
+
+// wrap encrypts a data key with the active master key, binding it to the given additional data.
+// It returns the ID of the master key, the nonce and the wrapped key.
+func (k *Keyring) wrap(dataKey []byte, additionalData []byte) (keyID, []byte, []byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return keyID{}, nil, nil, err
+	}
+	wrapped := k.keys[k.active].Seal(nil, nonce, dataKey, additionalData)
+	return k.active, nonce, wrapped, nil
+}
+
+// unwrap decrypts a data key wrapped by the master key with the given ID.
+func (k *Keyring) unwrap(id keyID, nonce, wrapped, additionalData []byte) ([]byte, error) {
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	dataKey, err := aead.Open(nil, nonce, wrapped, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key can't be unwrapped", ErrMalformed)
+	}
+	return dataKey, nil
+}
+
+// idOf derives the ID of a master key from its SHA-256 hash.
+func idOf(key []byte) keyID {
+	var id keyID
+	sum := sha256.Sum256(key)
+	copy(id[:], sum[:])
+	return id
+}
+
+// newAEAD builds an AES-GCM AEAD from the given key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
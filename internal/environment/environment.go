@@ -1,9 +1,124 @@
 package environment
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // GetProjectRoot returns the root directory of the project as specified by the "PROJECT_ROOT" environment variable.
 // It is a utility function used to retrieve the base path for project-related files and operations.
 func GetProjectRoot() string {
 	return os.Getenv("PROJECT_ROOT")
 }
+
+// GetTenantStorageRoots returns the storage roots configured for specific tenants through the
+// "TENANT_STORAGE_ROOTS" environment variable. The variable is a comma-separated list of
+// tenant=path pairs, e.g. "acme=/mnt/acme,globex=/mnt/globex". Malformed pairs are ignored.
+func GetTenantStorageRoots() map[string]string {
+	roots := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("TENANT_STORAGE_ROOTS"), ",") {
+		tenant, root, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || tenant == "" || root == "" {
+			continue
+		}
+		roots[tenant] = root
+	}
+	return roots
+}
+
+// GetAdminUsers returns the IDs of the users allowed to administer documents, such as breaking the locks of
+// others, as specified by the "ADMIN_USERS" environment variable, a comma-separated list such as "alice,bob".
+func GetAdminUsers() []string {
+	admins := []string{}
+	for _, user := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			admins = append(admins, user)
+		}
+	}
+	return admins
+}
+
+// GetInt64 returns the value of the given environment variable parsed as an int64.
+// It returns 0 if the variable is not set or is not a valid integer.
+func GetInt64(key string) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// GetMasterKeyFile returns the path of the keyfile holding the master keys for encryption at rest,
+// as specified by the "MASTER_KEY_FILE" environment variable. If it's empty, files are stored unencrypted.
+func GetMasterKeyFile() string {
+	return os.Getenv("MASTER_KEY_FILE")
+}
+
+// GetCompression returns the codec used for compressing stored files, as specified by the "COMPRESSION"
+// environment variable. It can be "gzip", "zstd" or empty, which disables compression.
+func GetCompression() string {
+	return os.Getenv("COMPRESSION")
+}
+
+// GetAuditLogFile returns the path of the audit log, as specified by the "AUDIT_LOG_FILE" environment variable.
+func GetAuditLogFile() string {
+	return os.Getenv("AUDIT_LOG_FILE")
+}
+
+// GetDuration returns the value of the given environment variable parsed as a time.Duration, e.g. "90s" or "24h".
+// It returns 0 if the variable is not set or is not a valid duration.
+func GetDuration(key string) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// GetBool returns the value of the given environment variable parsed as a bool, e.g. "true" or "1".
+// It returns false if the variable is not set or is not a valid bool.
+func GetBool(key string) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return false
+	}
+	return value
+}
+
+// GetWebhookStateFile returns the path of the file where webhook subscriptions and pending deliveries are
+// persisted, as specified by the "WEBHOOK_STATE_FILE" environment variable.
+func GetWebhookStateFile() string {
+	return os.Getenv("WEBHOOK_STATE_FILE")
+}
+
+// GetEventLogFile returns the path of the file document events are appended to, as specified by the
+// "EVENT_LOG_FILE" environment variable. If it's empty, events are only delivered to webhooks.
+func GetEventLogFile() string {
+	return os.Getenv("EVENT_LOG_FILE")
+}
+
+// GetScanner returns the malware scanner uploads are scanned with, as specified by the "SCANNER" environment
+// variable. It can be "clamd" or empty, which disables scanning.
+func GetScanner() string {
+	return os.Getenv("SCANNER")
+}
+
+// GetClamdAddress returns the address of the clamd daemon, as specified by the "CLAMD_ADDRESS" environment
+// variable, e.g. "tcp://127.0.0.1:3310" or "unix:///var/run/clamav/clamd.ctl".
+func GetClamdAddress() string {
+	return os.Getenv("CLAMD_ADDRESS")
+}
+
+// GetPipelineStateFile returns the path of the file where post-processing jobs are persisted, as specified by
+// the "PIPELINE_STATE_FILE" environment variable.
+func GetPipelineStateFile() string {
+	return os.Getenv("PIPELINE_STATE_FILE")
+}
+
+// GetThumbnailSizes returns the sizes, in pixels, of the thumbnails generated for images, as specified by the
+// "THUMBNAIL_SIZES" environment variable, a comma-separated list such as "128,256,512".
+func GetThumbnailSizes() string {
+	return os.Getenv("THUMBNAIL_SIZES")
+}
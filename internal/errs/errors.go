@@ -6,4 +6,11 @@ var (
 	ErrinternalError = errors.New("unexpected internal error")
 	ErrInvalidInput  = errors.New("invalid input")
 	ErrNotFound      = errors.New("resource not found")
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	ErrRetained      = errors.New("file is retained")
+	ErrInfected      = errors.New("file is infected")
+	ErrConflict      = errors.New("conflict")
+	ErrLocked        = errors.New("file is locked")
+	ErrForbidden     = errors.New("forbidden")
+	ErrUnavailable   = errors.New("service unavailable")
 )
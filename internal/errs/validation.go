@@ -0,0 +1,32 @@
+package errs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes what's wrong with a single field of the input.
+type FieldError struct {
+	Field   string `json:"field"`   // Field is the name of the field.
+	Message string `json:"message"` // Message describes what's wrong with it.
+}
+
+// ValidationError is an ErrInvalidInput error listing what's wrong with every invalid field of the input, so
+// all of them can be fixed at once.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error lists the invalid fields along with what's wrong with them.
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = fmt.Sprintf("%s %s", field.Field, field.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidInput.Error(), strings.Join(problems, "; "))
+}
+
+// Unwrap returns ErrInvalidInput, so validation errors are handled as any other invalid input.
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidInput
+}
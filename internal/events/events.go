@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// Types of the events emitted when documents change. document.relabeled is emitted when only their tags,
+// metadata or document type change.
+const (
+	DocumentCreated   = "document.created"
+	DocumentReplaced  = "document.replaced"
+	DocumentDeleted   = "document.deleted"
+	DocumentRestored  = "document.restored"
+	DocumentPurged    = "document.purged"
+	DocumentExpired   = "document.expired"
+	DocumentMoved     = "document.moved"
+	DocumentRelabeled = "document.relabeled"
+)
+
+// Types are all the types of events.
+var Types = []string{
+	DocumentCreated,
+	DocumentReplaced,
+	DocumentDeleted,
+	DocumentRestored,
+	DocumentPurged,
+	DocumentExpired,
+	DocumentMoved,
+	DocumentRelabeled,
+}
+
+// Event notifies that a document changed.
+type Event struct {
+	ID         string    `json:"id"`              // ID uniquely identifies the event, so consumers can deduplicate it.
+	Type       string    `json:"type"`            // Type is the kind of change.
+	Tenant     string    `json:"tenant"`          // Tenant is the tenant the document belongs to.
+	DocumentID int64     `json:"documentId"`      // DocumentID is the ID of the document that changed.
+	Actor      string    `json:"actor,omitempty"` // Actor is the user who changed the document, if known.
+	Time       time.Time `json:"time"`            // Time is when the document changed, in UTC.
+	// PreviousID is the ID the document had before it was moved to DocumentID. It's only set for
+	// document.moved events.
+	PreviousID int64 `json:"previousId,omitempty"`
+}
+
+// EventPublisher publishes events to their consumers.
+// Events are published at least once, so consumers should deduplicate them by ID.
+type EventPublisher interface {
+	// Publish hands the event over for delivery. Once it returns without error, the event must not be lost.
+	Publish(ctx context.Context, event Event) error
+}
+
+// New builds an event of the given type for the given document, attributed to the tenant and user found
+// in the context.
+func New(ctx context.Context, eventType string, documentID int64) (Event, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return Event{}, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:         hex.EncodeToString(id),
+		Type:       eventType,
+		Tenant:     tenant,
+		DocumentID: documentID,
+		Actor:      tenancy.UserFromContext(ctx),
+		Time:       time.Now().UTC(),
+	}, nil
+}
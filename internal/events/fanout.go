@@ -0,0 +1,27 @@
+package events
+
+import (
+	"context"
+	"errors"
+)
+
+// Fanout returns an EventPublisher that publishes every event through all the given publishers.
+// Publishing fails if any of them fails, even if others succeeded, so retrying it may publish the event
+// twice through those.
+func Fanout(publishers ...EventPublisher) EventPublisher {
+	return fanout(publishers)
+}
+
+// fanout implements the EventPublisher interface over several publishers.
+type fanout []EventPublisher
+
+// Publish publishes the event through every publisher, joining their errors.
+func (f fanout) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, publisher := range f {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
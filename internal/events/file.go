@@ -0,0 +1,51 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FilePublisher is an EventPublisher that appends the published events to a file, one JSON event per line,
+// for other processes to tail.
+type FilePublisher struct {
+	mu   sync.Mutex // mu serializes the appends, so lines are never interleaved.
+	file *os.File
+}
+
+// OpenFilePublisher opens the file at the given path for appending events, creating it if it doesn't exist.
+func OpenFilePublisher(path string) (*FilePublisher, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePublisher{file: file}, nil
+}
+
+// Publish appends the event to the file and syncs it to disk.
+func (p *FilePublisher) Publish(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append event: %w", err)
+	}
+	if err := p.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync events: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
@@ -0,0 +1,28 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "events.log")
+	publisher, err := OpenFilePublisher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	publisher.Publish(context.Background(), Event{ID: "a", Type: DocumentCreated})
+	publisher.Publish(context.Background(), Event{ID: "b", Type: DocumentDeleted})
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(content, []byte("\n")) != 2 {
+		t.Errorf("Expected one line per event, got: %s", content)
+	}
+}
@@ -0,0 +1,33 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher is an EventPublisher that keeps the published events in memory.
+// It's meant for tests and for consumers living in the same process.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewMemoryPublisher creates a new, empty, MemoryPublisher.
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish appends the event to the published ones.
+func (p *MemoryPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns the published events, in the order they were published.
+func (p *MemoryPublisher) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Event{}, p.events...)
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 )
 
 // DetermineMIME reads the first 512 bytes of the provided file to determine its MIME type
@@ -11,10 +12,10 @@ import (
 // of the file to ensure that subsequent operations on the file start from the correct position.
 // This function is particularly useful for dynamically determining the content type of a file
 // when serving it to HTTP clients, and the MIME type is not predetermined.
-func DetermineMIME(file *os.File) (string, error) {
+func DetermineMIME(file io.ReadSeeker) (string, error) {
 	buffer := make([]byte, 512)
-	n, err := file.Read(buffer)
-	if err != nil && err != io.EOF {
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return "", err
 	}
 	contentType := http.DetectContentType(buffer[:n])
@@ -26,3 +27,29 @@ func DetermineMIME(file *os.File) (string, error) {
 
 	return contentType, nil
 }
+
+// WriteAtomic replaces the file at the given path with the given content, creating its directory if needed.
+// The content is written to a temporary file, synced and renamed over the file, so readers, and the file
+// after a crash, either see the old content or the new one, never a mix of both.
+func WriteAtomic(path string, content []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	temporary := path + ".tmp"
+	file, err := os.OpenFile(temporary, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
@@ -0,0 +1,65 @@
+package folders
+
+import (
+	"context"
+
+	"github.com/lucastomic/dmsStorageService/internal/audit"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+// NewAudited wraps a Service so every change of the folder tree, successful or not, is recorded in the audit
+// log. Failing to record a change doesn't fail it, but it's logged.
+func NewAudited(service Service, log audit.Log, logger logging.Logger) Service {
+	return &auditedService{service, log, logger}
+}
+
+// auditedService implements the Service interface, recording the changes made through the wrapped one.
+type auditedService struct {
+	Service
+	log    audit.Log
+	logger logging.Logger
+}
+
+// CreateFolder creates the folder and records the change. Folders aren't documents, so changes of folders are
+// recorded with ID 0.
+func (s *auditedService) CreateFolder(ctx context.Context, parentID int64, name string) (Folder, error) {
+	folder, err := s.Service.CreateFolder(ctx, parentID, name)
+	s.record(ctx, audit.ActionCreateFolder, 0, err)
+	return folder, err
+}
+
+// MoveFolder moves the folder and records the change.
+func (s *auditedService) MoveFolder(ctx context.Context, id int64, parentID int64, name string) (Folder, error) {
+	folder, err := s.Service.MoveFolder(ctx, id, parentID, name)
+	s.record(ctx, audit.ActionMoveFolder, 0, err)
+	return folder, err
+}
+
+// DeleteFolder deletes the folder and records the change. The documents moved to the trash along with it are
+// recorded by the storage service.
+func (s *auditedService) DeleteFolder(ctx context.Context, id int64, recursive bool) error {
+	err := s.Service.DeleteFolder(ctx, id, recursive)
+	s.record(ctx, audit.ActionDeleteFolder, 0, err)
+	return err
+}
+
+// Place places the document and records the change.
+func (s *auditedService) Place(ctx context.Context, id int64, folderID int64, name string) (Document, error) {
+	document, err := s.Service.Place(ctx, id, folderID, name)
+	s.record(ctx, audit.ActionPlace, id, err)
+	return document, err
+}
+
+// Unplace takes the document out of the tree and records the change.
+func (s *auditedService) Unplace(ctx context.Context, id int64) error {
+	err := s.Service.Unplace(ctx, id)
+	s.record(ctx, audit.ActionUnplace, id, err)
+	return err
+}
+
+// record records a change in the audit log, logging any failure.
+func (s *auditedService) record(ctx context.Context, action string, id int64, err error) {
+	if auditErr := s.log.Record(ctx, action, id, err); auditErr != nil {
+		s.logger.Error(ctx, "Failed to audit %s of %d: %s", action, id, auditErr.Error())
+	}
+}
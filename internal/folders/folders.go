@@ -0,0 +1,105 @@
+package folders
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+)
+
+// Root is the ID of the root folder of every tenant, which always exists and can't be renamed, moved nor deleted.
+const Root int64 = 0
+
+// maxNameLength is the maximum length of the name of a folder or a document in the tree, in bytes.
+const maxNameLength = 255
+
+// Service manages the folder tree of every tenant, in which documents are placed under a logical path, such as
+// "/contracts/2024/acme.pdf". The tree is independent from where the documents are stored, so folders and
+// documents are moved and renamed without touching their content. Names are unique within a folder, whether
+// they're of folders or documents. Documents in the trash keep their place, so they're restored to it, and
+// their name stays taken until they're purged. The tenant is always taken from the context.
+type Service interface {
+	// CreateFolder creates a folder with the given name in the parent folder.
+	// It returns an ErrNotFound error if the parent doesn't exist, and an ErrConflict error if the name is taken.
+	CreateFolder(ctx context.Context, parentID int64, name string) (Folder, error)
+
+	// List returns the folder of the given ID along with the folders and the documents in it.
+	// Documents in the trash or expired aren't listed.
+	List(ctx context.Context, id int64) (Listing, error)
+
+	// MoveFolder moves the folder of the given ID to the parent folder, under the given name, along with
+	// everything in it, at once. It returns an ErrConflict error if the name is taken in the parent folder,
+	// and an ErrInvalidInput error if the parent is the folder itself or inside it.
+	MoveFolder(ctx context.Context, id int64, parentID int64, name string) (Folder, error)
+
+	// DeleteFolder deletes the folder of the given ID. Unless it's recursive, it returns an ErrConflict error if
+	// there's anything in the folder other than documents in the trash. If it's recursive, the folders in it are
+	// deleted too and the documents in them moved to the trash, stopping at the first one that can't be.
+	// Either way, the documents that were in the folder are taken out of the tree.
+	DeleteFolder(ctx context.Context, id int64, recursive bool) error
+
+	// Place places the document of the given ID in the folder, under the given name, or, if it's empty, under
+	// the name it was uploaded with, moving it if it was already placed. It returns an ErrNotFound error if the
+	// document or the folder don't exist, and an ErrConflict error if the name is taken in the folder.
+	Place(ctx context.Context, id int64, folderID int64, name string) (Document, error)
+
+	// Unplace takes the document of the given ID out of the tree. It returns an ErrNotFound error if the
+	// document isn't placed.
+	Unplace(ctx context.Context, id int64) error
+
+	// Resolve returns the folder or the document at the given logical path, such as "contracts/2024/acme.pdf".
+	// It returns an ErrNotFound error if there's nothing at the path, or only a document in the trash.
+	Resolve(ctx context.Context, path string) (Node, error)
+
+	// Publish takes the documents that were purged or expired out of the tree, and moves the documents moved to
+	// another ID along with them.
+	Publish(ctx context.Context, event events.Event) error
+}
+
+// Folder is a folder of the tree.
+type Folder struct {
+	ID        int64      `json:"id"`                  // ID is the identifier of the folder.
+	ParentID  int64      `json:"parentId"`            // ParentID is the ID of the folder it's in.
+	Name      string     `json:"name"`                // Name is the name of the folder. It's empty for the root.
+	Path      string     `json:"path"`                // Path is the logical path of the folder, e.g. "/contracts".
+	CreatedAt *time.Time `json:"createdAt,omitempty"` // CreatedAt is when the folder was created, unset for the root.
+}
+
+// Document is a document placed in the tree.
+type Document struct {
+	ID       int64  `json:"id"`       // ID is the identifier of the document.
+	FolderID int64  `json:"folderId"` // FolderID is the ID of the folder the document is in.
+	Name     string `json:"name"`     // Name is the name of the document in the folder.
+	Path     string `json:"path"`     // Path is the logical path of the document, e.g. "/contracts/acme.pdf".
+}
+
+// Listing is a folder along with its content, sorted by name.
+type Listing struct {
+	Folder    Folder     `json:"folder"`    // Folder is the folder listed.
+	Folders   []Folder   `json:"folders"`   // Folders are the folders in the folder.
+	Documents []Document `json:"documents"` // Documents are the documents in the folder.
+}
+
+// Node is what a logical path resolves to: either a folder or a document.
+type Node struct {
+	Folder   *Folder   // Folder is the folder at the path, if it's a folder.
+	Document *Document // Document is the document at the path, if it's a document.
+}
+
+// ValidateName checks the name can be given to a folder or a document, returning an ErrInvalidInput error
+// otherwise. Names can't be empty, "." nor "..", contain a slash or control characters, or be too long.
+func ValidateName(name string) error {
+	switch {
+	case name == "" || name == "." || name == "..":
+		return fmt.Errorf("%w: %q is not a valid name", errs.ErrInvalidInput, name)
+	case len(name) > maxNameLength:
+		return fmt.Errorf("%w: names can be up to %d bytes long", errs.ErrInvalidInput, maxNameLength)
+	case strings.ContainsFunc(name, func(r rune) bool { return r == '/' || unicode.IsControl(r) }):
+		return fmt.Errorf("%w: name %q can't contain slashes nor control characters", errs.ErrInvalidInput, name)
+	}
+	return nil
+}
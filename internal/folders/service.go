@@ -0,0 +1,424 @@
+package folders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// New returns a Service that keeps the folder tree of every tenant in memory. The path service tells which
+// documents exist, and the storage service is used to move the documents of the folders deleted recursively
+// to the trash.
+func New(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	storage storageservice.StorageService,
+) Service {
+	return &service{
+		logger:  logger,
+		pathsrv: pathsrv,
+		storage: storage,
+		trees:   make(map[string]*tree),
+	}
+}
+
+// service implements the Service interface.
+type service struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	storage storageservice.StorageService
+	mu      sync.Mutex       // mu guards the trees, so every change to them is atomic.
+	trees   map[string]*tree // trees are the folder trees of every tenant.
+	nextID  int64            // nextID is the last ID assigned to a folder.
+}
+
+// tree is the folder tree of a tenant.
+type tree struct {
+	folders   map[int64]*folder   // folders are the folders of the tree, by ID, including the root.
+	documents map[int64]*document // documents are the documents placed in the tree, by ID.
+}
+
+// folder is a folder of a tree.
+type folder struct {
+	parent    int64
+	name      string
+	createdAt time.Time
+	children  map[string]child // children are the folders and documents in the folder, by name.
+}
+
+// child is a folder or a document in a folder.
+type child struct {
+	id       int64
+	isFolder bool
+}
+
+// document is the place of a document in a tree.
+type document struct {
+	folder int64
+	name   string
+}
+
+// CreateFolder creates a folder in the parent folder, assigning it a new ID.
+func (s *service) CreateFolder(ctx context.Context, parentID int64, name string) (Folder, error) {
+	if err := ValidateName(name); err != nil {
+		return Folder{}, err
+	}
+	t, err := s.lockTree(ctx)
+	if err != nil {
+		return Folder{}, err
+	}
+	defer s.mu.Unlock()
+	parent, err := t.folder(parentID)
+	if err != nil {
+		return Folder{}, err
+	}
+	// Nothing in the folder can be the new folder yet, so any child with the name is a conflict.
+	if err := parent.checkFree(name, child{s.nextID + 1, true}); err != nil {
+		return Folder{}, err
+	}
+	s.nextID++
+	t.folders[s.nextID] = &folder{
+		parent:    parentID,
+		name:      name,
+		createdAt: time.Now(),
+		children:  make(map[string]child),
+	}
+	parent.children[name] = child{s.nextID, true}
+	return t.describeFolder(s.nextID), nil
+}
+
+// List returns the folder along with its folders and its live documents, sorted by name.
+func (s *service) List(ctx context.Context, id int64) (Listing, error) {
+	t, err := s.lockTree(ctx)
+	if err != nil {
+		return Listing{}, err
+	}
+	defer s.mu.Unlock()
+	f, err := t.folder(id)
+	if err != nil {
+		return Listing{}, err
+	}
+	listing := Listing{Folder: t.describeFolder(id), Folders: []Folder{}, Documents: []Document{}}
+	for _, name := range f.names() {
+		entry := f.children[name]
+		switch {
+		case entry.isFolder:
+			listing.Folders = append(listing.Folders, t.describeFolder(entry.id))
+		case s.live(ctx, entry.id):
+			listing.Documents = append(listing.Documents, t.describeDocument(entry.id))
+		}
+	}
+	return listing, nil
+}
+
+// MoveFolder moves the folder, and so everything in it, to the parent folder under the given name.
+func (s *service) MoveFolder(ctx context.Context, id int64, parentID int64, name string) (Folder, error) {
+	if id == Root {
+		return Folder{}, fmt.Errorf("%w: the root folder can't be moved", errs.ErrInvalidInput)
+	}
+	if err := ValidateName(name); err != nil {
+		return Folder{}, err
+	}
+	t, err := s.lockTree(ctx)
+	if err != nil {
+		return Folder{}, err
+	}
+	defer s.mu.Unlock()
+	f, err := t.folder(id)
+	if err != nil {
+		return Folder{}, err
+	}
+	parent, err := t.folder(parentID)
+	if err != nil {
+		return Folder{}, err
+	}
+	for ancestor := parentID; ancestor != Root; ancestor = t.folders[ancestor].parent {
+		if ancestor == id {
+			return Folder{}, fmt.Errorf("%w: folder %d can't be moved into itself", errs.ErrInvalidInput, id)
+		}
+	}
+	if err := parent.checkFree(name, child{id, true}); err != nil {
+		return Folder{}, err
+	}
+	delete(t.folders[f.parent].children, f.name)
+	f.parent, f.name = parentID, name
+	parent.children[name] = child{id, true}
+	return t.describeFolder(id), nil
+}
+
+// DeleteFolder deletes the folder, moving the live documents in it to the trash first if it's recursive.
+// As documents are moved to the trash without holding the tree, the folder is checked again after moving them,
+// in case documents were placed in it in the meantime.
+func (s *service) DeleteFolder(ctx context.Context, id int64, recursive bool) error {
+	if id == Root {
+		return fmt.Errorf("%w: the root folder can't be deleted", errs.ErrInvalidInput)
+	}
+	for {
+		t, err := s.lockTree(ctx)
+		if err != nil {
+			return err
+		}
+		f, err := t.folder(id)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		folders, documents := t.subtree(id)
+		var live []int64
+		for _, documentID := range documents {
+			if s.live(ctx, documentID) {
+				live = append(live, documentID)
+			}
+		}
+		if len(live) == 0 && (recursive || len(folders) == 1) {
+			delete(t.folders[f.parent].children, f.name)
+			for _, folderID := range folders {
+				delete(t.folders, folderID)
+			}
+			for _, documentID := range documents {
+				delete(t.documents, documentID)
+			}
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+
+		if !recursive {
+			return fmt.Errorf("%w: folder %d isn't empty", errs.ErrConflict, id)
+		}
+		for _, documentID := range live {
+			if err := s.storage.Delete(ctx, documentID); err != nil && !errors.Is(err, errs.ErrNotFound) {
+				return err
+			}
+		}
+	}
+}
+
+// Place places the document in the folder, taking it out of the folder it was in, if any.
+func (s *service) Place(ctx context.Context, id int64, folderID int64, name string) (Document, error) {
+	record, err := s.pathsrv.GetPath(ctx, id)
+	if err == nil && (record.Trashed() || record.Expired(time.Now())) {
+		err = fmt.Errorf("%w: file with ID %d not found", errs.ErrNotFound, id)
+	}
+	if err != nil {
+		return Document{}, err
+	}
+	if name == "" {
+		name = record.Name
+	}
+	if err := ValidateName(name); err != nil {
+		return Document{}, err
+	}
+	t, err := s.lockTree(ctx)
+	if err != nil {
+		return Document{}, err
+	}
+	defer s.mu.Unlock()
+	f, err := t.folder(folderID)
+	if err != nil {
+		return Document{}, err
+	}
+	if err := f.checkFree(name, child{id, false}); err != nil {
+		return Document{}, err
+	}
+	if placed, found := t.documents[id]; found {
+		delete(t.folders[placed.folder].children, placed.name)
+	}
+	t.documents[id] = &document{folderID, name}
+	f.children[name] = child{id, false}
+	return t.describeDocument(id), nil
+}
+
+// Unplace takes the document out of the folder it's in.
+func (s *service) Unplace(ctx context.Context, id int64) error {
+	t, err := s.lockTree(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if !t.unplace(id) {
+		return fmt.Errorf("%w: document %d isn't in any folder", errs.ErrNotFound, id)
+	}
+	return nil
+}
+
+// Resolve walks the tree from the root folder along the segments of the path. Empty segments are ignored, so
+// the path may start or end with a slash.
+func (s *service) Resolve(ctx context.Context, path string) (Node, error) {
+	t, err := s.lockTree(ctx)
+	if err != nil {
+		return Node{}, err
+	}
+	defer s.mu.Unlock()
+	current := Root
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	for i, segment := range segments {
+		entry, found := t.folders[current].children[segment]
+		switch {
+		case found && entry.isFolder:
+			current = entry.id
+		case found && i == len(segments)-1 && s.live(ctx, entry.id):
+			document := t.describeDocument(entry.id)
+			return Node{Document: &document}, nil
+		default:
+			return Node{}, fmt.Errorf("%w: nothing found at path %q", errs.ErrNotFound, path)
+		}
+	}
+	folder := t.describeFolder(current)
+	return Node{Folder: &folder}, nil
+}
+
+// Publish takes the documents that were purged or expired out of the tree of their tenant, and keeps the
+// documents moved to another ID in the place they had under the previous one.
+func (s *service) Publish(ctx context.Context, event events.Event) error {
+	switch event.Type {
+	case events.DocumentPurged, events.DocumentExpired:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if t, found := s.trees[event.Tenant]; found {
+			t.unplace(event.DocumentID)
+		}
+	case events.DocumentMoved:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if t, found := s.trees[event.Tenant]; found {
+			t.rekey(event.PreviousID, event.DocumentID)
+		}
+	}
+	return nil
+}
+
+// lockTree locks the trees and returns the one of the tenant found in the context, creating it if needed.
+// Unless it returns an error, the trees must be unlocked by the caller.
+func (s *service) lockTree(ctx context.Context) (*tree, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	t, found := s.trees[tenant]
+	if !found {
+		t = &tree{
+			folders:   map[int64]*folder{Root: {children: make(map[string]child)}},
+			documents: make(map[int64]*document),
+		}
+		s.trees[tenant] = t
+	}
+	return t, nil
+}
+
+// live reports whether the document of the given ID exists and isn't in the trash nor expired.
+func (s *service) live(ctx context.Context, id int64) bool {
+	record, err := s.pathsrv.GetPath(ctx, id)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		s.logger.Error(ctx, "Failed to check if document %d exists: %s", id, err.Error())
+	}
+	return err == nil && !record.Trashed() && !record.Expired(time.Now())
+}
+
+// folder returns the folder of the given ID, or an ErrNotFound error if it doesn't exist.
+func (t *tree) folder(id int64) (*folder, error) {
+	f, found := t.folders[id]
+	if !found {
+		return nil, fmt.Errorf("%w: folder with ID %d not found", errs.ErrNotFound, id)
+	}
+	return f, nil
+}
+
+// subtree returns the IDs of the folder and every folder inside it, and of the documents in any of them.
+func (t *tree) subtree(id int64) ([]int64, []int64) {
+	folders, documents := []int64{id}, []int64{}
+	for i := 0; i < len(folders); i++ {
+		for _, entry := range t.folders[folders[i]].children {
+			if entry.isFolder {
+				folders = append(folders, entry.id)
+			} else {
+				documents = append(documents, entry.id)
+			}
+		}
+	}
+	return folders, documents
+}
+
+// unplace takes the document of the given ID out of the tree, reporting whether it was placed.
+func (t *tree) unplace(id int64) bool {
+	placed, found := t.documents[id]
+	if found {
+		delete(t.folders[placed.folder].children, placed.name)
+		delete(t.documents, id)
+	}
+	return found
+}
+
+// rekey moves the place of the document of the given ID, if it's placed, to the new ID. Whatever was placed under
+// the new ID is taken out of the tree first.
+func (t *tree) rekey(from int64, to int64) {
+	placed, found := t.documents[from]
+	if !found || from == to {
+		return
+	}
+	t.unplace(to)
+	delete(t.documents, from)
+	t.documents[to] = placed
+	t.folders[placed.folder].children[placed.name] = child{to, false}
+}
+
+// path returns the logical path of the folder of the given ID.
+func (t *tree) path(id int64) string {
+	var segments []string
+	for ; id != Root; id = t.folders[id].parent {
+		segments = append(segments, t.folders[id].name)
+	}
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// describeFolder describes the folder of the given ID, which must exist.
+func (t *tree) describeFolder(id int64) Folder {
+	f := t.folders[id]
+	folder := Folder{ID: id, ParentID: f.parent, Name: f.name, Path: t.path(id)}
+	if id != Root {
+		createdAt := f.createdAt
+		folder.CreatedAt = &createdAt
+	}
+	return folder
+}
+
+// describeDocument describes the document of the given ID, which must be placed.
+func (t *tree) describeDocument(id int64) Document {
+	placed := t.documents[id]
+	path := strings.TrimSuffix(t.path(placed.folder), "/") + "/" + placed.name
+	return Document{ID: id, FolderID: placed.folder, Name: placed.name, Path: path}
+}
+
+// checkFree returns an ErrConflict error if the name is taken in the folder by anything other than the given
+// child.
+func (f *folder) checkFree(name string, self child) error {
+	if entry, taken := f.children[name]; taken && entry != self {
+		return fmt.Errorf("%w: name %q is already taken in the folder", errs.ErrConflict, name)
+	}
+	return nil
+}
+
+// names returns the names of the children of the folder, sorted.
+func (f *folder) names() []string {
+	names := make([]string, 0, len(f.children))
+	for name := range f.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
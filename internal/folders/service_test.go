@@ -0,0 +1,164 @@
+package folders
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// newTestService returns a folder service over a temporary storage with the files "a.pdf" and "b.pdf", of IDs
+// 1 and 2, along with the storage service and a context of the tenant "acme".
+func newTestService(t *testing.T) (Service, storageservice.StorageService, context.Context) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	storage := storageservice.New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, paths),
+		compression.None,
+		scanning.Policy{},
+	)
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	for id, name := range map[int64]string{1: "a.pdf", 2: "b.pdf"} {
+		src := filepath.Join(t.TempDir(), name)
+		os.WriteFile(src, []byte(name), 0o644)
+		file, err := os.Open(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		data := storageservice.UploadData{File: file, Filename: name, Id: id, Size: int64(len(name))}
+		if err := storage.Upload(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(logger, paths, storage), storage, ctx
+}
+
+func TestTree(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	contracts, err := service.CreateFolder(ctx, Root, "contracts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	year, _ := service.CreateFolder(ctx, contracts.ID, "2024")
+	if year.Path != "/contracts/2024" {
+		t.Errorf("Expected the path of the folder, got %s", year.Path)
+	}
+	if _, err := service.CreateFolder(ctx, Root, "contracts"); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Expected a conflict creating a folder with a taken name, got %v", err)
+	}
+	if _, err := service.CreateFolder(ctx, Root, "a/b"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected an invalid name to be rejected, got %v", err)
+	}
+
+	document, err := service.Place(ctx, 1, year.ID, "acme.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if document.Path != "/contracts/2024/acme.pdf" {
+		t.Errorf("Expected the logical path of the document, got %s", document.Path)
+	}
+	if _, err := service.Place(ctx, 2, year.ID, "acme.pdf"); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Expected a conflict placing a document under a taken name, got %v", err)
+	}
+	if _, err := service.CreateFolder(ctx, year.ID, "acme.pdf"); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Expected a conflict creating a folder with the name of a document, got %v", err)
+	}
+	if document, _ := service.Place(ctx, 2, Root, ""); document.Name != "b.pdf" {
+		t.Errorf("Expected the document to be named after its file, got %+v", document)
+	}
+
+	node, err := service.Resolve(ctx, "/contracts/2024/acme.pdf")
+	if err != nil || node.Document == nil || node.Document.ID != 1 {
+		t.Fatalf("Expected the path to resolve to document 1, got %+v, err=%v", node, err)
+	}
+	if node, _ := service.Resolve(ctx, "contracts/2024/"); node.Folder == nil || node.Folder.ID != year.ID {
+		t.Errorf("Expected the path to resolve to the folder, got %+v", node)
+	}
+	if _, err := service.Resolve(ctx, "contracts/acme.pdf"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound resolving a missing path, got %v", err)
+	}
+
+	archive, _ := service.CreateFolder(ctx, Root, "archive")
+	if _, err := service.MoveFolder(ctx, contracts.ID, year.ID, "contracts"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected an error moving a folder into itself, got %v", err)
+	}
+	if _, err := service.MoveFolder(ctx, contracts.ID, archive.ID, "old"); err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := service.Resolve(ctx, "archive/old/2024/acme.pdf"); node.Document == nil {
+		t.Errorf("Expected the document to move along with its folder")
+	}
+	listing, _ := service.List(ctx, Root)
+	if len(listing.Folders) != 1 || len(listing.Documents) != 1 || listing.Documents[0].ID != 2 {
+		t.Errorf("Expected the root to hold the archive folder and document 2, got %+v", listing)
+	}
+}
+
+func TestDeleteFolder(t *testing.T) {
+	service, storage, ctx := newTestService(t)
+	contracts, _ := service.CreateFolder(ctx, Root, "contracts")
+	year, _ := service.CreateFolder(ctx, contracts.ID, "2024")
+	service.Place(ctx, 1, year.ID, "")
+	service.Place(ctx, 2, Root, "")
+
+	if err := service.DeleteFolder(ctx, contracts.ID, false); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Expected a conflict deleting a folder that isn't empty, got %v", err)
+	}
+	if err := service.DeleteFolder(ctx, contracts.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Get(ctx, 1); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected the documents of the folder to be moved to the trash, got %v", err)
+	}
+	if _, err := service.List(ctx, year.ID); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected the folders inside the folder to be deleted, got %v", err)
+	}
+
+	storage.Delete(ctx, 2)
+	if _, err := service.Resolve(ctx, "b.pdf"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected documents in the trash not to be resolved, got %v", err)
+	}
+	service.Publish(ctx, events.Event{Type: events.DocumentPurged, Tenant: "acme", DocumentID: 2})
+	if err := service.Unplace(ctx, 2); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected purged documents to be taken out of the tree, got %v", err)
+	}
+}
+
+func TestMovedDocument(t *testing.T) {
+	service, storage, ctx := newTestService(t)
+	contracts, _ := service.CreateFolder(ctx, Root, "contracts")
+	service.Place(ctx, 1, contracts.ID, "acme.pdf")
+
+	if _, err := storage.Move(ctx, 1, 7, ""); err != nil {
+		t.Fatal(err)
+	}
+	service.Publish(ctx, events.Event{Type: events.DocumentMoved, Tenant: "acme", DocumentID: 7, PreviousID: 1})
+	node, err := service.Resolve(ctx, "contracts/acme.pdf")
+	if err != nil || node.Document == nil || node.Document.ID != 7 {
+		t.Errorf("Expected the document to keep its place under its new ID, got %+v, %v", node, err)
+	}
+	if err := service.Unplace(ctx, 1); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected nothing to be left placed under the previous ID, got %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// quarantineDir is the directory, inside the storage root of every tenant, where unreferenced blobs are
+// moved to until they are deleted. Every collection moves them into a subdirectory named after the
+// Unix time it started at, so the blobs can be restored by hand during the grace period.
+const quarantineDir = ".quarantine"
+
+// GCConfig configures the Collector.
+type GCConfig struct {
+	Interval    time.Duration // Interval is the time between the start of two collections.
+	GracePeriod time.Duration // GracePeriod is how long unreferenced blobs stay quarantined before being deleted.
+	DryRun      bool          // DryRun reports what would be collected without moving or deleting anything.
+}
+
+// GCConfigFromEnvironment reads the garbage collector configuration from the environment variables
+// GC_INTERVAL, which defaults to 1h, GC_GRACE_PERIOD, which defaults to 72h, and GC_DRY_RUN.
+func GCConfigFromEnvironment() GCConfig {
+	config := GCConfig{
+		Interval:    environment.GetDuration("GC_INTERVAL"),
+		GracePeriod: environment.GetDuration("GC_GRACE_PERIOD"),
+		DryRun:      environment.GetBool("GC_DRY_RUN"),
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Hour
+	}
+	if config.GracePeriod <= 0 {
+		config.GracePeriod = 72 * time.Hour
+	}
+	return config
+}
+
+// GCReport is the result of a collection.
+type GCReport struct {
+	StartedAt        time.Time       `json:"startedAt"`        // StartedAt is the time the collection started.
+	FinishedAt       time.Time       `json:"finishedAt"`       // FinishedAt is the time the collection finished.
+	DryRun           bool            `json:"dryRun"`           // DryRun tells whether nothing was actually collected.
+	Quarantined      []OrphanFinding `json:"quarantined"`      // Quarantined are the blobs moved to quarantine.
+	Deleted          []OrphanFinding `json:"deleted"`          // Deleted are the quarantined blobs deleted for good.
+	QuarantinedBytes int64           `json:"quarantinedBytes"` // QuarantinedBytes is the size of the quarantined blobs.
+	ReclaimedBytes   int64           `json:"reclaimedBytes"`   // ReclaimedBytes is the size of the deleted blobs.
+}
+
+// Collector is a background job that periodically diffs the storage against the path records, moving the
+// blobs no path references to quarantine, and deleting the quarantined blobs once their grace period is over.
+// It doesn't race with uploads, as it sweeps the storage under the blob guard.
+type Collector struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	blobs   blobstore.BlobStore
+	guard   *blobstore.Guard
+	config  GCConfig
+	mu      sync.RWMutex
+	last    *GCReport
+}
+
+// NewCollector creates a new Collector for the blobs of the given blob store, coordinated through the guard
+// with the uploads that store them.
+func NewCollector(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	blobs blobstore.BlobStore,
+	guard *blobstore.Guard,
+	config GCConfig,
+) *Collector {
+	return &Collector{logger: logger, pathsrv: pathsrv, blobs: blobs, guard: guard, config: config}
+}
+
+// Run collects garbage every configured interval until the context is done.
+// The first collection starts right away.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := c.Collect(ctx); err != nil {
+			c.logger.Error(ctx, "Garbage collection failed: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect performs a single collection, logging a summary of it, and keeps its report as the latest one.
+// In dry-run mode the report lists the blobs that would be quarantined and deleted, and the bytes that
+// would be reclaimed, but the storage isn't modified.
+func (c *Collector) Collect(ctx context.Context) (GCReport, error) {
+	report := GCReport{StartedAt: time.Now(), DryRun: c.config.DryRun}
+	if err := c.purge(ctx, &report); err != nil {
+		return report, err
+	}
+	if err := c.quarantine(ctx, &report); err != nil {
+		return report, err
+	}
+
+	report.FinishedAt = time.Now()
+	verb := "reclaimed"
+	if report.DryRun {
+		verb = "would be reclaimed"
+	}
+	c.logger.Info(
+		ctx,
+		"Garbage collection finished: %d files quarantined (%d bytes), %d files deleted, %d bytes %s",
+		len(report.Quarantined),
+		report.QuarantinedBytes,
+		len(report.Deleted),
+		report.ReclaimedBytes,
+		verb,
+	)
+	c.mu.Lock()
+	c.last = &report
+	c.mu.Unlock()
+	return report, nil
+}
+
+// Report returns the part of the latest collection that concerns the tenant found in the context.
+// It returns an ErrNotFound error if no collection has finished yet.
+func (c *Collector) Report(ctx context.Context) (GCReport, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return GCReport{}, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.last == nil {
+		return GCReport{}, fmt.Errorf("%w: no garbage collection has finished yet", errs.ErrNotFound)
+	}
+	report := GCReport{
+		StartedAt:   c.last.StartedAt,
+		FinishedAt:  c.last.FinishedAt,
+		DryRun:      c.last.DryRun,
+		Quarantined: []OrphanFinding{},
+		Deleted:     []OrphanFinding{},
+	}
+	for _, finding := range c.last.Quarantined {
+		if finding.Tenant == tenant {
+			report.Quarantined = append(report.Quarantined, finding)
+			report.QuarantinedBytes += finding.StoredSize
+		}
+	}
+	for _, finding := range c.last.Deleted {
+		if finding.Tenant == tenant {
+			report.Deleted = append(report.Deleted, finding)
+			report.ReclaimedBytes += finding.StoredSize
+		}
+	}
+	return report, nil
+}
+
+// quarantine moves the blobs no path references to the quarantine directory of their tenant.
+// The storage is swept under the guard, so the blobs of in-flight uploads are never taken for unreferenced.
+func (c *Collector) quarantine(ctx context.Context, report *GCReport) error {
+	defer c.guard.BeginSweep()()
+	found, err := listBlobs(c.blobs, report.StartedAt.Add(-orphanGracePeriod))
+	if err != nil {
+		return fmt.Errorf("failed to list stored files: %w", err)
+	}
+	entries, err := c.pathsrv.All(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list paths: %w", err)
+	}
+
+	batch := strconv.FormatInt(report.StartedAt.Unix(), 10)
+	for _, orphan := range unreferenced(found, entries) {
+		relative := orphan.relativePath()
+		if !report.DryRun {
+			destination := filepath.Join(orphan.root, quarantineDir, batch, relative)
+			if err := c.blobs.Move(orphan.Path, destination); err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					c.logger.Error(ctx, "Failed to quarantine file %s: %s", orphan.Path, err.Error())
+				}
+				continue
+			}
+		}
+		report.Quarantined = append(report.Quarantined, OrphanFinding{orphan.tenant, relative, orphan.StoredSize})
+		report.QuarantinedBytes += orphan.StoredSize
+	}
+	return nil
+}
+
+// purge deletes the quarantined blobs whose grace period is over, along with their emptied batch directories.
+func (c *Collector) purge(ctx context.Context, report *GCReport) error {
+	roots, err := tenancy.StorageRoots()
+	if err != nil {
+		return fmt.Errorf("failed to list storage roots: %w", err)
+	}
+	expiredBefore := report.StartedAt.Add(-c.config.GracePeriod)
+	for tenant, root := range roots {
+		quarantine := filepath.Join(root, quarantineDir)
+		quarantined, err := c.blobs.List(quarantine)
+		if err != nil {
+			return fmt.Errorf("failed to list quarantined files: %w", err)
+		}
+		emptied := make(map[string]bool)
+		for _, blob := range quarantined {
+			batch, relative, ok := splitQuarantined(quarantine, blob.Path)
+			if !ok || batch.After(expiredBefore) {
+				continue
+			}
+			if !report.DryRun {
+				if err := c.blobs.Remove(blob.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					c.logger.Error(ctx, "Failed to delete quarantined file %s: %s", blob.Path, err.Error())
+					continue
+				}
+				emptied[filepath.Join(quarantine, strconv.FormatInt(batch.Unix(), 10))] = true
+			}
+			report.Deleted = append(report.Deleted, OrphanFinding{tenant, relative, blob.StoredSize})
+			report.ReclaimedBytes += blob.StoredSize
+		}
+		for dir := range emptied {
+			// The filesystem store removes directories as long as they are empty, so batches
+			// still holding blobs, e.g. ones that failed to be deleted, are kept.
+			c.blobs.Remove(dir)
+		}
+	}
+	return nil
+}
+
+// splitQuarantined splits the path of a quarantined blob into the time it was quarantined at and its path
+// relative to the storage root it was found in. It returns false if the path isn't laid out as expected.
+func splitQuarantined(quarantine string, path string) (time.Time, string, bool) {
+	relative, err := filepath.Rel(quarantine, path)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	batch, relative, found := strings.Cut(filepath.ToSlash(relative), "/")
+	if !found {
+		return time.Time{}, "", false
+	}
+	seconds, err := strconv.ParseInt(batch, 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return time.Unix(seconds, 0), filepath.FromSlash(relative), true
+}
@@ -0,0 +1,147 @@
+package maintenance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// newTestCollector returns a Collector over a temporary storage, along with a context of the tenant "acme".
+func newTestCollector(
+	t *testing.T,
+	config GCConfig,
+) (*Collector, pathservice.PathService, *blobstore.Guard, context.Context) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	guard := blobstore.NewGuard()
+	collector := NewCollector(logger, paths, blobstore.NewFileSystem(nil), guard, config)
+	return collector, paths, guard, tenancy.WithTenant(context.Background(), "acme")
+}
+
+func TestCollectQuarantinesOrphans(t *testing.T) {
+	collector, paths, _, ctx := newTestCollector(t, GCConfig{time.Hour, time.Hour, false})
+	root := tenancy.StorageRoot("acme")
+	referenced := storeFile(t, ctx, paths, 1, "referenced.txt", "referenced content")
+	orphan := filepath.Join(root, "orphan.txt")
+	writeFile(t, orphan, "orphaned")
+
+	report, err := collector.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Quarantined) != 1 || report.Quarantined[0].Path != "orphan.txt" {
+		t.Errorf("Expected orphan.txt to be quarantined, got: %v", report.Quarantined)
+	}
+	if report.QuarantinedBytes != int64(len("orphaned")) || report.ReclaimedBytes != 0 {
+		t.Errorf("Unexpected byte counts: %+v", report)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("Expected orphan to be moved out of the storage root, got: %v", err)
+	}
+	batch := strconv.FormatInt(report.StartedAt.Unix(), 10)
+	if _, err := os.Stat(filepath.Join(root, quarantineDir, batch, "orphan.txt")); err != nil {
+		t.Errorf("Expected orphan to be quarantined: %v", err)
+	}
+	if _, err := os.Stat(referenced); err != nil {
+		t.Errorf("Expected referenced file to be kept: %v", err)
+	}
+}
+
+func TestCollectDeletesExpiredQuarantine(t *testing.T) {
+	collector, _, _, ctx := newTestCollector(t, GCConfig{time.Hour, time.Hour, false})
+	quarantine := filepath.Join(tenancy.StorageRoot("acme"), quarantineDir)
+	expiredBatch := filepath.Join(quarantine, strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10))
+	recentBatch := filepath.Join(quarantine, strconv.FormatInt(time.Now().Unix(), 10))
+	writeFile(t, filepath.Join(expiredBatch, "expired.txt"), "expired content")
+	writeFile(t, filepath.Join(recentBatch, "recent.txt"), "recent content")
+
+	report, err := collector.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Deleted) != 1 || report.Deleted[0].Path != "expired.txt" {
+		t.Errorf("Expected expired.txt to be deleted, got: %v", report.Deleted)
+	}
+	if report.ReclaimedBytes != int64(len("expired content")) {
+		t.Errorf("Expected %d reclaimed bytes, got: %d", len("expired content"), report.ReclaimedBytes)
+	}
+	if _, err := os.Stat(expiredBatch); !os.IsNotExist(err) {
+		t.Errorf("Expected expired batch to be removed, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(recentBatch, "recent.txt")); err != nil {
+		t.Errorf("Expected recent quarantined file to be kept: %v", err)
+	}
+
+	tenantReport, err := collector.Report(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenantReport.ReclaimedBytes != report.ReclaimedBytes {
+		t.Errorf("Expected the tenant report to match, got: %+v", tenantReport)
+	}
+}
+
+func TestCollectDryRun(t *testing.T) {
+	collector, _, _, ctx := newTestCollector(t, GCConfig{time.Hour, time.Hour, true})
+	root := tenancy.StorageRoot("acme")
+	orphan := filepath.Join(root, "orphan.txt")
+	writeFile(t, orphan, "orphaned")
+	expired := filepath.Join(
+		root,
+		quarantineDir,
+		strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10),
+		"expired.txt",
+	)
+	writeFile(t, expired, "expired content")
+
+	report, err := collector.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || len(report.Quarantined) != 1 || len(report.Deleted) != 1 {
+		t.Errorf("Expected the dry run to report both files, got: %+v", report)
+	}
+	if report.ReclaimedBytes != int64(len("expired content")) {
+		t.Errorf("Expected %d bytes to be reclaimable, got: %d", len("expired content"), report.ReclaimedBytes)
+	}
+	for _, path := range []string{orphan, expired} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be left in place: %v", path, err)
+		}
+	}
+}
+
+func TestCollectWaitsForUploads(t *testing.T) {
+	collector, paths, guard, ctx := newTestCollector(t, GCConfig{time.Hour, time.Hour, false})
+	endWrite := guard.BeginWrite()
+	uploaded := filepath.Join(tenancy.StorageRoot("acme"), "uploading.txt")
+	writeFile(t, uploaded, "upload in progress")
+
+	done := make(chan GCReport)
+	go func() {
+		report, _ := collector.Collect(ctx)
+		done <- report
+	}()
+	select {
+	case <-done:
+		t.Fatal("Expected the collection to wait for the upload")
+	case <-time.After(50 * time.Millisecond):
+	}
+	storeFile(t, ctx, paths, 1, "uploading.txt", "upload in progress")
+	endWrite()
+
+	report := <-done
+	if len(report.Quarantined) != 0 {
+		t.Errorf("Expected the uploaded file not to be quarantined, got: %v", report.Quarantined)
+	}
+}
@@ -0,0 +1,125 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// ExpiryConfig configures the ExpiryReaper.
+type ExpiryConfig struct {
+	Interval  time.Duration // Interval is the time between two reaps.
+	BatchSize int           // BatchSize is the number of files removed before yielding to other work.
+	Pause     time.Duration // Pause is the time waited between two batches.
+}
+
+// ExpiryConfigFromEnvironment reads the reaper configuration from the environment variables
+// EXPIRY_REAP_INTERVAL, which defaults to 1m, EXPIRY_REAP_BATCH_SIZE, which defaults to 100,
+// and EXPIRY_REAP_PAUSE, which defaults to 100ms.
+func ExpiryConfigFromEnvironment() ExpiryConfig {
+	config := ExpiryConfig{
+		Interval:  environment.GetDuration("EXPIRY_REAP_INTERVAL"),
+		BatchSize: int(environment.GetInt64("EXPIRY_REAP_BATCH_SIZE")),
+		Pause:     environment.GetDuration("EXPIRY_REAP_PAUSE"),
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.Pause <= 0 {
+		config.Pause = 100 * time.Millisecond
+	}
+	return config
+}
+
+// ExpiryReaper is a background job that periodically removes the files that have expired, along with
+// their paths. Files are removed in batches, pausing between them, so a large number of files expiring
+// at once doesn't starve live traffic.
+type ExpiryReaper struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	storage storageservice.StorageService
+	config  ExpiryConfig
+}
+
+// NewExpiryReaper creates a new ExpiryReaper that removes the expired files of the given path service
+// through the storage service.
+func NewExpiryReaper(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	storage storageservice.StorageService,
+	config ExpiryConfig,
+) *ExpiryReaper {
+	return &ExpiryReaper{logger, pathsrv, storage, config}
+}
+
+// Run reaps the expired files every configured interval until the context is done.
+// The first reap starts right away.
+func (r *ExpiryReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Reap(ctx); err != nil {
+			r.logger.Error(ctx, "Reaping expired files failed: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reap removes the files of every tenant that have expired, in batches, and returns the number of removed files.
+// Retained files are kept until their retention is over, and files that fail to be removed are logged and
+// retried on the next reap.
+func (r *ExpiryReaper) Reap(ctx context.Context) (int, error) {
+	entries, err := r.pathsrv.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var expired []pathrepository.PathEntry
+	for _, entry := range entries {
+		if entry.Record.Expired(now) {
+			expired = append(expired, entry)
+		}
+	}
+
+	reaped := 0
+	for start := 0; start < len(expired); start += r.config.BatchSize {
+		if start > 0 {
+			select {
+			case <-ctx.Done():
+				return reaped, ctx.Err()
+			case <-time.After(r.config.Pause):
+			}
+		}
+		end := min(start+r.config.BatchSize, len(expired))
+		for _, entry := range expired[start:end] {
+			err := r.storage.Expire(tenancy.WithTenant(ctx, entry.Tenant), entry.ID)
+			switch {
+			case err == nil:
+				reaped++
+			case errors.Is(err, errs.ErrNotFound), errors.Is(err, errs.ErrRetained):
+				// Removed since the entries were listed, or retained until later.
+			default:
+				r.logger.Error(ctx, "Failed to reap file %d of tenant %s: %s", entry.ID, entry.Tenant, err.Error())
+			}
+		}
+	}
+	if reaped > 0 {
+		r.logger.Info(ctx, "Reaped %d expired files", reaped)
+	}
+	return reaped, nil
+}
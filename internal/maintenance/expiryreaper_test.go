@@ -0,0 +1,75 @@
+package maintenance
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+func TestReapExpiredFiles(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	retain := retention.New(logger, paths)
+	storage := storageservice.New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retain,
+		compression.None,
+		scanning.Policy{},
+	)
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	expiredPaths := []string{
+		storeFile(t, ctx, paths, 1, "export-1.csv", "expired"),
+		storeFile(t, ctx, paths, 2, "export-2.csv", "expired"),
+		storeFile(t, ctx, paths, 3, "export-3.csv", "expired"),
+	}
+	storeFile(t, ctx, paths, 4, "retained.csv", "retained")
+	storeFile(t, ctx, paths, 5, "later.csv", "expires later")
+	for id := int64(1); id <= 5; id++ {
+		record, _ := paths.GetPath(ctx, id)
+		record.ExpiresAt = time.Now().Add(-time.Minute)
+		if id == 5 {
+			record.ExpiresAt = time.Now().Add(time.Hour)
+		}
+		paths.SavePath(ctx, id, record)
+	}
+	retain.SetLegalHold(ctx, 4, true, "audit")
+
+	reaper := NewExpiryReaper(logger, paths, storage, ExpiryConfig{time.Hour, 2, time.Millisecond})
+	reaped, err := reaper.Reap(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reaped != 3 {
+		t.Errorf("Expected 3 reaped files, got: %d", reaped)
+	}
+	for i, path := range expiredPaths {
+		if exists, _ := paths.Exists(ctx, int64(i+1)); exists {
+			t.Errorf("Expected the path of file %d to be removed", i+1)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected the blob of file %d to be removed, got: %v", i+1, err)
+		}
+	}
+	for _, id := range []int64{4, 5} {
+		if exists, _ := paths.Exists(ctx, id); !exists {
+			t.Errorf("Expected file %d to be kept", id)
+		}
+	}
+}
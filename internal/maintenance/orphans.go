@@ -0,0 +1,67 @@
+package maintenance
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// tenantBlob is a blob found in the storage root of a tenant.
+type tenantBlob struct {
+	blobstore.BlobInfo
+	tenant string // tenant is the tenant whose storage root holds the blob.
+	root   string // root is the storage root of the tenant.
+}
+
+// relativePath returns the path of the blob relative to the storage root of its tenant,
+// so the storage layout isn't disclosed to the tenant.
+func (b tenantBlob) relativePath() string {
+	relative, err := filepath.Rel(b.root, b.Path)
+	if err != nil {
+		return filepath.Base(b.Path)
+	}
+	return relative
+}
+
+// listBlobs lists the blobs in the storage root of every tenant that were last modified before the given time.
+// Newer blobs are skipped, as they may belong to uploads whose path hasn't been saved yet.
+func listBlobs(blobs blobstore.BlobStore, modifiedBefore time.Time) ([]tenantBlob, error) {
+	roots, err := tenancy.StorageRoots()
+	if err != nil {
+		return nil, err
+	}
+	var found []tenantBlob
+	for tenant, root := range roots {
+		infos, err := blobs.List(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.ModTime.Before(modifiedBefore) {
+				found = append(found, tenantBlob{info, tenant, root})
+			}
+		}
+	}
+	return found, nil
+}
+
+// unreferenced returns the blobs that no path record references, neither as its content nor as a previous version.
+// The records must have been listed after the blobs, so every blob stored by then is accounted for.
+func unreferenced(found []tenantBlob, entries []pathrepository.PathEntry) []tenantBlob {
+	referenced := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		for _, path := range entry.Record.Blobs() {
+			referenced[filepath.Clean(path)] = true
+		}
+	}
+	var orphans []tenantBlob
+	for _, blob := range found {
+		if !referenced[filepath.Clean(blob.Path)] {
+			orphans = append(orphans, blob)
+		}
+	}
+	return orphans
+}
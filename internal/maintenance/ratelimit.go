@@ -0,0 +1,58 @@
+package maintenance
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// rateLimiter spreads the reads of a maintenance job over time, so it doesn't starve live traffic.
+// It keeps the average throughput since it was created under the configured rate.
+type rateLimiter struct {
+	bytesPerSecond int64     // bytesPerSecond is the maximum average throughput. Non-positive values disable it.
+	start          time.Time // start is the time the limiter was created.
+	consumed       int64     // consumed is the number of bytes read since start.
+}
+
+// newRateLimiter returns a rateLimiter allowing the given number of bytes per second.
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{bytesPerSecond: bytesPerSecond, start: time.Now()}
+}
+
+// wait accounts for n read bytes, sleeping as long as needed to stay under the rate.
+// It returns early with the context's error if the context is done.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l.bytesPerSecond <= 0 {
+		return ctx.Err()
+	}
+	l.consumed += int64(n)
+	expected := time.Duration(float64(l.consumed) / float64(l.bytesPerSecond) * float64(time.Second))
+	delay := expected - time.Since(l.start)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader is a reader whose throughput is bounded by a rateLimiter.
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+// Read reads from the underlying reader and waits for the limiter.
+func (t throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if waitErr := t.limiter.wait(t.ctx, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
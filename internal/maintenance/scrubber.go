@@ -0,0 +1,253 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/checksum"
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// orphanGracePeriod is how old a blob must be to be considered an orphan. It keeps uploads in progress,
+// whose path hasn't been saved yet, from being reported.
+const orphanGracePeriod = time.Minute
+
+// ScrubConfig configures the Scrubber.
+type ScrubConfig struct {
+	Interval       time.Duration // Interval is the time between the start of two scrubbing passes.
+	BytesPerSecond int64         // BytesPerSecond bounds the average throughput of the reads of a pass.
+}
+
+// ScrubConfigFromEnvironment reads the scrubber configuration from the environment variables
+// SCRUB_INTERVAL, which defaults to 24h, and SCRUB_BYTES_PER_SECOND, which defaults to 8MB.
+func ScrubConfigFromEnvironment() ScrubConfig {
+	config := ScrubConfig{
+		Interval:       environment.GetDuration("SCRUB_INTERVAL"),
+		BytesPerSecond: environment.GetInt64("SCRUB_BYTES_PER_SECOND"),
+	}
+	if config.Interval <= 0 {
+		config.Interval = 24 * time.Hour
+	}
+	if config.BytesPerSecond <= 0 {
+		config.BytesPerSecond = 8 << 20
+	}
+	return config
+}
+
+// ScrubReport is the result of a scrubbing pass.
+type ScrubReport struct {
+	StartedAt  time.Time       `json:"startedAt"`  // StartedAt is the time the pass started.
+	FinishedAt time.Time       `json:"finishedAt"` // FinishedAt is the time the pass finished.
+	Checked    int             `json:"checked"`    // Checked is the number of files whose checksum was verified.
+	Corrupted  []FileFinding   `json:"corrupted"`  // Corrupted are the files that don't match their checksum.
+	Missing    []FileFinding   `json:"missing"`    // Missing are the files that can't be reached at their path.
+	Orphaned   []OrphanFinding `json:"orphaned"`   // Orphaned are the files that no ID references.
+
+	checkedByTenant map[string]int // checkedByTenant is the number of files verified of every tenant.
+}
+
+// FileFinding is a problem found with the file of an ID.
+type FileFinding struct {
+	Tenant string `json:"-"`     // Tenant is the tenant the ID belongs to.
+	ID     int64  `json:"id"`    // ID is the identifier of the file.
+	Error  string `json:"error"` // Error describes the problem.
+}
+
+// OrphanFinding is a file found in the storage root of a tenant that no ID references.
+type OrphanFinding struct {
+	Tenant     string `json:"-"`          // Tenant is the tenant whose storage root holds the file.
+	Path       string `json:"path"`       // Path is the location of the file, relative to the storage root.
+	StoredSize int64  `json:"storedSize"` // StoredSize is the number of bytes the file takes in the storage.
+}
+
+// Scrubber is a background job that periodically re-reads every stored file to verify its checksum,
+// looking also for files that can't be reached at their path and for files no ID references.
+// Reads are rate limited so the job doesn't starve live traffic.
+type Scrubber struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	blobs   blobstore.BlobStore
+	config  ScrubConfig
+	mu      sync.RWMutex
+	last    *ScrubReport
+}
+
+// NewScrubber creates a new Scrubber that verifies the files of the given path service and blob store.
+func NewScrubber(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	blobs blobstore.BlobStore,
+	config ScrubConfig,
+) *Scrubber {
+	return &Scrubber{logger: logger, pathsrv: pathsrv, blobs: blobs, config: config}
+}
+
+// Run scrubs the storage every configured interval until the context is done.
+// The first pass starts right away.
+func (s *Scrubber) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Scrub(ctx); err != nil {
+			s.logger.Error(ctx, "Scrubbing failed: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scrub performs a single scrubbing pass, logging every finding, and keeps its report as the latest one.
+func (s *Scrubber) Scrub(ctx context.Context) (ScrubReport, error) {
+	report := ScrubReport{StartedAt: time.Now(), checkedByTenant: make(map[string]int)}
+	found, err := listBlobs(s.blobs, report.StartedAt.Add(-orphanGracePeriod))
+	if err != nil {
+		return report, fmt.Errorf("failed to list stored files: %w", err)
+	}
+	entries, err := s.pathsrv.All(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list paths: %w", err)
+	}
+
+	for _, orphan := range unreferenced(found, entries) {
+		finding := OrphanFinding{orphan.tenant, orphan.relativePath(), orphan.StoredSize}
+		s.logger.Error(ctx, "Scrubber found orphaned file %s of tenant %s", orphan.Path, orphan.tenant)
+		report.Orphaned = append(report.Orphaned, finding)
+	}
+
+	limiter := newRateLimiter(s.config.BytesPerSecond)
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		err := s.verify(ctx, entry, limiter)
+		switch {
+		case err == nil:
+			report.Checked++
+			report.checkedByTenant[entry.Tenant]++
+		case errors.Is(err, fs.ErrNotExist):
+			if s.stillExists(ctx, entry) {
+				s.logger.Error(ctx, "Scrubber found missing file %d of tenant %s", entry.ID, entry.Tenant)
+				finding := FileFinding{entry.Tenant, entry.ID, "file can't be reached at its path"}
+				report.Missing = append(report.Missing, finding)
+			}
+		case ctx.Err() != nil:
+			return report, ctx.Err()
+		default:
+			s.logger.Error(
+				ctx,
+				"Scrubber found corrupted file %d of tenant %s: %s",
+				entry.ID,
+				entry.Tenant,
+				err.Error(),
+			)
+			report.Corrupted = append(report.Corrupted, FileFinding{entry.Tenant, entry.ID, err.Error()})
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	s.logger.Info(
+		ctx,
+		"Scrubbing finished: %d files checked, %d corrupted, %d missing, %d orphaned",
+		report.Checked,
+		len(report.Corrupted),
+		len(report.Missing),
+		len(report.Orphaned),
+	)
+	s.mu.Lock()
+	s.last = &report
+	s.mu.Unlock()
+	return report, nil
+}
+
+// Report returns the findings of the latest scrubbing pass that concern the tenant found in the context.
+// It returns an ErrNotFound error if no pass has finished yet.
+func (s *Scrubber) Report(ctx context.Context) (ScrubReport, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return ScrubReport{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.last == nil {
+		return ScrubReport{}, fmt.Errorf("%w: no scrubbing pass has finished yet", errs.ErrNotFound)
+	}
+	report := ScrubReport{
+		StartedAt:  s.last.StartedAt,
+		FinishedAt: s.last.FinishedAt,
+		Checked:    s.last.checkedByTenant[tenant],
+		Corrupted:  []FileFinding{},
+		Missing:    []FileFinding{},
+		Orphaned:   []OrphanFinding{},
+	}
+	for _, finding := range s.last.Corrupted {
+		if finding.Tenant == tenant {
+			report.Corrupted = append(report.Corrupted, finding)
+		}
+	}
+	for _, finding := range s.last.Missing {
+		if finding.Tenant == tenant {
+			report.Missing = append(report.Missing, finding)
+		}
+	}
+	for _, finding := range s.last.Orphaned {
+		if finding.Tenant == tenant {
+			report.Orphaned = append(report.Orphaned, finding)
+		}
+	}
+	return report, nil
+}
+
+// verify re-reads the file of the given entry and compares it against its stored checksums.
+func (s *Scrubber) verify(
+	ctx context.Context,
+	entry pathrepository.PathEntry,
+	limiter *rateLimiter,
+) error {
+	content, err := storageservice.OpenContent(s.blobs, entry.Record)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	sum, err := checksum.Compute(throttledReader{ctx, content, limiter})
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if entry.Record.SHA256 != "" && sum.SHA256 != entry.Record.SHA256 {
+		return fmt.Errorf(
+			"%w: expected sha-256 %s, got %s",
+			checksum.ErrMismatch,
+			entry.Record.SHA256,
+			sum.SHA256,
+		)
+	}
+	if entry.Record.CRC32C != 0 && sum.CRC32C != entry.Record.CRC32C {
+		return fmt.Errorf(
+			"%w: expected crc32c %d, got %d",
+			checksum.ErrMismatch,
+			entry.Record.CRC32C,
+			sum.CRC32C,
+		)
+	}
+	return nil
+}
+
+// stillExists reports whether the path record of the given entry still exists, so files deleted during
+// the pass aren't reported as missing.
+func (s *Scrubber) stillExists(ctx context.Context, entry pathrepository.PathEntry) bool {
+	record, err := s.pathsrv.GetPath(tenancy.WithTenant(ctx, entry.Tenant), entry.ID)
+	return err == nil && record.Path == entry.Record.Path
+}
@@ -0,0 +1,134 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/checksum"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// storeFile writes content to the storage root of the tenant found in ctx and saves its path under id.
+func storeFile(
+	t *testing.T,
+	ctx context.Context,
+	paths pathservice.PathService,
+	id int64,
+	name string,
+	content string,
+) string {
+	t.Helper()
+	tenant, _ := tenancy.FromContext(ctx)
+	path := filepath.Join(tenancy.StorageRoot(tenant), name)
+	writeFile(t, path, content)
+	sum, err := checksum.Compute(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := pathrepository.PathRecord{
+		Path:       path,
+		Size:       int64(len(content)),
+		StoredSize: int64(len(content)),
+		SHA256:     sum.SHA256,
+		CRC32C:     sum.CRC32C,
+	}
+	if err := paths.SavePath(ctx, id, record); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeFile writes content to path, backdating it so it's old enough to be considered an orphan.
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+
+	storeFile(t, ctx, paths, 1, "intact.txt", "intact content")
+	corrupted := storeFile(t, ctx, paths, 2, "corrupted.txt", "original content")
+	writeFile(t, corrupted, "tampered content")
+	missing := storeFile(t, ctx, paths, 3, "missing.txt", "missing content")
+	os.Remove(missing)
+	writeFile(t, filepath.Join(tenancy.StorageRoot("acme"), "orphan.txt"), "orphaned content")
+	storeFile(t, tenancy.WithTenant(context.Background(), "other"), paths, 1, "other.txt", "other content")
+
+	scrubber := NewScrubber(logger, paths, blobstore.NewFileSystem(nil), ScrubConfig{time.Hour, 1 << 30})
+	if _, err := scrubber.Report(ctx); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound before any pass, got: %v", err)
+	}
+	if _, err := scrubber.Scrub(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := scrubber.Report(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 1 {
+		t.Errorf("Expected 1 checked file, got: %d", report.Checked)
+	}
+	if len(report.Corrupted) != 1 || report.Corrupted[0].ID != 2 {
+		t.Errorf("Expected file 2 to be corrupted, got: %v", report.Corrupted)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].ID != 3 {
+		t.Errorf("Expected file 3 to be missing, got: %v", report.Missing)
+	}
+	if len(report.Orphaned) != 1 || report.Orphaned[0].Path != "orphan.txt" {
+		t.Errorf("Expected orphan.txt to be orphaned, got: %v", report.Orphaned)
+	}
+
+	other, err := scrubber.Report(tenancy.WithTenant(context.Background(), "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Checked != 1 || len(other.Corrupted)+len(other.Missing)+len(other.Orphaned) != 0 {
+		t.Errorf("Expected a clean report for the other tenant, got: %+v", other)
+	}
+}
+
+func TestScrubSkipsRecentFiles(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	path := filepath.Join(tenancy.StorageRoot("acme"), "uploading.txt")
+	writeFile(t, path, "upload in progress")
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	scrubber := NewScrubber(logger, paths, blobstore.NewFileSystem(nil), ScrubConfig{time.Hour, 1 << 30})
+	report, err := scrubber.Scrub(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphaned) != 0 {
+		t.Errorf("Expected recent files not to be orphaned, got: %v", report.Orphaned)
+	}
+}
@@ -0,0 +1,105 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// TrashConfig configures the TrashPurger.
+type TrashConfig struct {
+	Interval  time.Duration // Interval is the time between two purges.
+	Retention time.Duration // Retention is how long files stay in the trash before being purged.
+}
+
+// TrashConfigFromEnvironment reads the trash configuration from the environment variables
+// TRASH_PURGE_INTERVAL, which defaults to 1h, and TRASH_RETENTION, which defaults to 30 days.
+func TrashConfigFromEnvironment() TrashConfig {
+	config := TrashConfig{
+		Interval:  environment.GetDuration("TRASH_PURGE_INTERVAL"),
+		Retention: environment.GetDuration("TRASH_RETENTION"),
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Hour
+	}
+	if config.Retention <= 0 {
+		config.Retention = 30 * 24 * time.Hour
+	}
+	return config
+}
+
+// TrashPurger is a background job that periodically purges the files that have been in the trash
+// for longer than the retention period.
+type TrashPurger struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	storage storageservice.StorageService
+	config  TrashConfig
+}
+
+// NewTrashPurger creates a new TrashPurger that purges the expired files of the given path service
+// through the storage service.
+func NewTrashPurger(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	storage storageservice.StorageService,
+	config TrashConfig,
+) *TrashPurger {
+	return &TrashPurger{logger, pathsrv, storage, config}
+}
+
+// Run purges the expired files every configured interval until the context is done.
+// The first purge starts right away.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.Purge(ctx); err != nil {
+			p.logger.Error(ctx, "Purging the trash failed: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge permanently removes the files of every tenant that were moved to the trash before the retention period.
+// It returns the number of purged files. Files that fail to be purged are logged and retried on the next purge.
+func (p *TrashPurger) Purge(ctx context.Context) (int, error) {
+	entries, err := p.pathsrv.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	expiredBefore := time.Now().Add(-p.config.Retention)
+	purged := 0
+	for _, entry := range entries {
+		if !entry.Record.Trashed() || entry.Record.DeletedAt.After(expiredBefore) {
+			continue
+		}
+		err := p.storage.Purge(tenancy.WithTenant(ctx, entry.Tenant), entry.ID)
+		if errors.Is(err, errs.ErrNotFound) {
+			continue // Restored or purged since the entries were listed.
+		}
+		if errors.Is(err, errs.ErrRetained) {
+			continue // Retained since it was moved to the trash, it's purged once the retention is over.
+		}
+		if err != nil {
+			p.logger.Error(ctx, "Failed to purge file %d of tenant %s: %s", entry.ID, entry.Tenant, err.Error())
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		p.logger.Info(ctx, "Purged %d files from the trash", purged)
+	}
+	return purged, nil
+}
@@ -0,0 +1,60 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	storage := storageservice.New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, paths),
+		compression.None,
+		scanning.Policy{},
+	)
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	storeFile(t, ctx, paths, 1, "expired.txt", "expired")
+	storeFile(t, ctx, paths, 2, "recent.txt", "recent")
+	storeFile(t, ctx, paths, 3, "live.txt", "live")
+	for id, deletedAt := range map[int64]time.Time{1: time.Now().Add(-2 * time.Hour), 2: time.Now()} {
+		record, _ := paths.GetPath(ctx, id)
+		record.DeletedAt = deletedAt
+		paths.SavePath(ctx, id, record)
+	}
+
+	purger := NewTrashPurger(logger, paths, storage, TrashConfig{time.Hour, time.Hour})
+	purged, err := purger.Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged file, got: %d", purged)
+	}
+	if exists, _ := paths.Exists(ctx, 1); exists {
+		t.Errorf("Expected expired file to be purged")
+	}
+	for _, id := range []int64{2, 3} {
+		if exists, _ := paths.Exists(ctx, id); !exists {
+			t.Errorf("Expected file %d to be kept", id)
+		}
+	}
+}
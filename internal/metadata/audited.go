@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"context"
+
+	"github.com/lucastomic/dmsStorageService/internal/audit"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+// NewAudited wraps a Service so every change of the tags or the metadata of a document, successful or not, is
+// recorded in the audit log. Failing to record a change doesn't fail it, but it's logged.
+func NewAudited(service Service, log audit.Log, logger logging.Logger) Service {
+	return &auditedService{service, log, logger}
+}
+
+// auditedService implements the Service interface, recording the changes made through the wrapped one.
+type auditedService struct {
+	Service
+	log    audit.Log
+	logger logging.Logger
+}
+
+// SetTags replaces the tags of the document and records the change.
+func (s *auditedService) SetTags(ctx context.Context, id int64, tags []string) (Labels, error) {
+	labels, err := s.Service.SetTags(ctx, id, tags)
+	s.record(ctx, audit.ActionChangeTags, id, err)
+	return labels, err
+}
+
+// AddTags adds the tags to the document and records the change.
+func (s *auditedService) AddTags(ctx context.Context, id int64, tags []string) (Labels, error) {
+	labels, err := s.Service.AddTags(ctx, id, tags)
+	s.record(ctx, audit.ActionChangeTags, id, err)
+	return labels, err
+}
+
+// RemoveTag removes the tag from the document and records the change.
+func (s *auditedService) RemoveTag(ctx context.Context, id int64, tag string) (Labels, error) {
+	labels, err := s.Service.RemoveTag(ctx, id, tag)
+	s.record(ctx, audit.ActionChangeTags, id, err)
+	return labels, err
+}
+
+// SetMetadata replaces the metadata of the document and records the change.
+func (s *auditedService) SetMetadata(ctx context.Context, id int64, metadata map[string]string) (Labels, error) {
+	labels, err := s.Service.SetMetadata(ctx, id, metadata)
+	s.record(ctx, audit.ActionChangeMetadata, id, err)
+	return labels, err
+}
+
+// UpdateMetadata updates the metadata of the document and records the change.
+func (s *auditedService) UpdateMetadata(
+	ctx context.Context,
+	id int64,
+	metadata map[string]string,
+) (Labels, error) {
+	labels, err := s.Service.UpdateMetadata(ctx, id, metadata)
+	s.record(ctx, audit.ActionChangeMetadata, id, err)
+	return labels, err
+}
+
+// DeleteMetadata removes the key from the metadata of the document and records the change.
+func (s *auditedService) DeleteMetadata(ctx context.Context, id int64, key string) (Labels, error) {
+	labels, err := s.Service.DeleteMetadata(ctx, id, key)
+	s.record(ctx, audit.ActionChangeMetadata, id, err)
+	return labels, err
+}
+
+// SetType sets the document type of the document and records the change.
+func (s *auditedService) SetType(ctx context.Context, id int64, docType string) (Labels, error) {
+	labels, err := s.Service.SetType(ctx, id, docType)
+	s.record(ctx, audit.ActionChangeType, id, err)
+	return labels, err
+}
+
+// record records a change in the audit log, logging any failure.
+func (s *auditedService) record(ctx context.Context, action string, id int64, err error) {
+	if auditErr := s.log.Record(ctx, action, id, err); auditErr != nil {
+		s.logger.Error(ctx, "Failed to audit %s of file %d: %s", action, id, auditErr.Error())
+	}
+}
@@ -0,0 +1,114 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+// Limits on the labels of a single document.
+const (
+	maxTags        = 64   // maxTags is the maximum number of tags of a document.
+	maxKeys        = 64   // maxKeys is the maximum number of metadata keys of a document.
+	maxValueLength = 1024 // maxValueLength is the maximum length of a metadata value, in bytes.
+)
+
+var (
+	// tagPattern restricts tags, once lowercased, to characters that can't be taken for the operators or the
+	// parentheses of a tag query, e.g. "invoice", "client:acme" or "fy2024".
+	tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9:_./@+-]{0,63}$`)
+	// keyPattern restricts metadata keys to characters that can't be taken for the operators of a condition.
+	keyPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+)
+
+// Service manages the tags and the key/value metadata of the documents of every tenant, and finds the documents
+// matching a Query. Both are kept in the path records, whose repository indexes them. The tenant is always
+// taken from the context.
+// The changes are applied to the documents that aren't in the trash, and publish a document.relabeled event.
+// Like other changes of a document, they fail with an ErrLocked error if it's checked out by another user, or
+// an ErrRetained error if it's retained.
+type Service interface {
+	// Get returns the tags and the metadata of the document of the given ID.
+	// It returns an ErrNotFound error if the document doesn't exist.
+	Get(ctx context.Context, id int64) (Labels, error)
+
+	// SetTags replaces the tags of the document of the given ID.
+	SetTags(ctx context.Context, id int64, tags []string) (Labels, error)
+
+	// AddTags adds the given tags to the document of the given ID. Tags it already has are ignored.
+	AddTags(ctx context.Context, id int64, tags []string) (Labels, error)
+
+	// RemoveTag removes the given tag from the document of the given ID.
+	// It returns an ErrNotFound error if the document doesn't have the tag.
+	RemoveTag(ctx context.Context, id int64, tag string) (Labels, error)
+
+	// SetMetadata replaces the metadata of the document of the given ID.
+	// Like every change of the metadata, it returns an errs.ValidationError if the metadata doesn't satisfy the
+	// schema of the document type of the document.
+	SetMetadata(ctx context.Context, id int64, metadata map[string]string) (Labels, error)
+
+	// UpdateMetadata sets the given keys of the metadata of the document of the given ID, keeping the others.
+	UpdateMetadata(ctx context.Context, id int64, metadata map[string]string) (Labels, error)
+
+	// DeleteMetadata removes the given key from the metadata of the document of the given ID.
+	// It returns an ErrNotFound error if the document doesn't have the key.
+	DeleteMetadata(ctx context.Context, id int64, key string) (Labels, error)
+
+	// SetType sets the document type of the document of the given ID, empty for none. It returns an
+	// errs.ValidationError if the metadata of the document doesn't satisfy the schema of the type.
+	SetType(ctx context.Context, id int64, docType string) (Labels, error)
+
+	// Find returns the IDs of the documents matching the query, whether they're in the trash or not.
+	Find(ctx context.Context, query Query) (map[int64]bool, error)
+}
+
+// Labels are the tags, the metadata and the document type of a document.
+type Labels struct {
+	Tags     []string          `json:"tags"`           // Tags are the tags of the document, sorted.
+	Metadata map[string]string `json:"metadata"`       // Metadata are the key/value pairs of the document.
+	Type     string            `json:"type,omitempty"` // Type is the document type of the document, if any.
+}
+
+// NormalizeTag returns the tag lowercased, as tags are case-insensitive, or an ErrInvalidInput error if it's
+// not a valid tag: 1-64 letters, digits or any of ":_./@+-", starting with a letter or a digit.
+func NormalizeTag(tag string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(tag))
+	if !tagPattern.MatchString(normalized) || isOperator(normalized) {
+		return "", fmt.Errorf("%w: %q is not a valid tag", errs.ErrInvalidInput, tag)
+	}
+	return normalized, nil
+}
+
+// ValidateKey checks the metadata key is 1-64 letters, digits or any of "_.-", returning an ErrInvalidInput
+// error otherwise.
+func ValidateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("%w: %q is not a valid metadata key", errs.ErrInvalidInput, key)
+	}
+	return nil
+}
+
+// ValidateMetadata checks there aren't too many keys in the metadata, every key is valid and every value isn't
+// too long, returning an ErrInvalidInput error otherwise.
+func ValidateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxKeys {
+		return fmt.Errorf("%w: documents can have up to %d metadata keys", errs.ErrInvalidInput, maxKeys)
+	}
+	for key, value := range metadata {
+		if err := ValidateKey(key); err != nil {
+			return err
+		}
+		if len(value) > maxValueLength {
+			return fmt.Errorf(
+				"%w: the value of metadata key %q can be up to %d bytes long",
+				errs.ErrInvalidInput,
+				key,
+				maxValueLength,
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+// maxQueryTokens is the maximum number of tags, operators and parentheses of a tag query.
+const maxQueryTokens = 256
+
+// Operators of the conditions on metadata, longest first, so "<=" isn't taken for "<".
+var operators = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// Query selects documents by their tags and metadata. Its zero value matches every document.
+type Query struct {
+	Tags       Expr        // Tags is the boolean expression the tags of the documents satisfy, nil for any tags.
+	Conditions []Condition // Conditions are the conditions the metadata of the documents satisfy, all of them.
+}
+
+// Empty reports whether the query matches every document.
+func (q Query) Empty() bool {
+	return q.Tags == nil && len(q.Conditions) == 0
+}
+
+// ParseQuery parses a query made of a tag expression, empty for any tags, and conditions on metadata, as
+// described by ParseTags and ParseCondition.
+func ParseQuery(tags string, conditions []string) (Query, error) {
+	var query Query
+	if strings.TrimSpace(tags) != "" {
+		expr, err := ParseTags(tags)
+		if err != nil {
+			return Query{}, err
+		}
+		query.Tags = expr
+	}
+	for _, condition := range conditions {
+		parsed, err := ParseCondition(condition)
+		if err != nil {
+			return Query{}, err
+		}
+		query.Conditions = append(query.Conditions, parsed)
+	}
+	return query, nil
+}
+
+// Expr is a boolean expression on the tags of documents.
+type Expr interface {
+	// eval returns the IDs of the documents satisfying the expression.
+	eval(idx index) (map[int64]bool, error)
+}
+
+// index gives access to the indexes of the tags and the metadata of the documents of a tenant.
+type index interface {
+	tagged(tag string) (map[int64]bool, error)   // tagged returns the IDs of the documents with the tag.
+	values(key string) (map[int64]string, error) // values returns the values of the metadata key by ID.
+	all() (map[int64]bool, error)                // all returns the IDs of every document.
+}
+
+// tagExpr is satisfied by the documents with a tag.
+type tagExpr string
+
+// notExpr is satisfied by the documents not satisfying an expression.
+type notExpr struct{ expr Expr }
+
+// andExpr is satisfied by the documents satisfying both expressions.
+type andExpr struct{ left, right Expr }
+
+// orExpr is satisfied by the documents satisfying either expression.
+type orExpr struct{ left, right Expr }
+
+func (e tagExpr) eval(idx index) (map[int64]bool, error) {
+	return idx.tagged(string(e))
+}
+
+func (e notExpr) eval(idx index) (map[int64]bool, error) {
+	excluded, err := e.expr.eval(idx)
+	if err != nil {
+		return nil, err
+	}
+	all, err := idx.all()
+	if err != nil {
+		return nil, err
+	}
+	for id := range excluded {
+		delete(all, id)
+	}
+	return all, nil
+}
+
+func (e andExpr) eval(idx index) (map[int64]bool, error) {
+	left, err := e.left.eval(idx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(idx)
+	if err != nil {
+		return nil, err
+	}
+	return intersect(left, right), nil
+}
+
+func (e orExpr) eval(idx index) (map[int64]bool, error) {
+	left, err := e.left.eval(idx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(idx)
+	if err != nil {
+		return nil, err
+	}
+	for id := range right {
+		left[id] = true
+	}
+	return left, nil
+}
+
+// ParseTags parses a boolean expression on tags, such as "invoice AND (client:acme OR client:globex) AND NOT
+// draft". The operators are case-insensitive, NOT binds tighter than AND, and AND tighter than OR. Tags next to
+// each other are joined with AND, so "invoice fy2024" is "invoice AND fy2024". It returns an ErrInvalidInput
+// error if the expression is malformed.
+func ParseTags(query string) (Expr, error) {
+	tokens := tokenize(query)
+	if len(tokens) > maxQueryTokens {
+		return nil, fmt.Errorf("%w: tag queries can have up to %d terms", errs.ErrInvalidInput, maxQueryTokens)
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if token, ok := p.peek(); ok {
+		return nil, fmt.Errorf("%w: unexpected %q in tag query", errs.ErrInvalidInput, token)
+	}
+	return expr, nil
+}
+
+// tokenize splits a tag query into tags, operators and parentheses.
+func tokenize(query string) []string {
+	query = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(query)
+	return strings.Fields(query)
+}
+
+// isOperator reports whether the token is an operator of tag queries.
+func isOperator(token string) bool {
+	switch strings.ToUpper(token) {
+	case "AND", "OR", "NOT":
+		return true
+	}
+	return false
+}
+
+// parser parses the tokens of a tag query by recursive descent.
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+// peek returns the next token, if any, without consuming it.
+func (p *parser) peek() (string, bool) {
+	if p.pos == len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it's the given operator or parenthesis.
+func (p *parser) accept(want string) bool {
+	if token, ok := p.peek(); ok && strings.EqualFold(token, want) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// or parses terms joined with OR.
+func (p *parser) or() (Expr, error) {
+	expr, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		expr = orExpr{expr, right}
+	}
+	return expr, nil
+}
+
+// and parses terms joined with AND, or just next to each other.
+func (p *parser) and() (Expr, error) {
+	expr, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, ok := p.peek()
+		if !ok || token == ")" || strings.EqualFold(token, "OR") {
+			return expr, nil
+		}
+		p.accept("AND")
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		expr = andExpr{expr, right}
+	}
+}
+
+// unary parses a negated term, a parenthesized expression or a tag.
+func (p *parser) unary() (Expr, error) {
+	switch {
+	case p.accept("NOT"):
+		expr, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	case p.accept("("):
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("%w: missing closing parenthesis in tag query", errs.ErrInvalidInput)
+		}
+		return expr, nil
+	}
+	token, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("%w: tag query ends unexpectedly", errs.ErrInvalidInput)
+	}
+	if token == ")" || isOperator(token) {
+		return nil, fmt.Errorf("%w: unexpected %q in tag query", errs.ErrInvalidInput, token)
+	}
+	p.pos++
+	tag, err := NormalizeTag(token)
+	if err != nil {
+		return nil, err
+	}
+	return tagExpr(tag), nil
+}
+
+// Condition is a condition on the value of a metadata key, satisfied by the documents that have the key with
+// a matching value. Values are compared as numbers if both are, and as strings otherwise, so dates are
+// compared correctly if they're written as "2024-03-01".
+type Condition struct {
+	Key   string // Key is the metadata key.
+	Op    string // Op is the comparison, one of "=", "!=", ">", ">=", "<" and "<=".
+	Value string // Value is the value compared with.
+}
+
+// ParseCondition parses a condition on metadata such as "client=acme" or "amount>=1000".
+// It returns an ErrInvalidInput error if the condition is malformed.
+func ParseCondition(condition string) (Condition, error) {
+	at := strings.IndexAny(condition, "!=<>")
+	if at < 0 {
+		return Condition{}, fmt.Errorf("%w: condition %q has no comparison", errs.ErrInvalidInput, condition)
+	}
+	key, rest := condition[:at], condition[at:]
+	if err := ValidateKey(key); err != nil {
+		return Condition{}, err
+	}
+	for _, op := range operators {
+		if value, found := strings.CutPrefix(rest, op); found {
+			return Condition{Key: key, Op: op, Value: value}, nil
+		}
+	}
+	return Condition{}, fmt.Errorf("%w: condition %q has no valid comparison", errs.ErrInvalidInput, condition)
+}
+
+// Matches reports whether the value satisfies the condition.
+func (c Condition) Matches(value string) bool {
+	cmp := compare(value, c.Value)
+	switch c.Op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// eval returns the IDs of the documents satisfying the condition.
+func (c Condition) eval(idx index) (map[int64]bool, error) {
+	values, err := idx.values(c.Key)
+	if err != nil {
+		return nil, err
+	}
+	matching := make(map[int64]bool)
+	for id, value := range values {
+		if c.Matches(value) {
+			matching[id] = true
+		}
+	}
+	return matching, nil
+}
+
+// eval returns the IDs of the documents matching the query.
+func (q Query) eval(idx index) (map[int64]bool, error) {
+	var matching map[int64]bool
+	var err error
+	if q.Tags != nil {
+		matching, err = q.Tags.eval(idx)
+	} else if len(q.Conditions) == 0 {
+		matching, err = idx.all()
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, condition := range q.Conditions {
+		satisfying, err := condition.eval(idx)
+		if err != nil {
+			return nil, err
+		}
+		if matching == nil {
+			matching = satisfying
+			continue
+		}
+		matching = intersect(matching, satisfying)
+	}
+	return matching, nil
+}
+
+// compare compares two values as numbers if both are, and as strings otherwise.
+func compare(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// intersect returns the IDs in both sets.
+func intersect(a, b map[int64]bool) map[int64]bool {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	both := make(map[int64]bool, len(a))
+	for id := range a {
+		if b[id] {
+			both[id] = true
+		}
+	}
+	return both
+}
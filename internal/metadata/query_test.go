@@ -0,0 +1,108 @@
+package metadata
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+// fakeIndex is an index over a fixed set of documents.
+type fakeIndex struct {
+	tags     map[int64][]string
+	metadata map[int64]map[string]string
+}
+
+func (f fakeIndex) tagged(tag string) (map[int64]bool, error) {
+	tagged := make(map[int64]bool)
+	for id, tags := range f.tags {
+		for _, t := range tags {
+			if t == tag {
+				tagged[id] = true
+			}
+		}
+	}
+	return tagged, nil
+}
+
+func (f fakeIndex) values(key string) (map[int64]string, error) {
+	values := make(map[int64]string)
+	for id, metadata := range f.metadata {
+		if value, found := metadata[key]; found {
+			values[id] = value
+		}
+	}
+	return values, nil
+}
+
+func (f fakeIndex) all() (map[int64]bool, error) {
+	all := make(map[int64]bool)
+	for id := range f.tags {
+		all[id] = true
+	}
+	return all, nil
+}
+
+func TestQuery(t *testing.T) {
+	idx := fakeIndex{
+		tags: map[int64][]string{
+			1: {"invoice", "client:acme", "fy2024"},
+			2: {"invoice", "client:globex", "draft"},
+			3: {"contract", "client:acme"},
+			4: {},
+		},
+		metadata: map[int64]map[string]string{
+			1: {"amount": "1200", "issued": "2024-03-01"},
+			2: {"amount": "90", "issued": "2024-11-15"},
+			3: {"issued": "2023-06-30"},
+		},
+	}
+	tests := []struct {
+		tags       string
+		conditions []string
+		expected   []int64
+	}{
+		{"invoice", nil, []int64{1, 2}},
+		{"INVOICE and not Draft", nil, []int64{1}},
+		{"invoice client:acme", nil, []int64{1}},
+		{"contract OR invoice AND draft", nil, []int64{2, 3}},
+		{"(contract OR invoice) AND client:acme", nil, []int64{1, 3}},
+		{"NOT (invoice OR contract)", nil, []int64{4}},
+		{"", []string{"amount>=100"}, []int64{1}},
+		{"", []string{"amount<1000"}, []int64{2}},
+		{"", []string{"issued>=2024-01-01", "issued<2024-06-01"}, []int64{1}},
+		{"client:acme", []string{"issued!=2024-03-01"}, []int64{3}},
+		{"", nil, []int64{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.tags, tt.conditions)
+		if err != nil {
+			t.Fatalf("Expected %q %v to parse, got: %v", tt.tags, tt.conditions, err)
+		}
+		matching, err := query.eval(idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := make(map[int64]bool)
+		for _, id := range tt.expected {
+			expected[id] = true
+		}
+		if !reflect.DeepEqual(matching, expected) {
+			t.Errorf("Expected %q %v to match %v, got: %v", tt.tags, tt.conditions, tt.expected, matching)
+		}
+	}
+}
+
+func TestMalformedQuery(t *testing.T) {
+	for _, tags := range []string{"invoice AND", "(invoice", "invoice)", "OR draft", "NOT", "in voice!"} {
+		if _, err := ParseTags(tags); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput parsing %q, got: %v", tags, err)
+		}
+	}
+	for _, condition := range []string{"amount", "=100", "amount!100", "bad key=1"} {
+		if _, err := ParseCondition(condition); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput parsing %q, got: %v", condition, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+
+	"github.com/lucastomic/dmsStorageService/internal/doctypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+)
+
+// Labeler changes the labels of documents, serialized with every other change of them, as
+// storageservice.StorageService does.
+type Labeler interface {
+	UpdateLabels(
+		ctx context.Context,
+		id int64,
+		change func(record *pathrepository.PathRecord) error,
+	) (storageservice.FileInfo, error)
+}
+
+// New returns a Service that keeps the tags and the metadata of the documents in their path records, changing
+// them through the given labeler and validating the metadata against the schema of the document type with the
+// given validator.
+func New(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	labeler Labeler,
+	types doctypes.Validator,
+) Service {
+	return &service{logger: logger, pathsrv: pathsrv, labeler: labeler, types: types}
+}
+
+// service implements the Service interface.
+type service struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	labeler Labeler
+	types   doctypes.Validator
+}
+
+// Get returns the tags and the metadata of the document of the given ID.
+func (s *service) Get(ctx context.Context, id int64) (Labels, error) {
+	record, err := s.pathsrv.GetPath(ctx, id)
+	if err != nil {
+		return Labels{}, err
+	}
+	return labelsOf(record), nil
+}
+
+// SetTags replaces the tags of the document, normalizing them and dropping the repeated ones.
+func (s *service) SetTags(ctx context.Context, id int64, tags []string) (Labels, error) {
+	return s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		normalized, err := normalizeTags(tags)
+		record.Tags = normalized
+		return err
+	})
+}
+
+// AddTags adds the tags to those of the document.
+func (s *service) AddTags(ctx context.Context, id int64, tags []string) (Labels, error) {
+	return s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		normalized, err := normalizeTags(append(append([]string{}, record.Tags...), tags...))
+		record.Tags = normalized
+		return err
+	})
+}
+
+// RemoveTag removes the tag from those of the document.
+func (s *service) RemoveTag(ctx context.Context, id int64, tag string) (Labels, error) {
+	return s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		normalized, err := NormalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		kept := []string{}
+		for _, existing := range record.Tags {
+			if existing != normalized {
+				kept = append(kept, existing)
+			}
+		}
+		if len(kept) == len(record.Tags) {
+			return fmt.Errorf("%w: file with ID %d isn't tagged %q", errs.ErrNotFound, id, normalized)
+		}
+		record.Tags = kept
+		return nil
+	})
+}
+
+// SetMetadata replaces the metadata of the document.
+func (s *service) SetMetadata(ctx context.Context, id int64, metadata map[string]string) (Labels, error) {
+	return s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		record.Metadata = maps.Clone(metadata)
+		return s.validate(ctx, *record)
+	})
+}
+
+// UpdateMetadata sets the given keys of the metadata of the document.
+func (s *service) UpdateMetadata(ctx context.Context, id int64, metadata map[string]string) (Labels, error) {
+	return s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		updated := maps.Clone(record.Metadata)
+		if updated == nil {
+			updated = make(map[string]string, len(metadata))
+		}
+		maps.Copy(updated, metadata)
+		record.Metadata = updated
+		return s.validate(ctx, *record)
+	})
+}
+
+// DeleteMetadata removes the key from the metadata of the document.
+func (s *service) DeleteMetadata(ctx context.Context, id int64, key string) (Labels, error) {
+	return s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		if _, found := record.Metadata[key]; !found {
+			return fmt.Errorf("%w: file with ID %d has no metadata key %q", errs.ErrNotFound, id, key)
+		}
+		updated := maps.Clone(record.Metadata)
+		delete(updated, key)
+		record.Metadata = updated
+		return s.types.Validate(ctx, record.Type, updated)
+	})
+}
+
+// SetType sets the document type of the document, checking its metadata satisfies the schema of the type.
+func (s *service) SetType(ctx context.Context, id int64, docType string) (Labels, error) {
+	return s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		record.Type = docType
+		return s.types.Validate(ctx, docType, record.Metadata)
+	})
+}
+
+// Find returns the IDs of the documents of the tenant matching the query, looking them up in the indexes of
+// the repository.
+func (s *service) Find(ctx context.Context, query Query) (map[int64]bool, error) {
+	return query.eval(repositoryIndex{ctx, s.pathsrv})
+}
+
+// update changes the record of the document of the given ID with the given function through the labeler, which
+// saves it unless the function fails, and returns its labels. The function must not change the tags nor the
+// metadata of the record in place, as they're shared with the repository, but replace them.
+func (s *service) update(
+	ctx context.Context,
+	id int64,
+	change func(record *pathrepository.PathRecord) error,
+) (Labels, error) {
+	file, err := s.labeler.UpdateLabels(ctx, id, change)
+	if err != nil {
+		return Labels{}, err
+	}
+	return labelsOf(pathrepository.PathRecord{Tags: file.Tags, Metadata: file.Metadata, Type: file.Type}), nil
+}
+
+// validate checks the metadata of the record is valid and satisfies the schema of its document type.
+func (s *service) validate(ctx context.Context, record pathrepository.PathRecord) error {
+	if err := ValidateMetadata(record.Metadata); err != nil {
+		return err
+	}
+	return s.types.Validate(ctx, record.Type, record.Metadata)
+}
+
+// normalizeTags normalizes the tags, sorting them and dropping the repeated ones.
+func normalizeTags(tags []string) ([]string, error) {
+	unique := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		normalized, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		unique[normalized] = true
+	}
+	if len(unique) > maxTags {
+		return nil, fmt.Errorf("%w: documents can have up to %d tags", errs.ErrInvalidInput, maxTags)
+	}
+	normalized := make([]string, 0, len(unique))
+	for tag := range unique {
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// labelsOf returns the labels of the document described by the record, never nil.
+func labelsOf(record pathrepository.PathRecord) Labels {
+	labels := Labels{
+		Tags:     append([]string{}, record.Tags...),
+		Metadata: maps.Clone(record.Metadata),
+		Type:     record.Type,
+	}
+	if labels.Metadata == nil {
+		labels.Metadata = map[string]string{}
+	}
+	return labels
+}
+
+// repositoryIndex is the index of the documents of the tenant found in the context, served by the repository.
+type repositoryIndex struct {
+	ctx     context.Context
+	pathsrv pathservice.PathService
+}
+
+func (r repositoryIndex) tagged(tag string) (map[int64]bool, error) {
+	ids, err := r.pathsrv.Tagged(r.ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	tagged := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		tagged[id] = true
+	}
+	return tagged, nil
+}
+
+func (r repositoryIndex) values(key string) (map[int64]string, error) {
+	return r.pathsrv.MetadataValues(r.ctx, key)
+}
+
+func (r repositoryIndex) all() (map[int64]bool, error) {
+	entries, err := r.pathsrv.List(r.ctx)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[int64]bool, len(entries))
+	for _, entry := range entries {
+		all[entry.ID] = true
+	}
+	return all, nil
+}
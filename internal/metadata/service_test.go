@@ -0,0 +1,77 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/doctypes"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+func TestService(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	paths.SavePath(ctx, 1, pathrepository.PathRecord{Path: "invoice.pdf"})
+	paths.SavePath(ctx, 2, pathrepository.PathRecord{Path: "contract.pdf"})
+	service := New(logger, paths, pathLabeler{paths}, doctypes.New(logger, paths))
+
+	labels, err := service.SetTags(ctx, 1, []string{"Invoice", "client:acme", "invoice"})
+	if err != nil || !reflect.DeepEqual(labels.Tags, []string{"client:acme", "invoice"}) {
+		t.Fatalf("Expected the tags normalized and deduplicated, got: %v, %v", labels, err)
+	}
+	service.AddTags(ctx, 2, []string{"contract", "client:acme"})
+	if _, err := service.SetTags(ctx, 1, []string{"two words"}); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput setting an invalid tag, got: %v", err)
+	}
+	if _, err := service.RemoveTag(ctx, 2, "invoice"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound removing a tag the file doesn't have, got: %v", err)
+	}
+
+	service.SetMetadata(ctx, 1, map[string]string{"amount": "1200", "currency": "EUR"})
+	labels, err = service.UpdateMetadata(ctx, 1, map[string]string{"amount": "1500"})
+	if err != nil || !reflect.DeepEqual(labels.Metadata, map[string]string{"amount": "1500", "currency": "EUR"}) {
+		t.Errorf("Expected the metadata to be merged, got: %v, %v", labels, err)
+	}
+	service.SetMetadata(ctx, 2, map[string]string{"amount": "300"})
+
+	query, _ := ParseQuery("client:acme AND NOT contract", []string{"amount>1000"})
+	if matching, _ := service.Find(ctx, query); !reflect.DeepEqual(matching, map[int64]bool{1: true}) {
+		t.Errorf("Expected only file 1 to match, got: %v", matching)
+	}
+	service.RemoveTag(ctx, 1, "CLIENT:ACME")
+	service.DeleteMetadata(ctx, 1, "amount")
+	if matching, _ := service.Find(ctx, query); len(matching) != 0 {
+		t.Errorf("Expected no file to match once its labels are removed, got: %v", matching)
+	}
+}
+
+// pathLabeler changes the labels of documents straight in their path records.
+type pathLabeler struct {
+	paths pathservice.PathService
+}
+
+func (l pathLabeler) UpdateLabels(
+	ctx context.Context,
+	id int64,
+	change func(record *pathrepository.PathRecord) error,
+) (storageservice.FileInfo, error) {
+	record, err := l.paths.GetPath(ctx, id)
+	if err != nil {
+		return storageservice.FileInfo{}, err
+	}
+	if err := change(&record); err != nil {
+		return storageservice.FileInfo{}, err
+	}
+	if err := l.paths.SavePath(ctx, id, record); err != nil {
+		return storageservice.FileInfo{}, err
+	}
+	return storageservice.FileInfo{Tags: record.Tags, Metadata: record.Metadata, Type: record.Type}, nil
+}
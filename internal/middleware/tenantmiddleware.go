@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// tenantMiddleware is a middleware that resolves the tenant each HTTP request acts on behalf of.
+// As the service doesn't authenticate principals by itself, the tenant is taken from the
+// X-Tenant-ID header, which is expected to be set by the authenticating gateway in front of it.
+type tenantMiddleware struct{}
+
+// NewTenantMiddleware creates and returns a new instance of tenantMiddleware.
+// This middleware can be used to enforce the presence of a valid X-Tenant-ID header in incoming HTTP requests.
+func NewTenantMiddleware() Middleware {
+	return tenantMiddleware{}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain,
+// checking for the presence and validity of the X-Tenant-ID header in the request.
+// If the header is missing or malformed, it calls the errorHandler with a 400 status code.
+// Otherwise, it adds the tenant ID to the request's context and proceeds with the next handler.
+func (tenantMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get("X-Tenant-ID")
+		if tenant == "" {
+			errorHandler(r, w, errors.New("X-Tenant-ID can't be null"), http.StatusBadRequest)
+			return
+		}
+		if err := tenancy.Validate(tenant); err != nil {
+			errorHandler(r, w, err, http.StatusBadRequest)
+			return
+		}
+		*r = *r.WithContext(tenancy.WithTenant(r.Context(), tenant))
+		next(w, r)
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// TestTenantMiddlewareWithHeader tests the tenantMiddleware ensuring it passes the request through
+// with the tenant stored in the context when the X-Tenant-ID header is present.
+func TestTenantMiddlewareWithHeader(t *testing.T) {
+	middleware := NewTenantMiddleware()
+	var tenant string
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, _ = tenancy.FromContext(r.Context())
+	})
+
+	errorHandler := func(r *http.Request, w http.ResponseWriter, err error, statusCode int) {
+		t.Errorf("errorHandler should not be called when X-Tenant-ID is present")
+	}
+
+	handlerToTest := middleware.Execute(next, errorHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	w := httptest.NewRecorder()
+
+	handlerToTest.ServeHTTP(w, req)
+
+	if tenant != "acme" {
+		t.Errorf("Expected tenant 'acme' in the context, got '%v'", tenant)
+	}
+}
+
+// TestTenantMiddlewareInvalidHeader tests the tenantMiddleware ensuring it calls the errorHandler
+// when the X-Tenant-ID header is missing or could be used to escape the tenant's storage root.
+func TestTenantMiddlewareInvalidHeader(t *testing.T) {
+	for _, header := range []string{"", "../acme", "acme/files"} {
+		middleware := NewTenantMiddleware()
+		nextCalled := false
+		errorHandlerCalled := false
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nextCalled = true
+		})
+		errorHandler := func(r *http.Request, w http.ResponseWriter, err error, statusCode int) {
+			if statusCode != http.StatusBadRequest {
+				t.Errorf("Expected status code 400, got %v", statusCode)
+			}
+			errorHandlerCalled = true
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tenant-ID", header)
+		middleware.Execute(next, errorHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+		if nextCalled {
+			t.Errorf("Next handler should not be called for X-Tenant-ID '%v'", header)
+		}
+		if !errorHandlerCalled {
+			t.Errorf("Error handler was not called for X-Tenant-ID '%v'", header)
+		}
+	}
+}
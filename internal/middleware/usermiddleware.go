@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// userMiddleware is a middleware that resolves the user performing each HTTP request.
+// Like the tenant, the user is taken from a header set by the authenticating gateway, X-User-ID.
+// The header is optional: requests without it are attributed to the tenant only.
+type userMiddleware struct{}
+
+// NewUserMiddleware creates and returns a new instance of userMiddleware.
+func NewUserMiddleware() Middleware {
+	return userMiddleware{}
+}
+
+// Execute wraps the next http.HandlerFunc in the middleware chain.
+// If the X-User-ID header is present it is validated and added to the request's context,
+// otherwise the request proceeds without a user. A malformed header results in a 400 status code.
+func (userMiddleware) Execute(
+	next http.HandlerFunc,
+	errorHandler errorHandler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := r.Header.Get("X-User-ID")
+		if user == "" {
+			next(w, r)
+			return
+		}
+		if err := tenancy.ValidateUser(user); err != nil {
+			errorHandler(r, w, err, http.StatusBadRequest)
+			return
+		}
+		*r = *r.WithContext(tenancy.WithUser(r.Context(), user))
+		next(w, r)
+	}
+}
@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+)
+
+// Config configures the Relay.
+type Config struct {
+	Interval  time.Duration // Interval is the time between two polls of the outbox.
+	BatchSize int           // BatchSize is the number of events read from the outbox at once.
+}
+
+// ConfigFromEnvironment reads the relay configuration from the environment variables OUTBOX_POLL_INTERVAL,
+// which defaults to 1s, and OUTBOX_BATCH_SIZE, which defaults to 100.
+func ConfigFromEnvironment() Config {
+	config := Config{
+		Interval:  environment.GetDuration("OUTBOX_POLL_INTERVAL"),
+		BatchSize: int(environment.GetInt64("OUTBOX_BATCH_SIZE")),
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	return config
+}
+
+// Relay is a background job that publishes the events of the outbox, in the order they were written, through
+// several publishers, and removes them from it once every publisher got them. Each publisher keeps its own
+// cursor, so one failing doesn't hold back or republish to the others: it retries from the event it failed on
+// at the next poll, while the events after it stay in the outbox. No event is lost, though a publisher may get
+// one more than once if the relay restarts before it's removed.
+type Relay struct {
+	logger     logging.Logger
+	pathsrv    pathservice.PathService
+	publishers []events.EventPublisher
+	config     Config
+
+	mu      sync.Mutex
+	cursors []int64 // cursors holds the sequence number of the last event each publisher got.
+}
+
+// NewRelay creates a new Relay publishing the outbox of the given path service through the publishers.
+func NewRelay(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	publishers []events.EventPublisher,
+	config Config,
+) *Relay {
+	return &Relay{
+		logger:     logger,
+		pathsrv:    pathsrv,
+		publishers: publishers,
+		config:     config,
+		cursors:    make([]int64, len(publishers)),
+	}
+}
+
+// Run relays the outbox every configured interval until the context is done.
+// The first poll starts right away.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Relay(ctx); err != nil {
+			r.logger.Error(ctx, "Relaying events failed: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Relay publishes the pending events of the outbox through every publisher, until each has caught up or
+// failed, and then removes the events all of them got. It returns the errors of the publishers that failed.
+func (r *Relay) Relay(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for i, publisher := range r.publishers {
+		cursor, err := r.publish(ctx, publisher, r.cursors[i])
+		r.cursors[i] = cursor
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(r.cursors) > 0 {
+		if err := r.pathsrv.AcknowledgeEvents(ctx, slices.Min(r.cursors)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to acknowledge events: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publish publishes the events of the outbox after the given sequence number through the publisher, batch by
+// batch, until there are none left or publishing fails. It returns the sequence number of the last event
+// published.
+func (r *Relay) publish(ctx context.Context, publisher events.EventPublisher, cursor int64) (int64, error) {
+	for ctx.Err() == nil {
+		entries, err := r.pathsrv.PendingEvents(ctx, cursor, r.config.BatchSize)
+		if err != nil || len(entries) == 0 {
+			return cursor, err
+		}
+		for _, entry := range entries {
+			if err := publisher.Publish(ctx, entry.Event); err != nil {
+				err = fmt.Errorf("failed to publish event %s through %T: %w", entry.Event.ID, publisher, err)
+				return cursor, err
+			}
+			cursor = entry.Seq
+		}
+	}
+	return cursor, nil
+}
@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// flakyPublisher fails to publish the event with the given ID until it's healed.
+type flakyPublisher struct {
+	*events.MemoryPublisher
+	failing string
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event events.Event) error {
+	if event.ID == p.failing {
+		return errors.New("broker unavailable")
+	}
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+// writeEvents writes count document creation events to the outbox of the given path service.
+func writeEvents(t *testing.T, ctx context.Context, paths pathservice.PathService, count int64) {
+	for id := int64(1); id <= count; id++ {
+		event := events.Event{ID: string(rune('a' + id - 1)), Type: events.DocumentCreated, DocumentID: id}
+		if err := paths.SavePathWithEvent(ctx, id, pathrepository.PathRecord{}, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRelay(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	writeEvents(t, ctx, paths, 5)
+	publisher := &flakyPublisher{events.NewMemoryPublisher(), "c"}
+	relay := NewRelay(logger, paths, []events.EventPublisher{publisher}, Config{time.Hour, 2})
+
+	if err := relay.Relay(ctx); err == nil {
+		t.Error("Expected the relay to fail at the failing event")
+	}
+	if pending, _ := paths.PendingEvents(ctx, 0, 10); len(pending) != 3 {
+		t.Errorf("Expected the failing event and those after it to stay in the outbox, got %v", pending)
+	}
+	publisher.failing = ""
+	if err := relay.Relay(ctx); err != nil {
+		t.Errorf("Expected the remaining events to be published, got %v", err)
+	}
+
+	relayed := publisher.Events()
+	if len(relayed) != 5 {
+		t.Fatalf("Expected every event to be published once, got %v", relayed)
+	}
+	for i, event := range relayed {
+		if event.DocumentID != int64(i+1) {
+			t.Errorf("Expected events in the order they were written, got %v", relayed)
+		}
+	}
+	if pending, _ := paths.PendingEvents(ctx, 0, 10); len(pending) != 0 {
+		t.Errorf("Expected the outbox to be empty, got %v", pending)
+	}
+}
+
+func TestRelayFailingPublisher(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	writeEvents(t, ctx, paths, 3)
+	healthy := events.NewMemoryPublisher()
+	failing := &flakyPublisher{events.NewMemoryPublisher(), "a"}
+	relay := NewRelay(logger, paths, []events.EventPublisher{failing, healthy}, Config{time.Hour, 2})
+
+	for i := 0; i < 3; i++ {
+		if err := relay.Relay(ctx); err == nil {
+			t.Error("Expected the relay to report the failing publisher")
+		}
+	}
+	if relayed := healthy.Events(); len(relayed) != 3 {
+		t.Errorf("Expected the healthy publisher to get every event exactly once, got %v", relayed)
+	}
+	if pending, _ := paths.PendingEvents(ctx, 0, 10); len(pending) != 3 {
+		t.Errorf("Expected the events to stay in the outbox until every publisher got them, got %v", pending)
+	}
+
+	failing.failing = ""
+	if err := relay.Relay(ctx); err != nil {
+		t.Errorf("Expected the healed publisher to catch up, got %v", err)
+	}
+	if relayed := failing.Events(); len(relayed) != 3 {
+		t.Errorf("Expected the healed publisher to get every event, got %v", relayed)
+	}
+	if relayed := healthy.Events(); len(relayed) != 3 {
+		t.Errorf("Expected the healthy publisher not to get any event again, got %v", relayed)
+	}
+	if pending, _ := paths.PendingEvents(ctx, 0, 10); len(pending) != 0 {
+		t.Errorf("Expected the outbox to be empty, got %v", pending)
+	}
+}
@@ -3,9 +3,15 @@ package pathrepository
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
 
 	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
 	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
 )
 
 // MemoryRepository returns an instance of PathRepository that operates in memory.
@@ -13,42 +19,360 @@ import (
 // for logging operations. This repository is intended for scenarios where persistence
 // beyond the application lifecycle is not required.
 func MemoryRepository(l logging.Logger) PathRepository {
-	b := make(map[int64]string)
+	b := make(map[recordKey]PathRecord)
 	return &memoryRepository{
-		logger: l,
-		buffer: &b,
+		logger:   l,
+		buffer:   &b,
+		tags:     make(map[indexKey]map[int64]bool),
+		metadata: make(map[indexKey]map[int64]string),
+		lastIDs:  make(map[string]int64),
 	}
 }
 
+// recordKey identifies a path inside the repository. IDs are only unique within a tenant,
+// so the tenant is part of every key.
+type recordKey struct {
+	tenant string
+	id     int64
+}
+
+// indexKey identifies an entry of an index: a tag, or a metadata key, of a tenant.
+type indexKey struct {
+	tenant string
+	name   string
+}
+
 // memoryRepository implements the PathRepository interface, providing an in-memory storage solution
-// for paths. It uses a map to associate paths with int64 IDs and supports operations to check existence,
-// save, and retrieve paths.
+// for paths. It uses a map to associate paths with tenant-scoped int64 IDs and supports operations to check
+// existence, save, and retrieve paths. The tenant is always taken from the context.
+// Changes made along with an event are transactional as they happen under the same lock as the outbox.
+// The tags and the metadata of the records are indexed, the indexes being updated along with the records.
 type memoryRepository struct {
-	logger logging.Logger    // logger for logging any errors or informational messages.
-	buffer *map[int64]string // buffer is a map that stores paths associated with their IDs.
+	logger   logging.Logger                // logger for logging any errors or informational messages.
+	mu       sync.RWMutex                  // mu guards buffer, the indexes and the outbox.
+	buffer   *map[recordKey]PathRecord     // buffer is a map that stores paths associated with their keys.
+	tags     map[indexKey]map[int64]bool   // tags indexes the IDs of the records with every tag.
+	metadata map[indexKey]map[int64]string // metadata indexes the values of every metadata key by record ID.
+	outbox   []OutboxEntry                 // outbox are the events waiting to be published, oldest first.
+	lastSeq  int64                         // lastSeq is the sequence number of the last event appended to the outbox.
+	lastIDs  map[string]int64              // lastIDs are the highest IDs every tenant has had or been given.
 }
 
 // Exists checks if a path associated with the given ID exists in the repository.
-// It returns true if the path exists, false otherwise. This method does not generate errors.
+// It returns true if the path exists, false otherwise. It fails if the context carries no tenant.
 func (m *memoryRepository) Exists(ctx context.Context, id int64) (bool, error) {
-	_, exists := (*m.buffer)[id]
+	key, err := keyFor(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, exists := (*m.buffer)[key]
 	return exists, nil
 }
 
-// SavePath stores a path associated with an ID in the repository.
-// It overwrites any existing path associated with the ID.
-func (m *memoryRepository) SavePath(ctx context.Context, id int64, path string) error {
-	(*m.buffer)[id] = path
+// SavePath stores a path record associated with an ID in the repository.
+// It overwrites any existing record associated with the ID.
+func (m *memoryRepository) SavePath(ctx context.Context, id int64, record PathRecord) error {
+	key, err := keyFor(ctx, id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store(key, record)
 	return nil
 }
 
-// GetPath retrieves a path associated with the given ID from the repository.
-// It returns an error if the path does not exist, logging the error before returning.
-func (m memoryRepository) GetPath(ctx context.Context, id int64) (string, error) {
-	path, exists := (*m.buffer)[id]
+// GetPath retrieves the path record associated with the given ID from the repository.
+// It returns an error if the path does not exist.
+func (m *memoryRepository) GetPath(ctx context.Context, id int64) (PathRecord, error) {
+	key, err := keyFor(ctx, id)
+	if err != nil {
+		return PathRecord{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, exists := (*m.buffer)[key]
 	if !exists {
-		errMsg := fmt.Sprintf("path with id %d not found", id)
-		return "", fmt.Errorf("%w: %s", errs.ErrNotFound, errMsg)
+		return PathRecord{}, notFound(id)
+	}
+	return record, nil
+}
+
+// DeletePath removes the path record associated with the given ID from the repository.
+// It returns an error if the path does not exist.
+func (m *memoryRepository) DeletePath(ctx context.Context, id int64) error {
+	key, err := keyFor(ctx, id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := (*m.buffer)[key]; !exists {
+		return notFound(id)
+	}
+	m.remove(key)
+	return nil
+}
+
+// List returns the path records of the tenant found in the context, sorted by ID.
+// It fails if the context carries no tenant.
+func (m *memoryRepository) List(ctx context.Context) ([]PathEntry, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := []PathEntry{}
+	for key, record := range *m.buffer {
+		if key.tenant == tenant {
+			entries = append(entries, PathEntry{Tenant: key.tenant, ID: key.id, Record: record})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// All returns a snapshot of the path records of every tenant.
+func (m *memoryRepository) All(ctx context.Context) ([]PathEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := make([]PathEntry, 0, len(*m.buffer))
+	for key, record := range *m.buffer {
+		entries = append(entries, PathEntry{Tenant: key.tenant, ID: key.id, Record: record})
+	}
+	return entries, nil
+}
+
+// SavePathWithEvent stores a path record associated with an ID and appends the event to the outbox atomically.
+func (m *memoryRepository) SavePathWithEvent(
+	ctx context.Context,
+	id int64,
+	record PathRecord,
+	event events.Event,
+) error {
+	key, err := keyFor(ctx, id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store(key, record)
+	m.appendEvent(event)
+	return nil
+}
+
+// CreatePathsWithEvents stores the new path records and appends their events to the outbox atomically.
+// It returns an error, storing nothing, if any of the IDs already exists or appears twice.
+func (m *memoryRepository) CreatePathsWithEvents(ctx context.Context, paths []NewPath) error {
+	keys := make([]recordKey, len(paths))
+	for i, path := range paths {
+		key, err := keyFor(ctx, path.ID)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[recordKey]bool, len(keys))
+	for i, key := range keys {
+		if _, exists := (*m.buffer)[key]; exists || seen[key] {
+			return fmt.Errorf("%w: path with id %d already exists", errs.ErrInvalidInput, paths[i].ID)
+		}
+		seen[key] = true
+	}
+	for i, key := range keys {
+		m.store(key, paths[i].Record)
+		m.appendEvent(paths[i].Event)
+	}
+	return nil
+}
+
+// DeletePathWithEvent removes the path record associated with the given ID and appends the event to the
+// outbox atomically. It returns an error, appending nothing, if the path does not exist.
+func (m *memoryRepository) DeletePathWithEvent(ctx context.Context, id int64, event events.Event) error {
+	key, err := keyFor(ctx, id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := (*m.buffer)[key]; !exists {
+		return notFound(id)
+	}
+	m.remove(key)
+	m.appendEvent(event)
+	return nil
+}
+
+// MovePathWithEvent moves the path record of an ID to another one, replacing it with the given record, and
+// appends the event to the outbox atomically. It returns an error, changing nothing, if the ID doesn't exist or
+// the new one already does.
+func (m *memoryRepository) MovePathWithEvent(
+	ctx context.Context,
+	from int64,
+	to int64,
+	record PathRecord,
+	event events.Event,
+) error {
+	fromKey, err := keyFor(ctx, from)
+	if err != nil {
+		return err
+	}
+	toKey, err := keyFor(ctx, to)
+	if err != nil {
+		return err
 	}
-	return path, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := (*m.buffer)[fromKey]; !exists {
+		return notFound(from)
+	}
+	if _, exists := (*m.buffer)[toKey]; exists && toKey != fromKey {
+		return fmt.Errorf("%w: path with id %d already exists", errs.ErrInvalidInput, to)
+	}
+	m.remove(fromKey)
+	m.store(toKey, record)
+	m.appendEvent(event)
+	return nil
+}
+
+// NextID allocates the ID following the highest one the tenant has had or been given.
+func (m *memoryRepository) NextID(ctx context.Context) (int64, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastIDs[tenant]++
+	return m.lastIDs[tenant], nil
+}
+
+// PendingEvents returns up to limit events of the outbox with a sequence number after the given one, oldest
+// first.
+func (m *memoryRepository) PendingEvents(ctx context.Context, after int64, limit int) ([]OutboxEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	first := sort.Search(len(m.outbox), func(i int) bool { return m.outbox[i].Seq > after })
+	pending := m.outbox[first:]
+	return append([]OutboxEntry{}, pending[:min(limit, len(pending))]...), nil
+}
+
+// AcknowledgeEvents removes the events up to the given sequence number from the outbox.
+func (m *memoryRepository) AcknowledgeEvents(ctx context.Context, upTo int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	acknowledged := 0
+	for acknowledged < len(m.outbox) && m.outbox[acknowledged].Seq <= upTo {
+		acknowledged++
+	}
+	m.outbox = append([]OutboxEntry{}, m.outbox[acknowledged:]...)
+	return nil
+}
+
+// Tagged returns the IDs of the records of the tenant with the given tag, from the tag index.
+func (m *memoryRepository) Tagged(ctx context.Context, tag string) ([]int64, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ids := make([]int64, 0, len(m.tags[indexKey{tenant, tag}]))
+	for id := range m.tags[indexKey{tenant, tag}] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// MetadataValues returns the values of the given metadata key of the records of the tenant, from the
+// metadata index.
+func (m *memoryRepository) MetadataValues(ctx context.Context, key string) (map[int64]string, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	values := make(map[int64]string, len(m.metadata[indexKey{tenant, key}]))
+	for id, value := range m.metadata[indexKey{tenant, key}] {
+		values[id] = value
+	}
+	return values, nil
+}
+
+// store stores the record under the key, replacing the previous one in the indexes. Its tags, metadata and
+// history are copied, so the indexes, or the stored record, can't be left out of date by the caller changing
+// them. It must be called with mu held.
+func (m *memoryRepository) store(key recordKey, record PathRecord) {
+	m.remove(key)
+	record.Tags = slices.Clone(record.Tags)
+	record.Metadata = maps.Clone(record.Metadata)
+	record.History = slices.Clone(record.History)
+	(*m.buffer)[key] = record
+	m.lastIDs[key.tenant] = max(m.lastIDs[key.tenant], key.id)
+	for _, tag := range record.Tags {
+		entry := indexKey{key.tenant, tag}
+		if m.tags[entry] == nil {
+			m.tags[entry] = make(map[int64]bool)
+		}
+		m.tags[entry][key.id] = true
+	}
+	for name, value := range record.Metadata {
+		entry := indexKey{key.tenant, name}
+		if m.metadata[entry] == nil {
+			m.metadata[entry] = make(map[int64]string)
+		}
+		m.metadata[entry][key.id] = value
+	}
+}
+
+// remove removes the record stored under the key, if any, along with its entries in the indexes. It must be
+// called with mu held.
+func (m *memoryRepository) remove(key recordKey) {
+	record, exists := (*m.buffer)[key]
+	if !exists {
+		return
+	}
+	delete(*m.buffer, key)
+	for _, tag := range record.Tags {
+		entry := indexKey{key.tenant, tag}
+		delete(m.tags[entry], key.id)
+		if len(m.tags[entry]) == 0 {
+			delete(m.tags, entry)
+		}
+	}
+	for name := range record.Metadata {
+		entry := indexKey{key.tenant, name}
+		delete(m.metadata[entry], key.id)
+		if len(m.metadata[entry]) == 0 {
+			delete(m.metadata, entry)
+		}
+	}
+}
+
+// appendEvent appends the event to the outbox. It must be called with mu held.
+func (m *memoryRepository) appendEvent(event events.Event) {
+	m.lastSeq++
+	m.outbox = append(m.outbox, OutboxEntry{Seq: m.lastSeq, Event: event})
+}
+
+// keyFor builds the repository key for the given ID, scoped by the tenant found in the context.
+func keyFor(ctx context.Context, id int64) (recordKey, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return recordKey{}, err
+	}
+	return recordKey{tenant, id}, nil
+}
+
+// notFound builds the error returned when the given ID has no path in the repository.
+func notFound(id int64) error {
+	errMsg := fmt.Sprintf("path with id %d not found", id)
+	return fmt.Errorf("%w: %s", errs.ErrNotFound, errMsg)
 }
@@ -1,20 +1,24 @@
-package pathrepository
+package pathrepository_test
 
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
 	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
 )
 
 var (
 	logger = mocks.NewLoggerMock()
-	repo   = MemoryRepository(logger)
-	ctx    = context.TODO()
+	repo   = pathrepository.MemoryRepository(logger)
+	ctx    = tenancy.WithTenant(context.TODO(), "tenant-a")
 	id     = int64(1)
-	path   = "test/path"
+	path   = pathrepository.PathRecord{Path: "test/path", Owner: "owner", Size: 10}
 )
 
 func TestSavePath(t *testing.T) {
@@ -30,8 +34,8 @@ func TestGetPath(t *testing.T) {
 	repo.SavePath(ctx, id, path)
 
 	got, err := repo.GetPath(ctx, id)
-	if err != nil || got != path {
-		t.Errorf("Expected to retrieve path '%s', got '%s', err=%v", path, got, err)
+	if err != nil || !reflect.DeepEqual(got, path) {
+		t.Errorf("Expected to retrieve path '%v', got '%v', err=%v", path, got, err)
 	}
 }
 
@@ -47,10 +51,211 @@ func TestGetPathFail(t *testing.T) {
 
 func TestOverwrite(t *testing.T) {
 	repo.SavePath(ctx, id, path)
-	newPath := "new/test/path"
+	newPath := pathrepository.PathRecord{Path: "new/test/path"}
 	repo.SavePath(ctx, id, newPath)
 	path, _ = repo.GetPath(ctx, id)
-	if path != newPath {
-		t.Errorf("Expected path to be overwritten with '%s', got '%s'", newPath, path)
+	if !reflect.DeepEqual(path, newPath) {
+		t.Errorf("Expected path to be overwritten with '%v', got '%v'", newPath, path)
+	}
+}
+
+func TestDeletePath(t *testing.T) {
+	repo.SavePath(ctx, id, path)
+	if err := repo.DeletePath(ctx, id); err != nil {
+		t.Errorf("Expected path to be deleted, got err=%v", err)
+	}
+	exists, _ := repo.Exists(ctx, id)
+	if exists {
+		t.Errorf("Expected path not to exist after deleting it")
+	}
+	if err := repo.DeletePath(ctx, id); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing path, got %v", err)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	repo.SavePath(ctx, id, path)
+	otherCtx := tenancy.WithTenant(context.TODO(), "tenant-b")
+
+	exists, err := repo.Exists(otherCtx, id)
+	if err != nil || exists {
+		t.Errorf("Expected path to be invisible to other tenants, got exists=%v, err=%v", exists, err)
+	}
+	_, err = repo.GetPath(otherCtx, id)
+	if !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for other tenant, got %v", err)
+	}
+}
+
+func TestMissingTenant(t *testing.T) {
+	err := repo.SavePath(context.TODO(), id, path)
+	if !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput without tenant, got %v", err)
+	}
+}
+
+func TestAll(t *testing.T) {
+	repo := pathrepository.MemoryRepository(logger)
+	repo.SavePath(ctx, id, path)
+	repo.SavePath(tenancy.WithTenant(context.TODO(), "tenant-b"), id, path)
+
+	entries, err := repo.All(context.TODO())
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected records of both tenants, got %v, err=%v", entries, err)
+	}
+	for _, entry := range entries {
+		if entry.ID != id || !reflect.DeepEqual(entry.Record, path) {
+			t.Errorf("Unexpected entry %+v", entry)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	repo := pathrepository.MemoryRepository(logger)
+	repo.SavePath(ctx, 2, path)
+	repo.SavePath(ctx, 1, path)
+	repo.SavePath(tenancy.WithTenant(context.TODO(), "tenant-b"), 3, path)
+
+	entries, err := repo.List(ctx)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected only the records of the tenant, got %v, err=%v", entries, err)
+	}
+	if entries[0].ID != 1 || entries[1].ID != 2 {
+		t.Errorf("Expected records sorted by ID, got %v", entries)
+	}
+}
+func TestOutbox(t *testing.T) {
+	repo := pathrepository.MemoryRepository(logger)
+	created := events.Event{ID: "1", Type: events.DocumentCreated, DocumentID: id}
+	purged := events.Event{ID: "2", Type: events.DocumentPurged, DocumentID: id}
+	if err := repo.SavePathWithEvent(ctx, id, path, created); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SavePathWithEvent(context.TODO(), id, path, created); err == nil {
+		t.Error("Expected an error saving without a tenant")
+	}
+	if err := repo.DeletePathWithEvent(ctx, id+1, purged); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting an unsaved path, got %v", err)
+	}
+	if err := repo.DeletePathWithEvent(ctx, id, purged); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := repo.PendingEvents(context.TODO(), 0, 10)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("Expected only the events of the committed changes, got %v, err=%v", pending, err)
+	}
+	if pending[0].Event != created || pending[1].Event != purged || pending[0].Seq >= pending[1].Seq {
+		t.Errorf("Expected the events in the order they were written, got %v", pending)
+	}
+	repo.AcknowledgeEvents(context.TODO(), pending[0].Seq)
+	if pending, _ := repo.PendingEvents(context.TODO(), 0, 10); len(pending) != 1 || pending[0].Event != purged {
+		t.Errorf("Expected only the unacknowledged event to be pending, got %v", pending)
+	}
+}
+
+func TestCreatePathsWithEvents(t *testing.T) {
+	repo := pathrepository.MemoryRepository(logger)
+	repo.SavePath(ctx, 3, path)
+	created := func(id int64) pathrepository.NewPath {
+		event := events.Event{Type: events.DocumentCreated, DocumentID: id}
+		return pathrepository.NewPath{ID: id, Record: path, Event: event}
+	}
+	for _, paths := range [][]pathrepository.NewPath{
+		{created(1), created(2), created(3)},
+		{created(1), created(2), created(1)},
+	} {
+		if err := repo.CreatePathsWithEvents(ctx, paths); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput creating an ID in use, got %v", err)
+		}
+	}
+	if exists, _ := repo.Exists(ctx, 1); exists {
+		t.Error("Expected nothing to be saved when any ID is in use")
+	}
+	if err := repo.CreatePathsWithEvents(ctx, []pathrepository.NewPath{created(1), created(2)}); err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := repo.PendingEvents(ctx, 0, 10)
+	if len(pending) != 2 || pending[0].Event.DocumentID != 1 || pending[1].Event.DocumentID != 2 {
+		t.Errorf("Expected the events of the created paths in order, got %v", pending)
+	}
+	if after, _ := repo.PendingEvents(ctx, pending[0].Seq, 10); len(after) != 1 || after[0] != pending[1] {
+		t.Errorf("Expected only the events after the given sequence number, got %v", after)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	repo := pathrepository.MemoryRepository(logger)
+	tagged := func(tags []string, metadata map[string]string) pathrepository.PathRecord {
+		return pathrepository.PathRecord{Path: "test/path", Tags: tags, Metadata: metadata}
+	}
+	repo.SavePath(ctx, 1, tagged([]string{"invoice", "fy2024"}, map[string]string{"amount": "100"}))
+	repo.SavePath(ctx, 2, tagged([]string{"invoice"}, map[string]string{"amount": "250"}))
+	repo.SavePath(tenancy.WithTenant(context.TODO(), "tenant-b"), 3, tagged([]string{"invoice"}, nil))
+
+	if ids, _ := repo.Tagged(ctx, "invoice"); !reflect.DeepEqual(ids, []int64{1, 2}) {
+		t.Errorf("Expected the records of the tenant tagged invoice, got %v", ids)
+	}
+	repo.SavePath(ctx, 1, tagged([]string{"fy2024"}, nil))
+	if ids, _ := repo.Tagged(ctx, "invoice"); !reflect.DeepEqual(ids, []int64{2}) {
+		t.Errorf("Expected the index to follow the tags of an overwritten record, got %v", ids)
+	}
+	if values, _ := repo.MetadataValues(ctx, "amount"); !reflect.DeepEqual(values, map[int64]string{2: "250"}) {
+		t.Errorf("Expected the index to follow the metadata of an overwritten record, got %v", values)
+	}
+	repo.DeletePath(ctx, 2)
+	if ids, _ := repo.Tagged(ctx, "invoice"); len(ids) != 0 {
+		t.Errorf("Expected deleted records to be taken out of the index, got %v", ids)
+	}
+}
+
+func TestMovePath(t *testing.T) {
+	repo := pathrepository.MemoryRepository(logger)
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	tagged := pathrepository.PathRecord{Path: "a.pdf", Tags: []string{"invoice"}}
+	repo.SavePath(ctx, 1, tagged)
+	repo.SavePath(ctx, 2, pathrepository.PathRecord{Path: "b.pdf"})
+	event := events.Event{Type: events.DocumentMoved, DocumentID: 3, PreviousID: 1}
+
+	if err := repo.MovePathWithEvent(ctx, 1, 2, tagged, event); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput moving to an ID in use, got: %v", err)
+	}
+	if err := repo.MovePathWithEvent(ctx, 4, 3, tagged, event); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound moving an ID that doesn't exist, got: %v", err)
+	}
+	if pending, _ := repo.PendingEvents(ctx, 0, 10); len(pending) != 0 {
+		t.Errorf("Expected failed moves to append no event, got: %v", pending)
+	}
+
+	if err := repo.MovePathWithEvent(ctx, 1, 3, tagged, event); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := repo.Exists(ctx, 1); exists {
+		t.Errorf("Expected the previous ID to be gone")
+	}
+	if ids, _ := repo.Tagged(ctx, "invoice"); !reflect.DeepEqual(ids, []int64{3}) {
+		t.Errorf("Expected the indexes to follow the record, got: %v", ids)
+	}
+	if pending, _ := repo.PendingEvents(ctx, 0, 10); len(pending) != 1 || pending[0].Event.PreviousID != 1 {
+		t.Errorf("Expected the event to be appended along with the move, got: %v", pending)
+	}
+}
+
+func TestNextID(t *testing.T) {
+	repo := pathrepository.MemoryRepository(logger)
+	if id, err := repo.NextID(ctx); err != nil || id != 1 {
+		t.Errorf("Expected the first ID of a tenant to be 1, got %d, err=%v", id, err)
+	}
+	repo.SavePath(ctx, 7, path)
+	if id, _ := repo.NextID(ctx); id != 8 {
+		t.Errorf("Expected the ID following the highest one, got %d", id)
+	}
+	repo.DeletePath(ctx, 7)
+	if id, _ := repo.NextID(ctx); id != 9 {
+		t.Errorf("Expected IDs never to be given twice, got %d", id)
+	}
+	other := tenancy.WithTenant(context.TODO(), "tenant-b")
+	if id, _ := repo.NextID(other); id != 1 {
+		t.Errorf("Expected the IDs of every tenant to be allocated apart, got %d", id)
 	}
 }
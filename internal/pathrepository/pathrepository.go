@@ -1,21 +1,179 @@
 package pathrepository
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/events"
+)
 
 // PathRepository defines the interface for operations on path storage.
 // It outlines methods for saving, retrieving, and checking the existence of paths associated with unique identifiers.
+// Identifiers are scoped by the tenant carried in the context, so the same ID can be used by different
+// tenants without colliding, and a tenant can never reach the paths of another one.
 type PathRepository interface {
-	// SavePath persists a path associated with a given id in the storage.
+	// SavePath persists a path record associated with a given id in the storage.
 	// If the ID already exists SavePath would override it.
 	// The operation might fail due to storage errors, in which case an error will be returned.
-	SavePath(ctx context.Context, id int64, path string) error
+	SavePath(ctx context.Context, id int64, record PathRecord) error
 
-	// GetPath retrieves the path associated with the given id from the storage.
-	// If the id does not exist, it returns an empty string and a resource-not-found error.
+	// GetPath retrieves the path record associated with the given id from the storage.
+	// If the id does not exist, it returns an empty record and a resource-not-found error.
 	// For storage-related errors, an error is returned.
-	GetPath(ctx context.Context, id int64) (string, error)
+	GetPath(ctx context.Context, id int64) (PathRecord, error)
 
 	// Exists checks whether a path associated with the given id exists in the storage.
 	// It returns true if the path exists, false otherwise. Errors are returned for storage-related issues.
 	Exists(ctx context.Context, id int64) (bool, error)
+
+	// DeletePath removes the path record associated with the given id from the storage.
+	// If the id does not exist, it returns a resource-not-found error.
+	DeletePath(ctx context.Context, id int64) error
+
+	// List returns the path records of the tenant found in the context, sorted by ID.
+	List(ctx context.Context) ([]PathEntry, error)
+
+	// All returns a snapshot of the path records of every tenant.
+	// It's meant for maintenance jobs that work across tenants, and must never be reachable from a request.
+	All(ctx context.Context) ([]PathEntry, error)
+
+	// SavePathWithEvent persists a path record like SavePath and, in the same transaction, appends the event
+	// to the outbox. Either both are persisted or neither is.
+	SavePathWithEvent(ctx context.Context, id int64, record PathRecord, event events.Event) error
+
+	// CreatePathsWithEvents persists several new path records and, in the same transaction, appends the event of
+	// each one to the outbox. If the ID of any of them already exists it returns an ErrInvalidInput error and
+	// persists nothing. Either every record and event is persisted or none is.
+	CreatePathsWithEvents(ctx context.Context, paths []NewPath) error
+
+	// DeletePathWithEvent removes a path record like DeletePath and, in the same transaction, appends the
+	// event to the outbox. Either both happen or neither does.
+	DeletePathWithEvent(ctx context.Context, id int64, event events.Event) error
+
+	// MovePathWithEvent moves the path record of an ID to another one, replacing it with the given record, and,
+	// in the same transaction, appends the event to the outbox. If the ID doesn't exist it returns an ErrNotFound
+	// error, and if the new one is already in use an ErrInvalidInput error, changing nothing. The IDs may be the
+	// same, just to replace the record.
+	MovePathWithEvent(ctx context.Context, from int64, to int64, record PathRecord, event events.Event) error
+
+	// PendingEvents returns up to limit events of the outbox with a sequence number after the given one, of every
+	// tenant, in the order they were appended. It's meant for the relay publishing them, and must never be
+	// reachable from a request.
+	PendingEvents(ctx context.Context, after int64, limit int) ([]OutboxEntry, error)
+
+	// AcknowledgeEvents removes from the outbox the events up to the given sequence number, once published.
+	AcknowledgeEvents(ctx context.Context, upTo int64) error
+
+	// Tagged returns the IDs of the records of the tenant found in the context with the given tag, sorted.
+	// It's served from an index, so it doesn't go through every record.
+	Tagged(ctx context.Context, tag string) ([]int64, error)
+
+	// NextID allocates an ID for a new record of the tenant found in the context: one higher than any ID the
+	// tenant has had, or has been given by NextID before, so concurrent callers never get the same one.
+	NextID(ctx context.Context) (int64, error)
+
+	// MetadataValues returns the value of the given metadata key of every record of the tenant found in the
+	// context that has it, by ID. It's served from an index, so it doesn't go through every record.
+	MetadataValues(ctx context.Context, key string) (map[int64]string, error)
+}
+
+// OutboxEntry is an event waiting in the outbox to be published.
+type OutboxEntry struct {
+	Seq   int64        // Seq is the position of the event in the outbox. It only ever grows.
+	Event events.Event // Event is the event to publish.
+}
+
+// NewPath is a path record to be created under an ID, along with the event announcing it.
+type NewPath struct {
+	ID     int64        // ID is the identifier of the record within the tenant.
+	Record PathRecord   // Record is the path record.
+	Event  events.Event // Event is the event appended to the outbox along with the record.
+}
+
+// PathEntry is a path record along with the key it's stored under.
+type PathEntry struct {
+	Tenant string     // Tenant is the tenant the record belongs to.
+	ID     int64      // ID is the identifier of the record within the tenant.
+	Record PathRecord // Record is the path record.
+}
+
+// PathRecord holds everything the repository knows about a stored file.
+type PathRecord struct {
+	// Path is the location of the file's content in the storage. It's unique to the file, and to the version of
+	// its content, so no two records ever reference the same blob.
+	Path        string
+	Name        string    // Name is the name of the file, as it's shown to clients. It isn't unique.
+	Owner       string    // Owner is the user who uploaded the file. It's empty if the upload wasn't attributed to a user.
+	Size        int64     // Size is the logical size of the file, the number of bytes that were uploaded.
+	StoredSize  int64     // StoredSize is the number of bytes the file takes in the storage, once compressed.
+	ContentType string    // ContentType is the MIME type sniffed from the file's content.
+	Compression string    // Compression is the codec the file is stored with. It's empty if it's stored uncompressed.
+	SHA256      string    // SHA256 is the hex-encoded SHA-256 checksum of the file's content.
+	CRC32C      uint32    // CRC32C is the CRC-32 checksum of the file's content, using the Castagnoli polynomial.
+	CreatedAt   time.Time // CreatedAt is the time the file was uploaded.
+	// RetainUntil is the time until which the file can't be deleted nor replaced, regardless of any retention
+	// rule. It's zero if no retention was set on the file itself.
+	RetainUntil time.Time
+	LegalHold   bool // LegalHold blocks the deletion and replacement of the file, indefinitely, while it's set.
+	// ExpiresAt is the time from which the file is treated as not found, until the reaper removes it.
+	// It's zero if the file doesn't expire.
+	ExpiresAt time.Time
+	// DeletedAt is the time the file was moved to the trash. It's zero if the file isn't in the trash.
+	DeletedAt time.Time
+	DeletedBy string // DeletedBy is the user who moved the file to the trash, if it was attributed to one.
+	// Version is the number of the version of the file's content, 1 when it's uploaded and increased every time
+	// it's replaced or checked in.
+	Version int
+	// History are the previous versions of the file's content, oldest first. They're kept, and keep taking
+	// space, until the file is removed.
+	History []VersionRecord
+	// LockedBy is the user who checked the file out, holding an exclusive lock on it until LockedUntil.
+	// It's empty if the file was never checked out or was checked back in.
+	LockedBy    string
+	LockedUntil time.Time // LockedUntil is the time the lock of the file expires at.
+	Tags        []string  // Tags are the labels of the file, such as "invoice" or "client:acme", sorted.
+	// Metadata are the custom key/value pairs describing the file, such as "fiscalYear" = "2024".
+	Metadata map[string]string
+	// Type is the name of the document type of the file, whose schema its metadata must satisfy. It's empty if
+	// the file isn't typed, so its metadata is free-form.
+	Type string
+}
+
+// VersionRecord is a previous version of the content of a file, kept when the file is replaced.
+type VersionRecord struct {
+	Version     int       // Version is the number of the version.
+	Path        string    // Path is the location of the version's content in the storage.
+	Name        string    // Name is the name of the file at that version.
+	Size        int64     // Size is the logical size of the version.
+	StoredSize  int64     // StoredSize is the number of bytes the version takes in the storage.
+	ContentType string    // ContentType is the MIME type sniffed from the version's content.
+	Compression string    // Compression is the codec the version is stored with, empty if uncompressed.
+	SHA256      string    // SHA256 is the hex-encoded SHA-256 checksum of the version's content.
+	CRC32C      uint32    // CRC32C is the CRC-32C checksum of the version's content.
+	ReplacedAt  time.Time // ReplacedAt is the time the version was replaced by the next one.
+}
+
+// Blobs returns the paths of every blob of the file: the one of its current content, and those of its previous
+// versions.
+func (r PathRecord) Blobs() []string {
+	paths := []string{r.Path}
+	for _, version := range r.History {
+		paths = append(paths, version.Path)
+	}
+	return paths
+}
+
+// Expired reports whether the file has expired at the given time.
+func (r PathRecord) Expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
+}
+
+// Locked reports whether the file is checked out at the given time, its lock not expired yet.
+func (r PathRecord) Locked(now time.Time) bool {
+	return r.LockedBy != "" && now.Before(r.LockedUntil)
+}
+
+// Trashed reports whether the file has been moved to the trash.
+func (r PathRecord) Trashed() bool {
+	return !r.DeletedAt.IsZero()
 }
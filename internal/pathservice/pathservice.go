@@ -2,9 +2,11 @@ package pathservice
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
 	"github.com/lucastomic/dmsStorageService/internal/logging"
 	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
 )
@@ -16,16 +18,68 @@ type PathService interface {
 	SavePath(
 		ctx context.Context,
 		id int64,
-		path string,
-	) error // Saves a path associated with an ID.
+		record pathrepository.PathRecord,
+	) error // Saves a path record associated with an ID.
 	GetPath(
 		ctx context.Context,
 		id int64,
-	) (string, error) // Retrieves a path associated with an ID. Returns an error if the id doesn't exist.
+	) (pathrepository.PathRecord, error) // Retrieves the path record of an ID. Returns an error if the id doesn't exist.
 	Exists(
 		ctx context.Context,
 		id int64,
 	) (bool, error) // Checks if a path associated with an ID exists.
+	DeletePath(
+		ctx context.Context,
+		id int64,
+	) error // Deletes the path record of an ID. Returns an error if the id doesn't exist.
+	List(
+		ctx context.Context,
+	) ([]pathrepository.PathEntry, error) // Lists the path records of the tenant in the context, sorted by ID.
+	All(
+		ctx context.Context,
+	) ([]pathrepository.PathEntry, error) // Lists the path records of every tenant, for maintenance jobs.
+	SavePathWithEvent(
+		ctx context.Context,
+		id int64,
+		record pathrepository.PathRecord,
+		event events.Event,
+	) error // Saves a path record and appends the event to the outbox in the same transaction.
+	CreatePathsWithEvents(
+		ctx context.Context,
+		paths []pathrepository.NewPath,
+	) error // Saves several new path records and appends their events to the outbox in the same transaction.
+	DeletePathWithEvent(
+		ctx context.Context,
+		id int64,
+		event events.Event,
+	) error // Deletes the path record of an ID and appends the event to the outbox in the same transaction.
+	MovePathWithEvent(
+		ctx context.Context,
+		from int64,
+		to int64,
+		record pathrepository.PathRecord,
+		event events.Event,
+	) error // Moves the path record of an ID to another and appends the event to the outbox in the same transaction.
+	PendingEvents(
+		ctx context.Context,
+		after int64,
+		limit int,
+	) ([]pathrepository.OutboxEntry, error) // Lists the oldest events of the outbox after a sequence number.
+	AcknowledgeEvents(
+		ctx context.Context,
+		upTo int64,
+	) error // Removes the events up to a sequence number from the outbox, once published.
+	Tagged(
+		ctx context.Context,
+		tag string,
+	) ([]int64, error) // Lists the IDs of the path records of the tenant in the context with a tag, sorted.
+	MetadataValues(
+		ctx context.Context,
+		key string,
+	) (map[int64]string, error) // Maps the path records of the tenant in the context to their value of a key.
+	NextID(
+		ctx context.Context,
+	) (int64, error) // Allocates an ID for a new path record of the tenant in the context, never given twice.
 }
 
 // pathService implements the PathService interface, providing methods to interact
@@ -52,12 +106,16 @@ func (p pathService) Exists(ctx context.Context, id int64) (bool, error) {
 	return exists, nil
 }
 
-// SavePath persists a path associated with an ID.
+// SavePath persists a path record associated with an ID.
 // It's important to take into account that SavePath will override the path if the ID it's already in use.
 // In order to not override any ID, it can be performed Exists before Save.
 // If the repository fails to save the path, it logs the error and returns an internal error wrapped around the original error.
-func (p pathService) SavePath(ctx context.Context, id int64, path string) error {
-	err := p.repo.SavePath(ctx, id, path)
+func (p pathService) SavePath(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+) error {
+	err := p.repo.SavePath(ctx, id, record)
 	if err != nil {
 		p.logger.Error(ctx, "Error saving path: %s", err.Error())
 		return err
@@ -65,22 +123,172 @@ func (p pathService) SavePath(ctx context.Context, id int64, path string) error
 	return nil
 }
 
-// GetPath retrieves a path associated with the given ID from the repository.
+// GetPath retrieves the path record associated with the given ID from the repository.
 // If the id tryed to retrieve doesn't exist,returns an error
 // It logs and returns any error encountered during the retrieval process.
-func (p pathService) GetPath(ctx context.Context, id int64) (string, error) {
+func (p pathService) GetPath(ctx context.Context, id int64) (pathrepository.PathRecord, error) {
 	pathExists, err := p.Exists(ctx, id)
 	if err != nil {
 		p.logger.Error(ctx, "Error checking path with id %d: %s", id, err.Error())
-		return "", err
+		return pathrepository.PathRecord{}, err
 	}
 	if !pathExists {
-		return "", fmt.Errorf("failed retrieving ID %d: %w", id, errs.ErrNotFound)
+		return pathrepository.PathRecord{}, fmt.Errorf(
+			"failed retrieving ID %d: %w",
+			id,
+			errs.ErrNotFound,
+		)
 	}
-	path, err := p.repo.GetPath(ctx, id)
+	record, err := p.repo.GetPath(ctx, id)
 	if err != nil {
 		p.logger.Error(ctx, "Error retrieving path: %s", err.Error())
-		return "", err
+		return pathrepository.PathRecord{}, err
+	}
+	return record, nil
+}
+
+// DeletePath removes the path record associated with the given ID from the repository.
+// If the id doesn't exist it returns an ErrNotFound error. Any other error is logged and returned.
+func (p pathService) DeletePath(ctx context.Context, id int64) error {
+	err := p.repo.DeletePath(ctx, id)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		p.logger.Error(ctx, "Error deleting path with id %d: %s", id, err.Error())
+	}
+	return err
+}
+
+// List returns the path records of the tenant found in the context, sorted by ID.
+// Any error is logged and returned.
+func (p pathService) List(ctx context.Context) ([]pathrepository.PathEntry, error) {
+	entries, err := p.repo.List(ctx)
+	if err != nil {
+		p.logger.Error(ctx, "Error listing paths of tenant: %s", err.Error())
+		return nil, err
+	}
+	return entries, nil
+}
+
+// All returns a snapshot of the path records of every tenant.
+// It's meant for maintenance jobs only. Any error is logged and returned.
+func (p pathService) All(ctx context.Context) ([]pathrepository.PathEntry, error) {
+	entries, err := p.repo.All(ctx)
+	if err != nil {
+		p.logger.Error(ctx, "Error listing paths: %s", err.Error())
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SavePathWithEvent persists a path record associated with an ID, appending the event to the outbox in the
+// same transaction. Like SavePath, it overrides the path if the ID is already in use.
+// Any error is logged and returned.
+func (p pathService) SavePathWithEvent(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+	event events.Event,
+) error {
+	err := p.repo.SavePathWithEvent(ctx, id, record, event)
+	if err != nil {
+		p.logger.Error(ctx, "Error saving path along with %s event: %s", event.Type, err.Error())
+		return err
+	}
+	return nil
+}
+
+// CreatePathsWithEvents persists several new path records, appending their events to the outbox in the same
+// transaction. Unlike SavePathWithEvent, it saves nothing if any of the IDs is already in use, returning an
+// ErrInvalidInput error. Any other error is logged and returned.
+func (p pathService) CreatePathsWithEvents(ctx context.Context, paths []pathrepository.NewPath) error {
+	err := p.repo.CreatePathsWithEvents(ctx, paths)
+	if err != nil && !errors.Is(err, errs.ErrInvalidInput) {
+		p.logger.Error(ctx, "Error saving %d paths along with their events: %s", len(paths), err.Error())
+	}
+	return err
+}
+
+// DeletePathWithEvent removes the path record associated with the given ID, appending the event to the outbox
+// in the same transaction. If the id doesn't exist it returns an ErrNotFound error and appends nothing.
+// Any other error is logged and returned.
+func (p pathService) DeletePathWithEvent(ctx context.Context, id int64, event events.Event) error {
+	err := p.repo.DeletePathWithEvent(ctx, id, event)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		p.logger.Error(ctx, "Error deleting path with id %d along with %s event: %s", id, event.Type, err.Error())
+	}
+	return err
+}
+
+// MovePathWithEvent moves the path record of an ID to another one, replacing it with the given record, and
+// appends the event to the outbox in the same transaction. If the id doesn't exist it returns an ErrNotFound
+// error, and if the new one is already in use an ErrInvalidInput error, changing nothing.
+// Any other error is logged and returned.
+func (p pathService) MovePathWithEvent(
+	ctx context.Context,
+	from int64,
+	to int64,
+	record pathrepository.PathRecord,
+	event events.Event,
+) error {
+	err := p.repo.MovePathWithEvent(ctx, from, to, record, event)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) && !errors.Is(err, errs.ErrInvalidInput) {
+		p.logger.Error(ctx, "Error moving path with id %d to %d: %s", from, to, err.Error())
+	}
+	return err
+}
+
+// NextID allocates an ID for a new path record of the tenant found in the context. Any error is logged and
+// returned.
+func (p pathService) NextID(ctx context.Context) (int64, error) {
+	id, err := p.repo.NextID(ctx)
+	if err != nil {
+		p.logger.Error(ctx, "Error allocating an ID: %s", err.Error())
+	}
+	return id, err
+}
+
+// PendingEvents returns up to limit events of the outbox with a sequence number after the given one, oldest
+// first. It's meant for the outbox relay only. Any error is logged and returned.
+func (p pathService) PendingEvents(
+	ctx context.Context,
+	after int64,
+	limit int,
+) ([]pathrepository.OutboxEntry, error) {
+	entries, err := p.repo.PendingEvents(ctx, after, limit)
+	if err != nil {
+		p.logger.Error(ctx, "Error reading outbox: %s", err.Error())
+		return nil, err
+	}
+	return entries, nil
+}
+
+// AcknowledgeEvents removes the events up to the given sequence number from the outbox.
+// Any error is logged and returned.
+func (p pathService) AcknowledgeEvents(ctx context.Context, upTo int64) error {
+	err := p.repo.AcknowledgeEvents(ctx, upTo)
+	if err != nil {
+		p.logger.Error(ctx, "Error acknowledging outbox events: %s", err.Error())
+	}
+	return err
+}
+
+// Tagged returns the IDs of the path records of the tenant found in the context with the given tag, sorted.
+// Any error is logged and returned.
+func (p pathService) Tagged(ctx context.Context, tag string) ([]int64, error) {
+	ids, err := p.repo.Tagged(ctx, tag)
+	if err != nil {
+		p.logger.Error(ctx, "Error looking up paths tagged %q: %s", tag, err.Error())
+		return nil, err
+	}
+	return ids, nil
+}
+
+// MetadataValues returns the value of the given metadata key of every path record of the tenant found in the
+// context that has it, by ID. Any error is logged and returned.
+func (p pathService) MetadataValues(ctx context.Context, key string) (map[int64]string, error) {
+	values, err := p.repo.MetadataValues(ctx, key)
+	if err != nil {
+		p.logger.Error(ctx, "Error looking up metadata %q of paths: %s", key, err.Error())
+		return nil, err
 	}
-	return path, nil
+	return values, nil
 }
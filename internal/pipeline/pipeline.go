@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+)
+
+// States of the jobs, and of the processing of documents as a whole.
+const (
+	StatePending   = "pending"   // StatePending means the job is waiting to run, or to be retried.
+	StateRunning   = "running"   // StateRunning means the job is running.
+	StateSucceeded = "succeeded" // StateSucceeded means the job finished successfully.
+	StateFailed    = "failed"    // StateFailed means the job ran out of attempts.
+)
+
+// Stage is a post-processing step run on the documents whose content type it accepts, every time they are
+// uploaded, replaced or restored from the trash. Stages run off the request path, and are retried if they
+// fail, so they must be idempotent.
+type Stage interface {
+	// Name identifies the stage. It must be unique and stable across restarts, as pending jobs refer to it.
+	Name() string
+
+	// Accepts reports whether the stage processes documents of the given content type.
+	Accepts(contentType string) bool
+
+	// Process processes the document. The context carries the tenant the document belongs to.
+	Process(ctx context.Context, document Document) error
+}
+
+// Document is a document to process.
+type Document struct {
+	ID          int64  // ID is the identifier of the document within its tenant.
+	ContentType string // ContentType is the MIME type sniffed from the document's content when it was stored.
+}
+
+// NewStage creates a Stage named name that calls process with the documents matching any of the given content
+// types. Content types are either exact, e.g. "application/pdf", or match a whole type, e.g. "image/*".
+// Without content types the stage processes every document.
+func NewStage(name string, contentTypes []string, process func(context.Context, Document) error) Stage {
+	return funcStage{name, contentTypes, process}
+}
+
+// funcStage implements the Stage interface over a function.
+type funcStage struct {
+	name         string
+	contentTypes []string
+	process      func(context.Context, Document) error
+}
+
+// Name returns the name of the stage.
+func (s funcStage) Name() string {
+	return s.name
+}
+
+// Accepts reports whether the content type matches any of those of the stage.
+func (s funcStage) Accepts(contentType string) bool {
+	if len(s.contentTypes) == 0 {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for _, accepted := range s.contentTypes {
+		if prefix, found := strings.CutSuffix(accepted, "/*"); found {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if strings.EqualFold(mediaType, accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+// Process calls the function of the stage.
+func (s funcStage) Process(ctx context.Context, document Document) error {
+	return s.process(ctx, document)
+}
+
+// Config configures the Pipeline.
+type Config struct {
+	StateFile      string        // StateFile is where jobs are persisted.
+	Workers        int           // Workers is the number of jobs run concurrently.
+	MaxAttempts    int           // MaxAttempts is the number of attempts before a job fails for good.
+	InitialBackoff time.Duration // InitialBackoff is the delay before retrying a job for the first time.
+	MaxBackoff     time.Duration // MaxBackoff caps the delay between two attempts, which doubles every attempt.
+	Retention      time.Duration // Retention is how long succeeded jobs are kept for the status of documents.
+	// FailedRetention is how long failed jobs are kept for the status of documents, counting from their last
+	// attempt.
+	FailedRetention time.Duration
+	// FlushInterval is how often the outcomes of the jobs are persisted, at most. Queueing and dropping jobs
+	// persist them right away.
+	FlushInterval time.Duration
+}
+
+// ConfigFromEnvironment reads the pipeline configuration from the environment variables PIPELINE_STATE_FILE,
+// which defaults to pipeline/state.json inside the project root, PIPELINE_WORKERS, which defaults to 2,
+// PIPELINE_MAX_ATTEMPTS, which defaults to 5, PIPELINE_INITIAL_BACKOFF, which defaults to 1s,
+// PIPELINE_MAX_BACKOFF, which defaults to 10m, PIPELINE_RETENTION, which defaults to 1h,
+// PIPELINE_FAILED_RETENTION, which defaults to 168h, and PIPELINE_FLUSH_INTERVAL, which defaults to 1s.
+func ConfigFromEnvironment() Config {
+	config := Config{
+		StateFile:       environment.GetPipelineStateFile(),
+		Workers:         int(environment.GetInt64("PIPELINE_WORKERS")),
+		MaxAttempts:     int(environment.GetInt64("PIPELINE_MAX_ATTEMPTS")),
+		InitialBackoff:  environment.GetDuration("PIPELINE_INITIAL_BACKOFF"),
+		MaxBackoff:      environment.GetDuration("PIPELINE_MAX_BACKOFF"),
+		Retention:       environment.GetDuration("PIPELINE_RETENTION"),
+		FailedRetention: environment.GetDuration("PIPELINE_FAILED_RETENTION"),
+		FlushInterval:   environment.GetDuration("PIPELINE_FLUSH_INTERVAL"),
+	}
+	if config.StateFile == "" {
+		config.StateFile = filepath.Join(environment.GetProjectRoot(), "pipeline", "state.json")
+	}
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 10 * time.Minute
+	}
+	if config.Retention <= 0 {
+		config.Retention = time.Hour
+	}
+	if config.FailedRetention <= 0 {
+		config.FailedRetention = 7 * 24 * time.Hour
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	return config
+}
+
+// backoff returns the delay before the next attempt of a job that has failed the given number of times.
+func (c Config) backoff(attempts int) time.Duration {
+	delay := c.InitialBackoff
+	for i := 1; i < attempts && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, c.MaxBackoff)
+}
+
+// Job is the run of a stage on a document. A document has at most one job per stage, the latest one.
+type Job struct {
+	Tenant      string    `json:"tenant"`              // Tenant is the tenant the document belongs to.
+	DocumentID  int64     `json:"documentId"`          // DocumentID is the ID of the processed document.
+	ContentType string    `json:"contentType"`         // ContentType is the MIME type of the document.
+	Stage       string    `json:"stage"`               // Stage is the name of the stage run.
+	State       string    `json:"state"`               // State is one of the job states.
+	Attempts    int       `json:"attempts"`            // Attempts is the number of failed attempts.
+	NextAttempt time.Time `json:"nextAttempt"`         // NextAttempt is when a pending job runs next.
+	LastError   string    `json:"lastError,omitempty"` // LastError describes why the last attempt failed.
+	UpdatedAt   time.Time `json:"updatedAt"`           // UpdatedAt is the last time the state of the job changed.
+	// Rerun tells that the document changed while the job was running, so it must run again once it finishes.
+	Rerun bool `json:"rerun,omitempty"`
+}
+
+// Status is the processing status of a document.
+type Status struct {
+	DocumentID int64 `json:"documentId"` // DocumentID is the ID of the document.
+	// State sums up the states of the jobs: failed if any failed, pending if any is pending or running,
+	// and succeeded if all succeeded, or if there are none, as succeeded jobs are eventually pruned.
+	State  string        `json:"state"`
+	Stages []StageStatus `json:"stages"` // Stages are the states of the jobs, sorted by stage.
+}
+
+// StageStatus is the state of the job of a stage.
+type StageStatus struct {
+	Stage     string    `json:"stage"`               // Stage is the name of the stage.
+	State     string    `json:"state"`               // State is one of the job states.
+	Attempts  int       `json:"attempts"`            // Attempts is the number of failed attempts.
+	LastError string    `json:"lastError,omitempty"` // LastError describes why the last attempt failed.
+	UpdatedAt time.Time `json:"updatedAt"`           // UpdatedAt is the last time the state changed.
+}
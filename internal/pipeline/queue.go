@@ -0,0 +1,445 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/fileutils"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// idleWait is how long the Pipeline sleeps when there's nothing pending. It's woken up earlier by new jobs.
+const idleWait = time.Minute
+
+// jobKey identifies the job of a stage on a document.
+type jobKey struct {
+	tenant string
+	id     int64
+	stage  string
+}
+
+// key returns the key of the job.
+func (j Job) key() jobKey {
+	return jobKey{j.Tenant, j.DocumentID, j.Stage}
+}
+
+// state is everything the Pipeline persists.
+type state struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// Pipeline runs the registered stages on the documents uploaded, replaced or restored, off the request path.
+// It implements events.EventPublisher: publishing a document.created, document.replaced or document.restored
+// event queues a job for every stage accepting the document's content type, which Run then runs on a pool of
+// workers, retrying failed jobs with exponential backoff until they run out of attempts. Jobs are persisted to
+// a file, so the pending ones survive restarts. The outcomes of the jobs are persisted in batches, at most every
+// Config.FlushInterval, so jobs may run again after a restart. Succeeded jobs aren't persisted, and are pruned
+// once Config.Retention passes, and failed ones once Config.FailedRetention passes, so the file only grows with
+// the jobs left to run.
+type Pipeline struct {
+	logger   logging.Logger
+	pathsrv  pathservice.PathService
+	config   Config
+	mu       sync.Mutex       // mu guards the stages, the state and the jobs in flight.
+	stages   map[string]Stage // stages are the registered stages, mapped by name.
+	state    state            // state is kept in sync with the state file, but for the recorded outcomes.
+	dirty    bool             // dirty tells that outcomes were recorded since the state was last persisted.
+	saved    time.Time        // saved is the last time the state was persisted.
+	inFlight map[jobKey]bool  // inFlight are the jobs running.
+	wake     chan struct{}    // wake wakes Run up when there may be jobs due.
+}
+
+// New creates a new Pipeline for the documents of the given path service, loading the jobs persisted to the
+// state file. Jobs that were running when the service stopped are run again.
+func New(logger logging.Logger, pathsrv pathservice.PathService, config Config) (*Pipeline, error) {
+	p := &Pipeline{
+		logger:   logger,
+		pathsrv:  pathsrv,
+		config:   config,
+		stages:   make(map[string]Stage),
+		inFlight: make(map[jobKey]bool),
+		wake:     make(chan struct{}, 1),
+	}
+	content, err := os.ReadFile(config.StateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &p.state); err != nil {
+		return nil, fmt.Errorf("malformed pipeline state file %s: %w", config.StateFile, err)
+	}
+	for i := range p.state.Jobs {
+		if p.state.Jobs[i].State == StateRunning {
+			p.state.Jobs[i].State = StatePending
+		}
+	}
+	return p, nil
+}
+
+// Register adds a stage to the pipeline. Stages must be registered before Run is called.
+func (p *Pipeline) Register(stage Stage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stages[stage.Name()] = stage
+}
+
+// Publish queues the jobs for the document of the event: a job for every stage accepting it when it's
+// uploaded, replaced or restored, replacing any previous job of the stage, or none at all once it's removed.
+// Documents moved to another ID are processed again under the new one. Other events are ignored.
+func (p *Pipeline) Publish(ctx context.Context, event events.Event) error {
+	switch event.Type {
+	case events.DocumentCreated, events.DocumentReplaced, events.DocumentRestored:
+		return p.enqueue(ctx, event)
+	case events.DocumentPurged, events.DocumentExpired:
+		return p.drop(event.Tenant, event.DocumentID)
+	case events.DocumentMoved:
+		if event.PreviousID == event.DocumentID {
+			return nil
+		}
+		if err := p.drop(event.Tenant, event.PreviousID); err != nil {
+			return err
+		}
+		return p.enqueue(ctx, event)
+	}
+	return nil
+}
+
+// drop removes the jobs of the document of the given tenant and ID.
+func (p *Pipeline) drop(tenant string, id int64) error {
+	return p.update(func(s *state) error {
+		jobs := s.Jobs[:0]
+		for _, job := range s.Jobs {
+			if job.Tenant != tenant || job.DocumentID != id {
+				jobs = append(jobs, job)
+			}
+		}
+		s.Jobs = jobs
+		return nil
+	})
+}
+
+// Status returns the processing status of the document of the given ID, of the tenant found in the context.
+// It returns an ErrNotFound error if there's no such document.
+func (p *Pipeline) Status(ctx context.Context, id int64) (Status, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	if _, err := p.pathsrv.GetPath(ctx, id); err != nil {
+		return Status{}, err
+	}
+	status := Status{DocumentID: id, State: StateSucceeded, Stages: []StageStatus{}}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, job := range p.state.Jobs {
+		if job.Tenant != tenant || job.DocumentID != id {
+			continue
+		}
+		status.Stages = append(status.Stages, StageStatus{
+			Stage:     job.Stage,
+			State:     job.State,
+			Attempts:  job.Attempts,
+			LastError: job.LastError,
+			UpdatedAt: job.UpdatedAt,
+		})
+		switch {
+		case job.State == StateFailed:
+			status.State = StateFailed
+		case job.State != StateSucceeded && status.State != StateFailed:
+			status.State = StatePending
+		}
+	}
+	sort.Slice(status.Stages, func(i, j int) bool { return status.Stages[i].Stage < status.Stages[j].Stage })
+	return status, nil
+}
+
+// Run runs the pending jobs as they are due until the context is done, persisting their outcomes along the way,
+// and once more before returning.
+func (p *Pipeline) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(min(p.dispatchDue(ctx), p.flushDue(ctx, false)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			p.flushDue(ctx, true)
+			return
+		case <-p.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// enqueue queues a job of every stage accepting the document of the event. If a job of the stage is already
+// running, it's run again once it finishes, as it may be processing the content the document had before.
+func (p *Pipeline) enqueue(ctx context.Context, event events.Event) error {
+	record, err := p.pathsrv.GetPath(tenancy.WithTenant(ctx, event.Tenant), event.DocumentID)
+	if errors.Is(err, errs.ErrNotFound) {
+		// The document was removed since, so there's nothing to process.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	var accepting []string
+	for name, stage := range p.stages {
+		if stage.Accepts(record.ContentType) {
+			accepting = append(accepting, name)
+		}
+	}
+	p.mu.Unlock()
+	if len(accepting) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	err = p.update(func(s *state) error {
+		for _, name := range accepting {
+			job := Job{
+				Tenant:      event.Tenant,
+				DocumentID:  event.DocumentID,
+				ContentType: record.ContentType,
+				Stage:       name,
+				State:       StatePending,
+				NextAttempt: now,
+				UpdatedAt:   now,
+			}
+			index := s.index(job.key())
+			switch {
+			case index < 0:
+				s.Jobs = append(s.Jobs, job)
+			case s.Jobs[index].State == StateRunning:
+				s.Jobs[index].ContentType = record.ContentType
+				s.Jobs[index].Rerun = true
+			default:
+				s.Jobs[index] = job
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.signal()
+	return nil
+}
+
+// dispatchDue starts running the due jobs, as many as there are idle workers, and returns how long to wait
+// until the next one is due.
+func (p *Pipeline) dispatchDue(ctx context.Context) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	wait := idleWait
+	for i := range p.state.Jobs {
+		job := &p.state.Jobs[i]
+		if job.State != StatePending {
+			continue
+		}
+		if until := job.NextAttempt.Sub(now); until > 0 {
+			wait = min(wait, until)
+			continue
+		}
+		stage, registered := p.stages[job.Stage]
+		if !registered || len(p.inFlight) >= p.config.Workers {
+			// A worker going idle wakes Run up. Jobs of stages no longer registered wait for them to be back.
+			continue
+		}
+		job.State = StateRunning
+		job.UpdatedAt = now
+		p.inFlight[job.key()] = true
+		go p.run(ctx, *job, stage)
+	}
+	return wait
+}
+
+// run runs the job and records its outcome: a successful job succeeds, unless the document changed meanwhile
+// and it must run again, and a failed one is retried later or, if it ran out of attempts, fails for good.
+func (p *Pipeline) run(ctx context.Context, job Job, stage Stage) {
+	defer p.signal()
+	err := p.process(ctx, job, stage)
+	if ctx.Err() != nil {
+		// Interrupted by a shutdown: the job is still pending in the state file, so it runs after the restart.
+		p.mu.Lock()
+		delete(p.inFlight, job.key())
+		p.mu.Unlock()
+		return
+	}
+	if err != nil {
+		p.logger.Error(
+			ctx,
+			"Attempt %d of stage %s on document %d of tenant %s failed: %s",
+			job.Attempts+1,
+			job.Stage,
+			job.DocumentID,
+			job.Tenant,
+			err.Error(),
+		)
+	}
+	p.record(func(s *state) {
+		delete(p.inFlight, job.key())
+		index := s.index(job.key())
+		if index < 0 {
+			// The document was removed while the job was running.
+			return
+		}
+		current := &s.Jobs[index]
+		current.UpdatedAt = time.Now()
+		switch {
+		case current.Rerun:
+			current.State = StatePending
+			current.Attempts = 0
+			current.LastError = ""
+			current.NextAttempt = current.UpdatedAt
+			current.Rerun = false
+		case err == nil:
+			current.State = StateSucceeded
+			current.LastError = ""
+		default:
+			current.Attempts++
+			current.LastError = err.Error()
+			current.State = StatePending
+			current.NextAttempt = current.UpdatedAt.Add(p.config.backoff(current.Attempts))
+			if current.Attempts >= p.config.MaxAttempts {
+				current.State = StateFailed
+			}
+		}
+	})
+}
+
+// process runs the stage on the document of the job, on behalf of its tenant.
+// A panicking stage fails the attempt instead of crashing the service.
+func (p *Pipeline) process(ctx context.Context, job Job, stage Stage) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("stage panicked: %v", recovered)
+		}
+	}()
+	return stage.Process(
+		tenancy.WithTenant(ctx, job.Tenant),
+		Document{ID: job.DocumentID, ContentType: job.ContentType},
+	)
+}
+
+// update applies fn to a copy of the state and, unless it fails, persists the copy and makes it the state.
+func (p *Pipeline) update(fn func(*state) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	next := state{Jobs: append([]Job{}, p.state.Jobs...)}
+	if err := fn(&next); err != nil {
+		return err
+	}
+	if err := p.save(next); err != nil {
+		return fmt.Errorf("%w: failed to persist pipeline jobs: %s", errs.ErrinternalError, err.Error())
+	}
+	p.state = next
+	p.dirty = false
+	p.saved = time.Now()
+	return nil
+}
+
+// record applies fn to the state, leaving it to be persisted by Run or along with the next update. It's meant
+// for the outcomes of the jobs: if the service stops before they're persisted, the jobs run again.
+func (p *Pipeline) record(fn func(*state)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(&p.state)
+	p.dirty = true
+}
+
+// flushDue prunes the jobs past their retention period and persists the state if outcomes were recorded since
+// it was last persisted at least Config.FlushInterval ago, or right away if forced. It returns how long to wait
+// until the next flush is due.
+func (p *Pipeline) flushDue(ctx context.Context, force bool) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.prune(time.Now()) {
+		p.dirty = true
+	}
+	if !p.dirty {
+		return idleWait
+	}
+	if until := p.config.FlushInterval - time.Since(p.saved); until > 0 && !force {
+		return until
+	}
+	if err := p.save(p.state); err != nil {
+		p.logger.Error(ctx, "Failed to persist pipeline jobs: %s", err.Error())
+		// It's retried after another interval, rather than on every wake up.
+		p.saved = time.Now()
+		return p.config.FlushInterval
+	}
+	p.dirty = false
+	p.saved = time.Now()
+	return idleWait
+}
+
+// save writes the persisted part of the state to the state file.
+func (p *Pipeline) save(s state) error {
+	content, err := json.Marshal(s.persisted())
+	if err != nil {
+		return err
+	}
+	return fileutils.WriteAtomic(p.config.StateFile, content, 0644)
+}
+
+// prune drops the succeeded jobs last updated before Config.Retention, and the failed ones before
+// Config.FailedRetention. It reports whether any failed job was dropped, as only those are in the state file.
+func (p *Pipeline) prune(now time.Time) bool {
+	jobs := p.state.Jobs[:0:0]
+	droppedFailed := false
+	for _, job := range p.state.Jobs {
+		switch {
+		case job.State == StateSucceeded && now.Sub(job.UpdatedAt) >= p.config.Retention:
+		case job.State == StateFailed && now.Sub(job.UpdatedAt) >= p.config.FailedRetention:
+			droppedFailed = true
+		default:
+			jobs = append(jobs, job)
+		}
+	}
+	p.state.Jobs = jobs
+	return droppedFailed
+}
+
+// persisted returns the state without the succeeded jobs, which is what's persisted. Failed jobs are kept, so
+// their status survives restarts.
+func (s state) persisted() state {
+	jobs := []Job{}
+	for _, job := range s.Jobs {
+		if job.State != StateSucceeded {
+			jobs = append(jobs, job)
+		}
+	}
+	return state{Jobs: jobs}
+}
+
+// signal wakes Run up, unless it's already due to wake up.
+func (p *Pipeline) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// index returns the index of the job with the given key, or -1 if there's none.
+func (s *state) index(key jobKey) int {
+	for i, job := range s.Jobs {
+		if job.key() == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,262 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// recorder is a stage that fails the first attempts on every document, then records the documents processed.
+type recorder struct {
+	failures  int
+	mu        sync.Mutex
+	attempts  map[int64]int
+	processed []int64
+}
+
+func (r *recorder) process(ctx context.Context, document Document) error {
+	if _, err := tenancy.FromContext(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts[document.ID]++
+	if r.attempts[document.ID] <= r.failures {
+		return errors.New("transient failure")
+	}
+	r.processed = append(r.processed, document.ID)
+	return nil
+}
+
+func (r *recorder) documents() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64{}, r.processed...)
+}
+
+func testConfig(t *testing.T) Config {
+	return Config{
+		StateFile:       filepath.Join(t.TempDir(), "state.json"),
+		Workers:         2,
+		MaxAttempts:     3,
+		InitialBackoff:  time.Millisecond,
+		MaxBackoff:      10 * time.Millisecond,
+		Retention:       time.Hour,
+		FailedRetention: time.Hour,
+		FlushInterval:   10 * time.Millisecond,
+	}
+}
+
+// store saves a path record of the given content type and publishes its creation to the pipeline.
+func store(t *testing.T, ctx context.Context, paths pathservice.PathService, p *Pipeline, id int64, contentType string) {
+	t.Helper()
+	paths.SavePath(ctx, id, pathrepository.PathRecord{Path: "doc", ContentType: contentType})
+	event, err := events.New(ctx, events.DocumentCreated, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Publish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run runs the pipeline until the test finishes, waiting for it to persist its jobs before the state file is
+// removed.
+func run(t *testing.T, ctx context.Context, p *Pipeline) {
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		p.Run(runCtx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// eventually fails the test if the condition doesn't hold within a second.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStagesByContentType(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	pipeline, err := New(logger, paths, testConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := &recorder{failures: 1, attempts: map[int64]int{}}
+	images := &recorder{attempts: map[int64]int{}}
+	pipeline.Register(NewStage("text", []string{"text/*", "application/pdf"}, text.process))
+	pipeline.Register(NewStage("images", []string{"image/*"}, images.process))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	run(t, ctx, pipeline)
+
+	store(t, ctx, paths, pipeline, 1, "text/plain; charset=utf-8")
+	store(t, ctx, paths, pipeline, 2, "application/pdf")
+	store(t, ctx, paths, pipeline, 3, "application/zip")
+	eventually(t, func() bool { return len(text.documents()) == 2 })
+	if processed := images.documents(); len(processed) != 0 {
+		t.Errorf("Expected no image to be processed, got %v", processed)
+	}
+
+	var status Status
+	eventually(t, func() bool {
+		status, _ = pipeline.Status(ctx, 1)
+		return status.State == StateSucceeded
+	})
+	if len(status.Stages) != 1 || status.Stages[0].Stage != "text" || status.Stages[0].State != StateSucceeded {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if status, _ := pipeline.Status(ctx, 3); status.State != StateSucceeded || len(status.Stages) != 0 {
+		t.Errorf("Expected a document without stages to have nothing to process, got %+v", status)
+	}
+	if _, err := pipeline.Status(ctx, 4); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a not found error for an unknown document, got %v", err)
+	}
+}
+
+func TestFailedStage(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	pipeline, err := New(logger, paths, testConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline.Register(NewStage("broken", nil, func(context.Context, Document) error {
+		panic("unexpected content")
+	}))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	run(t, ctx, pipeline)
+
+	store(t, ctx, paths, pipeline, 1, "text/plain")
+	var status Status
+	eventually(t, func() bool {
+		status, _ = pipeline.Status(ctx, 1)
+		return status.State == StateFailed
+	})
+	if status.Stages[0].Attempts != 3 || status.Stages[0].LastError == "" {
+		t.Errorf("Expected the stage to fail after 3 attempts, got %+v", status.Stages[0])
+	}
+}
+
+func TestSucceededJobsPruned(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	config := testConfig(t)
+	config.Retention = 50 * time.Millisecond
+	pipeline, err := New(logger, paths, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage := &recorder{attempts: map[int64]int{}}
+	pipeline.Register(NewStage("text", nil, stage.process))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	run(t, ctx, pipeline)
+
+	store(t, ctx, paths, pipeline, 1, "text/plain")
+	eventually(t, func() bool {
+		status, _ := pipeline.Status(ctx, 1)
+		return len(status.Stages) == 1 && status.State == StateSucceeded
+	})
+	eventually(t, func() bool {
+		restarted, err := New(logger, paths, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, _ := restarted.Status(ctx, 1)
+		return status.State == StateSucceeded && len(status.Stages) == 0
+	})
+
+	time.Sleep(config.Retention)
+	pipeline.signal()
+	eventually(t, func() bool {
+		status, _ := pipeline.Status(ctx, 1)
+		return status.State == StateSucceeded && len(status.Stages) == 0
+	})
+}
+
+func TestFailedJobsPruned(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	config := testConfig(t)
+	config.FailedRetention = 100 * time.Millisecond
+	pipeline, err := New(logger, paths, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage := &recorder{failures: config.MaxAttempts, attempts: map[int64]int{}}
+	pipeline.Register(NewStage("text", nil, stage.process))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	run(t, ctx, pipeline)
+
+	store(t, ctx, paths, pipeline, 1, "text/plain")
+	eventually(t, func() bool {
+		status, _ := pipeline.Status(ctx, 1)
+		return status.State == StateFailed
+	})
+	time.Sleep(config.FailedRetention)
+	pipeline.signal()
+	eventually(t, func() bool {
+		status, _ := pipeline.Status(ctx, 1)
+		return status.State == StateSucceeded && len(status.Stages) == 0
+	})
+	eventually(t, func() bool {
+		restarted, err := New(logger, paths, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(restarted.state.Jobs) == 0
+	})
+}
+
+func TestPendingJobsSurviveRestart(t *testing.T) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	config := testConfig(t)
+	pipeline, err := New(logger, paths, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage := &recorder{attempts: map[int64]int{}}
+	pipeline.Register(NewStage("text", []string{"text/*"}, stage.process))
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	store(t, ctx, paths, pipeline, 1, "text/plain")
+	store(t, ctx, paths, pipeline, 2, "text/plain")
+	purged, _ := events.New(ctx, events.DocumentPurged, 2)
+	pipeline.Publish(ctx, purged)
+
+	restarted, err := New(logger, paths, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := restarted.Status(ctx, 1); status.State != StatePending {
+		t.Errorf("Expected the job to be reloaded as pending, got %+v", status)
+	}
+	restarted.Register(NewStage("text", []string{"text/*"}, stage.process))
+	run(t, ctx, restarted)
+	eventually(t, func() bool { return len(stage.documents()) == 1 })
+	time.Sleep(20 * time.Millisecond)
+	if processed := stage.documents(); len(processed) != 1 || processed[0] != 1 {
+		t.Errorf("Expected only the document that wasn't purged to be processed, got %v", processed)
+	}
+}
@@ -0,0 +1,100 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// NewMemoryTracker returns a Tracker that keeps the usage in memory, enforcing the given limits
+// for every tenant and every user. As the usage is derived from the stored paths, it's meant to be
+// used along with a repository that doesn't persist beyond the application lifecycle either.
+func NewMemoryTracker(tenantLimits Limits, userLimits Limits) Tracker {
+	return &memoryTracker{
+		tenantLimits: tenantLimits,
+		userLimits:   userLimits,
+		tenants:      make(map[string]Usage),
+		users:        make(map[userKey]Usage),
+	}
+}
+
+// userKey identifies a user. User IDs are only unique within a tenant.
+type userKey struct {
+	tenant string
+	user   string
+}
+
+// memoryTracker implements the Tracker interface in memory.
+// A single mutex guards both maps, so tenant and user usage are always updated together.
+type memoryTracker struct {
+	tenantLimits Limits            // tenantLimits is applied to every tenant.
+	userLimits   Limits            // userLimits is applied to every user.
+	mu           sync.Mutex        // mu guards tenants and users.
+	tenants      map[string]Usage  // tenants holds the usage of every tenant.
+	users        map[userKey]Usage // users holds the usage of every user.
+}
+
+// Reserve adds an object of the given size to the usage of the tenant and the owner,
+// failing with an ErrQuotaExceeded error if any of their limits would be exceeded.
+// Objects without owner only count against the tenant quota.
+func (m *memoryTracker) Reserve(ctx context.Context, owner string, bytes int64) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.tenantLimits.allows(m.tenants[tenant], bytes) {
+		return fmt.Errorf("%w: tenant %s can't store %d more bytes", errs.ErrQuotaExceeded, tenant, bytes)
+	}
+	user := userKey{tenant, owner}
+	if owner != "" && !m.userLimits.allows(m.users[user], bytes) {
+		return fmt.Errorf("%w: user %s can't store %d more bytes", errs.ErrQuotaExceeded, owner, bytes)
+	}
+	m.tenants[tenant] = add(m.tenants[tenant], bytes, 1)
+	if owner != "" {
+		m.users[user] = add(m.users[user], bytes, 1)
+	}
+	return nil
+}
+
+// Release removes an object of the given size from the usage of the tenant and the owner.
+func (m *memoryTracker) Release(ctx context.Context, owner string, bytes int64) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tenants[tenant] = add(m.tenants[tenant], -bytes, -1)
+	if owner != "" {
+		user := userKey{tenant, owner}
+		m.users[user] = add(m.users[user], -bytes, -1)
+	}
+	return nil
+}
+
+// Report returns the usage and limits of the tenant and, if given, of the user.
+func (m *memoryTracker) Report(ctx context.Context, user string) (Summary, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return Summary{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summary := Summary{Tenant: Report{m.tenants[tenant], m.tenantLimits}}
+	if user != "" {
+		summary.User = &Report{m.users[userKey{tenant, user}], m.userLimits}
+	}
+	return summary, nil
+}
+
+// add returns usage with the given bytes and objects added, never going below zero.
+func add(usage Usage, bytes int64, objects int64) Usage {
+	usage.Bytes = max(usage.Bytes+bytes, 0)
+	usage.Objects = max(usage.Objects+objects, 0)
+	return usage
+}
@@ -0,0 +1,63 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+func TestReserveWithinLimits(t *testing.T) {
+	tracker := NewMemoryTracker(Limits{MaxBytes: 100, MaxObjects: 2}, Limits{})
+	ctx := tenancy.WithTenant(context.TODO(), "acme")
+
+	if err := tracker.Reserve(ctx, "alice", 60); err != nil {
+		t.Fatalf("Expected reservation to succeed, got %v", err)
+	}
+	summary, _ := tracker.Report(ctx, "alice")
+	if summary.Tenant.Usage != (Usage{Bytes: 60, Objects: 1}) {
+		t.Errorf("Unexpected tenant usage %+v", summary.Tenant.Usage)
+	}
+	if summary.User == nil || summary.User.Usage != (Usage{Bytes: 60, Objects: 1}) {
+		t.Errorf("Unexpected user usage %+v", summary.User)
+	}
+}
+
+func TestReserveExceedingLimits(t *testing.T) {
+	tracker := NewMemoryTracker(Limits{MaxObjects: 3}, Limits{MaxBytes: 100})
+	ctx := tenancy.WithTenant(context.TODO(), "acme")
+
+	tracker.Reserve(ctx, "alice", 80)
+	if err := tracker.Reserve(ctx, "alice", 30); !errors.Is(err, errs.ErrQuotaExceeded) {
+		t.Errorf("Expected user quota to be exceeded, got %v", err)
+	}
+	if err := tracker.Reserve(ctx, "bob", 30); err != nil {
+		t.Errorf("Expected other users not to be affected, got %v", err)
+	}
+	tracker.Reserve(ctx, "", 1)
+	if err := tracker.Reserve(ctx, "bob", 1); !errors.Is(err, errs.ErrQuotaExceeded) {
+		t.Errorf("Expected tenant object quota to be exceeded, got %v", err)
+	}
+	summary, _ := tracker.Report(ctx, "")
+	if summary.Tenant.Usage != (Usage{Bytes: 111, Objects: 3}) {
+		t.Errorf("Failed reservations shouldn't change usage, got %+v", summary.Tenant.Usage)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	tracker := NewMemoryTracker(Limits{MaxBytes: 100}, Limits{})
+	ctx := tenancy.WithTenant(context.TODO(), "acme")
+
+	tracker.Reserve(ctx, "alice", 100)
+	tracker.Release(ctx, "alice", 100)
+	if err := tracker.Reserve(ctx, "alice", 100); err != nil {
+		t.Errorf("Expected released bytes to be available again, got %v", err)
+	}
+	other := tenancy.WithTenant(context.TODO(), "globex")
+	summary, _ := tracker.Report(other, "alice")
+	if summary.Tenant.Usage != (Usage{}) || summary.User.Usage != (Usage{}) {
+		t.Errorf("Expected usage to be isolated per tenant, got %+v", summary)
+	}
+}
@@ -0,0 +1,74 @@
+package quota
+
+import (
+	"context"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+)
+
+// Tracker defines the interface for tracking the storage used by every tenant and user.
+// The tenant is always taken from the context, while the user is given explicitly, as usage is
+// attributed to the owner of a file rather than to whoever performs the operation.
+type Tracker interface {
+	// Reserve atomically checks that storing an object of the given size doesn't exceed the quota
+	// of the tenant nor of the owner, and adds it to their usage.
+	// It returns an ErrQuotaExceeded error, without changing the usage, if any limit would be exceeded.
+	Reserve(ctx context.Context, owner string, bytes int64) error
+
+	// Release atomically removes an object of the given size from the usage of the tenant and the owner.
+	Release(ctx context.Context, owner string, bytes int64) error
+
+	// Report returns the current usage of the tenant and, if user is not empty, of the user,
+	// along with their limits.
+	Report(ctx context.Context, user string) (Summary, error)
+}
+
+// Limits bounds the storage a tenant or a user can take. A zero value means unlimited.
+type Limits struct {
+	MaxBytes   int64 `json:"maxBytes"`   // MaxBytes is the maximum number of bytes that can be stored.
+	MaxObjects int64 `json:"maxObjects"` // MaxObjects is the maximum number of files that can be stored.
+}
+
+// Usage is the storage currently taken by a tenant or a user.
+type Usage struct {
+	Bytes   int64 `json:"bytes"`   // Bytes is the number of bytes stored.
+	Objects int64 `json:"objects"` // Objects is the number of files stored.
+}
+
+// Report pairs the usage of a tenant or a user with its limits.
+type Report struct {
+	Usage  Usage  `json:"usage"`
+	Limits Limits `json:"limits"`
+}
+
+// Summary is the quota report of a tenant and, optionally, of one of its users.
+type Summary struct {
+	Tenant Report  `json:"tenant"`
+	User   *Report `json:"user,omitempty"`
+}
+
+// LimitsFromEnvironment reads the tenant and user limits from the environment variables
+// QUOTA_TENANT_MAX_BYTES, QUOTA_TENANT_MAX_OBJECTS, QUOTA_USER_MAX_BYTES and QUOTA_USER_MAX_OBJECTS.
+// Unset variables leave the corresponding limit unlimited.
+func LimitsFromEnvironment() (tenantLimits Limits, userLimits Limits) {
+	tenantLimits = Limits{
+		MaxBytes:   environment.GetInt64("QUOTA_TENANT_MAX_BYTES"),
+		MaxObjects: environment.GetInt64("QUOTA_TENANT_MAX_OBJECTS"),
+	}
+	userLimits = Limits{
+		MaxBytes:   environment.GetInt64("QUOTA_USER_MAX_BYTES"),
+		MaxObjects: environment.GetInt64("QUOTA_USER_MAX_OBJECTS"),
+	}
+	return tenantLimits, userLimits
+}
+
+// allows reports whether adding an object of the given size to usage stays within the limits.
+func (l Limits) allows(usage Usage, bytes int64) bool {
+	if l.MaxBytes > 0 && usage.Bytes+bytes > l.MaxBytes {
+		return false
+	}
+	if l.MaxObjects > 0 && usage.Objects+1 > l.MaxObjects {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/audit"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+// NewAudited wraps a Service so every change of retention, retention rules or legal holds, successful or not,
+// is recorded in the audit log. Failing to record a change doesn't fail it, but it's logged.
+func NewAudited(service Service, log audit.Log, logger logging.Logger) Service {
+	return &auditedService{service, log, logger}
+}
+
+// auditedService implements the Service interface, recording the changes made through the wrapped one.
+type auditedService struct {
+	Service
+	log    audit.Log
+	logger logging.Logger
+}
+
+// SetRetention sets the retention of the file and records the change.
+func (s *auditedService) SetRetention(ctx context.Context, id int64, until time.Time) error {
+	err := s.Service.SetRetention(ctx, id, until)
+	s.record(ctx, audit.ActionSetRetention, id, err)
+	return err
+}
+
+// SetLegalHold sets or clears the legal hold of the file and records the change.
+func (s *auditedService) SetLegalHold(ctx context.Context, id int64, hold bool, reason string) error {
+	err := s.Service.SetLegalHold(ctx, id, hold, reason)
+	action := audit.ActionClearLegalHold
+	if hold {
+		action = audit.ActionSetLegalHold
+	}
+	s.record(ctx, action, id, err)
+	return err
+}
+
+// AddRule adds the rule and records the change. Rules aren't tied to a document, so it's recorded with ID 0.
+func (s *auditedService) AddRule(ctx context.Context, rule Rule) (Rule, error) {
+	rule, err := s.Service.AddRule(ctx, rule)
+	s.record(ctx, audit.ActionAddRule, 0, err)
+	return rule, err
+}
+
+// RemoveRule removes the rule and records the change.
+func (s *auditedService) RemoveRule(ctx context.Context, id int64) error {
+	err := s.Service.RemoveRule(ctx, id)
+	s.record(ctx, audit.ActionRemoveRule, 0, err)
+	return err
+}
+
+// record records a change in the audit log, logging any failure.
+func (s *auditedService) record(ctx context.Context, action string, id int64, err error) {
+	if auditErr := s.log.Record(ctx, action, id, err); auditErr != nil {
+		s.logger.Error(ctx, "Failed to audit %s of file %d: %s", action, id, auditErr.Error())
+	}
+}
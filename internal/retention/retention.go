@@ -0,0 +1,122 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+)
+
+// Checker decides whether a file can be deleted or replaced.
+type Checker interface {
+	// Check returns an ErrRetained error if the file of the given ID and path record is under a legal hold,
+	// or under retention, whether set on the file itself or by a rule matching it.
+	Check(ctx context.Context, id int64, record pathrepository.PathRecord) error
+}
+
+// Records changes the retention of files serialized with every other change of them, as
+// storageservice.StorageService does.
+type Records interface {
+	UpdateRetention(ctx context.Context, id int64, change func(record *pathrepository.PathRecord) error) error
+}
+
+// Service manages the retention of the files of every tenant: the retention set on single files,
+// the rules that retain every file matching them, and the legal holds, whose changes are audited.
+// The tenant is always taken from the context.
+type Service interface {
+	Checker
+
+	// Status returns the retention status of the file of the given ID, along with the history of its legal hold.
+	Status(ctx context.Context, id int64) (Status, error)
+
+	// SetRetention retains the file of the given ID until the given time.
+	// Retention can only be extended: it returns an ErrRetained error if the file is already retained for longer.
+	SetRetention(ctx context.Context, id int64, until time.Time) error
+
+	// SetLegalHold sets or clears the legal hold of the file of the given ID, recording who did it and why.
+	SetLegalHold(ctx context.Context, id int64, hold bool, reason string) error
+
+	// AddRule adds a retention rule, returning it along with the ID assigned to it.
+	AddRule(ctx context.Context, rule Rule) (Rule, error)
+
+	// Rules returns the retention rules, sorted by ID.
+	Rules(ctx context.Context) ([]Rule, error)
+
+	// RemoveRule removes the retention rule with the given ID.
+	// It returns an ErrNotFound error if there's no such rule.
+	RemoveRule(ctx context.Context, id int64) error
+
+	// UseRecords makes the service change the retention and the legal holds of files through records, so the
+	// changes are serialized with every other change of the files. Until it's called they're saved straight to
+	// the path service. It's meant to be called once, at startup, as the records depend on the service.
+	UseRecords(records Records)
+}
+
+// Rule retains every file matching it for a period since the file was uploaded.
+// Empty criteria match every file.
+type Rule struct {
+	ID          int64  `json:"id"`                    // ID is the identifier of the rule.
+	ContentType string `json:"contentType,omitempty"` // ContentType matches a MIME type, e.g. "application/pdf" or "image/*".
+	Owner       string `json:"owner,omitempty"`       // Owner matches the user who uploaded the file.
+	Years       int    `json:"years,omitempty"`       // Years is the number of years files are retained for.
+	Days        int    `json:"days,omitempty"`        // Days is the number of days files are retained for, on top of Years.
+}
+
+// Validate checks that the rule retains files for a positive period and that its criteria are well-formed.
+func (r Rule) Validate() error {
+	if r.Years < 0 || r.Days < 0 || r.Years == 0 && r.Days == 0 {
+		return fmt.Errorf("%w: retention period must be positive", errs.ErrInvalidInput)
+	}
+	if r.ContentType != "" && !strings.Contains(r.ContentType, "/") {
+		return fmt.Errorf("%w: malformed content type %q", errs.ErrInvalidInput, r.ContentType)
+	}
+	return nil
+}
+
+// Matches reports whether the rule applies to the file described by the given record.
+func (r Rule) Matches(record pathrepository.PathRecord) bool {
+	if r.Owner != "" && r.Owner != record.Owner {
+		return false
+	}
+	if r.ContentType == "" {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(record.ContentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if prefix, found := strings.CutSuffix(r.ContentType, "/*"); found {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return strings.EqualFold(mediaType, r.ContentType)
+}
+
+// RetainUntil returns the time until which the rule retains the file described by the given record.
+func (r Rule) RetainUntil(record pathrepository.PathRecord) time.Time {
+	return record.CreatedAt.AddDate(r.Years, 0, r.Days)
+}
+
+// Status is the retention status of a file.
+type Status struct {
+	// RetainUntil is the time until which the file is retained, considering both its own retention and
+	// the rules matching it. It's nil if the file isn't retained.
+	RetainUntil *time.Time  `json:"retainUntil,omitempty"`
+	LegalHold   bool        `json:"legalHold"` // LegalHold tells whether the file is under a legal hold.
+	Rules       []int64     `json:"rules"`     // Rules are the IDs of the rules matching the file.
+	HoldHistory []HoldEvent `json:"holdHistory"`
+}
+
+// HoldEvent records a change of the legal hold of a file.
+type HoldEvent struct {
+	Action string    `json:"action"`          // Action is either "set" or "cleared".
+	Actor  string    `json:"actor,omitempty"` // Actor is the user who changed the hold, if it was attributed to one.
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"` // At is the time the hold was changed.
+}
+
+// Hold actions recorded in the hold history.
+const (
+	HoldSet     = "set"
+	HoldCleared = "cleared"
+)
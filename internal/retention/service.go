@@ -0,0 +1,238 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// New returns a Service that keeps the retention of single files and the legal holds in their path records,
+// and the rules and the hold history in memory.
+func New(logger logging.Logger, pathsrv pathservice.PathService) Service {
+	return &service{
+		logger:  logger,
+		pathsrv: pathsrv,
+		rules:   make(map[string][]Rule),
+		history: make(map[holdKey][]HoldEvent),
+	}
+}
+
+// holdKey identifies the file a hold history belongs to.
+type holdKey struct {
+	tenant string
+	id     int64
+}
+
+// service implements the Service interface.
+type service struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	mu      sync.Mutex              // mu guards the records, the rules and the hold history.
+	records Records                 // records change the retention of files, if set.
+	rules   map[string][]Rule       // rules are the retention rules of every tenant.
+	nextID  int64                   // nextID is the last ID assigned to a rule.
+	history map[holdKey][]HoldEvent // history is the hold history of every file.
+}
+
+// Check returns an ErrRetained error if the file is under a legal hold or retained until a later time.
+func (s *service) Check(ctx context.Context, id int64, record pathrepository.PathRecord) error {
+	if record.LegalHold {
+		return fmt.Errorf("%w: file with ID %d is under a legal hold", errs.ErrRetained, id)
+	}
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	until, _ := s.retainUntil(tenant, record)
+	s.mu.Unlock()
+	if until.After(time.Now()) {
+		return fmt.Errorf(
+			"%w: file with ID %d is retained until %s",
+			errs.ErrRetained,
+			id,
+			until.UTC().Format(time.RFC3339),
+		)
+	}
+	return nil
+}
+
+// Status returns the retention status of the file of the given ID.
+func (s *service) Status(ctx context.Context, id int64) (Status, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	record, err := s.pathsrv.GetPath(ctx, id)
+	if err != nil {
+		return Status{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, rules := s.retainUntil(tenant, record)
+	status := Status{
+		LegalHold:   record.LegalHold,
+		Rules:       rules,
+		HoldHistory: append([]HoldEvent{}, s.history[holdKey{tenant, id}]...),
+	}
+	if !until.IsZero() {
+		status.RetainUntil = &until
+	}
+	return status, nil
+}
+
+// SetRetention retains the file of the given ID until the given time, which can't be earlier than its
+// current retention.
+func (s *service) SetRetention(ctx context.Context, id int64, until time.Time) error {
+	if !until.After(time.Now()) {
+		return fmt.Errorf("%w: retention must end in the future", errs.ErrInvalidInput)
+	}
+	return s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		if record.RetainUntil.After(until) {
+			return fmt.Errorf(
+				"%w: file with ID %d is already retained until %s, retention can't be shortened",
+				errs.ErrRetained,
+				id,
+				record.RetainUntil.UTC().Format(time.RFC3339),
+			)
+		}
+		record.RetainUntil = until
+		return nil
+	})
+}
+
+// SetLegalHold sets or clears the legal hold of the file of the given ID, appending the change to its history.
+// Setting a hold that is already set, or clearing one that isn't, is recorded too.
+func (s *service) SetLegalHold(ctx context.Context, id int64, hold bool, reason string) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.update(ctx, id, func(record *pathrepository.PathRecord) error {
+		record.LegalHold = hold
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	event := HoldEvent{
+		Action: HoldCleared,
+		Actor:  tenancy.UserFromContext(ctx),
+		Reason: reason,
+		At:     time.Now(),
+	}
+	if hold {
+		event.Action = HoldSet
+	}
+	key := holdKey{tenant, id}
+	s.mu.Lock()
+	s.history[key] = append(s.history[key], event)
+	s.mu.Unlock()
+	s.logger.Info(ctx, "Legal hold of file %d %s by %q: %s", id, event.Action, event.Actor, reason)
+	return nil
+}
+
+// UseRecords sets the records the retention of files is changed through.
+func (s *service) UseRecords(records Records) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = records
+}
+
+// update changes the record of the file of the given ID with the given function, through the records if they
+// are set, and otherwise straight in the path service, serialized only with the other changes of retention.
+// mu isn't held while the records change it, as they check the retention of files holding their own lock.
+func (s *service) update(
+	ctx context.Context,
+	id int64,
+	change func(record *pathrepository.PathRecord) error,
+) error {
+	s.mu.Lock()
+	records := s.records
+	if records != nil {
+		s.mu.Unlock()
+		return records.UpdateRetention(ctx, id, change)
+	}
+	defer s.mu.Unlock()
+	record, err := s.pathsrv.GetPath(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := change(&record); err != nil {
+		return err
+	}
+	return s.pathsrv.SavePath(ctx, id, record)
+}
+
+// AddRule validates the rule and adds it to the rules of the tenant, assigning it a new ID.
+func (s *service) AddRule(ctx context.Context, rule Rule) (Rule, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	rule.ID = s.nextID
+	s.rules[tenant] = append(s.rules[tenant], rule)
+	return rule, nil
+}
+
+// Rules returns the rules of the tenant, sorted by ID.
+func (s *service) Rules(ctx context.Context) ([]Rule, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := append([]Rule{}, s.rules[tenant]...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+// RemoveRule removes the rule with the given ID from the rules of the tenant.
+func (s *service) RemoveRule(ctx context.Context, id int64) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := s.rules[tenant]
+	for i, rule := range rules {
+		if rule.ID == id {
+			s.rules[tenant] = append(rules[:i:i], rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: retention rule with id %d not found", errs.ErrNotFound, id)
+}
+
+// retainUntil returns the time until which the file described by the given record is retained, along with
+// the IDs of the rules of the tenant matching it. It must be called with mu held.
+func (s *service) retainUntil(tenant string, record pathrepository.PathRecord) (time.Time, []int64) {
+	until := record.RetainUntil
+	matching := []int64{}
+	for _, rule := range s.rules[tenant] {
+		if !rule.Matches(record) {
+			continue
+		}
+		matching = append(matching, rule.ID)
+		if ruleUntil := rule.RetainUntil(record); ruleUntil.After(until) {
+			until = ruleUntil
+		}
+	}
+	return until, matching
+}
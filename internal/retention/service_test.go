@@ -0,0 +1,139 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// newTestService returns a Service over a path service holding a PDF file with ID 1, uploaded now by "alice",
+// along with a context of the tenant "acme" and the user "bob".
+func newTestService(t *testing.T) (Service, pathservice.PathService, context.Context) {
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	ctx := tenancy.WithUser(tenancy.WithTenant(context.Background(), "acme"), "bob")
+	record := pathrepository.PathRecord{
+		Path:        "contract.pdf",
+		Owner:       "alice",
+		ContentType: "application/pdf",
+		CreatedAt:   time.Now(),
+	}
+	if err := paths.SavePath(ctx, 1, record); err != nil {
+		t.Fatal(err)
+	}
+	return New(logger, paths), paths, ctx
+}
+
+// check checks the retention of the file with ID 1.
+func check(t *testing.T, service Service, paths pathservice.PathService, ctx context.Context) error {
+	t.Helper()
+	record, err := paths.GetPath(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.Check(ctx, 1, record)
+}
+
+var matchTests = []struct {
+	rule     Rule
+	expected bool
+}{
+	{Rule{Years: 1}, true},
+	{Rule{ContentType: "application/pdf", Years: 1}, true},
+	{Rule{ContentType: "application/*", Years: 1}, true},
+	{Rule{ContentType: "image/*", Years: 1}, false},
+	{Rule{Owner: "alice", Days: 1}, true},
+	{Rule{Owner: "bob", Days: 1}, false},
+}
+
+func TestRuleMatches(t *testing.T) {
+	record := pathrepository.PathRecord{Owner: "alice", ContentType: "application/pdf; charset=binary"}
+	for _, tt := range matchTests {
+		if got := tt.rule.Matches(record); got != tt.expected {
+			t.Errorf("Expected %+v to match: %v, got: %v", tt.rule, tt.expected, got)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	service, paths, ctx := newTestService(t)
+	if err := check(t, service, paths, ctx); err != nil {
+		t.Errorf("Expected the file not to be retained, got: %v", err)
+	}
+	if _, err := service.AddRule(ctx, Rule{ContentType: "application/pdf"}); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for a rule without period, got: %v", err)
+	}
+	rule, err := service.AddRule(ctx, Rule{ContentType: "application/pdf", Years: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := check(t, service, paths, ctx); !errors.Is(err, errs.ErrRetained) {
+		t.Errorf("Expected ErrRetained under a matching rule, got: %v", err)
+	}
+	otherTenant := tenancy.WithTenant(context.Background(), "other")
+	if rules, _ := service.Rules(otherTenant); len(rules) != 0 {
+		t.Errorf("Expected rules to be scoped by tenant, got: %v", rules)
+	}
+	if err := service.RemoveRule(ctx, rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(t, service, paths, ctx); err != nil {
+		t.Errorf("Expected the file not to be retained once the rule is removed, got: %v", err)
+	}
+}
+
+func TestSetRetention(t *testing.T) {
+	service, paths, ctx := newTestService(t)
+	until := time.Now().Add(24 * time.Hour)
+	if err := service.SetRetention(ctx, 1, until); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(t, service, paths, ctx); !errors.Is(err, errs.ErrRetained) {
+		t.Errorf("Expected ErrRetained, got: %v", err)
+	}
+	if err := service.SetRetention(ctx, 1, until.Add(-time.Hour)); !errors.Is(err, errs.ErrRetained) {
+		t.Errorf("Expected ErrRetained shortening the retention, got: %v", err)
+	}
+	if err := service.SetRetention(ctx, 1, time.Now().Add(-time.Hour)); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for a past retention, got: %v", err)
+	}
+	status, _ := service.Status(ctx, 1)
+	if status.RetainUntil == nil || !status.RetainUntil.Equal(until) {
+		t.Errorf("Expected the file to be retained until %v, got: %+v", until, status)
+	}
+}
+
+func TestLegalHold(t *testing.T) {
+	service, paths, ctx := newTestService(t)
+	if err := service.SetLegalHold(ctx, 1, true, "litigation"); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(t, service, paths, ctx); !errors.Is(err, errs.ErrRetained) {
+		t.Errorf("Expected ErrRetained under a legal hold, got: %v", err)
+	}
+	if err := service.SetLegalHold(ctx, 1, false, "settled"); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(t, service, paths, ctx); err != nil {
+		t.Errorf("Expected the file not to be retained once the hold is cleared, got: %v", err)
+	}
+
+	status, err := service.Status(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := status.HoldHistory
+	if len(history) != 2 || history[0].Action != HoldSet || history[1].Action != HoldCleared {
+		t.Fatalf("Expected the hold to be set and cleared, got: %+v", history)
+	}
+	if history[0].Actor != "bob" || history[0].Reason != "litigation" {
+		t.Errorf("Expected the actor and reason to be recorded, got: %+v", history[0])
+	}
+}
@@ -0,0 +1,103 @@
+package scanning
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the chunks the content is streamed to clamd in.
+const chunkSize = 64 << 10
+
+// Clamd is a Scanner that streams the content to a clamd daemon with the INSTREAM command.
+type Clamd struct {
+	network string        // network is either "tcp" or "unix".
+	address string        // address is the host and port, or the socket path, clamd listens on.
+	timeout time.Duration // timeout bounds every exchange with clamd, so a stuck daemon doesn't hang uploads.
+}
+
+// NewClamd creates a new Clamd client for the daemon at the given address, either "tcp://host:port",
+// "unix:///path/to/socket" or a bare "host:port".
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	network, location, found := strings.Cut(address, "://")
+	if !found {
+		network, location = "tcp", address
+	}
+	if (network != "tcp" && network != "unix") || location == "" {
+		return nil, fmt.Errorf("malformed clamd address %q", address)
+	}
+	return &Clamd{network, location, timeout}, nil
+}
+
+// Scan streams the content to clamd in chunks, each prefixed by its length, and parses its verdict.
+func (c *Clamd) Scan(ctx context.Context, content io.Reader) (Result, error) {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	writeErr := c.stream(conn, content)
+	// clamd answers, and closes the connection, as soon as the stream exceeds its size limit,
+	// so its reply is read even if streaming failed.
+	conn.SetReadDeadline(time.Now().Add(c.timeout))
+	reply, readErr := bufio.NewReader(conn).ReadString(0)
+	if readErr != nil && (reply == "" || !errors.Is(readErr, io.EOF)) {
+		if writeErr != nil {
+			return Result{}, fmt.Errorf("failed to stream content to clamd: %w", writeErr)
+		}
+		return Result{}, fmt.Errorf("failed to read clamd reply: %w", readErr)
+	}
+	return parseReply(reply)
+}
+
+// stream sends the INSTREAM command followed by the content, chunk by chunk, and the terminating empty chunk.
+func (c *Clamd) stream(conn net.Conn, content io.Reader) error {
+	conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+	buffer := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(content, buffer[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buffer, uint32(n))
+			conn.SetWriteDeadline(time.Now().Add(c.timeout))
+			if _, err := conn.Write(buffer[:4+n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read content: %w", err)
+		}
+	}
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseReply parses the reply of clamd to an INSTREAM command, e.g. "stream: OK",
+// "stream: Eicar-Signature FOUND" or "INSTREAM size limit exceeded. ERROR".
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	verdict := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return Result{}, fmt.Errorf("clamd failed to scan: %s", reply)
+	}
+}
@@ -0,0 +1,114 @@
+package scanning
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveClamd serves the INSTREAM command on the listener like clamd does, flagging the EICAR test file and
+// rejecting streams longer than limit bytes.
+func serveClamd(t *testing.T, listener net.Listener, limit int) {
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				if command, _ := reader.ReadString(0); command != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var content bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if content.Len()+int(size) > limit {
+						conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+						return
+					}
+					io.CopyN(&content, reader, int64(size))
+				}
+				if bytes.Contains(content.Bytes(), []byte(EICAR)) {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}()
+		}
+	}()
+}
+
+func TestClamdOverTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveClamd(t, listener, 1<<20)
+	clamd, err := NewClamd("tcp://"+listener.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clean := strings.Repeat("harmless ", 20000) // Spans several chunks.
+	result, err := clamd.Scan(context.Background(), strings.NewReader(clean))
+	if err != nil || result.Infected {
+		t.Errorf("Expected clean content, got %+v, err=%v", result, err)
+	}
+	result, err = clamd.Scan(context.Background(), strings.NewReader(clean+EICAR))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Signature" {
+		t.Errorf("Expected the EICAR signature, got %+v, err=%v", result, err)
+	}
+	if _, err := clamd.Scan(context.Background(), strings.NewReader(strings.Repeat("x", 2<<20))); err == nil {
+		t.Error("Expected an error for content exceeding the size limit")
+	}
+}
+
+func TestClamdOverUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveClamd(t, listener, 1<<20)
+	clamd, err := NewClamd("unix://"+socket, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := clamd.Scan(context.Background(), strings.NewReader(EICAR))
+	if err != nil || !result.Infected {
+		t.Errorf("Expected infected content, got %+v, err=%v", result, err)
+	}
+}
+
+func TestClamdUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	clamd, _ := NewClamd(address, time.Second)
+	if _, err := clamd.Scan(context.Background(), strings.NewReader("content")); err == nil {
+		t.Error("Expected an error scanning with an unreachable clamd")
+	}
+	if _, err := NewClamd("udp://127.0.0.1:3310", time.Second); err == nil {
+		t.Error("Expected an error for an unsupported network")
+	}
+}
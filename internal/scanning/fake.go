@@ -0,0 +1,38 @@
+package scanning
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// EICAR is the standard antivirus test file, which every scanner detects as malware but is harmless.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// Fake is a Scanner that flags the content containing any of its patterns, meant for tests.
+type Fake struct {
+	Signatures map[string]string // Signatures maps the patterns flagged to the name of their signature.
+	Err        error             // Err, if set, is returned by every scan, simulating an unreachable scanner.
+}
+
+// NewFake creates a new Fake that detects the EICAR test file.
+func NewFake() *Fake {
+	return &Fake{Signatures: map[string]string{EICAR: "Eicar-Signature"}}
+}
+
+// Scan reads the whole content, looking for the patterns of the fake.
+func (f *Fake) Scan(ctx context.Context, content io.Reader) (Result, error) {
+	if f.Err != nil {
+		return Result{}, f.Err
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return Result{}, err
+	}
+	for pattern, signature := range f.Signatures {
+		if bytes.Contains(data, []byte(pattern)) {
+			return Result{Infected: true, Signature: signature}, nil
+		}
+	}
+	return Result{}, nil
+}
@@ -0,0 +1,69 @@
+package scanning
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+)
+
+// Scanner scans content for malware.
+type Scanner interface {
+	// Scan reads the content and reports whether it's infected.
+	// It returns an error if the content couldn't be scanned, e.g. because the scanner is unreachable.
+	Scan(ctx context.Context, content io.Reader) (Result, error)
+}
+
+// Result is the verdict of a scan.
+type Result struct {
+	Infected  bool   // Infected tells whether malware was found.
+	Signature string // Signature names the malware found, if any.
+}
+
+// Policy decides how uploads are scanned. The zero Policy disables scanning.
+type Policy struct {
+	Scanner Scanner // Scanner scans every upload. It's nil if scanning is disabled.
+	// FailOpen accepts uploads that couldn't be scanned. Otherwise, which is the default, they are rejected.
+	FailOpen bool
+	// Quarantine keeps infected uploads aside, in the storage, instead of dropping them.
+	Quarantine bool
+}
+
+// Enabled reports whether uploads are scanned.
+func (p Policy) Enabled() bool {
+	return p.Scanner != nil
+}
+
+// PolicyFromEnvironment reads the scanning policy from the environment variables SCANNER, which can be
+// "clamd" or empty, which disables scanning, CLAMD_ADDRESS, the address of clamd as "tcp://host:port" or
+// "unix:///path/to/socket", which defaults to tcp://127.0.0.1:3310, SCAN_TIMEOUT, which defaults to 30s,
+// SCAN_FAIL_OPEN and SCAN_QUARANTINE.
+func PolicyFromEnvironment() (Policy, error) {
+	policy := Policy{
+		FailOpen:   environment.GetBool("SCAN_FAIL_OPEN"),
+		Quarantine: environment.GetBool("SCAN_QUARANTINE"),
+	}
+	switch kind := environment.GetScanner(); kind {
+	case "":
+		return policy, nil
+	case "clamd":
+		address := environment.GetClamdAddress()
+		if address == "" {
+			address = "tcp://127.0.0.1:3310"
+		}
+		timeout := environment.GetDuration("SCAN_TIMEOUT")
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		clamd, err := NewClamd(address, timeout)
+		if err != nil {
+			return Policy{}, err
+		}
+		policy.Scanner = clamd
+		return policy, nil
+	default:
+		return Policy{}, fmt.Errorf("unknown scanner %q", kind)
+	}
+}
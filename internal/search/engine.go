@@ -0,0 +1,182 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/pipeline"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// StageName is the name of the pipeline stage indexing the documents.
+const StageName = "search"
+
+// contentTypes are the content types of the documents whose text can be extracted.
+var contentTypes = []string{"text/plain", "application/pdf", "application/zip"}
+
+// Config configures the Engine.
+type Config struct {
+	// MaxDocumentSize is the size, in bytes, from which documents aren't indexed, as their content is read
+	// into memory to extract their text.
+	MaxDocumentSize int64
+}
+
+// ConfigFromEnvironment reads the search configuration from the environment variable SEARCH_MAX_DOCUMENT_SIZE,
+// which defaults to 32MB.
+func ConfigFromEnvironment() Config {
+	config := Config{MaxDocumentSize: environment.GetInt64("SEARCH_MAX_DOCUMENT_SIZE")}
+	if config.MaxDocumentSize <= 0 {
+		config.MaxDocumentSize = 32 << 20
+	}
+	return config
+}
+
+// Engine searches the documents by their content.
+// Documents are indexed by a pipeline stage, so the index is updated off the request path every time they are
+// uploaded, replaced or restored, and the Engine implements events.EventPublisher to take them out of the index
+// once they are deleted. The index is kept in memory, so Reindex rebuilds it at startup.
+type Engine struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	storage storageservice.StorageService
+	config  Config
+	index   *Index
+}
+
+// NewEngine creates a new Engine with an empty index, reading the content of the documents to index from the
+// given storage service, and checking with the path service that the documents found are still there.
+func NewEngine(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	storage storageservice.StorageService,
+	config Config,
+) *Engine {
+	return &Engine{logger, pathsrv, storage, config, NewIndex()}
+}
+
+// Stage returns the pipeline stage indexing the text of plain text, PDF and Office Open XML documents.
+func (e *Engine) Stage() pipeline.Stage {
+	return pipeline.NewStage(StageName, contentTypes, e.indexDocument)
+}
+
+// Publish removes the document of the event from the index when it's deleted, purged or expires, and from
+// under its previous ID when it's moved to another one, as it's indexed again under the new ID.
+// Other events are ignored.
+func (e *Engine) Publish(ctx context.Context, event events.Event) error {
+	switch event.Type {
+	case events.DocumentDeleted, events.DocumentPurged, events.DocumentExpired:
+		e.index.Remove(event.Tenant, event.DocumentID)
+	case events.DocumentMoved:
+		if event.PreviousID != event.DocumentID {
+			e.index.Remove(event.Tenant, event.PreviousID)
+		}
+	}
+	return nil
+}
+
+// Search returns up to limit documents of the tenant found in the context matching the query, the most relevant
+// first. It returns an ErrInvalidInput error if the query has no words to search for.
+func (e *Engine) Search(ctx context.Context, query string, limit int) ([]Hit, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms(query)) == 0 {
+		return nil, fmt.Errorf("%w: query %q has no words to search for", errs.ErrInvalidInput, query)
+	}
+	return e.index.Search(tenant, query, limit, func(id int64) bool {
+		// A document may be indexed while it's being deleted, so it's only found if it's still live.
+		record, err := e.pathsrv.GetPath(ctx, id)
+		return err == nil && !record.Trashed() && !record.Expired(time.Now())
+	}), nil
+}
+
+// Reindex indexes the live documents of every tenant whose text can be extracted, as the index is empty after
+// a restart while their pipeline jobs aren't run again. It's meant to run once at startup, alongside the
+// pipeline: documents already indexed are skipped, so what the pipeline indexes meanwhile, which may be newer
+// than what Reindex reads, isn't overwritten. Errors are logged, and don't stop the other documents from being
+// indexed.
+func (e *Engine) Reindex(ctx context.Context) {
+	entries, err := e.pathsrv.All(ctx)
+	if err != nil {
+		return
+	}
+	stage := e.Stage()
+	now := time.Now()
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		record := entry.Record
+		if record.Trashed() || record.Expired(now) || !stage.Accepts(record.ContentType) {
+			continue
+		}
+		tenantCtx := tenancy.WithTenant(ctx, entry.Tenant)
+		if err := e.indexText(tenantCtx, entry.Tenant, entry.ID, false); err != nil {
+			e.logger.Error(tenantCtx, "Failed to reindex document %d: %s", entry.ID, err.Error())
+		}
+	}
+}
+
+// indexDocument indexes the text of the document. Documents that are gone, too large or whose text can't be
+// extracted are taken out of the index instead, as what's indexed for them is outdated.
+func (e *Engine) indexDocument(ctx context.Context, document pipeline.Document) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return e.indexText(ctx, tenant, document.ID, true)
+}
+
+// indexText indexes the text of the document of the given tenant and ID, replacing what was indexed for it if
+// replace is set. Documents that are gone, too large or whose text can't be extracted are taken out of the
+// index instead, unless replace isn't set, as what's indexed for them may be newer.
+func (e *Engine) indexText(ctx context.Context, tenant string, id int64, replace bool) error {
+	file, err := e.storage.Get(ctx, id)
+	if errors.Is(err, errs.ErrNotFound) {
+		e.skip(tenant, id, replace)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, e.config.MaxDocumentSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) > e.config.MaxDocumentSize {
+		e.logger.Info(ctx, "Document %d is too large to be indexed", id)
+		e.skip(tenant, id, replace)
+		return nil
+	}
+	text, err := Extract(content, file.ContentType)
+	if err != nil {
+		e.logger.Info(ctx, "Text of document %d can't be extracted: %s", id, err.Error())
+		e.skip(tenant, id, replace)
+		return nil
+	}
+	if replace {
+		e.index.Add(tenant, id, strings.TrimSpace(text))
+	} else {
+		e.index.AddIfAbsent(tenant, id, strings.TrimSpace(text))
+	}
+	return nil
+}
+
+// skip takes the document of the given tenant and ID out of the index if replace is set.
+func (e *Engine) skip(tenant string, id int64, replace bool) {
+	if replace {
+		e.index.Remove(tenant, id)
+	}
+}
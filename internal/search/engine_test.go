@@ -0,0 +1,154 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/pipeline"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// upload uploads a file with the given ID, name and content, and runs the indexing stage on it.
+func upload(
+	t *testing.T,
+	ctx context.Context,
+	service storageservice.StorageService,
+	stage pipeline.Stage,
+	id int64,
+	name string,
+	content []byte,
+) {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(src, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	data := storageservice.UploadData{File: file, Filename: name, Id: id, Size: int64(len(content))}
+	if err := service.Upload(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := service.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.Close()
+	if !stage.Accepts(stored.ContentType) {
+		return
+	}
+	if err := stage.Process(ctx, pipeline.Document{ID: id, ContentType: stored.ContentType}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEngine(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	service := storageservice.New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, paths),
+		compression.None,
+		scanning.Policy{},
+	)
+	engine := NewEngine(logger, paths, service, Config{MaxDocumentSize: 1 << 20})
+	stage := engine.Stage()
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	docx, err := os.ReadFile("../../files/EUC_EntrenarModelo.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload(t, ctx, service, stage, 1, "EUC_EntrenarModelo.docx", docx)
+	upload(t, ctx, service, stage, 2, "notes.txt", []byte("Notes on training the recommendation model."))
+	upload(t, ctx, service, stage, 3, "image.png", []byte("\x89PNG\r\n\x1a\nrecomendacion"))
+
+	hits, err := engine.Search(ctx, "recomendación", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].ID != 1 || hits[0].Snippet == "" {
+		t.Errorf("Expected the Word document to be found, got %+v", hits)
+	}
+	if _, err := engine.Search(ctx, " the ", 10); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected an invalid input error for a query without words, got %v", err)
+	}
+
+	// A deleted document is left out even before its deletion is published.
+	if err := service.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := engine.Search(ctx, "recommendation", 10); len(hits) != 0 {
+		t.Errorf("Expected the deleted document to be left out, got %+v", hits)
+	}
+	service.Restore(ctx, 2)
+	if hits, _ := engine.Search(ctx, "recommendation", 10); len(hits) != 1 {
+		t.Errorf("Expected the restored document to be found, got %+v", hits)
+	}
+	service.Delete(ctx, 2)
+	deleted, _ := events.New(ctx, events.DocumentDeleted, 2)
+	engine.Publish(ctx, deleted)
+	service.Restore(ctx, 2)
+	if hits, _ := engine.Search(ctx, "recommendation", 10); len(hits) != 0 {
+		t.Errorf("Expected the deleted document to be out of the index until it's indexed again, got %+v", hits)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	service := storageservice.New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, paths),
+		compression.None,
+		scanning.Policy{},
+	)
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	stage := NewEngine(logger, paths, service, Config{MaxDocumentSize: 1 << 20}).Stage()
+	upload(t, ctx, service, stage, 1, "notes.txt", []byte("Notes on the invoices."))
+	upload(t, ctx, service, stage, 2, "draft.txt", []byte("Draft of the invoices."))
+	upload(t, ctx, service, stage, 3, "old.txt", []byte("Old invoices."))
+	service.Delete(ctx, 3)
+
+	// The engine of a restarted service, whose index the pipeline already updated for document 2.
+	engine := NewEngine(logger, paths, service, Config{MaxDocumentSize: 1 << 20})
+	engine.index.Add("acme", 2, "Signed contract.")
+	engine.Reindex(context.Background())
+
+	if hits, _ := engine.Search(ctx, "invoices", 10); len(hits) != 1 || hits[0].ID != 1 {
+		t.Errorf("Expected only the live document not indexed yet to be reindexed, got %+v", hits)
+	}
+	if hits, _ := engine.Search(ctx, "contract", 10); len(hits) != 1 || hits[0].ID != 2 {
+		t.Errorf("Expected what the pipeline indexed to be kept, got %+v", hits)
+	}
+	service.Restore(ctx, 3)
+	if hits, _ := engine.Search(ctx, "old", 10); len(hits) != 0 {
+		t.Errorf("Expected the document in the trash not to be reindexed, got %+v", hits)
+	}
+}
@@ -0,0 +1,198 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ErrUnsupported is returned when extracting the text of content that isn't plain text, a PDF or an
+// Office Open XML document.
+var ErrUnsupported = errors.New("unsupported content type")
+
+const (
+	// maxTextSize caps the text extracted from a document. The rest of the document isn't indexed.
+	maxTextSize = 4 << 20
+	// maxPartsSize caps the decompressed size of the parts read from an Office Open XML document,
+	// so a zip bomb can't exhaust the memory. Like maxTextSize, the rest of the document isn't indexed.
+	maxPartsSize = 64 << 20
+)
+
+// officeParts are the patterns of the parts of Office Open XML documents holding their text, in the order
+// their text is extracted: the body, headers, footers, notes and comments of Word documents, the slides and
+// notes of PowerPoint presentations and the strings of Excel workbooks.
+var officeParts = []string{
+	"word/document.xml",
+	"word/header*.xml",
+	"word/footer*.xml",
+	"word/footnotes.xml",
+	"word/endnotes.xml",
+	"word/comments.xml",
+	"ppt/slides/slide*.xml",
+	"ppt/notesSlides/notesSlide*.xml",
+	"xl/sharedStrings.xml",
+}
+
+// errTextFull stops extracting text once maxTextSize is reached.
+var errTextFull = errors.New("extracted text is full")
+
+// Extract returns the text of the given content, of the given MIME type, as sniffed by fileutils.DetermineMIME.
+// It supports plain text, PDF documents and Office Open XML documents (DOCX, PPTX and XLSX), which are
+// sniffed as ZIP archives, returning an ErrUnsupported error for anything else. Only the first 4MB of text
+// are extracted.
+func Extract(content []byte, contentType string) (string, error) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(mediaType) {
+	case "text/plain":
+		return strings.ToValidUTF8(string(content[:min(len(content), maxTextSize)]), " "), nil
+	case "application/pdf":
+		return extractPDF(content)
+	case "application/zip":
+		return extractOffice(content)
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupported, contentType)
+}
+
+// extractPDF returns the text of a PDF document. Malformed documents make the PDF reader panic,
+// which is turned into an error.
+func extractPDF(content []byte) (text string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("malformed PDF document: %v", recovered)
+		}
+	}()
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("malformed PDF document: %w", err)
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("malformed PDF document: %w", err)
+	}
+	extracted, err := io.ReadAll(io.LimitReader(plain, maxTextSize))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(extracted), " "), nil
+}
+
+// extractOffice returns the text of an Office Open XML document, or an ErrUnsupported error if the content
+// is a ZIP archive but not such a document.
+func extractOffice(content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("malformed ZIP archive: %w", err)
+	}
+	type part struct {
+		file    *zip.File
+		pattern int
+	}
+	var parts []part
+	for _, file := range archive.File {
+		for i, pattern := range officeParts {
+			if matched, _ := path.Match(pattern, file.Name); matched {
+				parts = append(parts, part{file, i})
+				break
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%w: ZIP archive isn't an Office Open XML document", ErrUnsupported)
+	}
+	// Numbered parts are sorted by number, so slide10.xml comes after slide9.xml.
+	sort.Slice(parts, func(i, j int) bool {
+		a, b := parts[i], parts[j]
+		if a.pattern != b.pattern {
+			return a.pattern < b.pattern
+		}
+		if len(a.file.Name) != len(b.file.Name) {
+			return len(a.file.Name) < len(b.file.Name)
+		}
+		return a.file.Name < b.file.Name
+	})
+
+	text := &textBuilder{}
+	budget := int64(maxPartsSize)
+	for _, part := range parts {
+		reader, err := part.file.Open()
+		if err != nil {
+			return "", fmt.Errorf("malformed ZIP archive: %w", err)
+		}
+		limited := &io.LimitedReader{R: reader, N: budget}
+		err = extractXML(limited, text)
+		reader.Close()
+		budget = limited.N
+		if errors.Is(err, errTextFull) || budget <= 0 {
+			// The part is cut short once the budget runs out, so it's expected to end malformed.
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("malformed part %s: %w", part.file.Name, err)
+		}
+	}
+	return text.String(), nil
+}
+
+// extractXML writes the text of an Office Open XML part to the builder: the character data of its text
+// elements, with paragraphs and line breaks as new lines, and tabs as tabs.
+func extractXML(reader io.Reader, text *textBuilder) error {
+	decoder := xml.NewDecoder(reader)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "t":
+				inText = true
+			case "br", "cr":
+				err = text.write("\n")
+			case "tab":
+				err = text.write("\t")
+			}
+		case xml.EndElement:
+			switch token.Name.Local {
+			case "t":
+				inText = false
+			case "p", "si":
+				err = text.write("\n")
+			}
+		case xml.CharData:
+			if inText {
+				err = text.write(string(token))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// textBuilder builds the extracted text, up to maxTextSize.
+type textBuilder struct {
+	strings.Builder
+}
+
+// write appends s to the text, returning errTextFull once the text is full.
+func (t *textBuilder) write(s string) error {
+	if remaining := maxTextSize - t.Len(); len(s) > remaining {
+		t.WriteString(strings.ToValidUTF8(s[:remaining], ""))
+		return errTextFull
+	}
+	t.WriteString(s)
+	return nil
+}
@@ -0,0 +1,157 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExtractWordDocument(t *testing.T) {
+	content, err := os.ReadFile("../../files/EUC_EntrenarModelo.docx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := Extract(content, "application/zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Index(text, "Se entrena el modelo de recomendación de mentores")
+	header := strings.Index(text, "Ingeniería de Requisitos y Modelado")
+	if body < 0 || header < 0 {
+		t.Fatalf("Expected the text of the body and the header, got %q", text)
+	}
+	if body > header {
+		t.Errorf("Expected the body to come before the header")
+	}
+	if strings.Contains(text, "<w:") {
+		t.Errorf("Expected no markup in the text")
+	}
+}
+
+// officeDocument returns a ZIP archive with the given files.
+func officeDocument(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range files {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// slide returns a presentation slide with the given paragraphs.
+func slide(paragraphs ...string) string {
+	var body strings.Builder
+	for _, paragraph := range paragraphs {
+		fmt.Fprintf(&body, "<a:p><a:r><a:t>%s</a:t></a:r></a:p>", paragraph)
+	}
+	return `<p:sld xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+		`xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main">` + body.String() + `</p:sld>`
+}
+
+func TestExtractOfficeDocuments(t *testing.T) {
+	presentation := officeDocument(t, map[string]string{
+		"[Content_Types].xml":    "<Types/>",
+		"ppt/slides/slide10.xml": slide("Tenth"),
+		"ppt/slides/slide2.xml":  slide("Second", "slide"),
+		"ppt/slides/slide1.xml":  slide("First &amp; foremost"),
+		"ppt/media/image1.xml":   slide("Ignored"),
+	})
+	text, err := Extract(presentation, "application/zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "First & foremost\nSecond\nslide\nTenth\n"; text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+
+	workbook := officeDocument(t, map[string]string{
+		"xl/sharedStrings.xml": `<sst><si><t>Revenue</t></si><si><r><t>Q3 </t></r><r><t>forecast</t></r></si></sst>`,
+	})
+	if text, _ := Extract(workbook, "application/zip"); text != "Revenue\nQ3 forecast\n" {
+		t.Errorf("Unexpected text of the workbook: %q", text)
+	}
+
+	archive := officeDocument(t, map[string]string{"notes.txt": "not an office document"})
+	if _, err := Extract(archive, "application/zip"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected an unsupported error for a plain ZIP archive, got %v", err)
+	}
+}
+
+func TestExtractLargeOfficeDocument(t *testing.T) {
+	// The filler takes the whole budget, so the part is cut short before it's closed.
+	filler := strings.Repeat(" ", maxPartsSize)
+	body := "<w:document><w:body><w:p><w:t>Indexed</w:t></w:p>" + filler + "</w:body></w:document>"
+	document := officeDocument(t, map[string]string{
+		"word/document.xml": body,
+		"word/footer1.xml":  "<w:ftr><w:p><w:t>Skipped</w:t></w:p></w:ftr>",
+	})
+	text, err := Extract(document, "application/zip")
+	if err != nil {
+		t.Fatalf("Expected the text read before the budget ran out, got %v", err)
+	}
+	if text != "Indexed\n" {
+		t.Errorf("Expected only the text read before the budget ran out, got %q", text)
+	}
+}
+
+// pdfDocument returns a single page PDF document showing the given text.
+func pdfDocument(text string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R " +
+			"/Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+	var document bytes.Buffer
+	document.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = document.Len()
+		fmt.Fprintf(&document, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := document.Len()
+	fmt.Fprintf(&document, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&document, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&document, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return document.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	text, err := Extract(pdfDocument("Quarterly invoice"), "application/pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Quarterly invoice") {
+		t.Errorf("Expected the text of the page, got %q", text)
+	}
+	if _, err := Extract([]byte("%PDF-1.4\ngarbage"), "application/pdf"); err == nil {
+		t.Errorf("Expected an error for a malformed PDF document")
+	}
+}
+
+func TestExtractPlainText(t *testing.T) {
+	text, err := Extract([]byte("plain \xff text"), "text/plain; charset=utf-8")
+	if err != nil || text != "plain   text" {
+		t.Errorf("Expected the text with invalid UTF-8 replaced, got %q, %v", text, err)
+	}
+	if _, err := Extract([]byte{0x89, 'P', 'N', 'G'}, "image/png"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected an unsupported error for an image, got %v", err)
+	}
+}
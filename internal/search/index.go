@@ -0,0 +1,213 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BM25 parameters: k1 saturates the weight of repeated terms, and b normalizes it by the document length.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// snippetLength is the approximate length, in bytes, of the snippets of the hits.
+const snippetLength = 160
+
+// Hit is a document matching a search.
+type Hit struct {
+	ID      int64   `json:"id"`      // ID is the identifier of the document.
+	Score   float64 `json:"score"`   // Score is the relevance of the document, higher for better matches.
+	Snippet string  `json:"snippet"` // Snippet is the excerpt of the document around the first match.
+}
+
+// Index is an in-memory inverted index of the text of the documents of every tenant, ranking the documents
+// matching a search with BM25. It's safe for concurrent use.
+type Index struct {
+	mu      sync.RWMutex
+	tenants map[string]*tenantIndex
+}
+
+// tenantIndex is the inverted index of the documents of a tenant.
+type tenantIndex struct {
+	documents   map[int64]*document
+	postings    map[string]map[int64]int // postings map every term to the frequency of the term in each document.
+	totalLength int                      // totalLength is the sum of the lengths of the documents.
+}
+
+// document is an indexed document.
+type document struct {
+	text   string
+	length int            // length is the number of terms of the document.
+	first  map[string]int // first maps every term of the document to the offset its first occurrence starts at.
+}
+
+// NewIndex creates an empty Index.
+func NewIndex() *Index {
+	return &Index{tenants: make(map[string]*tenantIndex)}
+}
+
+// Add indexes the text of the document of the given tenant and ID, replacing what was indexed for it.
+func (i *Index) Add(tenant string, id int64, text string) {
+	i.add(tenant, id, text, true)
+}
+
+// AddIfAbsent indexes the text of the document of the given tenant and ID unless it's already indexed, and
+// reports whether it was indexed.
+func (i *Index) AddIfAbsent(tenant string, id int64, text string) bool {
+	return i.add(tenant, id, text, false)
+}
+
+// add indexes the text of the document of the given tenant and ID, replacing what was indexed for it only if
+// replace is set, and reports whether it was indexed.
+func (i *Index) add(tenant string, id int64, text string, replace bool) bool {
+	doc := &document{text: text, first: make(map[string]int)}
+	frequencies := make(map[string]int)
+	for _, token := range tokenize(text) {
+		if _, found := doc.first[token.term]; !found {
+			doc.first[token.term] = token.start
+		}
+		frequencies[token.term]++
+		doc.length++
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	index := i.tenants[tenant]
+	if index == nil {
+		index = &tenantIndex{documents: make(map[int64]*document), postings: make(map[string]map[int64]int)}
+		i.tenants[tenant] = index
+	}
+	if _, indexed := index.documents[id]; indexed && !replace {
+		return false
+	}
+	index.remove(id)
+	index.documents[id] = doc
+	index.totalLength += doc.length
+	for term, frequency := range frequencies {
+		if index.postings[term] == nil {
+			index.postings[term] = make(map[int64]int)
+		}
+		index.postings[term][id] = frequency
+	}
+	return true
+}
+
+// Remove removes the document of the given tenant and ID from the index, if it's indexed.
+func (i *Index) Remove(tenant string, id int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if index := i.tenants[tenant]; index != nil {
+		index.remove(id)
+	}
+}
+
+// Search returns up to limit documents of the tenant matching any term of the query, the most relevant first,
+// leaving out those keep rejects. Documents scoring the same are sorted by ID.
+func (i *Index) Search(tenant string, query string, limit int, keep func(id int64) bool) []Hit {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	index := i.tenants[tenant]
+	if index == nil || len(index.documents) == 0 {
+		return []Hit{}
+	}
+	queryTerms := terms(query)
+	averageLength := float64(index.totalLength) / float64(len(index.documents))
+	scores := make(map[int64]float64)
+	for _, term := range queryTerms {
+		postings := index.postings[term]
+		n := float64(len(postings))
+		idf := math.Log(1 + (float64(len(index.documents))-n+0.5)/(n+0.5))
+		for id, frequency := range postings {
+			tf := float64(frequency)
+			length := float64(index.documents[id].length)
+			scores[id] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*length/averageLength))
+		}
+	}
+
+	ranked := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		ranked = append(ranked, Hit{ID: id, Score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	hits := []Hit{}
+	for _, hit := range ranked {
+		if len(hits) == limit {
+			break
+		}
+		if keep(hit.ID) {
+			hit.Snippet = index.documents[hit.ID].snippet(queryTerms)
+			hits = append(hits, hit)
+		}
+	}
+	return hits
+}
+
+// remove removes the document of the given ID from the index.
+func (t *tenantIndex) remove(id int64) {
+	doc, found := t.documents[id]
+	if !found {
+		return
+	}
+	for term := range doc.first {
+		delete(t.postings[term], id)
+		if len(t.postings[term]) == 0 {
+			delete(t.postings, term)
+		}
+	}
+	t.totalLength -= doc.length
+	delete(t.documents, id)
+}
+
+// snippet returns the excerpt of the document around the earliest occurrence of any of the terms, with its
+// whitespace collapsed, and ellipses where the text is cut.
+func (d *document) snippet(terms []string) string {
+	match := -1
+	for _, term := range terms {
+		if offset, found := d.first[term]; found && (match < 0 || offset < match) {
+			match = offset
+		}
+	}
+	if match < 0 {
+		return ""
+	}
+	start := max(0, match-snippetLength/3)
+	end := min(len(d.text), start+snippetLength)
+	// The snippet is widened to whole words, unless they are too long.
+	for i := 0; start > 0 && i < 20 && !atBoundary(d.text, start); i++ {
+		start--
+	}
+	for i := 0; end < len(d.text) && i < 20 && !atBoundary(d.text, end); i++ {
+		end++
+	}
+	for start < end && !utf8.RuneStart(d.text[start]) {
+		start++
+	}
+	for end < len(d.text) && !utf8.RuneStart(d.text[end]) {
+		end++
+	}
+
+	snippet := strings.Join(strings.Fields(d.text[start:end]), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(d.text) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// atBoundary reports whether offset i of the text is a space or a punctuation mark, where words end.
+func atBoundary(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"agreed":         "agre",
+		"hoping":         "hope",
+		"hopping":        "hop",
+		"happy":          "happi",
+		"relational":     "relat",
+		"connections":    "connect",
+		"connected":      "connect",
+		"generalization": "gener",
+		"controll":       "control",
+		"invoices":       "invoic",
+		"invoice":        "invoic",
+		"cu400":          "cu400",
+		"is":             "is",
+	}
+	for word, expected := range tests {
+		if got := stem(word); got != expected {
+			t.Errorf("stem(%q) = %q, expected %q", word, got, expected)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	text := "The CREACIÓN of invoices, at 08/12/2003."
+	var got []string
+	for _, token := range tokenize(text) {
+		got = append(got, token.term+"="+text[token.start:token.end])
+	}
+	expected := "creacion=CREACIÓN invoic=invoices 08=08 12=12 2003=2003"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("Expected tokens %q, got %q", expected, strings.Join(got, " "))
+	}
+}
+
+// keepAll keeps every document found.
+func keepAll(int64) bool {
+	return true
+}
+
+func TestSearch(t *testing.T) {
+	index := NewIndex()
+	index.Add("acme", 1, "Invoice for the consulting services of March.")
+	index.Add("acme", 2, "Connected invoices: every invoice of the quarter, one invoice per client.")
+	index.Add("acme", 3, "Meeting notes about the new office.")
+	index.Add("globex", 4, "Invoice of another tenant.")
+
+	hits := index.Search("acme", "INVOICES", 10, keepAll)
+	if len(hits) != 2 || hits[0].ID != 2 || hits[1].ID != 1 {
+		t.Fatalf("Expected the documents mentioning invoices the most first, got %+v", hits)
+	}
+	if hits[0].Score <= hits[1].Score {
+		t.Errorf("Expected decreasing scores, got %+v", hits)
+	}
+	if hits := index.Search("acme", "connection notes", 10, keepAll); len(hits) != 2 {
+		t.Errorf("Expected the documents matching any of the stemmed terms, got %+v", hits)
+	}
+	if hits := index.Search("acme", "invoice", 1, keepAll); len(hits) != 1 || hits[0].ID != 2 {
+		t.Errorf("Expected the best hit only, got %+v", hits)
+	}
+	if hits := index.Search("acme", "invoice", 10, func(id int64) bool { return id != 2 }); len(hits) != 1 {
+		t.Errorf("Expected the rejected document to be left out, got %+v", hits)
+	}
+	if hits := index.Search("acme", "tenant", 10, keepAll); len(hits) != 0 {
+		t.Errorf("Expected no document of another tenant, got %+v", hits)
+	}
+
+	index.Add("acme", 2, "Nothing to see here.")
+	index.Remove("acme", 1)
+	if hits := index.Search("acme", "invoice", 10, keepAll); len(hits) != 0 {
+		t.Errorf("Expected the replaced and removed documents to be out of the index, got %+v", hits)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	index := NewIndex()
+	text := strings.Repeat("lorem ipsum dolor ", 20) + "the signed\n\n  contract " + strings.Repeat("sit amet ", 30)
+	index.Add("acme", 1, text)
+	hits := index.Search("acme", "contracts", 10, keepAll)
+	if len(hits) != 1 {
+		t.Fatalf("Expected a hit, got %+v", hits)
+	}
+	snippet := hits[0].Snippet
+	if !strings.HasPrefix(snippet, "…dolor") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("Expected a snippet cut at both ends, got %q", snippet)
+	}
+	if !strings.Contains(snippet, "the signed contract sit amet") {
+		t.Errorf("Expected the snippet around the match with its whitespace collapsed, got %q", snippet)
+	}
+	if len(snippet) > snippetLength+50 {
+		t.Errorf("Expected a short snippet, got %d bytes", len(snippet))
+	}
+}
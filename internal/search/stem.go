@@ -0,0 +1,207 @@
+package search
+
+import "bytes"
+
+// stem reduces an English word to its stem with the Porter stemming algorithm, so the different forms of a
+// word, e.g. "connect", "connected" and "connections", have the same term. Words that aren't made of lower
+// case ASCII letters only are returned as is.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	s := &stemmer{[]byte(word)}
+	s.step1()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return string(s.word)
+}
+
+// stemmer holds a word while it's stemmed.
+type stemmer struct {
+	word []byte
+}
+
+// step2Suffixes are the suffixes replaced in the second step of the algorithm.
+var step2Suffixes = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"}, {"izer", "ize"},
+	{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"},
+	{"ation", "ate"}, {"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}, {"logi", "log"},
+}
+
+// step3Suffixes are the suffixes replaced in the third step of the algorithm.
+var step3Suffixes = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"}, {"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+// step4Suffixes are the suffixes removed in the fourth step of the algorithm.
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent", "ion", "ou", "ism", "ate",
+	"iti", "ous", "ive", "ize",
+}
+
+// step1 removes plurals and the -ed and -ing suffixes, and turns a final y into an i.
+func (s *stemmer) step1() {
+	switch {
+	case s.ends("sses"), s.ends("ies"):
+		s.word = s.word[:len(s.word)-2]
+	case s.ends("ss"):
+	case s.ends("s"):
+		s.word = s.word[:len(s.word)-1]
+	}
+
+	switch {
+	case s.ends("eed"):
+		if s.measure(len(s.word)-3) > 0 {
+			s.word = s.word[:len(s.word)-1]
+		}
+	case s.ends("ed") && s.hasVowel(len(s.word)-2):
+		s.word = s.word[:len(s.word)-2]
+		s.restoreE()
+	case s.ends("ing") && s.hasVowel(len(s.word)-3):
+		s.word = s.word[:len(s.word)-3]
+		s.restoreE()
+	}
+
+	if s.ends("y") && s.hasVowel(len(s.word)-1) {
+		s.word[len(s.word)-1] = 'i'
+	}
+}
+
+// restoreE tidies up the word once the -ed or -ing suffix is removed, so e.g. "hoping" becomes "hope" and
+// "hopping" becomes "hop".
+func (s *stemmer) restoreE() {
+	end := len(s.word)
+	switch {
+	case s.ends("at"), s.ends("bl"), s.ends("iz"):
+		s.word = append(s.word, 'e')
+	case s.doubleConsonant(end) && !s.ends("l") && !s.ends("s") && !s.ends("z"):
+		s.word = s.word[:end-1]
+	case s.measure(end) == 1 && s.cvc(end):
+		s.word = append(s.word, 'e')
+	}
+}
+
+// step2 maps double suffixes to single ones.
+func (s *stemmer) step2() {
+	s.replaceFirst(step2Suffixes, 0)
+}
+
+// step3 deals with -ic-, -full, -ness and the like.
+func (s *stemmer) step3() {
+	s.replaceFirst(step3Suffixes, 0)
+}
+
+// step4 removes the suffixes of words with a long enough stem.
+func (s *stemmer) step4() {
+	for _, suffix := range step4Suffixes {
+		if !s.ends(suffix) {
+			continue
+		}
+		stem := len(s.word) - len(suffix)
+		if suffix == "ion" && (stem == 0 || (s.word[stem-1] != 's' && s.word[stem-1] != 't')) {
+			return
+		}
+		if s.measure(stem) > 1 {
+			s.word = s.word[:stem]
+		}
+		return
+	}
+}
+
+// step5 removes a final e and turns a final double l into a single one, in words with a long enough stem.
+func (s *stemmer) step5() {
+	if s.ends("e") {
+		stem := len(s.word) - 1
+		if m := s.measure(stem); m > 1 || (m == 1 && !s.cvc(stem)) {
+			s.word = s.word[:stem]
+		}
+	}
+	if s.ends("ll") && s.measure(len(s.word)) > 1 {
+		s.word = s.word[:len(s.word)-1]
+	}
+}
+
+// replaceFirst replaces the first of the suffixes the word ends with, if what precedes it has a measure
+// greater than minMeasure.
+func (s *stemmer) replaceFirst(suffixes [][2]string, minMeasure int) {
+	for _, suffix := range suffixes {
+		if !s.ends(suffix[0]) {
+			continue
+		}
+		stem := len(s.word) - len(suffix[0])
+		if s.measure(stem) > minMeasure {
+			s.word = append(s.word[:stem], suffix[1]...)
+		}
+		return
+	}
+}
+
+// ends reports whether the word ends with the suffix.
+func (s *stemmer) ends(suffix string) bool {
+	return bytes.HasSuffix(s.word, []byte(suffix))
+}
+
+// consonant reports whether the letter at i is a consonant. A y is a consonant unless it follows one.
+func (s *stemmer) consonant(i int) bool {
+	switch s.word[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.consonant(i-1)
+	}
+	return true
+}
+
+// measure returns the number of vowel-consonant sequences in word[:end].
+func (s *stemmer) measure(end int) int {
+	n, i := 0, 0
+	for i < end && s.consonant(i) {
+		i++
+	}
+	for i < end {
+		for i < end && !s.consonant(i) {
+			i++
+		}
+		if i == end {
+			break
+		}
+		for i < end && s.consonant(i) {
+			i++
+		}
+		n++
+	}
+	return n
+}
+
+// hasVowel reports whether word[:end] has a vowel.
+func (s *stemmer) hasVowel(end int) bool {
+	for i := 0; i < end; i++ {
+		if !s.consonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleConsonant reports whether word[:end] ends with a double consonant.
+func (s *stemmer) doubleConsonant(end int) bool {
+	return end >= 2 && s.word[end-1] == s.word[end-2] && s.consonant(end-1)
+}
+
+// cvc reports whether word[:end] ends with a consonant, a vowel and a consonant other than w, x or y,
+// as in "hop", which is then restored to "hope" rather than left as is.
+func (s *stemmer) cvc(end int) bool {
+	if end < 3 || !s.consonant(end-3) || s.consonant(end-2) || !s.consonant(end-1) {
+		return false
+	}
+	last := s.word[end-1]
+	return last != 'w' && last != 'x' && last != 'y'
+}
@@ -0,0 +1,96 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxTermLength is the length, in bytes, from which words aren't indexed, as they are rarely searched for
+// and are usually encoded data rather than words.
+const maxTermLength = 64
+
+// token is a term found in a text, with the byte offsets of the word it comes from.
+type token struct {
+	term       string
+	start, end int
+}
+
+// stopWords are the English words too common to be worth indexing.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "if": true, "in": true, "into": true, "is": true, "it": true, "no": true,
+	"not": true, "of": true, "on": true, "or": true, "such": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true, "to": true, "was": true,
+	"will": true, "with": true,
+}
+
+// foldings are the ASCII replacements of the letters with diacritics, so words are found whether they are
+// written with accents or not.
+var foldings = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae", 'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'ÿ': "y", 'ß': "ss",
+}
+
+// tokenize splits the text into words, runs of letters and digits, and turns them into terms: lower cased,
+// without diacritics and stemmed. Stop words and overly long words are left out.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = appendToken(tokens, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = appendToken(tokens, text, start, len(text))
+	}
+	return tokens
+}
+
+// appendToken appends the token of the word at text[start:end], unless it isn't worth indexing.
+func appendToken(tokens []token, text string, start, end int) []token {
+	if end-start > maxTermLength {
+		return tokens
+	}
+	term := normalize(text[start:end])
+	if stopWords[term] {
+		return tokens
+	}
+	return append(tokens, token{stem(term), start, end})
+}
+
+// normalize lower cases the word and replaces its letters with diacritics by their ASCII counterparts.
+func normalize(word string) string {
+	var normalized strings.Builder
+	normalized.Grow(len(word))
+	for _, r := range word {
+		r = unicode.ToLower(r)
+		if folded, found := foldings[r]; found {
+			normalized.WriteString(folded)
+		} else if r != utf8.RuneError {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// terms returns the distinct terms of the query, in order.
+func terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, token := range tokenize(query) {
+		if !seen[token.term] {
+			seen[token.term] = true
+			terms = append(terms, token.term)
+		}
+	}
+	return terms
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/lucastomic/dmsStorageService/internal/controller"
@@ -18,41 +18,43 @@ import (
 // for server operation, including routing, logging, and middleware management.
 type Server struct {
 	listenAddr  string                  // The address on which the server listens for incoming requests.
-	controller  controller.Controller   // Controller manages routing of requests to their respective handlers.
+	controllers []controller.Controller // Controllers manage routing of requests to their respective handlers.
 	apiLogger   logging.Logger          // apiLogger is used for logging general API requests information.
 	logicLogger logging.Logger          // logicLogger is specialized for logging business logic related events.
 	middlewares []middleware.Middleware // middlewares is a slice of Middleware interfaces to be applied to all requests.
 }
 
 // New creates a new instance of the Server struct, initializing it with the provided parameters
-// such as listen address, controller, API and logic loggers, and middlewares.
+// such as listen address, controllers, API and logic loggers, and middlewares.
 func New(
 	listenAddr string,
-	controller controller.Controller,
+	controllers []controller.Controller,
 	apilogger logging.Logger,
 	logicLogger logging.Logger,
 	middlewares []middleware.Middleware,
 ) Server {
 	return Server{
 		listenAddr,
-		controller,
+		controllers,
 		apilogger,
 		logicLogger,
 		middlewares,
 	}
 }
 
-// Run initializes the server's routes based on the controller's router, applies middlewares,
+// Run initializes the server's routes based on the controllers' routers, applies middlewares,
 // starts listening on the specified address, and logs the server's start or any errors encountered.
 func (s *Server) Run() {
 	r := mux.NewRouter()
-	for _, route := range s.controller.Router() {
-		handlerWithMiddlewares := middleware.ChainMiddleware(
-			s.makeHTTPHandlerFunc(route.Handler),
-			s.handleError,
-			s.middlewares...,
-		)
-		r.Handle(route.Path, handlerWithMiddlewares).Methods(route.Method)
+	for _, controller := range s.controllers {
+		for _, route := range controller.Router() {
+			handlerWithMiddlewares := middleware.ChainMiddleware(
+				s.makeHTTPHandlerFunc(route.Handler),
+				s.handleError,
+				s.middlewares...,
+			)
+			r.Handle(route.Path, handlerWithMiddlewares).Methods(route.Method)
+		}
 	}
 	r.NotFoundHandler = middleware.ChainMiddleware(
 		s.notFoundHandler,
@@ -94,10 +96,22 @@ func (s *Server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
 
 // writeResponse prepares and sends an HTTP response based on the provided apitypes.Response struct.
 // It sets custom headers, writes the status code, and sends the response content, which can vary in type.
-// For *os.File types, the file's content is streamed to the response. For other types, the content is
-// JSON-encoded and written to the response. Errors during JSON encoding are logged.
+// For io.Reader types, such as files, the content is streamed to the response. Successful responses whose
+// content can be seeked are served with http.ServeContent, which takes care of range requests.
+// For other types, the content is JSON-encoded and written to the response. Errors during JSON encoding are logged.
+// Nothing is written if the handler already wrote the response.
 func (s *Server) writeResponse(req *http.Request, w http.ResponseWriter, res apitypes.Response) {
+	if res.Written {
+		return
+	}
 	setCustomHeaders(w, res.Headers)
+	if content, ok := res.Content.(io.ReadSeeker); ok && res.Status == http.StatusOK {
+		if closer, ok := content.(io.Closer); ok {
+			defer closer.Close()
+		}
+		http.ServeContent(w, req, "", time.Time{}, content)
+		return
+	}
 	w.WriteHeader(res.Status)
 	if err := writeContent(w, res.Content); err != nil {
 		s.logicLogger.Error(req.Context(), "Failed to write response: %v", err)
@@ -105,12 +119,15 @@ func (s *Server) writeResponse(req *http.Request, w http.ResponseWriter, res api
 }
 
 // writeContent writes the content to the response writer based on the content type.
-// It handles *os.File by streaming the file's content and other types by JSON-encoding them.
+// It handles io.Reader by streaming its content, closing it afterwards if it's an io.Closer,
+// and other types by JSON-encoding them.
 // Returns an error if it encounters an issue during the write operation.
 func writeContent(w http.ResponseWriter, content interface{}) error {
 	switch c := content.(type) {
-	case *os.File:
-		defer c.Close()
+	case io.Reader:
+		if closer, ok := c.(io.Closer); ok {
+			defer closer.Close()
+		}
 		_, err := io.Copy(w, c)
 		return err
 	default:
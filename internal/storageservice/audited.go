@@ -0,0 +1,133 @@
+package storageservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/audit"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+)
+
+// NewAudited wraps a StorageService so every operation on a document, successful or not, is recorded in the
+// audit log. Listings aren't recorded. Failing to record an operation doesn't fail it, but it's logged.
+func NewAudited(service StorageService, log audit.Log, logger logging.Logger) StorageService {
+	return &auditedService{service, log, logger}
+}
+
+// auditedService implements the StorageService interface, recording the operations of the wrapped one.
+type auditedService struct {
+	StorageService
+	log    audit.Log
+	logger logging.Logger
+}
+
+// Upload uploads the file and records the upload.
+func (s *auditedService) Upload(ctx context.Context, data UploadData) error {
+	err := s.StorageService.Upload(ctx, data)
+	s.record(ctx, audit.ActionUpload, data.Id, err)
+	return err
+}
+
+// UploadBatch uploads the files and records the upload of each one.
+func (s *auditedService) UploadBatch(ctx context.Context, files []UploadData, atomic bool) []error {
+	results := s.StorageService.UploadBatch(ctx, files, atomic)
+	for i, data := range files {
+		s.record(ctx, audit.ActionUpload, data.Id, results[i])
+	}
+	return results
+}
+
+// Get opens the file and records the download.
+func (s *auditedService) Get(ctx context.Context, id int64) (File, error) {
+	file, err := s.StorageService.Get(ctx, id)
+	s.record(ctx, audit.ActionDownload, id, err)
+	return file, err
+}
+
+// GetVersion opens the version of the file and records the download.
+func (s *auditedService) GetVersion(ctx context.Context, id int64, version int) (File, error) {
+	file, err := s.StorageService.GetVersion(ctx, id, version)
+	s.record(ctx, audit.ActionDownload, id, err)
+	return file, err
+}
+
+// Replace replaces the file and records the replacement.
+func (s *auditedService) Replace(ctx context.Context, data UploadData) error {
+	err := s.StorageService.Replace(ctx, data)
+	s.record(ctx, audit.ActionReplace, data.Id, err)
+	return err
+}
+
+// Delete moves the file to the trash and records the deletion.
+func (s *auditedService) Delete(ctx context.Context, id int64) error {
+	err := s.StorageService.Delete(ctx, id)
+	s.record(ctx, audit.ActionDelete, id, err)
+	return err
+}
+
+// CheckOut checks the file out and records it.
+func (s *auditedService) CheckOut(ctx context.Context, id int64, ttl time.Duration) (FileInfo, error) {
+	info, err := s.StorageService.CheckOut(ctx, id, ttl)
+	s.record(ctx, audit.ActionCheckOut, id, err)
+	return info, err
+}
+
+// CheckIn checks the file in and records it.
+func (s *auditedService) CheckIn(ctx context.Context, data UploadData) error {
+	err := s.StorageService.CheckIn(ctx, data)
+	s.record(ctx, audit.ActionCheckIn, data.Id, err)
+	return err
+}
+
+// ReleaseLock releases or breaks the lock of the file and records it.
+func (s *auditedService) ReleaseLock(ctx context.Context, id int64, breakLock bool) error {
+	err := s.StorageService.ReleaseLock(ctx, id, breakLock)
+	action := audit.ActionReleaseLock
+	if breakLock {
+		action = audit.ActionBreakLock
+	}
+	s.record(ctx, action, id, err)
+	return err
+}
+
+// Copy copies the file and records the copy against the ID of the original.
+func (s *auditedService) Copy(ctx context.Context, id int64, newID int64, name string) (FileInfo, error) {
+	info, err := s.StorageService.Copy(ctx, id, newID, name)
+	s.record(ctx, audit.ActionCopy, id, err)
+	return info, err
+}
+
+// Move moves the file and records the move against its previous ID.
+func (s *auditedService) Move(ctx context.Context, id int64, newID int64, name string) (FileInfo, error) {
+	info, err := s.StorageService.Move(ctx, id, newID, name)
+	s.record(ctx, audit.ActionMove, id, err)
+	return info, err
+}
+
+// Restore restores the file from the trash and records the restoration.
+func (s *auditedService) Restore(ctx context.Context, id int64) error {
+	err := s.StorageService.Restore(ctx, id)
+	s.record(ctx, audit.ActionRestore, id, err)
+	return err
+}
+
+// Purge purges the file and records the purge.
+func (s *auditedService) Purge(ctx context.Context, id int64) error {
+	err := s.StorageService.Purge(ctx, id)
+	s.record(ctx, audit.ActionPurge, id, err)
+	return err
+}
+
+// Expire removes the expired file and records its removal.
+func (s *auditedService) Expire(ctx context.Context, id int64) error {
+	err := s.StorageService.Expire(ctx, id)
+	s.record(ctx, audit.ActionExpire, id, err)
+	return err
+}
+
+// record records an operation in the audit log, logging any failure.
+func (s *auditedService) record(ctx context.Context, action string, id int64, err error) {
+	if auditErr := s.log.Record(ctx, action, id, err); auditErr != nil {
+		s.logger.Error(ctx, "Failed to audit %s of file %d: %s", action, id, auditErr.Error())
+	}
+}
@@ -0,0 +1,58 @@
+package storageservice
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+// Filter selects files from a listing. Its zero fields match every file.
+type Filter struct {
+	// ContentType is the MIME type of the files, either exact, e.g. "application/pdf", or a whole type,
+	// e.g. "image/*". Parameters such as the charset are ignored.
+	ContentType string `json:"contentType,omitempty"`
+	Owner       string `json:"owner,omitempty"` // Owner is the user who uploaded the files.
+	Name        string `json:"name,omitempty"`  // Name is a shell pattern the names of the files match, e.g. "*.pdf".
+}
+
+// Validate checks the filter is well-formed, returning an ErrInvalidInput error otherwise.
+func (f Filter) Validate() error {
+	if _, err := path.Match(f.Name, ""); err != nil {
+		return fmt.Errorf("%w: malformed name pattern %q", errs.ErrInvalidInput, f.Name)
+	}
+	return nil
+}
+
+// Matches reports whether the file matches every field of the filter.
+func (f Filter) Matches(file FileInfo) bool {
+	if f.Owner != "" && file.Owner != f.Owner {
+		return false
+	}
+	if f.Name != "" {
+		if matched, _ := path.Match(f.Name, file.Name); !matched {
+			return false
+		}
+	}
+	if f.ContentType == "" {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(file.ContentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if prefix, found := strings.CutSuffix(f.ContentType, "/*"); found {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return strings.EqualFold(mediaType, f.ContentType)
+}
+
+// Apply returns the files matching the filter, in order.
+func (f Filter) Apply(files []FileInfo) []FileInfo {
+	matching := []FileInfo{}
+	for _, file := range files {
+		if f.Matches(file) {
+			matching = append(matching, file)
+		}
+	}
+	return matching
+}
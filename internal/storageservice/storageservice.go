@@ -2,17 +2,44 @@ package storageservice
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
+	"maps"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/checksum"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
 	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/fileutils"
 	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
 	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
 )
 
+// infectedDir is the directory, inside the storage root of every tenant, where infected uploads are quarantined.
+const infectedDir = ".infected"
+
+// MaxLockTTL is the longest a file can be checked out for at once.
+const MaxLockTTL = 7 * 24 * time.Hour
+
+// ErrBatchAborted is the error of the files of an atomic batch that weren't uploaded because another one failed.
+var ErrBatchAborted = errors.New("upload aborted, as another file of the batch failed")
+
 // StorageService defines the interface for storage operations, including uploading and retrieving files.
 // It abstracts the underlying storage mechanism, allowing for different implementations.
 type StorageService interface {
@@ -20,106 +47,1183 @@ type StorageService interface {
 	// It returns an error if the upload fails due to validation issues or storage errors.
 	Upload(context.Context, UploadData) error
 
+	// UploadBatch uploads several files, returning the error of each one, in order, nil if it was uploaded.
+	// If the batch is atomic, either every file is uploaded or none is.
+	UploadBatch(ctx context.Context, files []UploadData, atomic bool) []error
+
 	// Get retrieves the file identified by the specified identifier.
 	// It returns an error if the retrieval fails or if the file does not exist.
-	Get(context.Context, int64) (*os.File, error)
+	Get(context.Context, int64) (File, error)
+
+	// Replace replaces the content of the file identified by the ID of the provided UploadData with a new
+	// version, keeping the previous one. It returns an error if the file does not exist, is retained, is checked
+	// out by another user or the upload fails.
+	Replace(context.Context, UploadData) error
+
+	// Versions returns the versions of the content of the file identified by the specified identifier, oldest
+	// first, the current one being the last. It returns an error if the file does not exist.
+	Versions(ctx context.Context, id int64) ([]VersionInfo, error)
+
+	// GetVersion retrieves the given version of the content of the file identified by the specified identifier,
+	// current or previous. It returns an error if the retrieval fails or if the file or the version don't exist.
+	GetVersion(ctx context.Context, id int64, version int) (File, error)
+
+	// Delete moves the file identified by the specified identifier to the trash.
+	// It returns an error if the file does not exist, is already in the trash, is checked out by another user
+	// or can't be deleted.
+	Delete(context.Context, int64) error
+
+	// CheckOut locks the file identified by the specified identifier for the user found in the context, for the
+	// given time, so no one else can replace nor delete it. Checking out a file again extends its lock.
+	// It returns an error if the file does not exist or is checked out by another user.
+	CheckOut(ctx context.Context, id int64, ttl time.Duration) (FileInfo, error)
+
+	// CheckIn replaces the content of the file identified by the ID of the provided UploadData, which must be
+	// checked out by the user found in the context, with a new version, and releases its lock.
+	CheckIn(context.Context, UploadData) error
+
+	// ReleaseLock releases the lock of the file identified by the specified identifier without changing it.
+	// Unless it's broken, the file must be checked out by the user found in the context.
+	ReleaseLock(ctx context.Context, id int64, breakLock bool) error
+
+	// Copy copies the file identified by the specified identifier to a new file identified by newID, named
+	// name, or after the original and the new ID if it's empty. The copy belongs to the user found in the
+	// context. It returns an error if the file does not exist, or newID is already in use.
+	Copy(ctx context.Context, id int64, newID int64, name string) (FileInfo, error)
+
+	// Move moves the file identified by the specified identifier to newID, renaming it to name unless it's
+	// empty. Either both change or neither does. It returns an error if the file does not exist, is retained
+	// or checked out by another user, or newID is already in use.
+	Move(ctx context.Context, id int64, newID int64, name string) (FileInfo, error)
+
+	// UpdateRetention changes the retention or the legal hold of the file identified by the specified identifier
+	// with the given function, serialized with every other change of the file, whether it's in the trash or not.
+	// It returns an error if the file does not exist or the function fails.
+	UpdateRetention(ctx context.Context, id int64, change func(record *pathrepository.PathRecord) error) error
+
+	// UpdateLabels changes the tags, the metadata or the document type of the file identified by the specified
+	// identifier with the given function, serialized with every other change of the file, and returns the file.
+	// It returns an error if the file does not exist, is retained or checked out by another user, or the
+	// function fails.
+	UpdateLabels(
+		ctx context.Context,
+		id int64,
+		change func(record *pathrepository.PathRecord) error,
+	) (FileInfo, error)
+
+	// List returns the files that aren't in the trash.
+	List(context.Context) ([]FileInfo, error)
+
+	// Trash returns the files in the trash.
+	Trash(context.Context) ([]FileInfo, error)
+
+	// Restore takes the file identified by the specified identifier out of the trash.
+	// It returns an error if the file is not in the trash.
+	Restore(context.Context, int64) error
+
+	// Purge permanently removes the file identified by the specified identifier, which must be in the trash.
+	// It returns an error if the file is not in the trash or can't be removed.
+	Purge(context.Context, int64) error
+
+	// Expire permanently removes the file identified by the specified identifier, which must have expired.
+	// It returns an error if the file hasn't expired, is retained or can't be removed.
+	Expire(context.Context, int64) error
 }
 
-// New initializes a new instance of a StorageService with the provided logger and path service.
-// This constructor function returns a storageService that uses the given pathService for path management
-// and the logger for logging errors and information.
-func New(logger logging.Logger, pathservice pathservice.PathService) StorageService {
-	return &storageService{logger, pathservice}
+// New initializes a new instance of a StorageService with the provided logger, path service, quota tracker,
+// blob store, blob guard, retention checker, compression codec and scanning policy.
+// This constructor function returns a storageService that uses the given pathService for path management,
+// the quota tracker for enforcing storage quotas, the blob store for storing the files' content, compressed
+// with the given codec when their content type benefits from it, the guard for keeping the garbage collector
+// away from in-flight uploads, the retention checker for keeping retained files from being deleted or replaced,
+// the scanning policy for keeping malware out of the storage, and the logger for logging errors and information.
+func New(
+	logger logging.Logger,
+	pathservice pathservice.PathService,
+	quota quota.Tracker,
+	blobs blobstore.BlobStore,
+	guard *blobstore.Guard,
+	retention retention.Checker,
+	codec compression.Codec,
+	scan scanning.Policy,
+) StorageService {
+	return &storageService{
+		logger:  logger,
+		pathsrv: pathservice,
+		quota:   quota,
+		blobs:   blobs,
+		guard:   guard,
+		retain:  retention,
+		codec:   codec,
+		scan:    scan,
+	}
 }
 
 // storageService implements the StorageService interface, providing methods for file upload and retrieval.
-// It utilizes a path service for managing file paths and a logger for error logging.
+// It utilizes a path service for managing file paths, a quota tracker for keeping track of the
+// storage used by every tenant and user, a blob store for the files' content and a logger for error logging.
 type storageService struct {
 	logger  logging.Logger          // Logger for logging operations and errors.
 	pathsrv pathservice.PathService // Path service for managing file paths.
+	quota   quota.Tracker           // Quota tracker for enforcing storage quotas.
+	blobs   blobstore.BlobStore     // Blob store for storing the files' content.
+	guard   *blobstore.Guard        // Guard marking uploads as in progress until their path is saved.
+	retain  retention.Checker       // Retention checker for blocking the deletion of retained files.
+	codec   compression.Codec       // Codec for compressing the files' content. compression.None disables it.
+	scan    scanning.Policy         // Policy for scanning uploads for malware.
+	mu      sync.Mutex              // mu guards the read-modify-write of records against the changes of locks.
 }
 
 // Upload handles the storage of given UploadData.
-// It checks if the path already exists to prevent duplicates, stores the file, and then saves the path.
-// Errors during these operations are logged and may result in a rollback of the file storage.
+// It checks if the path already exists to prevent duplicates, reserves the file's size in the quota of the
+// tenant and the uploading user, stores the file, and then saves the path along with the file's checksums.
+// If the quota would be exceeded it returns an ErrQuotaExceeded error without storing anything.
+// If the content doesn't match the digests supplied by the client it returns an ErrInvalidInput error, and if
+// it's infected an ErrInfected error, as the file is scanned before anything is stored. If it can't be scanned
+// and the scan policy fails closed it returns an ErrUnavailable error.
+// From storing the file until saving its path the upload is guarded, so the garbage collector doesn't take
+// the file for an unreferenced one.
+// If the path can't be saved the stored file is removed and its size released from the quota. The path is
+// only created if the ID is still free, so concurrent uploads with the same ID don't override each other.
+// The document.created event is written to the outbox along with the path, so it's never published for an
+// upload that was rolled back.
 func (s *storageService) Upload(
 	ctx context.Context,
 	data UploadData,
 ) error {
+	defer s.guard.BeginWrite()()
+	paths, err := s.prepare(ctx, nil, data)
+	if err != nil {
+		return err
+	}
+	if err := s.pathsrv.CreatePathsWithEvents(ctx, paths); err != nil {
+		s.logger.Error(ctx, "Failed to save path: %s", err.Error())
+		s.rollback(ctx, paths)
+		return err
+	}
+	return nil
+}
+
+// UploadBatch uploads the files in order, returning the error of each one, nil if it was uploaded.
+// Files with the ID of a previous file of the batch are rejected with an ErrInvalidInput error, as they would
+// take its place, while names can be repeated, as in archives with several folders. Unless the batch is atomic,
+// every other file is uploaded like in Upload, regardless of the others. If it's atomic, the files are stored
+// first, each at a path of its own, and their paths are then saved all at once, so either every file is uploaded
+// or none is: the first failure removes the files stored by the batch so far, and nothing else, and fails the
+// remaining ones with an ErrBatchAborted error.
+func (s *storageService) UploadBatch(ctx context.Context, files []UploadData, atomic bool) []error {
+	results := checkBatch(files)
+	if !atomic {
+		for i, data := range files {
+			if results[i] == nil {
+				results[i] = s.Upload(ctx, data)
+			}
+		}
+		return results
+	}
+
+	defer s.guard.BeginWrite()()
+	paths := make([]pathrepository.NewPath, 0, len(files))
+	for i, data := range files {
+		if results[i] == nil {
+			paths, results[i] = s.prepare(ctx, paths, data)
+		}
+		if results[i] != nil {
+			s.rollback(ctx, paths)
+			return abortBatch(results)
+		}
+	}
+	if err := s.pathsrv.CreatePathsWithEvents(ctx, paths); err != nil {
+		s.rollback(ctx, paths)
+		for i := range results {
+			results[i] = err
+		}
+	}
+	return results
+}
+
+// prepare stores the file of an upload or of an atomic batch, appending its path, not saved yet, to paths.
+func (s *storageService) prepare(
+	ctx context.Context,
+	paths []pathrepository.NewPath,
+	data UploadData,
+) ([]pathrepository.NewPath, error) {
+	record, err := s.create(ctx, data)
+	if err != nil {
+		return paths, err
+	}
+	event, err := events.New(ctx, events.DocumentCreated, data.Id)
+	if err != nil {
+		s.rollback(ctx, []pathrepository.NewPath{{ID: data.Id, Record: record}})
+		return paths, err
+	}
+	return append(paths, pathrepository.NewPath{ID: data.Id, Record: record, Event: event}), nil
+}
+
+// rollback removes the files of the paths that couldn't be saved, releasing their size from the quota. Their
+// blobs were stored at paths of their own, so no other file is touched.
+func (s *storageService) rollback(ctx context.Context, paths []pathrepository.NewPath) {
+	for _, path := range paths {
+		s.removeBlob(ctx, path.Record.Path)
+		if err := s.quota.Release(ctx, path.Record.Owner, path.Record.Size); err != nil {
+			s.logger.Error(ctx, "Failed to release quota of file %d: %s", path.ID, err.Error())
+		}
+	}
+}
+
+// create stores a new file, checking its ID isn't in use, scanning it and reserving its size in the quota of
+// the tenant and the uploading user. It returns the record of the file, whose path is left for the caller to
+// save, releasing the quota if it can't. It must be called with the guard held.
+func (s *storageService) create(ctx context.Context, data UploadData) (pathrepository.PathRecord, error) {
 	alreadyExists, err := s.pathsrv.Exists(ctx, data.Id)
 	if err != nil {
 		s.logger.Error(ctx, "Failed to check if ID exists: %s", err.Error())
-		return err
+		return pathrepository.PathRecord{}, err
 	}
 	if alreadyExists {
-		return fmt.Errorf("path with id %d already exists: %w", data.Id, errs.ErrInvalidInput)
+		err := fmt.Errorf("path with id %d already exists: %w", data.Id, errs.ErrInvalidInput)
+		return pathrepository.PathRecord{}, err
+	}
+	if err := s.scanFile(ctx, data); err != nil {
+		return pathrepository.PathRecord{}, err
+	}
+	owner := tenancy.UserFromContext(ctx)
+	if err := s.quota.Reserve(ctx, owner, data.Size); err != nil {
+		return pathrepository.PathRecord{}, err
+	}
+	path, err := blobPath(ctx, data.Id)
+	if err != nil {
+		s.quota.Release(ctx, owner, data.Size)
+		return pathrepository.PathRecord{}, err
 	}
-	dest, err := s.storeFile(ctx, data)
+	record, err := s.storeFile(ctx, data, path)
 	if err != nil {
+		s.quota.Release(ctx, owner, data.Size)
+		if errors.Is(err, errs.ErrInvalidInput) {
+			return pathrepository.PathRecord{}, err
+		}
 		s.logger.Error(ctx, "Failed to store file: %s", err.Error())
+		return pathrepository.PathRecord{}, fmt.Errorf("error storing the file: %w", errs.ErrinternalError)
+	}
+	record.Name = filepath.Base(data.Filename)
+	record.Owner = owner
+	record.Size = data.Size
+	record.CreatedAt = time.Now()
+	record.ExpiresAt = data.ExpiresAt
+	record.Version = 1
+	record.Type = data.Type
+	record.Metadata = maps.Clone(data.Metadata)
+	return record, nil
+}
+
+// checkBatch returns, for every file of a batch, an ErrInvalidInput error if a previous file has the same ID,
+// and nil otherwise.
+func checkBatch(files []UploadData) []error {
+	results := make([]error, len(files))
+	ids := make(map[int64]bool, len(files))
+	for i, data := range files {
+		if ids[data.Id] {
+			results[i] = fmt.Errorf("%w: id %d is repeated in the batch", errs.ErrInvalidInput, data.Id)
+		}
+		ids[data.Id] = true
+	}
+	return results
+}
+
+// abortBatch fails with an ErrBatchAborted error every file of an atomic batch that didn't fail itself.
+func abortBatch(results []error) []error {
+	for i, err := range results {
+		if err == nil {
+			results[i] = ErrBatchAborted
+		}
+	}
+	return results
+}
+
+// Replace replaces the content of the file associated with the ID of the given UploadData, which must not be
+// in the trash, retained nor checked out by another user, with a new version. The new content is stored at a
+// path of its own and its size reserved in the quota of the file's owner, while the previous version is kept
+// in the file's history, retrievable with GetVersion, taking space until the file is removed. The owner, upload
+// time, retention, legal hold, lock, tags, metadata and document type of the file are kept.
+// If the id doesn't exist or its file is in the trash it returns an ErrNotFound error, if it's retained an
+// ErrRetained error, if it's checked out by another user an ErrLocked error, if the new content is infected an
+// ErrInfected error, and if it can't be scanned an ErrUnavailable error, like Upload.
+func (s *storageService) Replace(ctx context.Context, data UploadData) error {
+	return s.replace(ctx, data, false)
+}
+
+// CheckIn replaces the content of the file like Replace, releasing its lock along with it. The file must be
+// checked out by the user found in the context: if it isn't checked out it returns an ErrConflict error, and
+// if it's checked out by another user an ErrLocked error.
+func (s *storageService) CheckIn(ctx context.Context, data UploadData) error {
+	return s.replace(ctx, data, true)
+}
+
+// replace replaces the content of the file, as described in Replace, releasing its lock if it's checked in.
+// The file is checked before storing the new content, so rejected replacements don't store anything, and again
+// under mu once it's stored, in case it was deleted, retained or locked meanwhile. The current content is only
+// moved to the history then, along with saving the new record.
+func (s *storageService) replace(ctx context.Context, data UploadData, checkIn bool) error {
+	old, err := s.live(ctx, data.Id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkReplaceable(ctx, data.Id, old, checkIn); err != nil {
+		return err
+	}
+	if err := s.scanFile(ctx, data); err != nil {
+		return err
+	}
+	owner := old.Owner
+	if err := s.quota.Reserve(ctx, owner, data.Size); err != nil {
+		return err
+	}
+	defer s.guard.BeginWrite()()
+	path, err := blobPath(ctx, data.Id)
+	if err != nil {
+		s.quota.Release(ctx, owner, data.Size)
+		return err
+	}
+	stored, err := s.storeFile(ctx, data, path)
+	if err != nil {
+		s.quota.Release(ctx, owner, data.Size)
+		if errors.Is(err, errs.ErrInvalidInput) {
+			return err
+		}
+		s.logger.Error(ctx, "Failed to store replacement of file %d: %s", data.Id, err.Error())
 		return fmt.Errorf("error storing the file: %w", errs.ErrinternalError)
 	}
-	err = s.pathsrv.SavePath(ctx, data.Id, dest)
+	s.mu.Lock()
+	err = s.commitReplace(ctx, data, stored, checkIn)
+	s.mu.Unlock()
 	if err != nil {
-		// TODO: Rollback file storage
-		s.logger.Error(ctx, "Failed to save path: %s", err.Error())
+		s.removeBlob(ctx, path)
+		s.quota.Release(ctx, owner, data.Size)
 		return err
 	}
 	return nil
 }
 
+// commitReplace checks the file again and saves its record with the stored content of the replacement, moving
+// the current one to its history. It must be called with mu held.
+func (s *storageService) commitReplace(
+	ctx context.Context,
+	data UploadData,
+	stored pathrepository.PathRecord,
+	checkIn bool,
+) error {
+	record, err := s.live(ctx, data.Id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkReplaceable(ctx, data.Id, record, checkIn); err != nil {
+		return err
+	}
+	record.History = append(slices.Clip(record.History), pathrepository.VersionRecord{
+		Version:     record.Version,
+		Path:        record.Path,
+		Name:        record.Name,
+		Size:        record.Size,
+		StoredSize:  record.StoredSize,
+		ContentType: record.ContentType,
+		Compression: record.Compression,
+		SHA256:      record.SHA256,
+		CRC32C:      record.CRC32C,
+		ReplacedAt:  time.Now(),
+	})
+	record.Path = stored.Path
+	record.Name = filepath.Base(data.Filename)
+	record.Size = data.Size
+	record.StoredSize = stored.StoredSize
+	record.ContentType = stored.ContentType
+	record.Compression = stored.Compression
+	record.SHA256, record.CRC32C = stored.SHA256, stored.CRC32C
+	record.Version++
+	if checkIn {
+		record.LockedBy, record.LockedUntil = "", time.Time{}
+	}
+	return s.saveWithEvent(ctx, data.Id, record, events.DocumentReplaced)
+}
+
+// checkReplaceable returns an ErrRetained error if the file of the given ID and path record is retained, and
+// the error of checkLock if it's checked out by another user, or if it isn't checked out when checking it in.
+func (s *storageService) checkReplaceable(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+	checkIn bool,
+) error {
+	if err := s.retain.Check(ctx, id, record); err != nil {
+		return err
+	}
+	return s.checkLock(ctx, id, record, checkIn)
+}
+
 // Get retrieves the file associated with the given ID from the storage.
 // It first fetches the file path using the path service. If the path cannot be retrieved
 // or if the file does not exist at the retrieved path, it logs an error and returns an error.
-// If the id deosn't exist it returns an ErrNotFound error.
-// On successfully locating the file, it opens the file for reading and returns the file handle.
-func (s *storageService) Get(ctx context.Context, id int64) (*os.File, error) {
-	filePath, err := s.pathsrv.GetPath(ctx, id)
+// If the id deosn't exist or its file is in the trash it returns an ErrNotFound error.
+// On successfully locating the file, it opens the file for reading and returns it. The content
+// of the returned file is decrypted and decompressed transparently if it was stored encrypted or compressed,
+// and its checksum is verified if it's read entirely.
+func (s *storageService) Get(ctx context.Context, id int64) (File, error) {
+	record, err := s.live(ctx, id)
+	if err != nil {
+		return File{}, err
+	}
+	return s.open(ctx, id, record)
+}
+
+// Versions returns the versions of the content of the file associated with the given ID, which must not be in
+// the trash, oldest first, the current one being the last.
+// If the id doesn't exist or its file is in the trash it returns an ErrNotFound error.
+func (s *storageService) Versions(ctx context.Context, id int64) ([]VersionInfo, error) {
+	record, err := s.live(ctx, id)
 	if err != nil {
-		return &os.File{}, err
+		return nil, err
 	}
+	versions := make([]VersionInfo, 0, len(record.History)+1)
+	for _, version := range record.History {
+		versions = append(versions, newVersionInfo(version))
+	}
+	return append(versions, VersionInfo{
+		Version:     record.Version,
+		Name:        record.Name,
+		ContentType: record.ContentType,
+		Size:        record.Size,
+	}), nil
+}
 
-	if checkIfDoesntExist(filePath) {
-		s.logger.Error(ctx, "File with ID %d not found in path %s", id, filePath)
-		return &os.File{}, fmt.Errorf(
+// GetVersion retrieves the given version of the content of the file associated with the given ID, which must not
+// be in the trash, like Get does. If the id doesn't exist, its file is in the trash or it has no such version it
+// returns an ErrNotFound error.
+func (s *storageService) GetVersion(ctx context.Context, id int64, version int) (File, error) {
+	record, err := s.live(ctx, id)
+	if err != nil {
+		return File{}, err
+	}
+	if version == record.Version {
+		return s.open(ctx, id, record)
+	}
+	for _, previous := range record.History {
+		if previous.Version == version {
+			return s.open(ctx, id, atVersion(record, previous))
+		}
+	}
+	return File{}, fmt.Errorf("%w: file with ID %d has no version %d", errs.ErrNotFound, id, version)
+}
+
+// open opens the content described by the record of the file of the given ID.
+func (s *storageService) open(ctx context.Context, id int64, record pathrepository.PathRecord) (File, error) {
+	blob, err := s.blobs.Open(record.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.logger.Error(ctx, "File with ID %d not found in path %s", id, record.Path)
+		return File{}, fmt.Errorf(
 			"file with ID %d can't be reached at its path",
 			id,
 		)
 	}
-	file, err := os.Open(filePath)
 	if err != nil {
 		s.logger.Error(ctx, "Failed to open file %d: %s", id, err.Error())
-		return &os.File{}, fmt.Errorf(
+		return File{}, fmt.Errorf(
 			"failed to open file with ID %d: %w",
 			id,
 			errs.ErrinternalError,
 		)
 	}
 
+	file := File{
+		Blob:        decodeContent(blob, record),
+		Name:        record.Name,
+		ContentType: record.ContentType,
+		Encoding:    record.Compression,
+		Checksum:    checksum.Sum{SHA256: record.SHA256, CRC32C: record.CRC32C},
+		encoded:     blob,
+	}
+	if record.SHA256 != "" {
+		file.Blob = verifiedBlob{
+			checksum.NewVerifyingReader(file.Blob, record.SHA256, record.Size),
+			file.Blob,
+			func(err error) { s.logger.Error(ctx, "File with ID %d is corrupted: %s", id, err.Error()) },
+		}
+	}
 	return file, nil
 }
 
-// storeFile is a helper method for storing a file on the filesystem.
-// It generates the full path for the file, creates it, and copies the content from UploadData.
-// Returns the path of the stored file or an error if the operation fails.
-func (s storageService) storeFile(ctx context.Context, data UploadData) (string, error) {
-	root := environment.GetProjectRoot()
-	dirPath := filepath.Join(root, "files")
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
+// atVersion returns the record of the file with its content replaced by the one of the given previous version.
+func atVersion(record pathrepository.PathRecord, version pathrepository.VersionRecord) pathrepository.PathRecord {
+	record.Path = version.Path
+	record.Name = version.Name
+	record.Size = version.Size
+	record.StoredSize = version.StoredSize
+	record.ContentType = version.ContentType
+	record.Compression = version.Compression
+	record.SHA256, record.CRC32C = version.SHA256, version.CRC32C
+	record.Version = version.Version
+	return record
+}
+
+// Delete moves the file associated with the given ID to the trash, recording when and by whom it was deleted.
+// The file is hidden from Get and List, but it keeps taking space, and counting towards the quota, until it's
+// purged. If the id doesn't exist or its file is already in the trash it returns an ErrNotFound error, if
+// it's retained an ErrRetained error, and if it's checked out by another user an ErrLocked error.
+func (s *storageService) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.live(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.retain.Check(ctx, id, record); err != nil {
+		return err
+	}
+	if err := s.checkLock(ctx, id, record, false); err != nil {
+		return err
 	}
-	dst, err := os.Create(filepath.Join(dirPath, data.Filename))
+	record.DeletedAt = time.Now()
+	record.DeletedBy = tenancy.UserFromContext(ctx)
+	return s.saveWithEvent(ctx, id, record, events.DocumentDeleted)
+}
+
+// CheckOut locks the file associated with the given ID for the user found in the context until the given time
+// from now, which can't be longer than MaxLockTTL. The file must not be in the trash, and requests must be
+// attributed to a user to check it out. If the id doesn't exist or its file is in the trash it returns an
+// ErrNotFound error, and if it's checked out by another user an ErrLocked error.
+func (s *storageService) CheckOut(ctx context.Context, id int64, ttl time.Duration) (FileInfo, error) {
+	user := tenancy.UserFromContext(ctx)
+	switch {
+	case user == "":
+		return FileInfo{}, fmt.Errorf("%w: files can only be checked out by a user", errs.ErrInvalidInput)
+	case ttl <= 0 || ttl > MaxLockTTL:
+		return FileInfo{}, fmt.Errorf("%w: files can be checked out for up to %s", errs.ErrInvalidInput, MaxLockTTL)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.live(ctx, id)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return FileInfo{}, err
+	}
+	if err := s.checkLock(ctx, id, record, false); err != nil {
+		return FileInfo{}, err
 	}
-	defer dst.Close()
-	if _, err := io.Copy(dst, data.File); err != nil {
-		return "", fmt.Errorf("failed to write to file: %w", err)
+	record.LockedBy = user
+	record.LockedUntil = time.Now().Add(ttl)
+	if err := s.pathsrv.SavePath(ctx, id, record); err != nil {
+		return FileInfo{}, err
 	}
-	return dst.Name(), nil
+	return newFileInfo(id, record), nil
+}
+
+// ReleaseLock releases the lock of the file associated with the given ID, which must be checked out by the user
+// found in the context unless the lock is broken. It returns an ErrConflict error if the file isn't checked
+// out, and an ErrLocked error if it's checked out by another user and the lock isn't broken.
+func (s *storageService) ReleaseLock(ctx context.Context, id int64, breakLock bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.live(ctx, id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !breakLock:
+		err = s.checkLock(ctx, id, record, true)
+	case !record.Locked(time.Now()):
+		err = fmt.Errorf("%w: file with ID %d is not checked out", errs.ErrConflict, id)
+	default:
+		s.logger.Info(ctx, "Lock of file %d held by %q broken by %q", id, record.LockedBy, tenancy.UserFromContext(ctx))
+	}
+	if err != nil {
+		return err
+	}
+	record.LockedBy = ""
+	record.LockedUntil = time.Time{}
+	return s.pathsrv.SavePath(ctx, id, record)
+}
+
+// Copy copies the file of the given ID, which must not be in the trash, to a new file of the ID newID, reserving
+// its size in the quota of the tenant and the user found in the context, who owns the copy. The storage isn't
+// content-addressed, so the stored content is copied as is, without decompressing it, to a path of the copy's
+// own. The copy keeps the content type, checksums, tags, metadata and document type of the file, but not its
+// retention, legal hold, lock, expiry nor previous versions. The document.created event is written to the outbox
+// along with its path. The file is read and copied under mu, like in Move, so it isn't changed meanwhile.
+// If the id doesn't exist it returns an ErrNotFound error, and if newID is in use an ErrInvalidInput error.
+func (s *storageService) Copy(ctx context.Context, id int64, newID int64, name string) (FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	source, err := s.live(ctx, id)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if name == "" {
+		name = copyName(source.Name, newID)
+	}
+	if err := validateName(name); err != nil {
+		return FileInfo{}, err
+	}
+	if err := s.checkFreeID(ctx, newID); err != nil {
+		return FileInfo{}, err
+	}
+	path, err := blobPath(ctx, newID)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	owner := tenancy.UserFromContext(ctx)
+	if err := s.quota.Reserve(ctx, owner, source.Size); err != nil {
+		return FileInfo{}, err
+	}
+	defer s.guard.BeginWrite()()
+	record := pathrepository.PathRecord{
+		Path:        path,
+		Name:        name,
+		Owner:       owner,
+		Size:        source.Size,
+		StoredSize:  source.StoredSize,
+		ContentType: source.ContentType,
+		Compression: source.Compression,
+		SHA256:      source.SHA256,
+		CRC32C:      source.CRC32C,
+		CreatedAt:   time.Now(),
+		Version:     1,
+		Tags:        source.Tags,
+		Metadata:    source.Metadata,
+		Type:        source.Type,
+	}
+	if err := s.copyBlob(ctx, source.Path, path); err != nil {
+		s.quota.Release(ctx, owner, source.Size)
+		return FileInfo{}, err
+	}
+	paths := []pathrepository.NewPath{{ID: newID, Record: record}}
+	paths[0].Event, err = events.New(ctx, events.DocumentCreated, newID)
+	if err == nil {
+		err = s.pathsrv.CreatePathsWithEvents(ctx, paths)
+	}
+	if err != nil {
+		s.rollback(ctx, paths)
+		return FileInfo{}, err
+	}
+	return newFileInfo(newID, record), nil
+}
+
+// Move moves the file of the given ID, which must not be in the trash, retained nor checked out by another user,
+// to newID, renaming it if a new name is given. Only its record is moved, in a single transaction along with the
+// document.moved event, so the file is never found under both IDs nor under neither: its content stays at the
+// path it was stored at, which no other file can take. Retained files aren't moved, as the history of their
+// legal hold and the audit log refer to their ID.
+// If the id doesn't exist it returns an ErrNotFound error, if it's retained an ErrRetained error, if it's
+// checked out by another user an ErrLocked error, and if newID is in use or nothing would change an
+// ErrInvalidInput error.
+func (s *storageService) Move(ctx context.Context, id int64, newID int64, name string) (FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.live(ctx, id)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if err := s.retain.Check(ctx, id, record); err != nil {
+		return FileInfo{}, err
+	}
+	if err := s.checkLock(ctx, id, record, false); err != nil {
+		return FileInfo{}, err
+	}
+	if name == "" {
+		name = record.Name
+	}
+	if err := validateName(name); err != nil {
+		return FileInfo{}, err
+	}
+	if newID == id && name == record.Name {
+		return FileInfo{}, fmt.Errorf("%w: file with ID %d already has that ID and name", errs.ErrInvalidInput, id)
+	}
+	if newID != id {
+		if err := s.checkFreeID(ctx, newID); err != nil {
+			return FileInfo{}, err
+		}
+	}
+	record.Name = name
+	event, err := events.New(ctx, events.DocumentMoved, newID)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	event.PreviousID = id
+	if err := s.pathsrv.MovePathWithEvent(ctx, id, newID, record, event); err != nil {
+		return FileInfo{}, err
+	}
+	return newFileInfo(newID, record), nil
+}
+
+// UpdateRetention changes the retention or the legal hold of the file of the given ID with the given function,
+// under mu, so neither is lost to nor loses a concurrent change of the file, and deletions that already checked
+// the retention of the file finish before it changes. Only the retention and the legal hold the function sets
+// are kept. The record is saved unless the function fails, whose error is returned as is.
+// If the id doesn't exist or its file has expired it returns an ErrNotFound error.
+func (s *storageService) UpdateRetention(
+	ctx context.Context,
+	id int64,
+	change func(record *pathrepository.PathRecord) error,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.current(ctx, id)
+	if err != nil {
+		return err
+	}
+	changed := record
+	if err := change(&changed); err != nil {
+		return err
+	}
+	record.RetainUntil, record.LegalHold = changed.RetainUntil, changed.LegalHold
+	return s.pathsrv.SavePath(ctx, id, record)
+}
+
+// UpdateLabels changes the labels of the file of the given ID, which must not be in the trash, retained nor
+// checked out by another user, with the given function, under mu, so no concurrent change of the file is lost.
+// Only the tags, the metadata and the document type the function sets are kept. The record is saved along with
+// the document.relabeled event unless the function fails, whose error is returned as is.
+// If the id doesn't exist or its file is in the trash it returns an ErrNotFound error, if it's retained an
+// ErrRetained error, and if it's checked out by another user an ErrLocked error.
+func (s *storageService) UpdateLabels(
+	ctx context.Context,
+	id int64,
+	change func(record *pathrepository.PathRecord) error,
+) (FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.live(ctx, id)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if err := s.checkReplaceable(ctx, id, record, false); err != nil {
+		return FileInfo{}, err
+	}
+	changed := record
+	if err := change(&changed); err != nil {
+		return FileInfo{}, err
+	}
+	record.Tags, record.Metadata, record.Type = changed.Tags, changed.Metadata, changed.Type
+	if err := s.saveWithEvent(ctx, id, record, events.DocumentRelabeled); err != nil {
+		return FileInfo{}, err
+	}
+	return newFileInfo(id, record), nil
+}
+
+// checkFreeID returns an ErrInvalidInput error if the ID is in use, like uploads do.
+func (s *storageService) checkFreeID(ctx context.Context, id int64) error {
+	exists, err := s.pathsrv.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("path with id %d already exists: %w", id, errs.ErrInvalidInput)
+	}
+	return nil
+}
+
+// copyBlob copies the stored content at from to the new path to, as is. It must be called with the guard held.
+func (s *storageService) copyBlob(ctx context.Context, from string, to string) error {
+	src, err := s.blobs.Open(from)
+	if err != nil {
+		s.logger.Error(ctx, "Failed to open %s to copy it: %s", from, err.Error())
+		return fmt.Errorf("error copying the file: %w", errs.ErrinternalError)
+	}
+	defer src.Close()
+	dst, err := s.blobs.Create(to)
+	if err == nil {
+		_, err = io.Copy(dst, src)
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			s.removeBlob(ctx, to)
+		}
+	}
+	if err != nil {
+		s.logger.Error(ctx, "Failed to copy %s to %s: %s", from, to, err.Error())
+		return fmt.Errorf("error copying the file: %w", errs.ErrinternalError)
+	}
+	return nil
+}
+
+// copyName names the copy of the file of the given name with its new ID, e.g. "report (7).pdf".
+func copyName(name string, newID int64) string {
+	extension := filepath.Ext(name)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, extension), newID, extension)
+}
+
+// validateName returns an ErrInvalidInput error if the name can't name a file: it must not be empty, contain
+// slashes nor start with a dot.
+func validateName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: %q is not a valid file name", errs.ErrInvalidInput, name)
+	}
+	return nil
+}
+
+// List returns the files of the tenant found in the context that aren't in the trash, sorted by ID.
+func (s *storageService) List(ctx context.Context) ([]FileInfo, error) {
+	return s.listFiles(ctx, false)
+}
+
+// Trash returns the files of the tenant found in the context that are in the trash, sorted by ID.
+func (s *storageService) Trash(ctx context.Context) ([]FileInfo, error) {
+	return s.listFiles(ctx, true)
+}
+
+// Restore takes the file associated with the given ID out of the trash, making it reachable again.
+// If the id doesn't exist or its file is not in the trash it returns an ErrNotFound error.
+func (s *storageService) Restore(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.trashed(ctx, id)
+	if err != nil {
+		return err
+	}
+	record.DeletedAt = time.Time{}
+	record.DeletedBy = ""
+	return s.saveWithEvent(ctx, id, record, events.DocumentRestored)
+}
+
+// Purge permanently removes the file associated with the given ID, which must be in the trash.
+// If the id doesn't exist or its file is not in the trash it returns an ErrNotFound error, and if it has been
+// retained since it was moved to the trash an ErrRetained error.
+func (s *storageService) Purge(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.trashed(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.remove(ctx, id, record, events.DocumentPurged)
+}
+
+// Expire permanently removes the file associated with the given ID once it has expired, whether it's in the
+// trash or not, like Purge does. If the id doesn't exist or its file hasn't expired it returns an ErrNotFound
+// error, and if it's retained an ErrRetained error.
+func (s *storageService) Expire(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.pathsrv.GetPath(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !record.Expired(time.Now()) {
+		return fmt.Errorf("%w: file with ID %d hasn't expired", errs.ErrNotFound, id)
+	}
+	return s.remove(ctx, id, record, events.DocumentExpired)
+}
+
+// remove permanently removes the file associated with the given ID, unless it's retained. Blob paths are unique
+// to the record, so removing its blob never takes the content of another file.
+// The path is deleted first, along with an event of the given type, so the file is unreachable even if
+// removing it from the filesystem fails, and then the blobs of every version of the file are removed and their
+// sizes released from the quota of the tenant and its owner. It must be called with mu held.
+func (s *storageService) remove(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+	eventType string,
+) error {
+	if err := s.retain.Check(ctx, id, record); err != nil {
+		return err
+	}
+	event, err := events.New(ctx, eventType, id)
+	if err != nil {
+		return err
+	}
+	if err := s.pathsrv.DeletePathWithEvent(ctx, id, event); err != nil {
+		return err
+	}
+	for _, path := range record.Blobs() {
+		if err := s.blobs.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			s.logger.Error(ctx, "Failed to remove file %d at %s: %s", id, path, err.Error())
+		}
+	}
+	if err := s.quota.Release(ctx, record.Owner, record.Size); err != nil {
+		s.logger.Error(ctx, "Failed to release quota of file %d: %s", id, err.Error())
+	}
+	for _, version := range record.History {
+		if err := s.quota.Release(ctx, record.Owner, version.Size); err != nil {
+			s.logger.Error(ctx, "Failed to release quota of file %d v%d: %s", id, version.Version, err.Error())
+		}
+	}
+	return nil
+}
+
+// saveWithEvent saves the path record of the given ID along with an event of the given type, which is
+// only published if the record is saved.
+func (s *storageService) saveWithEvent(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+	eventType string,
+) error {
+	event, err := events.New(ctx, eventType, id)
+	if err != nil {
+		return err
+	}
+	return s.pathsrv.SavePathWithEvent(ctx, id, record, event)
+}
+
+// checkLock returns an ErrLocked error if the file of the given ID and path record is checked out by a user
+// other than the one found in the context. If the file must be checked out by the user, as when checking it
+// in, it also returns an ErrConflict error if it isn't checked out.
+func (s *storageService) checkLock(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+	mustHold bool,
+) error {
+	user := tenancy.UserFromContext(ctx)
+	switch {
+	case !record.Locked(time.Now()):
+		if mustHold {
+			return fmt.Errorf("%w: file with ID %d is not checked out", errs.ErrConflict, id)
+		}
+		return nil
+	case record.LockedBy != user:
+		return fmt.Errorf(
+			"%w: file with ID %d is checked out by %s until %s",
+			errs.ErrLocked,
+			id,
+			record.LockedBy,
+			record.LockedUntil.UTC().Format(time.RFC3339),
+		)
+	}
+	return nil
+}
+
+// live retrieves the path record of the given ID, returning an ErrNotFound error if its file is in the trash
+// or has expired.
+func (s *storageService) live(ctx context.Context, id int64) (pathrepository.PathRecord, error) {
+	record, err := s.current(ctx, id)
+	if err != nil {
+		return pathrepository.PathRecord{}, err
+	}
+	if record.Trashed() {
+		return pathrepository.PathRecord{}, fmt.Errorf(
+			"%w: file with ID %d is in the trash",
+			errs.ErrNotFound,
+			id,
+		)
+	}
+	return record, nil
+}
+
+// trashed retrieves the path record of the given ID, returning an ErrNotFound error if its file is not in
+// the trash or has expired.
+func (s *storageService) trashed(ctx context.Context, id int64) (pathrepository.PathRecord, error) {
+	record, err := s.current(ctx, id)
+	if err != nil {
+		return pathrepository.PathRecord{}, err
+	}
+	if !record.Trashed() {
+		return pathrepository.PathRecord{}, fmt.Errorf(
+			"%w: file with ID %d is not in the trash",
+			errs.ErrNotFound,
+			id,
+		)
+	}
+	return record, nil
+}
+
+// current retrieves the path record of the given ID, returning an ErrNotFound error if its file has expired.
+func (s *storageService) current(ctx context.Context, id int64) (pathrepository.PathRecord, error) {
+	record, err := s.pathsrv.GetPath(ctx, id)
+	if err != nil {
+		return pathrepository.PathRecord{}, err
+	}
+	if record.Expired(time.Now()) {
+		return pathrepository.PathRecord{}, fmt.Errorf("%w: file with ID %d has expired", errs.ErrNotFound, id)
+	}
+	return record, nil
+}
+
+// listFiles returns the unexpired files of the tenant found in the context that are, or aren't, in the trash.
+func (s *storageService) listFiles(ctx context.Context, trashed bool) ([]FileInfo, error) {
+	entries, err := s.pathsrv.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	files := []FileInfo{}
+	for _, entry := range entries {
+		if entry.Record.Trashed() == trashed && !entry.Record.Expired(now) {
+			files = append(files, newFileInfo(entry.ID, entry.Record))
+		}
+	}
+	return files, nil
+}
+
+// OpenContent opens the content of the file described by the given record, decrypting and decompressing
+// it transparently. It's meant for maintenance jobs that need to read files regardless of their tenant.
+// It returns an error wrapping fs.ErrNotExist if the file can't be found at its path.
+func OpenContent(blobs blobstore.BlobStore, record pathrepository.PathRecord) (blobstore.Blob, error) {
+	blob, err := blobs.Open(record.Path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeContent(blob, record), nil
+}
+
+// decodeContent returns the content of the blob described by the given record, decompressing it if needed.
+func decodeContent(blob blobstore.Blob, record pathrepository.PathRecord) blobstore.Blob {
+	if record.Compression == "" {
+		return blob
+	}
+	codec := compression.Codec(record.Compression)
+	return decompressedBlob{compression.NewReader(blob, codec, record.Size), blob}
+}
+
+// blobPath returns a new path to store a version of the content of the file of the given ID at, inside the
+// storage root of the tenant found in the context. Paths are random, rather than derived from the name of the
+// file or the number of the version, which concurrent replacements only know once saved, so no two records
+// nor versions ever share a blob: not even those of a file moved to another ID and of a file uploaded later
+// under the ID it left.
+func blobPath(ctx context.Context, id int64) (string, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(tenancy.StorageRoot(tenant), strconv.FormatInt(id, 10), hex.EncodeToString(name)), nil
+}
+
+// storeFile is a helper method for storing a file in the blob store at the given path.
+// It sniffs the file's content type to decide whether to compress it, creates it, and copies the content
+// from UploadData computing its checksums on the way. If the content doesn't match the digests supplied by
+// the client, the file is removed and an ErrInvalidInput error is returned.
+// Returns a record with the path, content type, compression, stored size and checksums of the file,
+// or an error if the operation fails.
+func (s *storageService) storeFile(
+	ctx context.Context,
+	data UploadData,
+	path string,
+) (pathrepository.PathRecord, error) {
+	contentType, err := fileutils.DetermineMIME(data.File)
+	if err != nil {
+		return pathrepository.PathRecord{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	codec := compression.Choose(contentType, s.codec)
+	dst, err := s.blobs.Create(path)
+	if err != nil {
+		return pathrepository.PathRecord{}, err
+	}
+	stored := &countingWriter{w: dst}
+	hasher := checksum.NewHasher(data.Digests)
+	if err := copyCompressed(stored, io.TeeReader(data.File, hasher), codec); err != nil {
+		dst.Close()
+		s.removeBlob(ctx, path)
+		return pathrepository.PathRecord{}, fmt.Errorf("failed to write to file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		s.removeBlob(ctx, path)
+		return pathrepository.PathRecord{}, fmt.Errorf("failed to write to file: %w", err)
+	}
+	if err := hasher.Verify(); err != nil {
+		s.removeBlob(ctx, path)
+		return pathrepository.PathRecord{}, fmt.Errorf("%w: %w", errs.ErrInvalidInput, err)
+	}
+	sum := hasher.Sum()
+	return pathrepository.PathRecord{
+		Path:        path,
+		StoredSize:  stored.n,
+		ContentType: contentType,
+		Compression: string(codec),
+		SHA256:      sum.SHA256,
+		CRC32C:      sum.CRC32C,
+	}, nil
+}
+
+// scanFile scans the uploaded file for malware, unless scanning is disabled, and rewinds it.
+// It returns an ErrInfected error if malware is found, quarantining the file first if the policy says so.
+// If the file can't be scanned it's accepted when the policy fails open, and otherwise rejected with an
+// ErrUnavailable error, as the upload can be retried once the scanner is back.
+func (s *storageService) scanFile(ctx context.Context, data UploadData) error {
+	if !s.scan.Enabled() {
+		return nil
+	}
+	result, err := s.scan.Scanner.Scan(ctx, data.File)
+	if _, seekErr := data.File.Seek(0, io.SeekStart); seekErr != nil {
+		return fmt.Errorf("failed to read file: %w", seekErr)
+	}
+	if err != nil {
+		if s.scan.FailOpen {
+			s.logger.Error(ctx, "Failed to scan file %d, accepting it unscanned: %s", data.Id, err.Error())
+			return nil
+		}
+		s.logger.Error(ctx, "Failed to scan file %d: %s", data.Id, err.Error())
+		return fmt.Errorf("%w: file couldn't be scanned for malware", errs.ErrUnavailable)
+	}
+	if !result.Infected {
+		return nil
+	}
+	s.logger.Info(ctx, "File %d is infected with %s, rejecting it", data.Id, result.Signature)
+	if s.scan.Quarantine {
+		s.quarantineFile(ctx, data)
+	}
+	return fmt.Errorf("%w with %s", errs.ErrInfected, result.Signature)
+}
+
+// quarantineFile keeps an infected upload in the infected directory of the tenant's storage root, named after
+// the time it was uploaded at, its ID and its name. As the directory starts with a dot, the file is neither
+// listed nor collected. Any failure is logged.
+func (s *storageService) quarantineFile(ctx context.Context, data UploadData) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return
+	}
+	name := fmt.Sprintf("%d-%d-%s", time.Now().UnixNano(), data.Id, filepath.Base(data.Filename))
+	path := filepath.Join(tenancy.StorageRoot(tenant), infectedDir, name)
+	dst, err := s.blobs.Create(path)
+	if err == nil {
+		_, err = io.Copy(dst, data.File)
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		s.logger.Error(ctx, "Failed to quarantine infected file %d: %s", data.Id, err.Error())
+		s.removeBlob(ctx, path)
+		return
+	}
+	s.logger.Info(ctx, "Infected file %d quarantined at %s", data.Id, path)
+}
+
+// removeBlob removes a blob that was left incomplete, logging any failure.
+func (s *storageService) removeBlob(ctx context.Context, path string) {
+	if err := s.blobs.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		s.logger.Error(ctx, "Failed to remove incomplete file %s: %s", path, err.Error())
+	}
+}
+
+// copyCompressed copies src into dst, compressing it with the given codec unless it's compression.None.
+func copyCompressed(dst io.Writer, src io.Reader, codec compression.Codec) error {
+	if codec == compression.None {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	compressor, err := compression.NewWriter(dst, codec)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(compressor, src); err != nil {
+		compressor.Close()
+		return err
+	}
+	return compressor.Close()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
 }
 
-func checkIfDoesntExist(filePath string) bool {
-	_, err := os.Stat(filePath)
-	return os.IsNotExist(err)
+// Write writes p to the underlying writer, counting the written bytes.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
@@ -3,10 +3,24 @@ package storageservice
 import (
 	"context"
 	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/checksum"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
 	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
 	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
 	"github.com/stretchr/testify/mock"
 )
@@ -26,8 +40,18 @@ func TestUploadMetjod(t *testing.T) {
 	ctx := context.TODO()
 	pathService := new(mocks.MockPathService)
 	pathService.On("Exists", ctx, int64(1)).Return(true, nil)
-	pathService.On("SavePath", ctx, 1, mock.AnythingOfType("string")).Return(nil)
-	service := New(logger, pathService)
+	pathService.On("SavePathWithEvent", ctx, 1, mock.AnythingOfType("pathrepository.PathRecord"), mock.Anything).
+		Return(nil)
+	service := New(
+		logger,
+		pathService,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, pathService),
+		compression.None,
+		scanning.Policy{},
+	)
 
 	for i, tt := range uploadTests {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
@@ -38,3 +62,783 @@ func TestUploadMetjod(t *testing.T) {
 		})
 	}
 }
+
+// newTestService returns a storage service over a temporary storage, along with its retention service and
+// a context of the tenant "acme".
+func newTestService(t *testing.T) (StorageService, retention.Service, context.Context) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	retain := retention.New(logger, paths)
+	service := New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retain,
+		compression.None,
+		scanning.Policy{},
+	)
+	return service, retain, tenancy.WithTenant(context.Background(), "acme")
+}
+
+// blobOf returns the path of the blob of the file with the given ID.
+func blobOf(t *testing.T, service StorageService, ctx context.Context, id int64) string {
+	t.Helper()
+	record, err := service.(*storageService).pathsrv.GetPath(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record.Path
+}
+
+// storedBlobs returns the paths of the blobs in the storage root of the tenant "acme".
+func storedBlobs(t *testing.T) []string {
+	t.Helper()
+	infos, err := blobstore.NewFileSystem(nil).List(tenancy.StorageRoot("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, len(infos))
+	for i, info := range infos {
+		paths[i] = info.Path
+	}
+	return paths
+}
+
+// upload uploads a file with the given ID, name and content.
+func upload(t *testing.T, service StorageService, ctx context.Context, id int64, name string, content string) {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(src, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	data := UploadData{File: file, Filename: name, Id: id, Size: int64(len(content))}
+	if err := service.Upload(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "kept.txt", "kept")
+	upload(t, service, ctx, 2, "deleted.txt", "deleted")
+
+	if err := service.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Get(ctx, 2); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting a file in the trash, got: %v", err)
+	}
+	if err := service.Delete(ctx, 2); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a file in the trash, got: %v", err)
+	}
+	files, _ := service.List(ctx)
+	if len(files) != 1 || files[0].ID != 1 {
+		t.Errorf("Expected only file 1 to be listed, got: %v", files)
+	}
+	trash, _ := service.Trash(ctx)
+	if len(trash) != 1 || trash[0].ID != 2 || trash[0].DeletedAt == nil {
+		t.Errorf("Expected file 2 to be in the trash, got: %v", trash)
+	}
+
+	if err := service.Restore(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	file, err := service.Get(ctx, 2)
+	if err != nil {
+		t.Fatalf("Expected restored file to be reachable, got: %v", err)
+	}
+	file.Close()
+	if err := service.Restore(ctx, 2); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound restoring a file not in the trash, got: %v", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "purged.txt", "purged")
+	path := blobOf(t, service, ctx, 1)
+
+	if err := service.Purge(ctx, 1); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound purging a file not in the trash, got: %v", err)
+	}
+	service.Delete(ctx, 1)
+	if err := service.Purge(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected purged file to be removed, got: %v", err)
+	}
+	if trash, _ := service.Trash(ctx); len(trash) != 0 {
+		t.Errorf("Expected the trash to be empty, got: %v", trash)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "report.txt", "first version")
+	replaced := blobOf(t, service, ctx, 1)
+
+	src := filepath.Join(t.TempDir(), "report-v2.txt")
+	os.WriteFile(src, []byte("second version"), 0o644)
+	file, _ := os.Open(src)
+	defer file.Close()
+	data := UploadData{File: file, Filename: "report-v2.txt", Id: 1, Size: int64(len("second version"))}
+	if err := service.Replace(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := service.Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Close()
+	content, _ := io.ReadAll(got)
+	if string(content) != "second version" || got.Name != "report-v2.txt" {
+		t.Errorf("Expected the replaced content, got: %q named %s", content, got.Name)
+	}
+	if _, err := os.Stat(replaced); err != nil {
+		t.Errorf("Expected the replaced blob to be kept as the previous version, got: %v", err)
+	}
+
+	versions, err := service.Versions(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Name != "report.txt" || versions[0].ReplacedAt == nil ||
+		versions[1].Version != 2 || versions[1].ReplacedAt != nil {
+		t.Errorf("Expected the previous and the current version, got: %+v", versions)
+	}
+	previous, err := service.GetVersion(ctx, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer previous.Close()
+	if content, _ := io.ReadAll(previous); string(content) != "first version" || previous.Name != "report.txt" {
+		t.Errorf("Expected the content of the previous version, got: %q named %s", content, previous.Name)
+	}
+	if _, err := service.GetVersion(ctx, 1, 3); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting a version that doesn't exist, got: %v", err)
+	}
+
+	service.Delete(ctx, 1)
+	if err := service.Purge(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if blobs := storedBlobs(t); len(blobs) != 0 {
+		t.Errorf("Expected the blobs of every version to be removed along with the file, got: %v", blobs)
+	}
+}
+
+func TestRetainedFile(t *testing.T) {
+	service, retain, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "contract.txt", "retained")
+	if err := retain.SetRetention(ctx, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Delete(ctx, 1); !errors.Is(err, errs.ErrRetained) {
+		t.Errorf("Expected ErrRetained deleting a retained file, got: %v", err)
+	}
+	data := batchFile(t, 1, "contract.txt", "replaced")
+	if err := service.Replace(ctx, data); !errors.Is(err, errs.ErrRetained) {
+		t.Errorf("Expected ErrRetained replacing a retained file, got: %v", err)
+	}
+}
+
+// pausedPaths is a path service whose SavePathWithEvent, once paused, announces it's saving a record and waits
+// to be released before saving it.
+type pausedPaths struct {
+	pathservice.PathService
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (p *pausedPaths) SavePathWithEvent(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+	event events.Event,
+) error {
+	if p.saving != nil {
+		p.saving <- struct{}{}
+		<-p.release
+	}
+	return p.PathService.SavePathWithEvent(ctx, id, record, event)
+}
+
+func TestLegalHoldRaces(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := &pausedPaths{PathService: pathservice.New(logger, pathrepository.MemoryRepository(logger))}
+	retain := retention.New(logger, paths)
+	service := New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retain,
+		compression.None,
+		scanning.Policy{},
+	)
+	retain.UseRecords(service)
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	upload(t, service, ctx, 1, "contract.txt", "v1")
+	upload(t, service, ctx, 2, "draft.txt", "draft")
+	paths.saving, paths.release = make(chan struct{}), make(chan struct{})
+
+	// holdDuring sets the legal hold of the file while change is saving its record, and returns the error of
+	// the change.
+	holdDuring := func(id int64, change func() error) error {
+		changed := make(chan error)
+		go func() { changed <- change() }()
+		<-paths.saving
+		held := make(chan error)
+		go func() { held <- retain.SetLegalHold(ctx, id, true, "litigation") }()
+		time.Sleep(20 * time.Millisecond)
+		paths.release <- struct{}{}
+		if err := <-held; err != nil {
+			t.Fatal(err)
+		}
+		return <-changed
+	}
+
+	replaceErr := holdDuring(1, func() error { return service.Replace(ctx, batchFile(t, 1, "contract.txt", "v2")) })
+	record, _ := paths.GetPath(ctx, 1)
+	if replaceErr != nil || !record.LegalHold || record.Version != 2 || len(storedBlobs(t)) != 3 {
+		t.Errorf("Expected both the hold and the new version to be kept, got %+v, %v", record, replaceErr)
+	}
+	deleteErr := holdDuring(2, func() error { return service.Delete(ctx, 2) })
+	record, _ = paths.GetPath(ctx, 2)
+	if deleteErr != nil || !record.LegalHold || !record.Trashed() {
+		t.Errorf("Expected the file to be held once deleted, got %+v, %v", record, deleteErr)
+	}
+}
+
+// whileSaving runs change until it saves a record through paths, then starts other and lets the save go on
+// shortly after, so other gets the chance to run meanwhile. It returns the errors of both.
+func whileSaving(paths *pausedPaths, change func() error, other func() error) (error, error) {
+	paths.saving, paths.release = make(chan struct{}), make(chan struct{})
+	defer func() { paths.saving = nil }()
+	changed, done := make(chan error), make(chan error)
+	go func() { changed <- change() }()
+	<-paths.saving
+	go func() { done <- other() }()
+	time.Sleep(20 * time.Millisecond)
+	paths.release <- struct{}{}
+	changeErr := <-changed
+	return changeErr, <-done
+}
+
+func TestPurgeWhileRestoring(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	paths := &pausedPaths{PathService: service.(*storageService).pathsrv}
+	service.(*storageService).pathsrv = paths
+	upload(t, service, ctx, 1, "contract.txt", "signed")
+	service.Delete(ctx, 1)
+
+	restoreErr, purgeErr := whileSaving(
+		paths,
+		func() error { return service.Restore(ctx, 1) },
+		func() error { return service.Purge(ctx, 1) },
+	)
+	if restoreErr != nil || !errors.Is(purgeErr, errs.ErrNotFound) {
+		t.Errorf("Expected the file to be restored and not purged, got %v, %v", restoreErr, purgeErr)
+	}
+	if got := content(t, service, ctx, 1); got != "signed" {
+		t.Errorf("Expected the restored file to keep its content, got: %q", got)
+	}
+}
+
+func TestExpireWhileReplacing(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	paths := &pausedPaths{PathService: service.(*storageService).pathsrv}
+	service.(*storageService).pathsrv = paths
+	upload(t, service, ctx, 1, "preview.png", "v1")
+	// The file expires once the replacement is checked, but before it's saved.
+	record, _ := paths.GetPath(ctx, 1)
+	record.ExpiresAt = time.Now().Add(10 * time.Millisecond)
+	paths.SavePath(ctx, 1, record)
+
+	replaceErr, expireErr := whileSaving(
+		paths,
+		func() error { return service.Replace(ctx, batchFile(t, 1, "preview.png", "v2")) },
+		func() error {
+			time.Sleep(time.Until(record.ExpiresAt))
+			return service.Expire(ctx, 1)
+		},
+	)
+	if replaceErr != nil || expireErr != nil {
+		t.Errorf("Expected the file to be replaced and then expired, got %v, %v", replaceErr, expireErr)
+	}
+	if exists, _ := paths.Exists(ctx, 1); exists {
+		t.Error("Expected the path of the expired file to be removed")
+	}
+	if blobs := storedBlobs(t); len(blobs) != 0 {
+		t.Errorf("Expected the blobs of every version to be removed, got: %v", blobs)
+	}
+}
+
+func TestSameName(t *testing.T) {
+	service, retain, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "contract.txt", "signed")
+	if err := retain.SetLegalHold(ctx, 1, true, "litigation"); err != nil {
+		t.Fatal(err)
+	}
+	upload(t, service, ctx, 2, "contract.txt", "draft")
+
+	if got := content(t, service, ctx, 1); got != "signed" {
+		t.Errorf("Expected a file with the same name not to overwrite the held one, got: %q", got)
+	}
+	service.Delete(ctx, 2)
+	if err := service.Purge(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := content(t, service, ctx, 1); got != "signed" {
+		t.Errorf("Expected purging a file with the same name to keep the held one, got: %q", got)
+	}
+}
+
+func TestUnsavedUpload(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	pathService := new(mocks.MockPathService)
+	pathService.On("Exists", ctx, int64(1)).Return(false, nil)
+	pathService.On("CreatePathsWithEvents", ctx, mock.Anything).Return(errors.New("repository unavailable"))
+	tracker := quota.NewMemoryTracker(quota.Limits{}, quota.Limits{})
+	service := New(
+		logger,
+		pathService,
+		tracker,
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, pathService),
+		compression.None,
+		scanning.Policy{},
+	)
+
+	if err := service.Upload(ctx, batchFile(t, 1, "lost.txt", "lost")); err == nil {
+		t.Fatal("Expected the upload to fail when its path can't be saved")
+	}
+	if blobs := storedBlobs(t); len(blobs) != 0 {
+		t.Errorf("Expected the stored file to be removed, got: %v", blobs)
+	}
+	if report, _ := tracker.Report(ctx, ""); report.Tenant.Usage != (quota.Usage{}) {
+		t.Errorf("Expected the size of the file to be released, got: %+v", report.Tenant.Usage)
+	}
+}
+
+func TestExpiredFile(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "preview.png", "preview")
+	if err := service.Expire(ctx, 1); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound expiring an unexpired file, got: %v", err)
+	}
+
+	paths := service.(*storageService).pathsrv
+	record, _ := paths.GetPath(ctx, 1)
+	record.ExpiresAt = time.Now().Add(-time.Second)
+	paths.SavePath(ctx, 1, record)
+
+	if _, err := service.Get(ctx, 1); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting an expired file, got: %v", err)
+	}
+	if files, _ := service.List(ctx); len(files) != 0 {
+		t.Errorf("Expected expired files not to be listed, got: %v", files)
+	}
+	if err := service.Expire(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := paths.Exists(ctx, 1); exists {
+		t.Errorf("Expected the path of the expired file to be removed")
+	}
+}
+
+func TestUploadEvents(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	service := New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobstore.NewFileSystem(nil),
+		blobstore.NewGuard(),
+		retention.New(logger, paths),
+		compression.None,
+		scanning.Policy{},
+	)
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	upload(t, service, ctx, 1, "report.txt", "report")
+	src := filepath.Join(t.TempDir(), "tampered.txt")
+	os.WriteFile(src, []byte("tampered"), 0o644)
+	file, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rejected := UploadData{
+		File:     file,
+		Filename: "tampered.txt",
+		Id:       2,
+		Size:     8,
+		Digests:  checksum.Digests{"sha-256": make([]byte, 32)},
+	}
+	if err := service.Upload(ctx, rejected); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("Expected the upload to be rejected, got: %v", err)
+	}
+	service.Delete(ctx, 1)
+
+	pending, err := paths.PendingEvents(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 ||
+		pending[0].Event.Type != events.DocumentCreated ||
+		pending[1].Event.Type != events.DocumentDeleted ||
+		pending[0].Event.DocumentID != 1 {
+		t.Errorf("Expected only the events of the committed changes of file 1, got: %+v", pending)
+	}
+}
+
+func TestScannedUpload(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	scanner := scanning.NewFake()
+	policy := scanning.Policy{Scanner: scanner, Quarantine: true}
+	newService := func() StorageService {
+		return New(
+			logger,
+			paths,
+			quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+			blobstore.NewFileSystem(nil),
+			blobstore.NewGuard(),
+			retention.New(logger, paths),
+			compression.None,
+			policy,
+		)
+	}
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	service := newService()
+	upload(t, service, ctx, 1, "clean.txt", "clean")
+
+	src := filepath.Join(t.TempDir(), "eicar.com")
+	os.WriteFile(src, []byte(scanning.EICAR), 0o644)
+	file, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	infected := UploadData{File: file, Filename: "eicar.com", Id: 2, Size: int64(len(scanning.EICAR))}
+	if err := service.Upload(ctx, infected); !errors.Is(err, errs.ErrInfected) {
+		t.Fatalf("Expected an infected error, got: %v", err)
+	}
+	if exists, _ := paths.Exists(ctx, 2); exists {
+		t.Error("Expected the infected file not to be stored")
+	}
+	if blobs, _ := filepath.Glob(filepath.Join(tenancy.StorageRoot("acme"), "2", "*")); len(blobs) != 0 {
+		t.Errorf("Expected no blob for the infected file, got: %v", blobs)
+	}
+	quarantined, _ := filepath.Glob(filepath.Join(tenancy.StorageRoot("acme"), ".infected", "*-2-eicar.com"))
+	if len(quarantined) != 1 {
+		t.Errorf("Expected the infected file to be quarantined, got: %v", quarantined)
+	}
+
+	file.Seek(0, io.SeekStart)
+	replacement := UploadData{File: file, Filename: "clean.txt", Id: 1, Size: infected.Size}
+	if err := service.Replace(ctx, replacement); !errors.Is(err, errs.ErrInfected) {
+		t.Errorf("Expected an infected error replacing a file, got: %v", err)
+	}
+
+	scanner.Err = errors.New("clamd unreachable")
+	file.Seek(0, io.SeekStart)
+	if err := service.Upload(ctx, infected); !errors.Is(err, errs.ErrUnavailable) {
+		t.Errorf("Expected uploads to be rejected when scanning fails closed, got: %v", err)
+	}
+	policy.FailOpen = true
+	upload(t, newService(), ctx, 3, "unscanned.txt", "unscanned")
+}
+
+func TestFilter(t *testing.T) {
+	file := FileInfo{ID: 1, Name: "scan.PDF", ContentType: "application/pdf", Owner: "bob"}
+	tests := []struct {
+		filter  Filter
+		matches bool
+	}{
+		{Filter{}, true},
+		{Filter{ContentType: "application/pdf", Owner: "bob"}, true},
+		{Filter{ContentType: "application/*"}, true},
+		{Filter{ContentType: "image/*"}, false},
+		{Filter{Owner: "alice"}, false},
+		{Filter{Name: "*.PDF"}, true},
+		{Filter{Name: "*.pdf"}, false},
+	}
+	for _, test := range tests {
+		if matches := test.filter.Matches(file); matches != test.matches {
+			t.Errorf("%+v.Matches() = %t, expected %t", test.filter, matches, test.matches)
+		}
+	}
+	if err := (Filter{Name: "[a-"}).Validate(); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected an invalid input error for a malformed pattern, got %v", err)
+	}
+}
+
+// batchFile returns the upload data of a file of a batch with the given ID, name and content.
+func batchFile(t *testing.T, id int64, name string, content string) UploadData {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(src, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return UploadData{File: file, Filename: name, Id: id, Size: int64(len(content))}
+}
+
+func TestUploadBatch(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "existing.txt", "existing")
+
+	results := service.UploadBatch(ctx, []UploadData{
+		batchFile(t, 2, "a.txt", "a"),
+		batchFile(t, 1, "b.txt", "b"),
+		batchFile(t, 3, "a.txt", "c"),
+		batchFile(t, 4, "d.txt", "d"),
+	}, false)
+	if results[0] != nil || results[2] != nil || results[3] != nil {
+		t.Errorf("Expected the valid files, even with a repeated name, to be uploaded, got: %v", results)
+	}
+	if !errors.Is(results[1], errs.ErrInvalidInput) {
+		t.Errorf("Expected the file with an ID in use to fail, got: %v", results)
+	}
+	if got := content(t, service, ctx, 2); got != "a" {
+		t.Errorf("Expected a file with a repeated name to keep its content, got: %q", got)
+	}
+	if files, _ := service.List(ctx); len(files) != 4 {
+		t.Errorf("Expected the valid files to be listed, got: %v", files)
+	}
+
+	results = service.UploadBatch(ctx, []UploadData{
+		batchFile(t, 5, "existing.txt", "e"),
+		batchFile(t, 1, "f.txt", "f"),
+		batchFile(t, 6, "g.txt", "g"),
+	}, true)
+	if !errors.Is(results[0], ErrBatchAborted) ||
+		!errors.Is(results[1], errs.ErrInvalidInput) ||
+		!errors.Is(results[2], ErrBatchAborted) {
+		t.Errorf("Expected the atomic batch to be aborted by the file with an ID in use, got: %v", results)
+	}
+	if blobs := storedBlobs(t); len(blobs) != 4 {
+		t.Errorf("Expected the files stored before the failure to be rolled back, got: %v", blobs)
+	}
+	if files, _ := service.List(ctx); len(files) != 4 {
+		t.Errorf("Expected no file of the aborted batch to be listed, got: %v", files)
+	}
+	if got := content(t, service, ctx, 1); got != "existing" {
+		t.Errorf("Expected the rollback to keep the file with the same name as a rolled back one, got: %q", got)
+	}
+
+	retried := []UploadData{batchFile(t, 5, "e.txt", "e"), batchFile(t, 6, "g.txt", "g")}
+	results = service.UploadBatch(ctx, retried, true)
+	if results[0] != nil || results[1] != nil {
+		t.Fatalf("Expected the atomic batch to be uploaded, got: %v", results)
+	}
+	file, err := service.Get(ctx, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if content, _ := io.ReadAll(file); string(content) != "g" {
+		t.Errorf("Expected the content of the uploaded file, got: %s", content)
+	}
+}
+
+func TestCheckOut(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "contract.txt", "draft")
+	alice := tenancy.WithUser(ctx, "alice")
+	bob := tenancy.WithUser(ctx, "bob")
+
+	if _, err := service.CheckOut(ctx, 1, time.Hour); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput checking out without a user, got: %v", err)
+	}
+	info, err := service.CheckOut(alice, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LockedBy != "alice" || info.LockedUntil == nil || info.Version != 1 {
+		t.Errorf("Expected the file to be checked out by alice, got: %+v", info)
+	}
+	if _, err := service.CheckOut(bob, 1, time.Hour); !errors.Is(err, errs.ErrLocked) {
+		t.Errorf("Expected ErrLocked checking out a file checked out by another user, got: %v", err)
+	}
+	if err := service.Replace(bob, batchFile(t, 1, "contract.txt", "bob's")); !errors.Is(err, errs.ErrLocked) {
+		t.Errorf("Expected ErrLocked replacing a file checked out by another user, got: %v", err)
+	}
+	if err := service.Delete(bob, 1); !errors.Is(err, errs.ErrLocked) {
+		t.Errorf("Expected ErrLocked deleting a file checked out by another user, got: %v", err)
+	}
+	if err := service.CheckIn(bob, batchFile(t, 1, "contract.txt", "bob's")); !errors.Is(err, errs.ErrLocked) {
+		t.Errorf("Expected ErrLocked checking in a file checked out by another user, got: %v", err)
+	}
+
+	if err := service.CheckIn(alice, batchFile(t, 1, "contract.txt", "signed")); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := service.List(ctx)
+	if len(files) != 1 || files[0].Version != 2 || files[0].LockedBy != "" {
+		t.Errorf("Expected a new version of the file, no longer locked, got: %+v", files)
+	}
+	if err := service.CheckIn(alice, batchFile(t, 1, "contract.txt", "again")); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Expected ErrConflict checking in a file that isn't checked out, got: %v", err)
+	}
+
+	service.CheckOut(alice, 1, time.Hour)
+	if err := service.ReleaseLock(bob, 1, false); !errors.Is(err, errs.ErrLocked) {
+		t.Errorf("Expected ErrLocked releasing the lock of another user, got: %v", err)
+	}
+	if err := service.ReleaseLock(bob, 1, true); err != nil {
+		t.Fatalf("Expected the lock to be broken, got: %v", err)
+	}
+	if err := service.Delete(bob, 1); err != nil {
+		t.Errorf("Expected the file to be deleted once its lock is broken, got: %v", err)
+	}
+}
+
+func TestUpdateLabels(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "contract.txt", "draft")
+	upload(t, service, ctx, 2, "invoice.txt", "invoice")
+	tag := func(record *pathrepository.PathRecord) error {
+		record.Tags = []string{"signed"}
+		record.Name = "renamed.txt"
+		return nil
+	}
+
+	info, err := service.UpdateLabels(ctx, 1, tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Tags) != 1 || info.Tags[0] != "signed" || info.Name != "contract.txt" || info.Version != 1 {
+		t.Errorf("Expected only the labels of the file to change, got: %+v", info)
+	}
+	pending, err := service.(*storageService).pathsrv.PendingEvents(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := pending[len(pending)-1].Event; last.Type != events.DocumentRelabeled || last.DocumentID != 1 {
+		t.Errorf("Expected a relabeled event of file 1, got: %+v", last)
+	}
+
+	service.CheckOut(tenancy.WithUser(ctx, "alice"), 1, time.Hour)
+	if _, err := service.UpdateLabels(tenancy.WithUser(ctx, "bob"), 1, tag); !errors.Is(err, errs.ErrLocked) {
+		t.Errorf("Expected ErrLocked relabeling a file checked out by another user, got: %v", err)
+	}
+	service.Delete(ctx, 2)
+	if _, err := service.UpdateLabels(ctx, 2, tag); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound relabeling a file in the trash, got: %v", err)
+	}
+}
+
+// content reads the content of the file with the given ID.
+func content(t *testing.T, service StorageService, ctx context.Context, id int64) string {
+	t.Helper()
+	file, err := service.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestCopy(t *testing.T) {
+	service, _, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "template.txt", "dear customer")
+	upload(t, service, ctx, 2, "other.txt", "other")
+	bob := tenancy.WithUser(ctx, "bob")
+
+	info, err := service.Copy(bob, 1, 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != 3 || info.Name != "template (3).txt" || info.Owner != "bob" || info.Version != 1 {
+		t.Errorf("Expected a copy owned by bob named after the original, got: %+v", info)
+	}
+	if got := content(t, service, ctx, 3); got != "dear customer" {
+		t.Errorf("Expected the copy to have the content of the original, got: %q", got)
+	}
+	if _, err := service.Copy(ctx, 1, 2, "new.txt"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput copying to an ID in use, got: %v", err)
+	}
+	if _, err := service.Copy(ctx, 1, 4, "other.txt"); err != nil {
+		t.Errorf("Expected a copy to be able to take the name of another file, got: %v", err)
+	}
+	if got := content(t, service, ctx, 2); got != "other" {
+		t.Errorf("Expected the file whose name was taken to be kept, got: %q", got)
+	}
+	if _, err := service.Copy(ctx, 1, 5, "../escape.txt"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput copying to an invalid name, got: %v", err)
+	}
+
+	service.Delete(ctx, 3)
+	service.Purge(ctx, 3)
+	if got := content(t, service, ctx, 1); got != "dear customer" {
+		t.Errorf("Expected the original to outlive its copy, got: %q", got)
+	}
+}
+
+func TestMove(t *testing.T) {
+	service, retain, ctx := newTestService(t)
+	upload(t, service, ctx, 1, "draft.txt", "content")
+	upload(t, service, ctx, 2, "other.txt", "other")
+
+	info, err := service.Move(ctx, 1, 10, "final.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != 10 || info.Name != "final.txt" {
+		t.Errorf("Expected the file to be moved and renamed, got: %+v", info)
+	}
+	if _, err := service.Get(ctx, 1); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound getting the file under its previous ID, got: %v", err)
+	}
+	if got := content(t, service, ctx, 10); got != "content" {
+		t.Errorf("Expected the moved file to keep its content, got: %q", got)
+	}
+
+	if _, err := service.Move(ctx, 10, 2, ""); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput moving to an ID in use, got: %v", err)
+	}
+	if _, err := service.Move(ctx, 10, 10, ""); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput moving a file where it already is, got: %v", err)
+	}
+	if got := content(t, service, ctx, 10); got != "content" {
+		t.Errorf("Expected a failed move to leave the file in place, got: %q", got)
+	}
+	upload(t, service, ctx, 1, "draft.txt", "new draft")
+	if got := content(t, service, ctx, 10); got != "content" {
+		t.Errorf("Expected a file uploaded under the previous ID to leave the moved one alone, got: %q", got)
+	}
+
+	retain.SetLegalHold(ctx, 2, true, "audit")
+	if _, err := service.Move(ctx, 2, 20, ""); !errors.Is(err, errs.ErrRetained) {
+		t.Errorf("Expected ErrRetained moving a file under a legal hold, got: %v", err)
+	}
+}
@@ -1,12 +1,149 @@
 package storageservice
 
-import "mime/multipart"
+import (
+	"errors"
+	"mime/multipart"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/checksum"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+)
 
 // UploadData encapsulates the data required to upload a file.
 // It contains the file stream, the name of the file, and an identifier
 // that can be used to reference the file within the storage system.
 type UploadData struct {
-	File     multipart.File // File is the file stream to be uploaded.
-	Filename string         // Filename is the name of the file.
-	Id       int64          // Id is a unique identifier for the file.
+	File     multipart.File   // File is the file stream to be uploaded.
+	Filename string           // Filename is the name of the file.
+	Id       int64            // Id is a unique identifier for the file.
+	Size     int64            // Size is the size of the file in bytes.
+	Digests  checksum.Digests // Digests are the digests of the file supplied by the client, if any.
+	// ExpiresAt is the time from which the file is treated as not found and removed. Zero means it doesn't expire.
+	ExpiresAt time.Time
+	Type      string            // Type is the document type of the file, whose schema Metadata must satisfy.
+	Metadata  map[string]string // Metadata are the custom key/value pairs of the file. Replacements keep the old ones.
+}
+
+// File is a stored file opened for reading.
+// Its content is exposed through the embedded blob, so it can be read and seeked as a regular file.
+// If the file is stored compressed, the blob decompresses it transparently, while Encoded gives
+// access to the compressed content. Only one of them should be read.
+type File struct {
+	blobstore.Blob                // Blob is the content of the file.
+	Name           string         // Name is the name of the file.
+	ContentType    string         // ContentType is the MIME type of the file's content.
+	Encoding       string         // Encoding is the compression codec of the stored content, empty if uncompressed.
+	Checksum       checksum.Sum   // Checksum holds the checksums of the file's content.
+	encoded        blobstore.Blob // encoded is the content as stored, compressed with Encoding.
+}
+
+// FileInfo describes a stored file, for listings.
+type FileInfo struct {
+	ID          int64      `json:"id"`                  // ID is the identifier of the file.
+	Name        string     `json:"name"`                // Name is the name of the file.
+	ContentType string     `json:"contentType"`         // ContentType is the MIME type of the file's content.
+	Size        int64      `json:"size"`                // Size is the size of the file's content in bytes.
+	Owner       string     `json:"owner,omitempty"`     // Owner is the user who uploaded the file, if any.
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"` // ExpiresAt is the time the file expires, if it does.
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"` // DeletedAt is the time the file was moved to the trash.
+	DeletedBy   string     `json:"deletedBy,omitempty"` // DeletedBy is the user who moved the file to the trash.
+	Version     int        `json:"version"`             // Version is the number of the version of the file's content.
+	// LockedBy is the user who checked the file out, if it's checked out, and LockedUntil when the lock expires.
+	LockedBy    string            `json:"lockedBy,omitempty"`
+	LockedUntil *time.Time        `json:"lockedUntil,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`     // Tags are the tags of the file, sorted.
+	Metadata    map[string]string `json:"metadata,omitempty"` // Metadata are the custom key/value pairs of the file.
+	Type        string            `json:"type,omitempty"`     // Type is the document type of the file, if any.
+}
+
+// VersionInfo describes a version of the content of a file.
+type VersionInfo struct {
+	Version     int    `json:"version"`     // Version is the number of the version.
+	Name        string `json:"name"`        // Name is the name of the file at that version.
+	ContentType string `json:"contentType"` // ContentType is the MIME type of the version's content.
+	Size        int64  `json:"size"`        // Size is the size of the version's content in bytes.
+	// ReplacedAt is the time the version was replaced by the next one. It's nil for the current version.
+	ReplacedAt *time.Time `json:"replacedAt,omitempty"`
+}
+
+// newVersionInfo describes the given previous version of a file.
+func newVersionInfo(version pathrepository.VersionRecord) VersionInfo {
+	replacedAt := version.ReplacedAt
+	return VersionInfo{
+		Version:     version.Version,
+		Name:        version.Name,
+		ContentType: version.ContentType,
+		Size:        version.Size,
+		ReplacedAt:  &replacedAt,
+	}
+}
+
+// newFileInfo describes the file of the given ID and path record.
+func newFileInfo(id int64, record pathrepository.PathRecord) FileInfo {
+	info := FileInfo{
+		ID:          id,
+		Name:        record.Name,
+		ContentType: record.ContentType,
+		Size:        record.Size,
+		Owner:       record.Owner,
+		DeletedBy:   record.DeletedBy,
+		Version:     record.Version,
+		Tags:        record.Tags,
+		Metadata:    record.Metadata,
+		Type:        record.Type,
+	}
+	if !record.ExpiresAt.IsZero() {
+		expiresAt := record.ExpiresAt
+		info.ExpiresAt = &expiresAt
+	}
+	if record.Trashed() {
+		deletedAt := record.DeletedAt
+		info.DeletedAt = &deletedAt
+	}
+	if record.Locked(time.Now()) {
+		lockedUntil := record.LockedUntil
+		info.LockedBy = record.LockedBy
+		info.LockedUntil = &lockedUntil
+	}
+	return info
+}
+
+// Encoded returns the content of the file as it's stored, compressed with the file's Encoding.
+func (f File) Encoded() blobstore.Blob {
+	return f.encoded
+}
+
+// decompressedBlob is the content of a compressed blob, decompressed while it's read.
+type decompressedBlob struct {
+	*compression.Reader
+	blob blobstore.Blob
+}
+
+// Close releases the decompressor and closes the compressed blob.
+func (b decompressedBlob) Close() error {
+	b.Reader.Close()
+	return b.blob.Close()
+}
+
+// verifiedBlob is the content of a blob whose checksum is verified while it's read.
+type verifiedBlob struct {
+	*checksum.VerifyingReader
+	blob       blobstore.Blob
+	onMismatch func(error) // onMismatch is called when the content doesn't match its checksum.
+}
+
+// Read reads from the blob, reporting any checksum mismatch.
+func (b verifiedBlob) Read(p []byte) (int, error) {
+	n, err := b.VerifyingReader.Read(p)
+	if errors.Is(err, checksum.ErrMismatch) {
+		b.onMismatch(err)
+	}
+	return n, err
+}
+
+// Close closes the blob.
+func (b verifiedBlob) Close() error {
+	return b.blob.Close()
 }
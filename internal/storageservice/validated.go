@@ -0,0 +1,49 @@
+package storageservice
+
+import (
+	"context"
+
+	"github.com/lucastomic/dmsStorageService/internal/doctypes"
+)
+
+// NewValidated wraps a StorageService so the metadata of every upload is validated against the schema of its
+// document type before the file is stored. Replacements keep the type and the metadata of the file, so they
+// aren't validated.
+func NewValidated(service StorageService, validator doctypes.Validator) StorageService {
+	return &validatedService{service, validator}
+}
+
+// validatedService implements the StorageService interface, validating the uploads made through the wrapped one.
+type validatedService struct {
+	StorageService
+	validator doctypes.Validator
+}
+
+// Upload validates the metadata of the file and uploads it.
+func (s *validatedService) Upload(ctx context.Context, data UploadData) error {
+	if err := s.validator.Validate(ctx, data.Type, data.Metadata); err != nil {
+		return err
+	}
+	return s.StorageService.Upload(ctx, data)
+}
+
+// UploadBatch validates the metadata of every file and uploads those that are valid. If the batch is atomic and
+// any file is invalid, none is uploaded.
+func (s *validatedService) UploadBatch(ctx context.Context, files []UploadData, atomic bool) []error {
+	results := make([]error, len(files))
+	valid := make([]UploadData, 0, len(files))
+	positions := make([]int, 0, len(files))
+	for i, data := range files {
+		if results[i] = s.validator.Validate(ctx, data.Type, data.Metadata); results[i] == nil {
+			valid = append(valid, data)
+			positions = append(positions, i)
+		}
+	}
+	if atomic && len(valid) < len(files) {
+		return abortBatch(results)
+	}
+	for i, err := range s.StorageService.UploadBatch(ctx, valid, atomic) {
+		results[positions[i]] = err
+	}
+	return results
+}
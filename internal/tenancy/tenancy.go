@@ -0,0 +1,102 @@
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/lucastomic/dmsStorageService/internal/contextypes"
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+)
+
+// tenantIDPattern restricts tenant IDs to a safe set of characters. Since tenant IDs are used
+// to build storage paths, this prevents a tenant from escaping its own storage root.
+var tenantIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Validate checks that the given tenant ID is well formed.
+// It returns an ErrInvalidInput error if the ID is empty, too long or contains forbidden characters.
+func Validate(tenant string) error {
+	if !tenantIDPattern.MatchString(tenant) {
+		return fmt.Errorf(
+			"%w: tenant ID must be 1-64 letters, digits, '-' or '_'",
+			errs.ErrInvalidInput,
+		)
+	}
+	return nil
+}
+
+// ValidateUser checks that the given user ID is well formed.
+// User IDs follow the same rules as tenant IDs.
+func ValidateUser(user string) error {
+	if !tenantIDPattern.MatchString(user) {
+		return fmt.Errorf(
+			"%w: user ID must be 1-64 letters, digits, '-' or '_'",
+			errs.ErrInvalidInput,
+		)
+	}
+	return nil
+}
+
+// WithTenant returns a copy of ctx carrying the given tenant ID.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, contextypes.CTXTenantIDKey{}, tenant)
+}
+
+// FromContext retrieves the tenant ID stored in the context.
+// It returns an ErrInvalidInput error if the context doesn't carry a valid tenant, so that
+// no operation can ever be performed outside of a tenant scope.
+func FromContext(ctx context.Context) (string, error) {
+	tenant, ok := ctx.Value(contextypes.CTXTenantIDKey{}).(string)
+	if !ok {
+		return "", fmt.Errorf("%w: tenant is required", errs.ErrInvalidInput)
+	}
+	if err := Validate(tenant); err != nil {
+		return "", err
+	}
+	return tenant, nil
+}
+
+// WithUser returns a copy of ctx carrying the given user ID.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, contextypes.CTXUserIDKey{}, user)
+}
+
+// UserFromContext retrieves the user ID stored in the context.
+// Requests are not required to identify their user, so it returns an empty string if there is none.
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(contextypes.CTXUserIDKey{}).(string)
+	return user
+}
+
+// StorageRoot returns the directory where the files of the given tenant are stored.
+// If the tenant has its own root configured it is used, otherwise the files are stored
+// in a directory named after the tenant inside the project's "files" directory.
+func StorageRoot(tenant string) string {
+	if root, ok := environment.GetTenantStorageRoots()[tenant]; ok {
+		return root
+	}
+	return filepath.Join(environment.GetProjectRoot(), "files", tenant)
+}
+
+// StorageRoots returns the storage root of every tenant that has one, mapped by tenant ID.
+// These are the tenants with a configured root and the tenants with a directory in the project's
+// "files" directory.
+func StorageRoots() (map[string]string, error) {
+	roots := make(map[string]string)
+	entries, err := os.ReadDir(filepath.Join(environment.GetProjectRoot(), "files"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && Validate(entry.Name()) == nil {
+			roots[entry.Name()] = StorageRoot(entry.Name())
+		}
+	}
+	for tenant, root := range environment.GetTenantStorageRoots() {
+		roots[tenant] = root
+	}
+	return roots, nil
+}
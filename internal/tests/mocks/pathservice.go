@@ -3,6 +3,8 @@ package mocks
 import (
 	"context"
 
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -15,12 +17,91 @@ func (m *MockPathService) Exists(ctx context.Context, id int64) (bool, error) {
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockPathService) GetPath(ctx context.Context, id int64) (string, error) {
+func (m *MockPathService) GetPath(ctx context.Context, id int64) (pathrepository.PathRecord, error) {
 	args := m.Called(ctx, id)
-	return args.String(0), args.Error(1)
+	return args.Get(0).(pathrepository.PathRecord), args.Error(1)
 }
 
-func (m *MockPathService) SavePath(ctx context.Context, id int64, path string) error {
-	args := m.Called(ctx, id, path)
+func (m *MockPathService) SavePath(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+) error {
+	args := m.Called(ctx, id, record)
 	return args.Error(0)
 }
+
+func (m *MockPathService) DeletePath(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPathService) List(ctx context.Context) ([]pathrepository.PathEntry, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]pathrepository.PathEntry), args.Error(1)
+}
+
+func (m *MockPathService) All(ctx context.Context) ([]pathrepository.PathEntry, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]pathrepository.PathEntry), args.Error(1)
+}
+
+func (m *MockPathService) SavePathWithEvent(
+	ctx context.Context,
+	id int64,
+	record pathrepository.PathRecord,
+	event events.Event,
+) error {
+	args := m.Called(ctx, id, record, event)
+	return args.Error(0)
+}
+
+func (m *MockPathService) CreatePathsWithEvents(ctx context.Context, paths []pathrepository.NewPath) error {
+	args := m.Called(ctx, paths)
+	return args.Error(0)
+}
+
+func (m *MockPathService) DeletePathWithEvent(ctx context.Context, id int64, event events.Event) error {
+	args := m.Called(ctx, id, event)
+	return args.Error(0)
+}
+
+func (m *MockPathService) MovePathWithEvent(
+	ctx context.Context,
+	from int64,
+	to int64,
+	record pathrepository.PathRecord,
+	event events.Event,
+) error {
+	args := m.Called(ctx, from, to, record, event)
+	return args.Error(0)
+}
+
+func (m *MockPathService) NextID(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPathService) PendingEvents(
+	ctx context.Context,
+	after int64,
+	limit int,
+) ([]pathrepository.OutboxEntry, error) {
+	args := m.Called(ctx, after, limit)
+	return args.Get(0).([]pathrepository.OutboxEntry), args.Error(1)
+}
+
+func (m *MockPathService) AcknowledgeEvents(ctx context.Context, upTo int64) error {
+	args := m.Called(ctx, upTo)
+	return args.Error(0)
+}
+
+func (m *MockPathService) Tagged(ctx context.Context, tag string) ([]int64, error) {
+	args := m.Called(ctx, tag)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockPathService) MetadataValues(ctx context.Context, key string) (map[int64]string, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(map[int64]string), args.Error(1)
+}
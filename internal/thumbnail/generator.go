@@ -0,0 +1,241 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Registers the GIF decoder.
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/pipeline"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder.
+)
+
+// StageName is the name of the pipeline stage generating the thumbnails.
+const StageName = "thumbnails"
+
+// thumbnailsDir is the directory, inside the storage root of every tenant, where thumbnails are stored.
+// As it starts with a dot, thumbnails are neither listed nor collected.
+const thumbnailsDir = ".thumbnails"
+
+// Thumbnail is a thumbnail opened for reading.
+type Thumbnail struct {
+	blobstore.Blob        // Blob is the content of the thumbnail.
+	ContentType    string // ContentType is the MIME type of the thumbnail: JPEG for JPEG images, PNG otherwise.
+}
+
+// Generator generates thumbnails of the images stored, in every configured size.
+// Thumbnails are generated by a pipeline stage, so they are generated off the request path every time an image
+// is uploaded or replaced, and stored as blobs derived from the image, named after the checksum of its content,
+// so the thumbnails of a previous content are never served. The Generator implements events.EventPublisher to
+// remove the thumbnails of the images that are purged or expire.
+type Generator struct {
+	logger  logging.Logger
+	pathsrv pathservice.PathService
+	storage storageservice.StorageService
+	blobs   blobstore.BlobStore
+	config  Config
+}
+
+// NewGenerator creates a new Generator reading the images from the given storage service, the records of the
+// documents from the given path service, and storing the thumbnails in the given blob store.
+func NewGenerator(
+	logger logging.Logger,
+	pathsrv pathservice.PathService,
+	storage storageservice.StorageService,
+	blobs blobstore.BlobStore,
+	config Config,
+) *Generator {
+	return &Generator{logger, pathsrv, storage, blobs, config}
+}
+
+// Stage returns the pipeline stage generating the thumbnails of PNG, JPEG, GIF and WebP images.
+func (g *Generator) Stage() pipeline.Stage {
+	return pipeline.NewStage(StageName, ContentTypes, g.generate)
+}
+
+// Sizes returns the sizes of the thumbnails generated, in increasing order.
+func (g *Generator) Sizes() []int {
+	return g.config.Sizes
+}
+
+// Publish removes the thumbnails of the document of the event when it's purged or expires, and those of its
+// previous ID when it's moved to another one, as they're generated again under the new ID.
+// Other events are ignored.
+func (g *Generator) Publish(ctx context.Context, event events.Event) error {
+	switch event.Type {
+	case events.DocumentPurged, events.DocumentExpired:
+		return g.prune(event.Tenant, event.DocumentID, nil)
+	case events.DocumentMoved:
+		if event.PreviousID != event.DocumentID {
+			return g.prune(event.Tenant, event.PreviousID, nil)
+		}
+	}
+	return nil
+}
+
+// Open opens the thumbnail of the given size of the image of the given ID, of the tenant found in the context.
+// It returns an ErrInvalidInput error if thumbnails of the size aren't generated, and an ErrNotFound error if
+// there's no such image, or its thumbnail isn't generated yet.
+func (g *Generator) Open(ctx context.Context, id int64, size int) (Thumbnail, error) {
+	if !g.config.supports(size) {
+		sizes := make([]string, len(g.config.Sizes))
+		for i, supported := range g.config.Sizes {
+			sizes[i] = strconv.Itoa(supported)
+		}
+		return Thumbnail{}, fmt.Errorf("%w: size must be one of %s", errs.ErrInvalidInput, strings.Join(sizes, ", "))
+	}
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+	record, err := g.pathsrv.GetPath(ctx, id)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+	if record.Trashed() || record.Expired(time.Now()) {
+		return Thumbnail{}, fmt.Errorf("%w: file with ID %d is in the trash or has expired", errs.ErrNotFound, id)
+	}
+	if !g.Stage().Accepts(record.ContentType) {
+		return Thumbnail{}, fmt.Errorf("%w: file with ID %d isn't an image", errs.ErrNotFound, id)
+	}
+	contentType, extension := format(record.ContentType)
+	blob, err := g.blobs.Open(g.path(tenant, id, record.SHA256, size, extension))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Thumbnail{}, fmt.Errorf("%w: thumbnail of file with ID %d isn't generated yet", errs.ErrNotFound, id)
+	}
+	if err != nil {
+		g.logger.Error(ctx, "Failed to open thumbnail of file %d: %s", id, err.Error())
+		return Thumbnail{}, fmt.Errorf("failed to open thumbnail of file with ID %d: %w", id, errs.ErrinternalError)
+	}
+	return Thumbnail{blob, contentType}, nil
+}
+
+// generate generates the thumbnails of the image, and removes those of its previous contents.
+// Images that are gone are skipped, as are those that can't be decoded, which won't do any better if retried.
+func (g *Generator) generate(ctx context.Context, document pipeline.Document) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	file, err := g.storage.Get(ctx, document.ID)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		g.logger.Info(ctx, "Image %d can't be decoded: %s", document.ID, err.Error())
+		return nil
+	}
+	if int64(config.Width)*int64(config.Height) > g.config.MaxPixels {
+		g.logger.Info(ctx, "Image %d is too large to generate thumbnails of", document.ID)
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		g.logger.Info(ctx, "Image %d can't be decoded: %s", document.ID, err.Error())
+		return nil
+	}
+
+	_, extension := format(file.ContentType)
+	generated := make(map[string]bool)
+	for _, size := range g.config.Sizes {
+		path := g.path(tenant, document.ID, file.Checksum.SHA256, size, extension)
+		if err := g.store(path, resize(img, size), extension); err != nil {
+			return fmt.Errorf("failed to store thumbnail of size %d: %w", size, err)
+		}
+		generated[path] = true
+	}
+	return g.prune(tenant, document.ID, generated)
+}
+
+// store encodes the thumbnail in the format of the extension and stores it at the given path.
+func (g *Generator) store(path string, thumbnail image.Image, extension string) error {
+	var encoded bytes.Buffer
+	var err error
+	if extension == "jpg" {
+		err = jpeg.Encode(&encoded, thumbnail, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&encoded, thumbnail)
+	}
+	if err != nil {
+		return err
+	}
+	dst, err := g.blobs.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(encoded.Bytes())
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		g.blobs.Remove(path)
+	}
+	return err
+}
+
+// prune removes the thumbnails of the document of the given tenant and ID but those to keep.
+func (g *Generator) prune(tenant string, id int64, keep map[string]bool) error {
+	thumbnails, err := g.blobs.List(g.dir(tenant, id))
+	if err != nil {
+		return err
+	}
+	for _, thumbnail := range thumbnails {
+		if keep[thumbnail.Path] {
+			continue
+		}
+		if err := g.blobs.Remove(thumbnail.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// dir returns the directory of the thumbnails of the document of the given tenant and ID.
+func (g *Generator) dir(tenant string, id int64) string {
+	return filepath.Join(tenancy.StorageRoot(tenant), thumbnailsDir, strconv.FormatInt(id, 10))
+}
+
+// path returns the path of the thumbnail of the given size of the content with the given checksum of the
+// document of the given tenant and ID.
+func (g *Generator) path(tenant string, id int64, sha256 string, size int, extension string) string {
+	if sha256 == "" {
+		// Files stored before checksums were recorded have a single version of their thumbnails.
+		sha256 = "current"
+	}
+	return filepath.Join(g.dir(tenant, id), fmt.Sprintf("%s-%d.%s", sha256, size, extension))
+}
+
+// format returns the content type and extension of the thumbnails of images of the given content type:
+// JPEG for JPEG images, which have no transparency, and PNG otherwise.
+func format(contentType string) (string, string) {
+	if strings.HasPrefix(contentType, "image/jpeg") {
+		return "image/jpeg", "jpg"
+	}
+	return "image/png", "png"
+}
@@ -0,0 +1,186 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucastomic/dmsStorageService/internal/blobstore"
+	"github.com/lucastomic/dmsStorageService/internal/compression"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/pathrepository"
+	"github.com/lucastomic/dmsStorageService/internal/pathservice"
+	"github.com/lucastomic/dmsStorageService/internal/pipeline"
+	"github.com/lucastomic/dmsStorageService/internal/quota"
+	"github.com/lucastomic/dmsStorageService/internal/retention"
+	"github.com/lucastomic/dmsStorageService/internal/scanning"
+	"github.com/lucastomic/dmsStorageService/internal/storageservice"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// picture returns an image of the given dimensions encoded with the given encoder.
+func picture(t *testing.T, width, height int, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 200, 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+	return encoded.Bytes()
+}
+
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, nil)
+}
+
+// store uploads, or replaces, a file with the given ID, name and content, and runs the thumbnail stage on it.
+func store(
+	t *testing.T,
+	ctx context.Context,
+	service storageservice.StorageService,
+	generator *Generator,
+	id int64,
+	name string,
+	content []byte,
+	replace bool,
+) {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(src, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	data := storageservice.UploadData{File: file, Filename: name, Id: id, Size: int64(len(content))}
+	if replace {
+		err = service.Replace(ctx, data)
+	} else {
+		err = service.Upload(ctx, data)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := service.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.Close()
+	stage := generator.Stage()
+	if !stage.Accepts(stored.ContentType) {
+		return
+	}
+	if err := stage.Process(ctx, pipeline.Document{ID: id, ContentType: stored.ContentType}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bounds opens the thumbnail and returns its content type and dimensions.
+func bounds(t *testing.T, ctx context.Context, generator *Generator, id int64, size int) (string, image.Point) {
+	t.Helper()
+	thumbnail, err := generator.Open(ctx, id, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer thumbnail.Close()
+	config, _, err := image.DecodeConfig(thumbnail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return thumbnail.ContentType, image.Pt(config.Width, config.Height)
+}
+
+func TestGenerator(t *testing.T) {
+	t.Setenv("PROJECT_ROOT", t.TempDir())
+	logger := mocks.NewLoggerMock()
+	paths := pathservice.New(logger, pathrepository.MemoryRepository(logger))
+	blobs := blobstore.NewFileSystem(nil)
+	service := storageservice.New(
+		logger,
+		paths,
+		quota.NewMemoryTracker(quota.Limits{}, quota.Limits{}),
+		blobs,
+		blobstore.NewGuard(),
+		retention.New(logger, paths),
+		compression.None,
+		scanning.Policy{},
+	)
+	generator := NewGenerator(logger, paths, service, blobs, Config{Sizes: []int{16, 64}, MaxPixels: 1 << 20})
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+
+	store(t, ctx, service, generator, 1, "landscape.png", picture(t, 200, 100, png.Encode), false)
+	if contentType, size := bounds(t, ctx, generator, 1, 64); contentType != "image/png" || size != image.Pt(64, 32) {
+		t.Errorf("Expected a 64x32 PNG thumbnail, got a %v %s", size, contentType)
+	}
+	if _, size := bounds(t, ctx, generator, 1, 16); size != image.Pt(16, 8) {
+		t.Errorf("Expected a 16x8 thumbnail, got %v", size)
+	}
+	if _, err := generator.Open(ctx, 1, 32); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Expected an invalid input error for a size that isn't generated, got %v", err)
+	}
+
+	store(t, ctx, service, generator, 1, "portrait.jpg", picture(t, 30, 120, encodeJPEG), true)
+	if contentType, size := bounds(t, ctx, generator, 1, 64); contentType != "image/jpeg" || size != image.Pt(16, 64) {
+		t.Errorf("Expected a 16x64 JPEG thumbnail of the new content, got a %v %s", size, contentType)
+	}
+	if _, size := bounds(t, ctx, generator, 1, 16); size != image.Pt(4, 16) {
+		t.Errorf("Expected a 4x16 thumbnail, got %v", size)
+	}
+	if thumbnails, _ := blobs.List(generator.dir("acme", 1)); len(thumbnails) != 2 {
+		t.Errorf("Expected the thumbnails of the previous content to be removed, got %+v", thumbnails)
+	}
+
+	store(t, ctx, service, generator, 2, "notes.txt", []byte("not an image"), false)
+	if _, err := generator.Open(ctx, 2, 16); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a not found error for a file that isn't an image, got %v", err)
+	}
+	store(t, ctx, service, generator, 3, "huge.png", picture(t, 2048, 1024, png.Encode), false)
+	if _, err := generator.Open(ctx, 3, 16); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected no thumbnail for an image that's too large, got %v", err)
+	}
+
+	service.Delete(ctx, 1)
+	if _, err := generator.Open(ctx, 1, 16); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a not found error for a file in the trash, got %v", err)
+	}
+	service.Purge(ctx, 1)
+	purged, _ := events.New(ctx, events.DocumentPurged, 1)
+	if err := generator.Publish(ctx, purged); err != nil {
+		t.Fatal(err)
+	}
+	if thumbnails, _ := blobs.List(generator.dir("acme", 1)); len(thumbnails) != 0 {
+		t.Errorf("Expected the thumbnails of a purged file to be removed, got %+v", thumbnails)
+	}
+}
+
+func TestConfigFromEnvironment(t *testing.T) {
+	t.Setenv("THUMBNAIL_SIZES", "512, 64,256")
+	config, err := ConfigFromEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Sizes) != 3 || config.Sizes[0] != 64 || config.Sizes[2] != 512 {
+		t.Errorf("Expected the sizes sorted, got %v", config.Sizes)
+	}
+	t.Setenv("THUMBNAIL_SIZES", "64,big")
+	if _, err := ConfigFromEnvironment(); err == nil {
+		t.Errorf("Expected an error for an invalid size")
+	}
+}
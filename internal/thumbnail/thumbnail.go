@@ -0,0 +1,78 @@
+package thumbnail
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"golang.org/x/image/draw"
+)
+
+// ContentTypes are the content types of the images thumbnails are generated for.
+var ContentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// Config configures the Generator.
+type Config struct {
+	// Sizes are the sizes, in pixels, of the thumbnails generated for every image, sorted in increasing order.
+	// A thumbnail fits in a square of its size, keeping the aspect ratio of the image.
+	Sizes []int
+	// MaxPixels is the number of pixels from which images aren't decoded, so a small image claiming huge
+	// dimensions can't exhaust the memory.
+	MaxPixels int64
+}
+
+// ConfigFromEnvironment reads the thumbnail configuration from the environment variables THUMBNAIL_SIZES,
+// which defaults to 128, 256 and 512 pixels, and THUMBNAIL_MAX_PIXELS, which defaults to 50 million pixels.
+// It returns an error if any size isn't a positive integer.
+func ConfigFromEnvironment() (Config, error) {
+	config := Config{
+		Sizes:     []int{128, 256, 512},
+		MaxPixels: environment.GetInt64("THUMBNAIL_MAX_PIXELS"),
+	}
+	if config.MaxPixels <= 0 {
+		config.MaxPixels = 50_000_000
+	}
+	if value := environment.GetThumbnailSizes(); value != "" {
+		config.Sizes = nil
+		for _, field := range strings.Split(value, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || size <= 0 {
+				return Config{}, fmt.Errorf("invalid thumbnail size %q", field)
+			}
+			config.Sizes = append(config.Sizes, size)
+		}
+	}
+	sort.Ints(config.Sizes)
+	return config, nil
+}
+
+// supports reports whether thumbnails of the given size are generated.
+func (c Config) supports(size int) bool {
+	for _, supported := range c.Sizes {
+		if supported == size {
+			return true
+		}
+	}
+	return false
+}
+
+// resize scales the image down to fit in a square of the given size, keeping its aspect ratio.
+// Images that already fit are returned as is, as they aren't scaled up.
+func resize(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return src
+	}
+	if width >= height {
+		width, height = size, max(1, height*size/width)
+	} else {
+		width, height = max(1, width*size/height), size
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,521 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/fileutils"
+	"github.com/lucastomic/dmsStorageService/internal/logging"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+)
+
+// idleWait is how long the Dispatcher sleeps when there's nothing pending. It's woken up earlier by new events.
+const idleWait = time.Minute
+
+// maxRecentEvents is the number of event IDs the Dispatcher remembers to skip events published again.
+const maxRecentEvents = 1024
+
+// state is everything the Dispatcher persists.
+type state struct {
+	LastID        int64          `json:"lastId"` // LastID is the last ID assigned to a subscription or delivery.
+	Subscriptions []Subscription `json:"subscriptions"`
+	Pending       []Delivery     `json:"pending"`
+	DeadLetters   []Delivery     `json:"deadLetters"`
+	RecentEvents  []string       `json:"recentEvents"` // RecentEvents are the IDs of the last events queued.
+}
+
+// clone returns a copy of the state whose slices can be modified without affecting the original.
+func (s state) clone() state {
+	return state{
+		LastID:        s.LastID,
+		Subscriptions: append([]Subscription{}, s.Subscriptions...),
+		Pending:       append([]Delivery{}, s.Pending...),
+		DeadLetters:   append([]Delivery{}, s.DeadLetters...),
+		RecentEvents:  append([]string{}, s.RecentEvents...),
+	}
+}
+
+// Dispatcher delivers events to the webhook subscriptions of their tenant. It implements events.EventPublisher:
+// publishing an event persists a delivery for every matching subscription, which Run then posts to its
+// endpoint, retrying failed attempts with exponential backoff until they run out and the delivery is
+// dead-lettered. Subscriptions and deliveries are persisted to a file, so they survive restarts. The outcomes of
+// the attempts are persisted in batches, at most every Config.FlushInterval, so deliveries may be attempted
+// again after a restart. Dead letters are capped per tenant and expire after Config.DeadLetterRetention.
+type Dispatcher struct {
+	logger   logging.Logger
+	client   *http.Client
+	config   Config
+	mu       sync.Mutex     // mu guards the state and the deliveries in flight.
+	state    state          // state is kept in sync with the state file, but for the recorded outcomes.
+	dirty    bool           // dirty tells that outcomes were recorded since the state was last persisted.
+	saved    time.Time      // saved is the last time the state was persisted.
+	inFlight map[int64]bool // inFlight are the IDs of the deliveries being attempted.
+	wake     chan struct{}  // wake wakes Run up when there may be deliveries due.
+}
+
+// NewDispatcher creates a new Dispatcher, loading the subscriptions and deliveries persisted to the state file.
+// Unless the configuration allows private targets, it refuses to connect to addresses that aren't public.
+func NewDispatcher(logger logging.Logger, config Config) (*Dispatcher, error) {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateTargets {
+		dialer.Control = checkDialed
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	d := &Dispatcher{
+		logger:   logger,
+		client:   &http.Client{Timeout: config.Timeout, Transport: transport},
+		config:   config,
+		inFlight: make(map[int64]bool),
+		wake:     make(chan struct{}, 1),
+	}
+	content, err := os.ReadFile(config.StateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &d.state); err != nil {
+		return nil, fmt.Errorf("malformed webhook state file %s: %w", config.StateFile, err)
+	}
+	now := time.Now()
+	for i := range d.state.DeadLetters {
+		if d.state.DeadLetters[i].DeadLetteredAt == nil {
+			// Dead-lettered before their time was recorded: they expire counting from now.
+			d.state.DeadLetters[i].DeadLetteredAt = &now
+		}
+	}
+	return d, nil
+}
+
+// Register creates a subscription for the tenant found in the context, generating its secret if it has none.
+// It returns the subscription along with its ID and secret. Unless the configuration allows private targets, it
+// returns an ErrInvalidInput error if the endpoint is a loopback, private or link-local address.
+func (d *Dispatcher) Register(ctx context.Context, subscription Subscription) (Subscription, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if err := subscription.Validate(); err != nil {
+		return Subscription{}, err
+	}
+	if !d.config.AllowPrivateTargets {
+		if err := subscription.checkTarget(); err != nil {
+			return Subscription{}, err
+		}
+	}
+	if subscription.Secret == "" {
+		if subscription.Secret, err = randomSecret(); err != nil {
+			return Subscription{}, err
+		}
+	}
+	subscription.Tenant = tenant
+	subscription.CreatedAt = time.Now().UTC()
+	err = d.update(func(s *state) error {
+		s.LastID++
+		subscription.ID = s.LastID
+		s.Subscriptions = append(s.Subscriptions, subscription)
+		return nil
+	})
+	if err != nil {
+		return Subscription{}, err
+	}
+	return subscription, nil
+}
+
+// Subscriptions returns the subscriptions of the tenant found in the context, without their secrets.
+func (d *Dispatcher) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	subscriptions := []Subscription{}
+	for _, subscription := range d.state.Subscriptions {
+		if subscription.Tenant == tenant {
+			subscription.Secret = ""
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+// Unregister removes the subscription with the given ID, along with its pending deliveries.
+// It returns an ErrNotFound error if the tenant found in the context has no such subscription.
+func (d *Dispatcher) Unregister(ctx context.Context, id int64) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return d.update(func(s *state) error {
+		index := d.subscriptionIndex(s, tenant, id)
+		if index < 0 {
+			return fmt.Errorf("%w: webhook with id %d not found", errs.ErrNotFound, id)
+		}
+		s.Subscriptions = append(s.Subscriptions[:index], s.Subscriptions[index+1:]...)
+		pending := s.Pending[:0]
+		for _, delivery := range s.Pending {
+			if delivery.SubscriptionID != id {
+				pending = append(pending, delivery)
+			}
+		}
+		s.Pending = pending
+		return nil
+	})
+}
+
+// DeadLetters returns the dead-lettered deliveries of the tenant found in the context, oldest first.
+func (d *Dispatcher) DeadLetters(ctx context.Context) ([]Delivery, error) {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deliveries := []Delivery{}
+	for _, delivery := range d.state.DeadLetters {
+		if delivery.Event.Tenant == tenant {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+// Redeliver moves the dead-lettered delivery with the given ID back to the pending ones, with a fresh set
+// of attempts. It returns an ErrNotFound error if the tenant found in the context has no such dead letter,
+// or if its subscription has been removed since.
+func (d *Dispatcher) Redeliver(ctx context.Context, id int64) error {
+	tenant, err := tenancy.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	err = d.update(func(s *state) error {
+		for i, delivery := range s.DeadLetters {
+			if delivery.ID != id || delivery.Event.Tenant != tenant {
+				continue
+			}
+			if d.subscriptionIndex(s, tenant, delivery.SubscriptionID) < 0 {
+				return fmt.Errorf("%w: webhook with id %d not found", errs.ErrNotFound, delivery.SubscriptionID)
+			}
+			s.DeadLetters = append(s.DeadLetters[:i], s.DeadLetters[i+1:]...)
+			delivery.Attempts = 0
+			delivery.NextAttempt = time.Now()
+			delivery.DeadLetteredAt = nil
+			s.Pending = append(s.Pending, delivery)
+			return nil
+		}
+		return fmt.Errorf("%w: dead letter with id %d not found", errs.ErrNotFound, id)
+	})
+	if err != nil {
+		return err
+	}
+	d.signal()
+	return nil
+}
+
+// Publish persists a delivery of the event for every subscription of its tenant matching it.
+// Once it returns, the event is delivered even if the service restarts. Events already queued, as told by their
+// ID among the last ones, are skipped, so publishing an event again doesn't deliver it twice.
+func (d *Dispatcher) Publish(ctx context.Context, event events.Event) error {
+	queued := 0
+	err := d.update(func(s *state) error {
+		if event.ID != "" && slices.Contains(s.RecentEvents, event.ID) {
+			return errNothingToSave
+		}
+		for _, subscription := range s.Subscriptions {
+			if subscription.Tenant != event.Tenant || !subscription.Matches(event.Type) {
+				continue
+			}
+			s.LastID++
+			s.Pending = append(s.Pending, Delivery{
+				ID:             s.LastID,
+				SubscriptionID: subscription.ID,
+				Event:          event,
+				NextAttempt:    time.Now(),
+			})
+			queued++
+		}
+		if queued == 0 {
+			return errNothingToSave
+		}
+		if event.ID != "" {
+			s.RecentEvents = append(s.RecentEvents, event.ID)
+			s.RecentEvents = s.RecentEvents[max(0, len(s.RecentEvents)-maxRecentEvents):]
+		}
+		return nil
+	})
+	if errors.Is(err, errNothingToSave) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	d.signal()
+	return nil
+}
+
+// Run delivers the pending deliveries as they are due until the context is done, persisting the outcomes of
+// the attempts along the way, and once more before returning.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(min(d.dispatchDue(ctx), d.flushDue(ctx, false)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.flushDue(ctx, true)
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// dispatchDue starts attempting the due deliveries, as many as there are idle workers, and returns how long
+// to wait until the next one is due.
+func (d *Dispatcher) dispatchDue(ctx context.Context) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	wait := idleWait
+	for _, delivery := range d.state.Pending {
+		if d.inFlight[delivery.ID] {
+			continue
+		}
+		if until := delivery.NextAttempt.Sub(now); until > 0 {
+			wait = min(wait, until)
+			continue
+		}
+		if len(d.inFlight) >= d.config.Workers {
+			// A worker going idle wakes Run up.
+			continue
+		}
+		index := d.subscriptionIndex(&d.state, delivery.Event.Tenant, delivery.SubscriptionID)
+		if index < 0 {
+			continue
+		}
+		d.inFlight[delivery.ID] = true
+		go d.attempt(ctx, delivery, d.state.Subscriptions[index])
+	}
+	return wait
+}
+
+// attempt posts the delivery to the endpoint of its subscription and records the outcome: a successful
+// delivery is removed, and a failed one is retried later or, if it ran out of attempts, dead-lettered, dropping
+// the oldest dead letters of the tenant beyond the cap.
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery, subscription Subscription) {
+	err := d.post(ctx, delivery, subscription)
+	defer d.signal()
+	if ctx.Err() != nil {
+		// Interrupted by a shutdown: the delivery stays pending and is attempted again after the restart.
+		d.mu.Lock()
+		delete(d.inFlight, delivery.ID)
+		d.mu.Unlock()
+		return
+	}
+	if err != nil {
+		d.logger.Error(
+			ctx,
+			"Attempt %d of webhook delivery %d to %s failed: %s",
+			delivery.Attempts+1,
+			delivery.ID,
+			subscription.URL,
+			err.Error(),
+		)
+	}
+	d.record(func(s *state) {
+		delete(d.inFlight, delivery.ID)
+		index := -1
+		for i := range s.Pending {
+			if s.Pending[i].ID == delivery.ID {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			// The subscription was removed while the delivery was in flight.
+			return
+		}
+		if err == nil {
+			s.Pending = append(s.Pending[:index], s.Pending[index+1:]...)
+			return
+		}
+		pending := &s.Pending[index]
+		pending.Attempts++
+		pending.LastError = err.Error()
+		now := time.Now()
+		if pending.Attempts < d.config.MaxAttempts {
+			pending.NextAttempt = now.Add(d.config.backoff(pending.Attempts))
+			return
+		}
+		pending.DeadLetteredAt = &now
+		s.DeadLetters = append(s.DeadLetters, *pending)
+		s.Pending = append(s.Pending[:index], s.Pending[index+1:]...)
+		d.pruneDeadLetters(s, now)
+	})
+}
+
+// post sends the signed event to the endpoint of the subscription. Only 2xx responses are successful.
+func (d *Dispatcher) post(ctx context.Context, delivery Delivery, subscription Subscription) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderEvent, delivery.Event.Type)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// errNothingToSave is returned by the functions passed to update when they didn't change the state.
+var errNothingToSave = errors.New("nothing to save")
+
+// update applies fn to a copy of the state and, unless it fails, persists the copy, along with the outcomes
+// recorded since the state was last persisted, and makes it the state.
+// The state file is replaced atomically, so it's never left half-written.
+func (d *Dispatcher) update(fn func(*state) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	next := d.state.clone()
+	if err := fn(&next); err != nil {
+		return err
+	}
+	if err := d.save(next); err != nil {
+		return fmt.Errorf("%w: failed to persist webhooks: %s", errs.ErrinternalError, err.Error())
+	}
+	d.state = next
+	d.dirty = false
+	d.saved = time.Now()
+	return nil
+}
+
+// record applies fn to the state, leaving it to be persisted by Run or along with the next update. It's meant
+// for the outcomes of the attempts: if the service stops before they're persisted, the deliveries are attempted
+// again, which receivers tell apart by their HeaderDelivery.
+func (d *Dispatcher) record(fn func(*state)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(&d.state)
+	d.dirty = true
+}
+
+// flushDue drops the expired dead letters and persists the state if outcomes were recorded since it was last
+// persisted at least Config.FlushInterval ago, or right away if forced. It returns how long to wait until the
+// next flush is due.
+func (d *Dispatcher) flushDue(ctx context.Context, force bool) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pruneDeadLetters(&d.state, time.Now()) {
+		d.dirty = true
+	}
+	if !d.dirty {
+		return idleWait
+	}
+	if until := d.config.FlushInterval - time.Since(d.saved); until > 0 && !force {
+		return until
+	}
+	if err := d.save(d.state); err != nil {
+		d.logger.Error(ctx, "Failed to persist webhook deliveries: %s", err.Error())
+		// It's retried after another interval, rather than on every wake up.
+		d.saved = time.Now()
+		return d.config.FlushInterval
+	}
+	d.dirty = false
+	d.saved = time.Now()
+	return idleWait
+}
+
+// pruneDeadLetters drops the dead letters dead-lettered before the retention period, and the oldest ones of
+// every tenant beyond the cap. It reports whether any was dropped.
+func (d *Dispatcher) pruneDeadLetters(s *state, now time.Time) bool {
+	kept := make([]Delivery, 0, len(s.DeadLetters))
+	perTenant := make(map[string]int)
+	for i := len(s.DeadLetters) - 1; i >= 0; i-- {
+		delivery := s.DeadLetters[i]
+		if delivery.DeadLetteredAt != nil && now.Sub(*delivery.DeadLetteredAt) >= d.config.DeadLetterRetention {
+			continue
+		}
+		perTenant[delivery.Event.Tenant]++
+		if perTenant[delivery.Event.Tenant] > d.config.MaxDeadLetters {
+			continue
+		}
+		kept = append(kept, delivery)
+	}
+	if len(kept) == len(s.DeadLetters) {
+		return false
+	}
+	slices.Reverse(kept)
+	s.DeadLetters = kept
+	return true
+}
+
+// save persists the state to the state file, replacing it atomically.
+func (d *Dispatcher) save(s state) error {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	// The state holds the secrets of the subscriptions, so it's only readable by the service.
+	return fileutils.WriteAtomic(d.config.StateFile, content, 0600)
+}
+
+// signal wakes Run up, unless it's already due to wake up.
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// subscriptionIndex returns the index of the subscription of the tenant with the given ID, or -1 if there's none.
+func (d *Dispatcher) subscriptionIndex(s *state, tenant string, id int64) int {
+	for i, subscription := range s.Subscriptions {
+		if subscription.ID == id && subscription.Tenant == tenant {
+			return i
+		}
+	}
+	return -1
+}
+
+// randomSecret generates a secret for signing deliveries.
+func randomSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
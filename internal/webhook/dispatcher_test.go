@@ -0,0 +1,341 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+	"github.com/lucastomic/dmsStorageService/internal/tenancy"
+	"github.com/lucastomic/dmsStorageService/internal/tests/mocks"
+)
+
+// receiver is a webhook endpoint recording the deliveries it accepts.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	failures int // failures is the number of attempts rejected before accepting any.
+	mu       sync.Mutex
+	attempts int
+	received []string // received are the types of the accepted events.
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	timestamp, _ := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if req.Header.Get(HeaderSignature) != Sign(r.secret, timestamp, body) {
+		r.t.Errorf("Delivery has an invalid signature: %s", req.Header.Get(HeaderSignature))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	if r.attempts <= r.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	r.received = append(r.received, req.Header.Get(HeaderEvent))
+}
+
+func (r *receiver) events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.received...)
+}
+
+func testConfig(t *testing.T) Config {
+	return Config{
+		StateFile:      filepath.Join(t.TempDir(), "state.json"),
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Timeout:        time.Second,
+		Workers:        2,
+		// The receivers are test servers listening on the loopback interface.
+		FlushInterval:       10 * time.Millisecond,
+		MaxDeadLetters:      10,
+		DeadLetterRetention: time.Hour,
+		AllowPrivateTargets: true,
+	}
+}
+
+func publish(t *testing.T, ctx context.Context, d *Dispatcher, eventType string, id int64) {
+	event, err := events.New(ctx, eventType, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Publish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run runs the dispatcher until the test finishes, waiting for it to persist its state before the state file is
+// removed.
+func run(t *testing.T, ctx context.Context, d *Dispatcher) {
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		d.Run(runCtx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// eventually fails the test if the condition doesn't hold within a second.
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliverSignedEvents(t *testing.T) {
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	dispatcher, err := NewDispatcher(mocks.NewLoggerMock(), testConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := &receiver{t: t, secret: "s3cret"}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	_, err = dispatcher.Register(ctx, Subscription{
+		URL:    server.URL,
+		Events: []string{events.DocumentDeleted, events.DocumentCreated},
+		Secret: receiver.secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, ctx, dispatcher)
+
+	publish(t, ctx, dispatcher, events.DocumentReplaced, 1)
+	publish(t, ctx, dispatcher, events.DocumentDeleted, 1)
+	publish(t, tenancy.WithTenant(context.Background(), "globex"), dispatcher, events.DocumentCreated, 2)
+	eventually(t, func() bool { return len(receiver.events()) == 1 })
+	time.Sleep(20 * time.Millisecond)
+	if received := receiver.events(); len(received) != 1 || received[0] != events.DocumentDeleted {
+		t.Errorf("Expected only the deletion to be delivered, got: %v", received)
+	}
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	dispatcher, err := NewDispatcher(mocks.NewLoggerMock(), testConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky := &receiver{t: t, secret: "flaky", failures: 2}
+	flakyServer := httptest.NewServer(flaky)
+	defer flakyServer.Close()
+	down := &receiver{t: t, secret: "down", failures: 1000}
+	downServer := httptest.NewServer(down)
+	defer downServer.Close()
+	dispatcher.Register(ctx, Subscription{URL: flakyServer.URL, Secret: flaky.secret})
+	dispatcher.Register(ctx, Subscription{URL: downServer.URL, Secret: down.secret, Events: []string{"document.*"}})
+	run(t, ctx, dispatcher)
+
+	publish(t, ctx, dispatcher, events.DocumentCreated, 1)
+	eventually(t, func() bool { return len(flaky.events()) == 1 })
+	var deadLetters []Delivery
+	eventually(t, func() bool {
+		deadLetters, _ = dispatcher.DeadLetters(ctx)
+		return len(deadLetters) == 1
+	})
+	if deadLetters[0].Attempts != 3 || deadLetters[0].Event.Type != events.DocumentCreated {
+		t.Errorf("Unexpected dead letter: %+v", deadLetters[0])
+	}
+	if others, _ := dispatcher.DeadLetters(tenancy.WithTenant(context.Background(), "globex")); len(others) != 0 {
+		t.Errorf("Expected no dead letters for another tenant, got: %+v", others)
+	}
+
+	down.mu.Lock()
+	down.failures = 0
+	down.mu.Unlock()
+	if err := dispatcher.Redeliver(ctx, deadLetters[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool { return len(down.events()) == 1 })
+	if deadLetters, _ := dispatcher.DeadLetters(ctx); len(deadLetters) != 0 {
+		t.Errorf("Expected the dead letter to be redelivered, got: %+v", deadLetters)
+	}
+	if err := dispatcher.Redeliver(ctx, deadLetters[0].ID); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a not found error redelivering twice, got: %v", err)
+	}
+}
+
+func TestDeliveriesSurviveRestart(t *testing.T) {
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	config := testConfig(t)
+	dispatcher, err := NewDispatcher(mocks.NewLoggerMock(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := &receiver{t: t, secret: "s3cret"}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	subscription, err := dispatcher.Register(ctx, Subscription{URL: server.URL, Secret: receiver.secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, ctx, dispatcher, events.DocumentCreated, 1)
+
+	restarted, err := NewDispatcher(mocks.NewLoggerMock(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscriptions, _ := restarted.Subscriptions(ctx)
+	if len(subscriptions) != 1 || subscriptions[0].ID != subscription.ID || subscriptions[0].Secret != "" {
+		t.Errorf("Expected the subscription to be reloaded without its secret, got: %+v", subscriptions)
+	}
+	run(t, ctx, restarted)
+	eventually(t, func() bool { return len(receiver.events()) == 1 })
+
+	eventually(t, func() bool {
+		reloaded, err := NewDispatcher(mocks.NewLoggerMock(), config)
+		return err == nil && len(reloaded.state.Pending) == 0
+	})
+
+	if err := restarted.Unregister(ctx, subscription.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Unregister(ctx, subscription.ID); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a not found error unregistering twice, got: %v", err)
+	}
+}
+
+func TestSkipDuplicateEvents(t *testing.T) {
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	config := testConfig(t)
+	dispatcher, err := NewDispatcher(mocks.NewLoggerMock(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dispatcher.Register(ctx, Subscription{URL: "http://localhost:1"}); err != nil {
+		t.Fatal(err)
+	}
+	event, err := events.New(ctx, events.DocumentCreated, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := dispatcher.Publish(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	restarted, err := NewDispatcher(mocks.NewLoggerMock(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Publish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if pending := restarted.state.Pending; len(pending) != 1 {
+		t.Errorf("Expected an event published again to be queued once, got: %v", pending)
+	}
+}
+
+func TestBoundedDeadLetters(t *testing.T) {
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	config := testConfig(t)
+	config.MaxAttempts = 1
+	config.MaxDeadLetters = 2
+	config.DeadLetterRetention = 100 * time.Millisecond
+	dispatcher, err := NewDispatcher(mocks.NewLoggerMock(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := &receiver{t: t, secret: "down", failures: 1000}
+	server := httptest.NewServer(down)
+	defer server.Close()
+	dispatcher.Register(ctx, Subscription{URL: server.URL, Secret: down.secret})
+	run(t, ctx, dispatcher)
+
+	for id := int64(1); id <= 3; id++ {
+		publish(t, ctx, dispatcher, events.DocumentCreated, id)
+		eventually(t, func() bool {
+			down.mu.Lock()
+			defer down.mu.Unlock()
+			return down.attempts == int(id)
+		})
+	}
+	var deadLetters []Delivery
+	eventually(t, func() bool {
+		deadLetters, _ = dispatcher.DeadLetters(ctx)
+		return len(deadLetters) == 2 && deadLetters[0].Event.DocumentID == 2
+	})
+	if deadLetters[1].Event.DocumentID != 3 || deadLetters[1].DeadLetteredAt == nil {
+		t.Errorf("Expected only the newest dead letters to be kept, got: %+v", deadLetters)
+	}
+	eventually(t, func() bool {
+		dispatcher.signal()
+		deadLetters, _ = dispatcher.DeadLetters(ctx)
+		return len(deadLetters) == 0
+	})
+}
+
+func TestPrivateTargets(t *testing.T) {
+	ctx := tenancy.WithTenant(context.Background(), "acme")
+	config := testConfig(t)
+	config.AllowPrivateTargets = false
+	dispatcher, err := NewDispatcher(mocks.NewLoggerMock(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data",
+	} {
+		if _, err := dispatcher.Register(ctx, Subscription{URL: target}); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("Expected an invalid input error registering %s, got: %v", target, err)
+		}
+	}
+	if _, err := dispatcher.Register(ctx, Subscription{URL: "https://93.184.216.34/hook"}); err != nil {
+		t.Errorf("Expected a public address to be accepted, got: %v", err)
+	}
+	if err := checkDialed("tcp", "127.0.0.1:80", nil); err == nil {
+		t.Error("Expected connections to the loopback interface to be refused")
+	}
+	if err := checkDialed("tcp", "[::ffff:192.168.1.1]:443", nil); err == nil {
+		t.Error("Expected connections to mapped private addresses to be refused")
+	}
+	if err := checkDialed("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("Expected connections to public addresses to be allowed, got: %v", err)
+	}
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	tests := []struct {
+		subscription Subscription
+		valid        bool
+	}{
+		{Subscription{URL: "https://example.com/hook"}, true},
+		{Subscription{URL: "http://example.com", Events: []string{"*", "document.*", "document.purged"}}, true},
+		{Subscription{URL: "ftp://example.com"}, false},
+		{Subscription{URL: "/hook"}, false},
+		{Subscription{URL: "https://example.com", Events: []string{"document.unknown"}}, false},
+		{Subscription{URL: "https://example.com", Events: []string{"doc*"}}, false},
+	}
+	for _, test := range tests {
+		err := test.subscription.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("Validate(%+v) = %v, expected valid: %t", test.subscription, err, test.valid)
+		}
+	}
+}
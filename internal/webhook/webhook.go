@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lucastomic/dmsStorageService/internal/environment"
+	"github.com/lucastomic/dmsStorageService/internal/errs"
+	"github.com/lucastomic/dmsStorageService/internal/events"
+)
+
+// Headers of the requests delivering events.
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"  // HeaderDelivery is the ID of the delivery, the same across its attempts.
+	HeaderEvent     = "X-Webhook-Event"     // HeaderEvent is the type of the delivered event.
+	HeaderTimestamp = "X-Webhook-Timestamp" // HeaderTimestamp is the Unix time the attempt was signed at.
+	HeaderSignature = "X-Webhook-Signature" // HeaderSignature is the signature of the attempt, as computed by Sign.
+)
+
+// Config configures the Dispatcher.
+type Config struct {
+	StateFile      string        // StateFile is where subscriptions and deliveries are persisted.
+	MaxAttempts    int           // MaxAttempts is the number of attempts before a delivery is dead-lettered.
+	InitialBackoff time.Duration // InitialBackoff is the delay before retrying a delivery for the first time.
+	MaxBackoff     time.Duration // MaxBackoff caps the delay between two attempts, which doubles every attempt.
+	Timeout        time.Duration // Timeout is how long an endpoint has to answer an attempt.
+	Workers        int           // Workers is the number of deliveries attempted concurrently.
+	// FlushInterval is how often the outcomes of the attempts are persisted, at most. Publishing events and
+	// managing subscriptions persist the state right away.
+	FlushInterval       time.Duration
+	MaxDeadLetters      int           // MaxDeadLetters caps the dead letters kept per tenant, dropping the oldest.
+	DeadLetterRetention time.Duration // DeadLetterRetention is how long dead letters are kept.
+	// AllowPrivateTargets allows endpoints on loopback, private and link-local addresses, which are otherwise
+	// rejected so tenants can't reach the internal network through webhooks.
+	AllowPrivateTargets bool
+}
+
+// ConfigFromEnvironment reads the webhook configuration from the environment variables WEBHOOK_STATE_FILE, which
+// defaults to webhooks/state.json inside the project root, WEBHOOK_MAX_ATTEMPTS, which defaults to 8,
+// WEBHOOK_INITIAL_BACKOFF, which defaults to 1s, WEBHOOK_MAX_BACKOFF, which defaults to 1h,
+// WEBHOOK_TIMEOUT, which defaults to 10s, WEBHOOK_WORKERS, which defaults to 4, WEBHOOK_FLUSH_INTERVAL, which
+// defaults to 1s, WEBHOOK_MAX_DEAD_LETTERS, which defaults to 1000, WEBHOOK_DEAD_LETTER_RETENTION, which defaults
+// to 168h, and WEBHOOK_ALLOW_PRIVATE_TARGETS, which defaults to false.
+func ConfigFromEnvironment() Config {
+	config := Config{
+		StateFile:           environment.GetWebhookStateFile(),
+		MaxAttempts:         int(environment.GetInt64("WEBHOOK_MAX_ATTEMPTS")),
+		InitialBackoff:      environment.GetDuration("WEBHOOK_INITIAL_BACKOFF"),
+		MaxBackoff:          environment.GetDuration("WEBHOOK_MAX_BACKOFF"),
+		Timeout:             environment.GetDuration("WEBHOOK_TIMEOUT"),
+		Workers:             int(environment.GetInt64("WEBHOOK_WORKERS")),
+		FlushInterval:       environment.GetDuration("WEBHOOK_FLUSH_INTERVAL"),
+		MaxDeadLetters:      int(environment.GetInt64("WEBHOOK_MAX_DEAD_LETTERS")),
+		DeadLetterRetention: environment.GetDuration("WEBHOOK_DEAD_LETTER_RETENTION"),
+		AllowPrivateTargets: environment.GetBool("WEBHOOK_ALLOW_PRIVATE_TARGETS"),
+	}
+	if config.StateFile == "" {
+		config.StateFile = filepath.Join(environment.GetProjectRoot(), "webhooks", "state.json")
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Hour
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.MaxDeadLetters <= 0 {
+		config.MaxDeadLetters = 1000
+	}
+	if config.DeadLetterRetention <= 0 {
+		config.DeadLetterRetention = 7 * 24 * time.Hour
+	}
+	return config
+}
+
+// backoff returns the delay before the next attempt of a delivery that has failed the given number of times.
+func (c Config) backoff(attempts int) time.Duration {
+	delay := c.InitialBackoff
+	for i := 1; i < attempts && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, c.MaxBackoff)
+}
+
+// Subscription registers an endpoint to be notified of the events of a tenant.
+type Subscription struct {
+	ID     int64  `json:"id"`     // ID is the identifier of the subscription.
+	Tenant string `json:"tenant"` // Tenant is the tenant whose events are delivered.
+	URL    string `json:"url"`    // URL is the HTTP or HTTPS endpoint events are posted to.
+	// Events filters the delivered events by type. Types can end in a wildcard, e.g. "document.*" or "*".
+	// An empty filter delivers every event.
+	Events []string `json:"events,omitempty"`
+	// Secret is the key the deliveries are signed with. It's only disclosed when the subscription is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"` // CreatedAt is the time the subscription was created.
+}
+
+// Validate checks that the endpoint is an absolute HTTP or HTTPS URL and that the filters name known events.
+func (s Subscription) Validate() error {
+	endpoint, err := url.Parse(s.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", errs.ErrInvalidInput)
+	}
+	for _, filter := range s.Events {
+		if prefix, found := strings.CutSuffix(filter, "*"); found {
+			if prefix != "" && !strings.HasSuffix(prefix, ".") {
+				return fmt.Errorf("%w: malformed event filter %q", errs.ErrInvalidInput, filter)
+			}
+			continue
+		}
+		if !slices.Contains(events.Types, filter) {
+			return fmt.Errorf("%w: unknown event type %q", errs.ErrInvalidInput, filter)
+		}
+	}
+	return nil
+}
+
+// checkTarget returns an ErrInvalidInput error if the host of the endpoint is a loopback, private or link-local
+// address, or localhost. Host names are checked again once resolved, when connecting, by checkDialed.
+func (s Subscription) checkTarget() error {
+	endpoint, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", errs.ErrInvalidInput)
+	}
+	host := strings.TrimSuffix(strings.ToLower(endpoint.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: url must not target a local address", errs.ErrInvalidInput)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !public(addr) {
+		return fmt.Errorf("%w: url must not target a private address", errs.ErrInvalidInput)
+	}
+	return nil
+}
+
+// checkDialed is a net.Dialer control function refusing to connect to addresses that aren't public.
+func checkDialed(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !public(addrPort.Addr()) {
+		return &net.AddrError{Err: "private address not allowed", Addr: address}
+	}
+	return nil
+}
+
+// public reports whether the address is neither unspecified, loopback, private, link-local nor multicast.
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsUnspecified() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast()
+}
+
+// Matches reports whether events of the given type are delivered to the subscription.
+func (s Subscription) Matches(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, filter := range s.Events {
+		if prefix, found := strings.CutSuffix(filter, "*"); found && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+		if filter == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is an event pending delivery to a subscription, or dead-lettered after running out of attempts.
+type Delivery struct {
+	ID             int64        `json:"id"`                  // ID is the identifier of the delivery.
+	SubscriptionID int64        `json:"subscriptionId"`      // SubscriptionID is the subscription it's delivered to.
+	Event          events.Event `json:"event"`               // Event is the delivered event.
+	Attempts       int          `json:"attempts"`            // Attempts is the number of failed attempts.
+	NextAttempt    time.Time    `json:"nextAttempt"`         // NextAttempt is when the delivery is attempted next.
+	LastError      string       `json:"lastError,omitempty"` // LastError describes why the last attempt failed.
+	// DeadLetteredAt is when the delivery was dead-lettered, if it was.
+	DeadLetteredAt *time.Time `json:"deadLetteredAt,omitempty"`
+}
+
+// Sign returns the signature of a delivery: the hex-encoded HMAC-SHA256, keyed with the secret of the
+// subscription, of the timestamp of the attempt, a dot and the body. It's prefixed with "sha256=".
+// Receivers should recompute it and compare it in constant time, and reject stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}